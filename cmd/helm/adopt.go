@@ -0,0 +1,96 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli/output"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/kube"
+)
+
+const adoptDesc = `
+This command installs a chart over objects that already exist in the cluster,
+such as objects created with kubectl, and makes the new release own them.
+
+The chart is rendered and the live objects it would create are looked up.
+Objects that lack the ownership labels and annotations of a release are given
+those of the new release before its first revision is stored, and are then
+updated to match the chart. Objects owned by another release are only adopted
+with '--force'.
+
+Use '--dry-run' to list the objects that would be adopted without changing
+them. The arguments and flags are those of 'helm install'.
+`
+
+func newAdoptCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewInstall(cfg)
+	client.Adopt = true
+	valueOpts := &values.Options{}
+	var outfmt output.Format
+
+	cmd := &cobra.Command{
+		Use:   "adopt [NAME] [CHART]",
+		Short: "install a chart over existing resources and take ownership of them",
+		Long:  adoptDesc,
+		Args:  require.MinimumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return compInstall(args, toComplete, client)
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			watchProgress(cfg)
+			rel, err := runInstall(args, client, valueOpts, out)
+			if err != nil {
+				return err
+			}
+
+			if outfmt == output.Table {
+				writeAdopted(out, client.Adopted, client.DryRun)
+			}
+			return outfmt.Write(out, &statusPrinter{rel, settings.Debug, false})
+		},
+	}
+
+	addInstallFlags(cmd, cmd.Flags(), client, valueOpts)
+	addServerSideApplyFlags(cmd.Flags(), &client.ServerSideApply, &client.ForceConflicts)
+	cmd.Flags().BoolVar(&client.ForceAdopt, "force", false, "adopt resources that are owned by another release")
+	bindOutputFlag(cmd, &outfmt)
+	bindPostRenderFlag(cmd, &client.PostRenderer)
+
+	return cmd
+}
+
+// writeAdopted lists the resources adopted by an install.
+func writeAdopted(out io.Writer, adopted kube.ResourceList, dryRun bool) {
+	if len(adopted) == 0 {
+		fmt.Fprintln(out, "No existing resources to adopt")
+		return
+	}
+	verb := "Adopted"
+	if dryRun {
+		verb = "Would adopt"
+	}
+	for _, info := range adopted {
+		fmt.Fprintf(out, "%s %s %q in namespace %q\n", verb, info.Mapping.GroupVersionKind.Kind, info.Name, info.Namespace)
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestAdoptCmd(t *testing.T) {
+	tests := []cmdTestCase{
+		{
+			name:   "adopt with no existing resources",
+			cmd:    "adopt aeneas testdata/testcharts/empty --namespace default",
+			golden: "output/adopt-no-resources.txt",
+		},
+		{
+			name:   "adopt dry run",
+			cmd:    "adopt aeneas testdata/testcharts/empty --namespace default --dry-run",
+			golden: "output/adopt-dry-run.txt",
+		},
+		{
+			name:   "install with adopt",
+			cmd:    "install aeneas testdata/testcharts/empty --namespace default --adopt",
+			golden: "output/adopt-no-resources.txt",
+		},
+		{
+			name:      "adopt without chart",
+			cmd:       "adopt aeneas",
+			golden:    "output/adopt-no-chart.txt",
+			wantError: true,
+		},
+	}
+	runTestCmd(t, tests)
+}
+
+func TestAdoptFileCompletion(t *testing.T) {
+	checkFileCompletion(t, "adopt", false)
+	checkFileCompletion(t, "adopt myname", true)
+	checkFileCompletion(t, "adopt myname mychart", false)
+}
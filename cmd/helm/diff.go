@@ -0,0 +1,293 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli/output"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+)
+
+const diffHelp = `
+This command consists of multiple subcommands which can be used to preview
+the changes an operation would make to a release, without performing it.
+
+By default, the changes are computed against the manifest of the last stored
+revision of the release. Use '--live' to compare against the objects that
+currently exist in the cluster instead.
+
+With '--detailed-exitcode', the command exits with status 2 when changes were
+found, which allows CI pipelines to gate on pending changes.
+`
+
+const diffUpgradeHelp = `
+This command shows the changes 'helm upgrade' would make to a release.
+
+It accepts the same chart and values arguments as 'helm upgrade'.
+
+    $ helm diff upgrade -f myvalues.yaml redis ./redis
+`
+
+const diffRollbackHelp = `
+This command shows the changes 'helm rollback' would make to a release.
+
+If the revision is omitted, the changes of a rollback to the previous revision
+are shown.
+`
+
+const diffRevisionHelp = `
+This command shows the changes between two stored revisions of a release.
+
+If the second revision is omitted, the latest revision is used.
+`
+
+// errDiffChanges is returned when --detailed-exitcode is set and the diff
+// found changes, to exit with status 2.
+var errDiffChanges = exitCodeError{errors.New("changes detected"), 2}
+
+type diffOptions struct {
+	outfmt           output.Format
+	detailedExitCode bool
+}
+
+func newDiffCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff",
+		Short: "preview the changes of an operation on a release",
+		Long:  diffHelp,
+		Args:  require.NoArgs,
+	}
+
+	cmd.AddCommand(newDiffUpgradeCmd(cfg, out))
+	cmd.AddCommand(newDiffRollbackCmd(cfg, out))
+	cmd.AddCommand(newDiffRevisionCmd(cfg, out))
+
+	return cmd
+}
+
+func newDiffUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewDiff(cfg)
+	upgrade := action.NewUpgrade(cfg)
+	valueOpts := &values.Options{}
+	opts := &diffOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "upgrade [RELEASE] [CHART]",
+		Short: "show the changes an upgrade would make",
+		Long:  diffUpgradeHelp,
+		Args:  require.ExactArgs(2),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) == 0 {
+				return compListReleases(toComplete, args, cfg)
+			}
+			if len(args) == 1 {
+				return compListCharts(toComplete, true)
+			}
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			upgrade.Namespace = settings.Namespace()
+
+			if upgrade.Version == "" && upgrade.Devel {
+				debug("setting version to >0.0.0-0")
+				upgrade.Version = ">0.0.0-0"
+			}
+
+			chartPath, err := upgrade.ChartPathOptions.LocateChart(args[1], settings)
+			if err != nil {
+				return err
+			}
+
+			vals, err := valueOpts.MergeValues(getter.ValuesProviders(settings))
+			if err != nil {
+				return err
+			}
+
+			ch, err := loader.Load(chartPath)
+			if err != nil {
+				return err
+			}
+			if req := ch.Metadata.Dependencies; req != nil {
+				if err := action.CheckDependencies(ch, req); err != nil {
+					return err
+				}
+			}
+
+			res, err := client.RunUpgrade(upgrade, args[0], ch, vals)
+			if err != nil {
+				return err
+			}
+			return silenceExitCode(cmd, opts.write(out, res))
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&upgrade.Devel, "devel", false, "use development versions, too. Equivalent to version '>0.0.0-0'. If --version is set, this is ignored")
+	f.BoolVar(&upgrade.DisableOpenAPIValidation, "disable-openapi-validation", false, "if set, the rendered templates are not validated against the Kubernetes OpenAPI Schema")
+	f.BoolVar(&upgrade.ResetValues, "reset-values", false, "when upgrading, reset the values to the ones built into the chart")
+	f.BoolVar(&upgrade.ReuseValues, "reuse-values", false, "when upgrading, reuse the last release's values and merge in any overrides from the command line via --set and -f. If '--reset-values' is specified, this is ignored")
+	f.BoolVar(&upgrade.SubNotes, "render-subchart-notes", false, "if set, render subchart notes along with the parent")
+	addChartPathOptionsFlags(f, &upgrade.ChartPathOptions)
+	addValueOptionsFlags(f, valueOpts)
+	addDiffFlags(cmd, f, client, opts)
+	bindPostRenderFlag(cmd, &upgrade.PostRenderer)
+
+	return cmd
+}
+
+func newDiffRollbackCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewDiff(cfg)
+	opts := &diffOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "rollback <RELEASE> [REVISION]",
+		Short: "show the changes a rollback would make",
+		Long:  diffRollbackHelp,
+		Args:  require.MinimumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) == 0 {
+				return compListReleases(toComplete, args, cfg)
+			}
+			if len(args) == 1 {
+				return compListRevisions(toComplete, cfg, args[0])
+			}
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var version int
+			if len(args) > 1 {
+				ver, err := strconv.Atoi(args[1])
+				if err != nil {
+					return fmt.Errorf("could not convert revision to a number: %v", err)
+				}
+				version = ver
+			}
+
+			res, err := client.RunRollback(args[0], version)
+			if err != nil {
+				return err
+			}
+			return silenceExitCode(cmd, opts.write(out, res))
+		},
+	}
+
+	addDiffFlags(cmd, cmd.Flags(), client, opts)
+
+	return cmd
+}
+
+func newDiffRevisionCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewDiff(cfg)
+	opts := &diffOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "revision <RELEASE> <REVISION> [REVISION]",
+		Short: "show the changes between two revisions of a release",
+		Long:  diffRevisionHelp,
+		Args:  require.MinimumNArgs(2),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) == 0 {
+				return compListReleases(toComplete, args, cfg)
+			}
+			if len(args) < 3 {
+				return compListRevisions(toComplete, cfg, args[0])
+			}
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) > 3 {
+				return errors.New("at most two revisions can be compared")
+			}
+			revisions := make([]int, 2)
+			for i, arg := range args[1:] {
+				ver, err := strconv.Atoi(arg)
+				if err != nil {
+					return fmt.Errorf("could not convert revision to a number: %v", err)
+				}
+				revisions[i] = ver
+			}
+
+			res, err := client.RunRevision(args[0], revisions[0], revisions[1])
+			if err != nil {
+				return err
+			}
+			return silenceExitCode(cmd, opts.write(out, res))
+		},
+	}
+
+	addDiffFlags(cmd, cmd.Flags(), client, opts)
+
+	return cmd
+}
+
+func addDiffFlags(cmd *cobra.Command, f *pflag.FlagSet, client *action.Diff, opts *diffOptions) {
+	f.BoolVar(&client.Live, "live", false, "compare against the objects in the cluster instead of the last stored release manifest")
+	f.IntVar(&client.Context, "context", client.Context, "number of unchanged lines to show around each change")
+	f.BoolVar(&client.ShowSecrets, "show-secrets", false, "do not redact the data of Secrets in the output")
+	f.BoolVar(&opts.detailedExitCode, "detailed-exitcode", false, "exit with status 2 when changes are found")
+	bindOutputFlag(cmd, &opts.outfmt)
+}
+
+func (o *diffOptions) write(out io.Writer, res *action.DiffResult) error {
+	if err := o.outfmt.Write(out, &diffPrinter{res}); err != nil {
+		return err
+	}
+	if o.detailedExitCode && res.HasChanges() {
+		return errDiffChanges
+	}
+	return nil
+}
+
+type diffPrinter struct {
+	result *action.DiffResult
+}
+
+func (p diffPrinter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, p.result)
+}
+
+func (p diffPrinter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, p.result)
+}
+
+func (p diffPrinter) WriteTable(out io.Writer) error {
+	if !p.result.HasChanges() {
+		fmt.Fprintf(out, "No changes to release %q between %s and %s\n", p.result.Release, p.result.From, p.result.To)
+		return nil
+	}
+	for _, r := range p.result.Resources {
+		id := r.Name
+		if r.Namespace != "" {
+			id = r.Namespace + "/" + r.Name
+		}
+		fmt.Fprintf(out, "%s %s (%s) %s:\n", r.Kind, id, r.APIVersion, r.Change)
+		fmt.Fprintln(out, r.Diff)
+	}
+	fmt.Fprintf(out, "Release %q: %d resource(s) changed between %s and %s\n", p.result.Release, len(p.result.Resources), p.result.From, p.result.To)
+	return nil
+}
@@ -0,0 +1,116 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli/output"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestDiffCmd(t *testing.T) {
+	rels := []*release.Release{
+		release.Mock(&release.MockReleaseOptions{Name: "funny-bunny", Version: 1, Status: release.StatusSuperseded}),
+		release.Mock(&release.MockReleaseOptions{Name: "funny-bunny", Version: 2, Status: release.StatusDeployed}),
+	}
+
+	tests := []cmdTestCase{{
+		name:   "diff two revisions",
+		cmd:    "diff revision funny-bunny 1 2",
+		golden: "output/diff-revision.txt",
+		rels:   rels,
+	}, {
+		name:   "diff revision against latest",
+		cmd:    "diff revision funny-bunny 1 -o json",
+		golden: "output/diff-revision-json.txt",
+		rels:   rels,
+	}, {
+		name:   "diff rollback to previous revision",
+		cmd:    "diff rollback funny-bunny",
+		golden: "output/diff-rollback.txt",
+		rels:   rels,
+	}, {
+		name:      "diff revision with too many revisions",
+		cmd:       "diff revision funny-bunny 1 2 3",
+		golden:    "output/diff-revision-too-many-args.txt",
+		rels:      rels,
+		wantError: true,
+	}, {
+		name:      "diff revision without args",
+		cmd:       "diff revision",
+		golden:    "output/diff-revision-no-args.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestDiffOutputCompletion(t *testing.T) {
+	outputFlagCompletionTest(t, "diff revision")
+}
+
+func TestDiffFileCompletion(t *testing.T) {
+	checkFileCompletion(t, "diff", false)
+	checkFileCompletion(t, "diff upgrade", false)
+	checkFileCompletion(t, "diff upgrade myrelease", true)
+	checkFileCompletion(t, "diff rollback", false)
+	checkFileCompletion(t, "diff rollback myrelease", false)
+	checkFileCompletion(t, "diff revision myrelease 1 2", false)
+}
+
+func TestDiffDetailedExitCode(t *testing.T) {
+	opts := &diffOptions{outfmt: output.Table, detailedExitCode: true}
+	res := &action.DiffResult{
+		Release: "funny-bunny",
+		From:    "revision 1",
+		To:      "revision 2",
+		Resources: []action.ResourceDiff{{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Name:       "funny-bunny",
+			Change:     action.DiffModified,
+			Diff:       "-color: blue\n+color: green\n",
+		}},
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := &cobra.Command{
+		Use: "diff",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return silenceExitCode(cmd, opts.write(&stdout, res))
+		},
+	}
+	cmd.SetArgs([]string{})
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+
+	err := cmd.Execute()
+	if e, ok := err.(exitCodeError); !ok || e.code != 2 {
+		t.Errorf("Expected an exit code error with code 2, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "+color: green") {
+		t.Errorf("Expected the diff on stdout, got %q", stdout.String())
+	}
+	if stderr.String() != "" {
+		t.Errorf("Expected no write to stderr, got %q", stderr.String())
+	}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+const docsValuesDesc = `
+Generate a reference of the values of a chart.
+
+Every value in values.yaml is listed with its type, its default and the comment
+documenting it, or the description in the chart's values schema. See
+'helm schema generate --help' for the annotations understood in comments.
+
+The reference is written as Markdown or HTML.
+`
+
+func newDocsValuesCmd(out io.Writer) *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "values CHART",
+		Short: "generate a reference of the values of a chart",
+		Long:  docsValuesDesc,
+		Args:  require.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ch, err := loader.Load(args[0])
+			if err != nil {
+				return err
+			}
+			docs, err := chartutil.DocumentValues(ch)
+			if err != nil {
+				return err
+			}
+			switch format {
+			case "markdown", "mdown", "md":
+				return writeValuesMarkdown(out, ch.Name(), ch.Metadata.Description, docs)
+			case "html":
+				return writeValuesHTML(out, ch.Name(), ch.Metadata.Description, docs)
+			}
+			return errors.Errorf("unknown format %q. Try 'markdown' or 'html'", format)
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "markdown", "the format of the reference (markdown, html)")
+	cmd.RegisterFlagCompletionFunc("format", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"html", "markdown"}, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	return cmd
+}
+
+// valueDescription returns the description of a value followed by its
+// constraints.
+func valueDescription(doc chartutil.ValueDoc) string {
+	description := doc.Description
+	if len(doc.Enum) > 0 {
+		enum := make([]string, len(doc.Enum))
+		for i, v := range doc.Enum {
+			enum[i] = formatValue(v)
+		}
+		description = strings.TrimSpace(fmt.Sprintf("%s One of %s.", description, strings.Join(enum, ", ")))
+	}
+	if doc.Required {
+		description = strings.TrimSpace("Required. " + description)
+	}
+	return description
+}
+
+func formatValue(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func writeValuesMarkdown(out io.Writer, name, description string, docs []chartutil.ValueDoc) error {
+	cell := func(s string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ")
+	}
+
+	fmt.Fprintf(out, "# %s\n\n", name)
+	if description != "" {
+		fmt.Fprintf(out, "%s\n\n", description)
+	}
+	fmt.Fprintln(out, "## Values")
+	fmt.Fprintln(out)
+	if len(docs) == 0 {
+		fmt.Fprintln(out, "This chart has no values.")
+		return nil
+	}
+	fmt.Fprintln(out, "| Key | Type | Default | Description |")
+	fmt.Fprintln(out, "|-----|------|---------|-------------|")
+	for _, doc := range docs {
+		fmt.Fprintf(out, "| `%s` | %s | `%s` | %s |\n", cell(doc.Key), cell(doc.Type), cell(formatValue(doc.Default)), cell(valueDescription(doc)))
+	}
+	return nil
+}
+
+func writeValuesHTML(out io.Writer, name, description string, docs []chartutil.ValueDoc) error {
+	fmt.Fprintf(out, "<h1>%s</h1>\n", html.EscapeString(name))
+	if description != "" {
+		fmt.Fprintf(out, "<p>%s</p>\n", html.EscapeString(description))
+	}
+	fmt.Fprintln(out, "<h2>Values</h2>")
+	if len(docs) == 0 {
+		fmt.Fprintln(out, "<p>This chart has no values.</p>")
+		return nil
+	}
+	fmt.Fprintln(out, "<table>")
+	fmt.Fprintln(out, "<thead><tr><th>Key</th><th>Type</th><th>Default</th><th>Description</th></tr></thead>")
+	fmt.Fprintln(out, "<tbody>")
+	for _, doc := range docs {
+		fmt.Fprintf(out, "<tr><td><code>%s</code></td><td>%s</td><td><code>%s</code></td><td>%s</td></tr>\n",
+			html.EscapeString(doc.Key), html.EscapeString(doc.Type), html.EscapeString(formatValue(doc.Default)), html.EscapeString(valueDescription(doc)))
+	}
+	fmt.Fprintln(out, "</tbody>")
+	fmt.Fprintln(out, "</table>")
+	return nil
+}
@@ -40,10 +40,14 @@ const outputFlag = "output"
 const postRenderFlag = "post-renderer"
 
 func addValueOptionsFlags(f *pflag.FlagSet, v *values.Options) {
-	f.StringSliceVarP(&v.ValueFiles, "values", "f", []string{}, "specify values in a YAML file or a URL (can specify multiple)")
+	f.StringSliceVarP(&v.ValueFiles, "values", "f", []string{}, "specify values in a YAML file or a URL, such as https://, oci://, env://PREFIX_ or exec://PLUGIN (can specify multiple)")
 	f.StringArrayVar(&v.Values, "set", []string{}, "set values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
 	f.StringArrayVar(&v.StringValues, "set-string", []string{}, "set STRING values on the command line (can specify multiple or separate values with commas: key1=val1,key2=val2)")
+	f.StringArrayVar(&v.JSONValues, "set-json", []string{}, "set JSON values on the command line (can specify multiple or separate values with commas: key1=jsonval1,key2=jsonval2)")
 	f.StringArrayVar(&v.FileValues, "set-file", []string{}, "set values from respective files specified via the command line (can specify multiple or separate values with commas: key1=path1,key2=path2)")
+	f.StringVar(&v.Keyring, "values-keyring", "", "location of a keyring with the secret PGP keys that decrypt PGP and SOPS encrypted values files")
+	f.StringVar(&v.AgeIdentity, "values-age-identity", "", "location of a file of age secret keys that decrypt age and SOPS encrypted values files. Defaults to $SOPS_AGE_KEY_FILE")
+	f.StringVar(&v.DecryptCommand, "values-decrypt-command", "", "an executable, followed by its arguments, that decrypts encrypted values files given on stdin, such as 'sops --decrypt --input-type yaml --output-type yaml /dev/stdin'. It takes precedence over --values-keyring and --values-age-identity")
 }
 
 func addChartPathOptionsFlags(f *pflag.FlagSet, c *action.ChartPathOptions) {
@@ -59,6 +63,11 @@ func addChartPathOptionsFlags(f *pflag.FlagSet, c *action.ChartPathOptions) {
 	f.StringVar(&c.CaFile, "ca-file", "", "verify certificates of HTTPS-enabled servers using this CA bundle")
 }
 
+func addServerSideApplyFlags(f *pflag.FlagSet, serverSide, forceConflicts *bool) {
+	f.BoolVar(serverSide, "server-side", false, "send resources with server-side apply using the field manager \"helm\" instead of client-side patches. With --dry-run, resources are validated with a server-side dry run")
+	f.BoolVar(forceConflicts, "force-conflicts", false, "if --server-side is set, take ownership of fields managed by other field managers instead of failing on conflicts")
+}
+
 // bindOutputFlag will add the output flag to the given command and bind the
 // value to the given format pointer
 func bindOutputFlag(cmd *cobra.Command, varRef *output.Format) {
@@ -111,30 +120,36 @@ func (o *outputValue) Set(s string) error {
 }
 
 func bindPostRenderFlag(cmd *cobra.Command, varRef *postrender.PostRenderer) {
-	cmd.Flags().Var(&postRenderer{varRef}, postRenderFlag, "the path to an executable to be used for post rendering. If it exists in $PATH, the binary will be used, otherwise it will try to look for the executable at the given path")
+	cmd.Flags().Var(&postRenderer{renderer: varRef}, postRenderFlag, "a post-renderer to run on the rendered manifests (can specify multiple, run in order): the path to an executable, which is looked up in $PATH if the path has no separators, 'plugin:<name>' to run a Helm plugin, or one of the built-in post-renderers 'builtin:labels:<key>=<value>,...', 'builtin:annotations:<key>=<value>,...', 'builtin:image-registry:<from>=<to>,...', 'builtin:patch:<file>' for strategic merge patches and 'builtin:json-patch:<file>' for JSON patches")
 }
 
 type postRenderer struct {
-	renderer *postrender.PostRenderer
+	renderer  *postrender.PostRenderer
+	renderers []postrender.PostRenderer
 }
 
-func (p postRenderer) String() string {
+func (p *postRenderer) String() string {
 	return "exec"
 }
 
-func (p postRenderer) Type() string {
+func (p *postRenderer) Type() string {
 	return "postrenderer"
 }
 
-func (p postRenderer) Set(s string) error {
+func (p *postRenderer) Set(s string) error {
 	if s == "" {
 		return nil
 	}
-	pr, err := postrender.NewExec(s)
+	pr, err := newPostRenderer(s)
 	if err != nil {
 		return err
 	}
-	*p.renderer = pr
+	p.renderers = append(p.renderers, pr)
+	if len(p.renderers) == 1 {
+		*p.renderer = pr
+	} else {
+		*p.renderer = postrender.NewChain(p.renderers...)
+	}
 	return nil
 }
 
@@ -54,7 +54,7 @@ func newGetAllCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			}
 			if template != "" {
 				data := map[string]interface{}{
-					"Release": res,
+					"Release": redactedRelease(res),
 				}
 				return tpl(template, data, out)
 			}
@@ -33,6 +33,11 @@ func TestGetCmd(t *testing.T) {
 		cmd:    "get all elevated-turkey --template {{.Release.Chart.Metadata.Version}}",
 		golden: "output/get-release-template.txt",
 		rels:   []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "elevated-turkey"})},
+	}, {
+		name:   "get all with a formatted release with sensitive values",
+		cmd:    "get all elevated-turkey --template {{.Release.Config}}",
+		golden: "output/get-release-template-sensitive.txt",
+		rels:   []*release.Release{sensitiveRelease("elevated-turkey")},
 	}, {
 		name:      "get all requires release name arg",
 		cmd:       "get all",
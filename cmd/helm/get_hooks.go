@@ -20,21 +20,27 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"helm.sh/helm/v3/cmd/helm/require"
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
 )
 
 const getHooksHelp = `
 This command downloads hooks for a given release.
 
 Hooks are formatted in YAML and separated by the YAML '---\n' separator.
+
+With '--logs', the container logs and Kubernetes events recorded for the last
+run of each hook are appended to it as YAML comments.
 `
 
 func newGetHooksCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	client := action.NewGet(cfg)
+	var showLogs bool
 
 	cmd := &cobra.Command{
 		Use:   "hooks RELEASE_NAME",
@@ -54,12 +60,16 @@ func newGetHooksCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			}
 			for _, hook := range res.Hooks {
 				fmt.Fprintf(out, "---\n# Source: %s\n%s\n", hook.Path, hook.Manifest)
+				if showLogs {
+					writeHookOutput(out, hook)
+				}
 			}
 			return nil
 		},
 	}
 
 	cmd.Flags().IntVar(&client.Version, "revision", 0, "get the named release with revision")
+	cmd.Flags().BoolVar(&showLogs, "logs", false, "show the container logs and events recorded for the last run of each hook")
 	err := cmd.RegisterFlagCompletionFunc("revision", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		if len(args) == 1 {
 			return compListRevisions(toComplete, cfg, args[0])
@@ -73,3 +83,33 @@ func newGetHooksCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 
 	return cmd
 }
+
+// writeHookOutput writes the output recorded for the last run of the hook as
+// YAML comments.
+func writeHookOutput(out io.Writer, hook *release.Hook) {
+	run := hook.LastRun
+	if run.Phase == "" {
+		fmt.Fprintln(out, "# Last run: never")
+		return
+	}
+	fmt.Fprintf(out, "# Last run: %s\n", run.Phase)
+	for _, l := range run.Logs {
+		fmt.Fprintf(out, "# Logs of pod %s, container %s:\n", l.Pod, l.Container)
+		if l.Truncated {
+			fmt.Fprintln(out, "#   ...")
+		}
+		writeCommented(out, l.Log)
+	}
+	if len(run.KubeEvents) > 0 {
+		fmt.Fprintln(out, "# Events:")
+		for _, e := range run.KubeEvents {
+			writeCommented(out, e)
+		}
+	}
+}
+
+func writeCommented(out io.Writer, s string) {
+	for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+		fmt.Fprintf(out, "#   %s\n", line)
+	}
+}
@@ -28,6 +28,11 @@ func TestGetHooks(t *testing.T) {
 		cmd:    "get hooks aeneas",
 		golden: "output/get-hooks.txt",
 		rels:   []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "aeneas"})},
+	}, {
+		name:   "get hooks with logs",
+		cmd:    "get hooks aeneas --logs",
+		golden: "output/get-hooks-logs.txt",
+		rels:   []*release.Release{releaseWithHookOutput("aeneas")},
 	}, {
 		name:      "get hooks without args",
 		cmd:       "get hooks",
@@ -37,6 +42,18 @@ func TestGetHooks(t *testing.T) {
 	runTestCmd(t, tests)
 }
 
+func releaseWithHookOutput(name string) *release.Release {
+	rel := release.Mock(&release.MockReleaseOptions{Name: name})
+	rel.Hooks[0].LastRun = release.HookExecution{
+		Phase: release.HookPhaseFailed,
+		Logs: []release.HookLog{
+			{Pod: "pre-install-hook", Container: "hook", Log: "running migration\nerror: table exists\n", Truncated: true},
+		},
+		KubeEvents: []string{"Warning BackOff Pod/pre-install-hook: Back-off restarting failed container"},
+	}
+	return rel
+}
+
 func TestGetHooksCompletion(t *testing.T) {
 	checkReleaseCompletion(t, "get hooks", false)
 }
@@ -20,16 +20,26 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"sort"
+	"strings"
 
 	"github.com/spf13/cobra"
+	yamlv3 "gopkg.in/yaml.v3"
 
 	"helm.sh/helm/v3/cmd/helm/require"
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/cli/output"
 )
 
 var getValuesHelp = `
 This command downloads a values file for a given release.
+
+With '--explain', all (computed) values are shown, each annotated with where it
+came from: the values supplied by the user, a chart or subchart default, a
+global propagated from a parent chart, a value imported from a subchart, or a
+schema default. The files and flags the user-supplied values came from are not
+stored with the release.
 `
 
 type valuesWriter struct {
@@ -39,6 +49,7 @@ type valuesWriter struct {
 
 func newGetValuesCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	var outfmt output.Format
+	var explain bool
 	client := action.NewGetValues(cfg)
 
 	cmd := &cobra.Command{
@@ -53,6 +64,13 @@ func newGetValuesCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			return compListReleases(toComplete, args, cfg)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if explain {
+				vals, origins, err := client.Explain(args[0])
+				if err != nil {
+					return err
+				}
+				return outfmt.Write(out, &explainedValuesWriter{vals, origins})
+			}
 			vals, err := client.Run(args[0])
 			if err != nil {
 				return err
@@ -75,6 +93,7 @@ func newGetValuesCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	}
 
 	f.BoolVarP(&client.AllValues, "all", "a", false, "dump all (computed) values")
+	f.BoolVar(&explain, "explain", false, "dump all (computed) values, annotated with where each of them came from")
 	bindOutputFlag(cmd, &outfmt)
 
 	return cmd
@@ -96,3 +115,110 @@ func (v valuesWriter) WriteJSON(out io.Writer) error {
 func (v valuesWriter) WriteYAML(out io.Writer) error {
 	return output.EncodeYAML(out, v.vals)
 }
+
+// explainedValue is a value along with where it came from.
+type explainedValue struct {
+	Path   string      `json:"path"`
+	Value  interface{} `json:"value"`
+	Origin string      `json:"origin"`
+}
+
+type explainedValuesWriter struct {
+	vals    map[string]interface{}
+	origins chartutil.ValueOrigins
+}
+
+// WriteTable writes the values as YAML, with the origin of each value in a
+// comment at the end of its line.
+func (v explainedValuesWriter) WriteTable(out io.Writer) error {
+	fmt.Fprintln(out, "COMPUTED VALUES:")
+	node, err := explainedNode(v.vals, "", v.origins)
+	if err != nil {
+		return err
+	}
+	enc := yamlv3.NewEncoder(out)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func (v explainedValuesWriter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, v.list())
+}
+
+func (v explainedValuesWriter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, v.list())
+}
+
+// list returns the leaves of the values with their origin, ordered by path.
+func (v explainedValuesWriter) list() []explainedValue {
+	list := []explainedValue{}
+	var walk func(m map[string]interface{}, ptr string)
+	walk = func(m map[string]interface{}, ptr string) {
+		for k, val := range m {
+			p := ptr + "/" + pointerToken(k)
+			if t, ok := val.(map[string]interface{}); ok && len(t) > 0 {
+				walk(t, p)
+				continue
+			}
+			list = append(list, explainedValue{Path: p, Value: val, Origin: v.origins[p]})
+		}
+	}
+	walk(v.vals, "")
+	sort.Slice(list, func(i, j int) bool { return list[i].Path < list[j].Path })
+	return list
+}
+
+// pointerToken escapes a key for use in a JSON pointer.
+func pointerToken(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// explainedNode builds the YAML node of the value at ptr, annotating every
+// leaf with its origin.
+func explainedNode(val interface{}, ptr string, origins chartutil.ValueOrigins) (*yamlv3.Node, error) {
+	m, ok := val.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		data, err := yamlv3.Marshal(val)
+		if err != nil {
+			return nil, err
+		}
+		var doc yamlv3.Node
+		if err := yamlv3.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		node := doc.Content[0]
+		if len(node.Content) == 0 {
+			// Keep empty tables and lists on the line of their key.
+			node.Style = yamlv3.FlowStyle
+		}
+		if ptr != "" {
+			node.LineComment = origins[ptr]
+		}
+		return node, nil
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	node := &yamlv3.Node{Kind: yamlv3.MappingNode, Tag: "!!map"}
+	for _, k := range keys {
+		p := ptr + "/" + pointerToken(k)
+		value, err := explainedNode(m[k], p, origins)
+		if err != nil {
+			return nil, err
+		}
+		key := &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: k}
+		if value.Style != yamlv3.FlowStyle && value.Kind != yamlv3.ScalarNode {
+			// Comments on tables and lists go on the line of their key.
+			key.LineComment, value.LineComment = value.LineComment, ""
+		}
+		node.Content = append(node.Content, key, value)
+	}
+	return node, nil
+}
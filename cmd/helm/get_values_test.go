@@ -23,6 +23,9 @@ import (
 )
 
 func TestGetValuesCmd(t *testing.T) {
+	sensitive := release.Mock(&release.MockReleaseOptions{Name: "thomas-guide"})
+	sensitive.SensitiveValues = []string{"/name"}
+
 	tests := []cmdTestCase{{
 		name:   "get values with a release",
 		cmd:    "get values thomas-guide",
@@ -49,6 +52,26 @@ func TestGetValuesCmd(t *testing.T) {
 		cmd:    "get values thomas-guide --output yaml",
 		golden: "output/values.yaml",
 		rels:   []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "thomas-guide"})},
+	}, {
+		name:   "get values with sensitive values",
+		cmd:    "get values thomas-guide",
+		golden: "output/get-values-sensitive.txt",
+		rels:   []*release.Release{sensitive},
+	}, {
+		name:   "get values with sensitive values (all)",
+		cmd:    "get values thomas-guide --all",
+		golden: "output/get-values-sensitive-all.txt",
+		rels:   []*release.Release{sensitive},
+	}, {
+		name:   "get values with origins",
+		cmd:    "get values thomas-guide --explain",
+		golden: "output/get-values-explain.txt",
+		rels:   []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "thomas-guide"})},
+	}, {
+		name:   "get values with origins to json",
+		cmd:    "get values thomas-guide --explain --output json",
+		golden: "output/get-values-explain.json",
+		rels:   []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "thomas-guide"})},
 	}}
 	runTestCmd(t, tests)
 }
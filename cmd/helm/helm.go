@@ -17,11 +17,14 @@ limitations under the License.
 package main // import "helm.sh/helm/v3/cmd/helm"
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
 	"sigs.k8s.io/yaml"
@@ -58,6 +61,13 @@ func warning(format string, v ...interface{}) {
 	fmt.Fprintf(os.Stderr, format, v...)
 }
 
+// interruptContext returns a context that is cancelled when helm receives
+// SIGINT or SIGTERM, so that a running operation can mark its release as
+// failed instead of leaving it pending.
+func interruptContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
 func main() {
 	actionConfig := new(action.Configuration)
 	cmd, err := newRootCmd(actionConfig, os.Stdout, os.Args[1:])
@@ -82,12 +92,31 @@ func main() {
 		switch e := err.(type) {
 		case pluginError:
 			os.Exit(e.code)
+		case exitCodeError:
+			os.Exit(e.code)
 		default:
 			os.Exit(1)
 		}
 	}
 }
 
+// exitCodeError is returned by the commands that report their result with the
+// exit status of helm, such as 'helm diff --detailed-exitcode'. helm exits
+// with its code without printing it.
+type exitCodeError struct {
+	error
+	code int
+}
+
+// silenceExitCode keeps cobra from printing err if it is an exitCodeError,
+// and returns it.
+func silenceExitCode(cmd *cobra.Command, err error) error {
+	if _, ok := err.(exitCodeError); ok {
+		cmd.SilenceErrors = true
+	}
+	return err
+}
+
 func checkOCIFeatureGate() func(_ *cobra.Command, _ []string) error {
 	return func(_ *cobra.Command, _ []string) error {
 		if !FeatureGateOCI.IsEnabled() {
@@ -18,6 +18,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -183,6 +184,35 @@ func testChdir(t *testing.T, dir string) func() {
 	return func() { os.Chdir(old) }
 }
 
+func TestSilenceExitCode(t *testing.T) {
+	tests := []struct {
+		err    error
+		stderr string
+	}{
+		{errDriftDetected, ""},
+		{errDiffChanges, ""},
+		{errors.New("boom"), "Error: boom\n"},
+	}
+	for _, tt := range tests {
+		var stderr bytes.Buffer
+		cmd := &cobra.Command{
+			Use: "test",
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				return silenceExitCode(cmd, tt.err)
+			},
+			SilenceUsage: true,
+		}
+		cmd.SetArgs([]string{})
+		cmd.SetErr(&stderr)
+		if err := cmd.Execute(); err != tt.err {
+			t.Errorf("Expected %v, got %v", tt.err, err)
+		}
+		if stderr.String() != tt.stderr {
+			t.Errorf("%v: expected %q written to stderr, got %q", tt.err, tt.stderr, stderr.String())
+		}
+	}
+}
+
 func TestPluginExitCode(t *testing.T) {
 	if os.Getenv("RUN_MAIN_FOR_TESTING") == "1" {
 		os.Args = []string{"helm", "exitwith", "2"}
@@ -77,6 +77,11 @@ func newHistoryCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 		},
 	}
 
+	cmd.AddCommand(
+		newHistoryExportCmd(cfg, out),
+		newHistoryImportCmd(cfg, out),
+	)
+
 	f := cmd.Flags()
 	f.IntVar(&client.Max, "max", 256, "maximum number of revision to include in history")
 	bindOutputFlag(cmd, &outfmt)
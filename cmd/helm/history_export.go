@@ -0,0 +1,86 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/pkg/action"
+)
+
+const historyExportDesc = `
+This command backs up every revision of the given releases, or of all the
+releases of the namespace, to a portable archive, which 'helm history import'
+restores into any storage driver, e.g. after a cluster has been rebuilt.
+
+The archive is written to standard output unless '--file' is set:
+
+    $ helm history export --all-namespaces --file releases.json.gz
+`
+
+func newHistoryExportCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewHistoryExport(cfg)
+	var file string
+	var allNamespaces bool
+
+	cmd := &cobra.Command{
+		Use:   "export [RELEASE...]",
+		Short: "back up the history of releases to an archive",
+		Long:  historyExportDesc,
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if allNamespaces {
+				if err := cfg.Init(settings.RESTClientGetter(), "", os.Getenv("HELM_DRIVER"), debug); err != nil {
+					return err
+				}
+			}
+			archive, err := client.Run(args...)
+			if err != nil {
+				return err
+			}
+
+			if file == "" {
+				return archive.Write(out)
+			}
+			f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+			if err != nil {
+				return err
+			}
+			if err := archive.Write(f); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Exported %d revisions to %s\n", len(archive.Releases), file)
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&file, "file", "", "write the archive to this file instead of standard output")
+	f.BoolVarP(&allNamespaces, "all-namespaces", "A", false, "export the releases of all namespaces")
+
+	return cmd
+}
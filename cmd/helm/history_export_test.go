@@ -0,0 +1,126 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// exportHistoryFixture exports the history of releases to an archive file and
+// returns its path.
+func exportHistoryFixture(t *testing.T) string {
+	t.Helper()
+	store := storageFixture()
+	for _, rls := range []*release.Release{
+		release.Mock(&release.MockReleaseOptions{Name: "angry-panda", Version: 1, Status: release.StatusSuperseded}),
+		release.Mock(&release.MockReleaseOptions{Name: "angry-panda", Version: 2}),
+		release.Mock(&release.MockReleaseOptions{Name: "happy-bunny", Version: 1}),
+	} {
+		if err := store.Create(rls); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "releases.json.gz")
+	_, out, err := executeActionCommandC(store, "history export --file "+path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("Exported 3 revisions to %s\n", path); out != want {
+		t.Errorf("expected %q, got %q", want, out)
+	}
+	return path
+}
+
+func TestHistoryExportCmd(t *testing.T) {
+	path := exportHistoryFixture(t)
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	archive, err := action.ReadReleaseArchive(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Releases) != 3 {
+		t.Errorf("expected 3 revisions in the archive, got %d", len(archive.Releases))
+	}
+
+	// The archive is written to the standard output by default
+	store := storageFixture()
+	if err := store.Create(release.Mock(&release.MockReleaseOptions{Name: "angry-panda"})); err != nil {
+		t.Fatal(err)
+	}
+	_, out, err := executeActionCommandC(store, "history export angry-panda")
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err = action.ReadReleaseArchive(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.Releases) != 1 {
+		t.Errorf("expected 1 revision in the archive, got %d", len(archive.Releases))
+	}
+}
+
+func TestHistoryImportCmd(t *testing.T) {
+	defer resetEnv()()
+	path := exportHistoryFixture(t)
+
+	tests := []cmdTestCase{{
+		name:   "import releases",
+		cmd:    "history import " + path,
+		golden: "output/history-import.txt",
+	}, {
+		name:   "import releases with dry run",
+		cmd:    "history import --dry-run " + path,
+		golden: "output/history-import-dry-run.txt",
+	}, {
+		name:   "import releases that are already stored",
+		cmd:    "history import " + path,
+		golden: "output/history-import-existing.txt",
+		rels: []*release.Release{
+			release.Mock(&release.MockReleaseOptions{Name: "happy-bunny", Version: 1}),
+		},
+	}, {
+		name:   "import releases of another namespace",
+		cmd:    "history import --namespace other " + path,
+		golden: "output/history-import-empty.txt",
+	}, {
+		name:      "import an archive of an unsupported version",
+		cmd:       "history import testdata/history/archive-v2.json",
+		golden:    "output/history-import-invalid.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestHistoryExportFileCompletion(t *testing.T) {
+	checkFileCompletion(t, "history export", false)
+	checkFileCompletion(t, "history import", true)
+	checkFileCompletion(t, "history import file", false)
+}
@@ -0,0 +1,135 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+)
+
+const historyImportDesc = `
+This command restores the revisions of the releases of an archive written by
+'helm history export' into the storage of HELM_DRIVER. Use '-' to read the
+archive from standard input.
+
+Only the releases of the namespace are imported, unless '--all-namespaces' is
+set. Revisions that already are stored are left as they are if they are
+identical, so an archive can be imported again; a stored revision that differs
+fails the import.
+
+With '--adopt', the live resources of the deployed revision of each release
+are given the ownership labels and annotations of the release, if they lack
+them, so that the release can be upgraded. Resources owned by another release
+are only adopted with '--force'.
+`
+
+func newHistoryImportCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewHistoryImport(cfg)
+	var allNamespaces bool
+
+	cmd := &cobra.Command{
+		Use:   "import FILE",
+		Short: "restore the history of releases from an archive",
+		Long:  historyImportDesc,
+		Args:  require.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return nil, cobra.ShellCompDirectiveDefault
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			archive, err := readReleaseArchive(args[0])
+			if err != nil {
+				return err
+			}
+
+			namespaces := []string{settings.Namespace()}
+			if allNamespaces {
+				namespaces = archive.Namespaces()
+			}
+			var revisions []*action.MigratedRevision
+			var adopted, missing []string
+			for _, ns := range namespaces {
+				if allNamespaces {
+					if err := cfg.Init(settings.RESTClientGetter(), ns, os.Getenv("HELM_DRIVER"), debug); err != nil {
+						return err
+					}
+				}
+				client.Namespace = ns
+				result, err := client.Run(archive)
+				if result != nil {
+					revisions = append(revisions, result.Revisions...)
+					adopted = append(adopted, result.Adopted...)
+					missing = append(missing, result.Missing...)
+				}
+				if err != nil {
+					writeImportResult(out, revisions, adopted, missing, client.DryRun)
+					return err
+				}
+			}
+			return writeImportResult(out, revisions, adopted, missing, client.DryRun)
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVarP(&allNamespaces, "all-namespaces", "A", false, "import the releases of all the namespaces of the archive")
+	f.BoolVar(&client.Adopt, "adopt", false, "set the ownership metadata of the releases on their live resources")
+	f.BoolVar(&client.Force, "force", false, "with --adopt, adopt resources that are owned by another release")
+	f.BoolVar(&client.DryRun, "dry-run", false, "report the revisions that would be imported without storing them")
+
+	return cmd
+}
+
+func readReleaseArchive(path string) (*action.ReleaseArchive, error) {
+	if path == "-" {
+		return action.ReadReleaseArchive(os.Stdin)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return action.ReadReleaseArchive(f)
+}
+
+func writeImportResult(out io.Writer, revisions []*action.MigratedRevision, adopted, missing []string, dryRun bool) error {
+	if len(revisions) == 0 {
+		fmt.Fprintln(out, "No releases to import")
+		return nil
+	}
+	if err := writeMigratedRevisions(out, revisions, dryRun, "imported", "would import"); err != nil {
+		return err
+	}
+	verb := "Adopted"
+	if dryRun {
+		verb = "Would adopt"
+	}
+	for _, r := range adopted {
+		fmt.Fprintf(out, "%s %s\n", verb, r)
+	}
+	for _, r := range missing {
+		fmt.Fprintf(out, "Not found in the cluster: %s\n", r)
+	}
+	return nil
+}
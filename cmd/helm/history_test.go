@@ -110,7 +110,25 @@ func revisionFlagCompletionTest(t *testing.T, cmdName string) {
 }
 
 func TestHistoryCompletion(t *testing.T) {
-	checkReleaseCompletion(t, "history", false)
+	// The subcommands are completed along with the releases
+	tests := []cmdTestCase{{
+		name:   "completion for history",
+		cmd:    "__complete history ''",
+		golden: "output/history_comp.txt",
+		rels: []*release.Release{
+			release.Mock(&release.MockReleaseOptions{Name: "athos"}),
+			release.Mock(&release.MockReleaseOptions{Name: "porthos"}),
+			release.Mock(&release.MockReleaseOptions{Name: "aramis"}),
+		},
+	}, {
+		name:   "completion for history with a release",
+		cmd:    "__complete history athos ''",
+		golden: "output/empty_nofile_comp.txt",
+		rels: []*release.Release{
+			release.Mock(&release.MockReleaseOptions{Name: "athos"}),
+		},
+	}}
+	runTestCmd(t, tests)
 }
 
 func TestHistoryFileCompletion(t *testing.T) {
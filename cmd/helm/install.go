@@ -62,6 +62,32 @@ or
 
     $ helm install --set-file my_script=dothings.sh myredis ./redis
 
+or
+
+    $ helm install --set-json 'resources={"limits":{"cpu":"100m"}}' myredis ./redis
+
+Values files may also be read from a URL. Besides http(s):// and the protocols
+of downloader plugins, 'oci://' reads values.yaml (or the file named after '#')
+from a chart in a registry, 'env://PREFIX_' reads the environment variables
+starting with PREFIX_ ('__' separates nested keys) and 'exec://PLUGIN' reads
+the output of an installed downloader plugin:
+
+    $ helm install -f oci://example.com/charts/redis-values:1.0.0#prod.yaml myredis ./redis
+    $ REDIS_auth__password=secret helm install -f env://REDIS_ myredis ./redis
+
+Encrypted values files are decrypted in memory. PGP encrypted files, and SOPS
+files whose data key is encrypted with PGP, are decrypted with the secret keys
+in '--values-keyring'. age encrypted files, and SOPS files whose data key is
+encrypted with age, are decrypted with the X25519 keys in '--values-age-identity'
+or $SOPS_AGE_KEY_FILE. Any other tool, such as sops, can decrypt them with
+'--values-decrypt-command'. Decrypted values are marked sensitive, and are
+redacted by 'helm get values':
+
+    $ helm install -f secrets.sops.yaml --values-keyring ~/.gnupg/secring.gpg myredis ./redis
+    $ helm install -f secrets.yaml.age --values-age-identity ~/.config/sops/age/keys.txt myredis ./redis
+    $ helm install -f secrets.sops.yaml --values-decrypt-command \
+        'sops --decrypt --input-type yaml --output-type yaml /dev/stdin' myredis ./redis
+
 You can specify the '--values'/'-f' flag multiple times. The priority will be given to the
 last (right-most) file specified. For example, if both myvalues.yaml and override.yaml
 contained a key called 'Test', the value set in override.yaml would take precedence:
@@ -117,16 +143,22 @@ func newInstallCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			return compInstall(args, toComplete, client)
 		},
 		RunE: func(_ *cobra.Command, args []string) error {
+			watchProgress(cfg)
 			rel, err := runInstall(args, client, valueOpts, out)
 			if err != nil {
 				return err
 			}
 
+			if client.Adopt && outfmt == output.Table {
+				writeAdopted(out, client.Adopted, client.DryRun)
+			}
 			return outfmt.Write(out, &statusPrinter{rel, settings.Debug, false})
 		},
 	}
 
 	addInstallFlags(cmd, cmd.Flags(), client, valueOpts)
+	addServerSideApplyFlags(cmd.Flags(), &client.ServerSideApply, &client.ForceConflicts)
+	cmd.Flags().BoolVar(&client.Adopt, "adopt", false, "take ownership of existing resources that lack the ownership metadata of a release, as 'helm adopt' does")
 	bindOutputFlag(cmd, &outfmt)
 	bindPostRenderFlag(cmd, &client.PostRenderer)
 
@@ -139,8 +171,9 @@ func addInstallFlags(cmd *cobra.Command, f *pflag.FlagSet, client *action.Instal
 	f.BoolVar(&client.DisableHooks, "no-hooks", false, "prevent hooks from running during install")
 	f.BoolVar(&client.Replace, "replace", false, "re-use the given name, only if that name is a deleted release which remains in the history. This is unsafe in production")
 	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks)")
-	f.BoolVar(&client.Wait, "wait", false, "if set, will wait until all Pods, PVCs, Services, and minimum number of Pods of a Deployment, StatefulSet, or ReplicaSet are in a ready state before marking the release as successful. It will wait for as long as --timeout")
+	f.BoolVar(&client.Wait, "wait", false, "if set, will wait until all Pods, PVCs, Services, and minimum number of Pods of a Deployment, StatefulSet, or ReplicaSet are in a ready state, and custom resources report a Ready condition or satisfy their helm.sh/readiness rule, before marking the release as successful. It will wait for as long as --timeout")
 	f.BoolVar(&client.WaitForJobs, "wait-for-jobs", false, "if set and --wait enabled, will wait until all Jobs have been completed before marking the release as successful. It will wait for as long as --timeout")
+	f.IntVar(&client.HookParallelism, "hook-parallelism", 1, "maximum number of hooks of the same weight to run at the same time")
 	f.BoolVarP(&client.GenerateName, "generate-name", "g", false, "generate the name (and omit the NAME parameter)")
 	f.StringVar(&client.NameTemplate, "name-template", "", "specify template used to name the release")
 	f.StringVar(&client.Description, "description", "", "add a custom description")
@@ -190,10 +223,12 @@ func runInstall(args []string, client *action.Install, valueOpts *values.Options
 	debug("CHART PATH: %s\n", cp)
 
 	p := getter.All(settings)
-	vals, err := valueOpts.MergeValues(p)
+	vals, sources, sensitive, err := valueOpts.MergeValuesWithSources(getter.ValuesProviders(settings))
 	if err != nil {
 		return nil, err
 	}
+	client.ValueSources = sources
+	client.SensitiveValues = sensitive
 
 	// Check chart dependencies to make sure all are present in /charts
 	chartRequested, err := loader.Load(cp)
@@ -239,7 +274,10 @@ func runInstall(args []string, client *action.Install, valueOpts *values.Options
 	}
 
 	client.Namespace = settings.Namespace()
-	return client.Run(chartRequested, vals)
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+	return client.RunWithContext(ctx, chartRequested, vals)
 }
 
 // checkIfInstallable validates if a chart can be installed
@@ -48,6 +48,18 @@ func TestInstall(t *testing.T) {
 			cmd:    "install virgil testdata/testcharts/alpine -f testdata/testcharts/alpine/extra_values.yaml",
 			golden: "output/install-with-values-file.txt",
 		},
+		// Install, values from an encrypted yaml
+		{
+			name:   "install with encrypted values file",
+			cmd:    "install virgil testdata/testcharts/alpine -f testdata/testcharts/alpine/secret_values.sops.yaml --values-keyring testdata/helm-test-key.secret",
+			golden: "output/install-with-encrypted-values-file.txt",
+		},
+		{
+			name:      "install with encrypted values file and no keyring",
+			cmd:       "install virgil testdata/testcharts/alpine -f testdata/testcharts/alpine/secret_values.sops.yaml",
+			golden:    "output/install-with-encrypted-values-file-no-keyring.txt",
+			wantError: true,
+		},
 		// Install, no hooks
 		{
 			name:   "install without hooks",
@@ -167,7 +179,7 @@ func TestInstall(t *testing.T) {
 			name:      "install with schema file, extra values from yaml, with errors",
 			cmd:       "install schema testdata/testcharts/chart-with-schema -f testdata/testcharts/chart-with-schema/extra-values.yaml",
 			wantError: true,
-			golden:    "output/schema-negative.txt",
+			golden:    "output/schema-negative-file.txt",
 		},
 		// Install, values from yaml, extra values from cli, schematized with errors
 		{
@@ -69,7 +69,7 @@ func newLintCmd(out io.Writer) *cobra.Command {
 			}
 
 			client.Namespace = settings.Namespace()
-			vals, err := valueOpts.MergeValues(getter.All(settings))
+			vals, err := valueOpts.MergeValues(getter.ValuesProviders(settings))
 			if err != nil {
 				return err
 			}
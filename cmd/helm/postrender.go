@@ -0,0 +1,124 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/plugin"
+	"helm.sh/helm/v3/pkg/postrender"
+)
+
+// newPostRenderer returns the post-renderer of a --post-renderer value: a
+// Helm plugin prefixed with "plugin:", a built-in post-renderer prefixed with
+// "builtin:", or else an executable. Only these prefixes are recognized, so
+// that paths such as C:\bin\post-renderer.exe are executables.
+func newPostRenderer(s string) (postrender.PostRenderer, error) {
+	if name := strings.TrimPrefix(s, "plugin:"); name != s {
+		return &pluginPostRenderer{name: name}, nil
+	}
+	builtin := strings.TrimPrefix(s, "builtin:")
+	if builtin == s {
+		return postrender.NewExec(s)
+	}
+
+	kind, arg := builtin, ""
+	if i := strings.Index(builtin, ":"); i >= 0 {
+		kind, arg = builtin[:i], builtin[i+1:]
+	}
+	switch kind {
+	case "labels":
+		labels, err := parseKeyValues(arg)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid labels post-renderer")
+		}
+		return postrender.NewLabels(labels), nil
+	case "annotations":
+		annotations, err := parseKeyValues(arg)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid annotations post-renderer")
+		}
+		return postrender.NewAnnotations(annotations), nil
+	case "image-registry":
+		rewrites, err := parseKeyValues(arg)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid image-registry post-renderer")
+		}
+		return postrender.NewImageRegistry(rewrites), nil
+	case "patch":
+		data, err := ioutil.ReadFile(arg)
+		if err != nil {
+			return nil, err
+		}
+		return postrender.NewStrategicMergePatch(data)
+	case "json-patch":
+		data, err := ioutil.ReadFile(arg)
+		if err != nil {
+			return nil, err
+		}
+		return postrender.NewJSON6902Patch(data)
+	}
+	return nil, errors.Errorf("unknown built-in post-renderer %q", kind)
+}
+
+// parseKeyValues parses a comma separated list of key=value pairs.
+func parseKeyValues(s string) (map[string]string, error) {
+	m := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, errors.Errorf("expected <key>=<value>, got %q", pair)
+		}
+		m[kv[0]] = kv[1]
+	}
+	return m, nil
+}
+
+// pluginPostRenderer runs the command of a Helm plugin as post-renderer. The
+// plugin is looked up when it runs, once all the flags have been parsed, so
+// that it sees the same environment as when it is run as a command.
+type pluginPostRenderer struct {
+	name string
+}
+
+func (p *pluginPostRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	plugins, err := plugin.FindPlugins(settings.PluginsDirectory)
+	if err != nil {
+		return nil, err
+	}
+	for _, plug := range plugins {
+		if plug.Metadata.Name != p.name {
+			continue
+		}
+		// PrepareCommand expands the environment set up for the plugin
+		plugin.SetupPluginEnv(settings, plug.Metadata.Name, plug.Dir)
+		main, argv, err := plug.PrepareCommand(nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "plugin %q", p.name)
+		}
+		pr, err := postrender.NewExecWithArgs(main, argv)
+		if err != nil {
+			return nil, errors.Wrapf(err, "plugin %q", p.name)
+		}
+		return pr.Run(renderedManifests)
+	}
+	return nil, errors.Errorf("plugin %q not found", p.name)
+}
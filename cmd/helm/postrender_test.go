@@ -0,0 +1,102 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/postrender"
+)
+
+func TestNewPostRenderer(t *testing.T) {
+	for _, tt := range []struct {
+		value   string
+		wantErr bool
+	}{
+		{value: "builtin:labels:a=b,c=d"},
+		{value: "builtin:annotations:a=b"},
+		{value: "builtin:image-registry:docker.io=mirror.example.com"},
+		{value: "builtin:patch:testdata/postrender/service-patch.yaml"},
+		{value: "builtin:json-patch:testdata/postrender/missing-patch.yaml"},
+		{value: "plugin:uppercase"},
+		{value: "builtin:labels:a", wantErr: true},
+		{value: "builtin:annotations:=b", wantErr: true},
+		{value: "builtin:patch:testdata/postrender/nonexistent.yaml", wantErr: true},
+		{value: "builtin:json-patch:testdata/postrender/service-patch.yaml", wantErr: true},
+		{value: "builtin:nonexistent", wantErr: true},
+		{value: "nonexistent-post-renderer", wantErr: true},
+	} {
+		_, err := newPostRenderer(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %t, got %v", tt.value, tt.wantErr, err)
+		}
+	}
+}
+
+func TestNewPostRendererExecutable(t *testing.T) {
+	// Without the plugin: or builtin: prefix, the value is the path to an
+	// executable, even if it contains a colon
+	for _, value := range []string{"labels:a=b", `C:\bin\post-renderer.exe`} {
+		_, err := newPostRenderer(value)
+		if err == nil || !strings.Contains(err.Error(), "unable to find binary at "+value) {
+			t.Errorf("%s: expected the executable not to be found, got %v", value, err)
+		}
+	}
+}
+
+func TestPostRenderFlagChain(t *testing.T) {
+	var pr postrender.PostRenderer
+	flag := &postRenderer{renderer: &pr}
+	if err := flag.Set("builtin:labels:a=1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := flag.Set("builtin:labels:a=2,b=3"); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := pr.Run(bytes.NewBufferString("kind: ConfigMap\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "---\nkind: ConfigMap\nmetadata:\n  labels:\n    a: \"2\"\n    b: \"3\"\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestPluginPostRenderer(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the test plugin is a shell script")
+	}
+	defer resetEnv()()
+	settings.PluginsDirectory = "testdata/postrender/plugins"
+
+	out, err := (&pluginPostRenderer{name: "uppercase"}).Run(bytes.NewBufferString("kind: ConfigMap\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "KIND: CONFIGMAP\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	if _, err := (&pluginPostRenderer{name: "missing"}).Run(bytes.NewBufferString("")); err == nil {
+		t.Error("expected an error for a missing plugin")
+	}
+}
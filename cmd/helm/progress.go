@@ -0,0 +1,97 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gosuri/uitable"
+	"golang.org/x/term"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/kube"
+)
+
+// progressMaxColWidth keeps the rows of the progress table on one line, so
+// that it can be redrawn in place.
+const progressMaxColWidth = 80
+
+// watchProgress renders the progress of the operations of cfg as a live
+// table on stderr. It does nothing unless stderr is a terminal, as the table
+// is redrawn in place, or if debug output is enabled.
+func watchProgress(cfg *action.Configuration) {
+	if settings.Debug || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return
+	}
+	cfg.Progress = newProgressTable(os.Stderr).handle
+}
+
+// progressTable is a table of the resources an operation waits for, with
+// the latest state of each.
+type progressTable struct {
+	mu     sync.Mutex
+	out    io.Writer
+	keys   []string
+	events map[string]kube.ProgressEvent
+	// lines is the number of lines drawn last, which are erased on redraw.
+	lines int
+}
+
+func newProgressTable(out io.Writer) *progressTable {
+	return &progressTable{
+		out:    out,
+		events: map[string]kube.ProgressEvent{},
+	}
+}
+
+func (p *progressTable) handle(e kube.ProgressEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := strings.Join([]string{e.Kind, e.Namespace, e.Name}, "/")
+	old, ok := p.events[key]
+	if ok && old == e {
+		return
+	}
+	if !ok {
+		p.keys = append(p.keys, key)
+	}
+	p.events[key] = e
+	p.draw()
+}
+
+func (p *progressTable) draw() {
+	if p.lines > 0 {
+		// Move the cursor up to the first line of the table and erase it
+		fmt.Fprintf(p.out, "\x1b[%dA\x1b[J", p.lines)
+	}
+
+	table := uitable.New()
+	table.MaxColWidth = progressMaxColWidth
+	table.AddRow("KIND", "NAME", "HOOK", "STATUS", "MESSAGE")
+	for _, key := range p.keys {
+		e := p.events[key]
+		table.AddRow(e.Kind, e.Name, e.Hook, e.Phase, e.Message)
+	}
+	s := table.String() + "\n"
+	fmt.Fprint(p.out, s)
+	p.lines = strings.Count(s, "\n")
+}
@@ -0,0 +1,65 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/kube"
+)
+
+func TestProgressTable(t *testing.T) {
+	var buf bytes.Buffer
+	p := newProgressTable(&buf)
+
+	pending := kube.ProgressEvent{Kind: "Deployment", Namespace: "default", Name: "web", Phase: kube.ProgressPending, Message: "0 out of 1 expected pods are ready"}
+	p.handle(pending)
+	first := buf.String()
+	if !strings.Contains(first, "web") {
+		t.Fatalf("expected the table to list the deployment, got %q", first)
+	}
+	if strings.Contains(first, "\x1b[") {
+		t.Errorf("expected the first draw not to erase anything, got %q", first)
+	}
+
+	// an unchanged event does not redraw the table
+	p.handle(pending)
+	if buf.String() != first {
+		t.Errorf("expected no redraw for an unchanged event, got %q", buf.String())
+	}
+
+	p.handle(kube.ProgressEvent{Kind: "Job", Namespace: "default", Name: "migrate", Hook: "pre-upgrade", Phase: kube.ProgressReady})
+	p.handle(kube.ProgressEvent{Kind: "Deployment", Namespace: "default", Name: "web", Phase: kube.ProgressReady})
+
+	out := buf.String()
+	last := out[strings.LastIndex(out, "\x1b[J")+len("\x1b[J"):]
+	lines := strings.Split(strings.TrimSpace(last), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a header and 2 rows, got %q", last)
+	}
+	if !strings.HasPrefix(lines[1], "Deployment") || !strings.Contains(lines[1], "Ready") {
+		t.Errorf("expected the deployment to keep its row and be ready, got %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "Job") || !strings.Contains(lines[2], "pre-upgrade") {
+		t.Errorf("expected a row for the hook job, got %q", lines[2])
+	}
+	if !strings.Contains(out, "\x1b[2A\x1b[J") {
+		t.Errorf("expected the table of 2 lines to be erased before the redraw, got %q", out)
+	}
+}
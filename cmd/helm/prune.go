@@ -0,0 +1,172 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gosuri/uitable"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli/output"
+)
+
+const pruneDesc = `
+This command finds the objects of the cluster that carry the ownership labels
+and annotations of a release but are not in the manifest of its deployed
+revision, and deletes them. Such objects are left over by failed upgrades, by
+hooks, or by the 'helm.sh/resource-policy: keep' annotation. If the release was
+uninstalled, every object it still owns is orphaned.
+
+Every namespace and every kind served by the API server are searched, which
+requires the permission to list them; kinds that cannot be listed are skipped.
+
+The orphaned objects are listed and the deletion has to be confirmed, unless
+'--yes' is set. Use '--dry-run' to only list them. Objects with the keep
+resource policy are only deleted with '--include-kept'.
+`
+
+func newPruneCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewPrune(cfg)
+	var outfmt output.Format
+	var yes bool
+
+	cmd := &cobra.Command{
+		Use:   "prune RELEASE",
+		Short: "delete the objects of a release that are no longer in its manifest",
+		Long:  pruneDesc,
+		Args:  require.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client.Namespace = settings.Namespace()
+			if client.DryRun || yes {
+				orphans, err := client.Run(args[0])
+				if werr := outfmt.Write(out, &orphansPrinter{orphans, client.DryRun, client.IncludeKept}); werr != nil {
+					return werr
+				}
+				return err
+			}
+			if outfmt != output.Table {
+				return errors.New("--yes or --dry-run is required with --output")
+			}
+
+			var confirmed bool
+			var promptErr error
+			orphans, err := client.RunWithConfirm(args[0], func(orphans []*action.Orphan) bool {
+				if promptErr = outfmt.Write(out, &orphansPrinter{orphans, true, client.IncludeKept}); promptErr != nil {
+					return false
+				}
+				if !hasDeletableOrphans(orphans, client.IncludeKept) {
+					return false
+				}
+				fmt.Fprint(out, "Delete these objects? [y/N]: ")
+				answer, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+				if err != nil && err != io.EOF {
+					promptErr = err
+					return false
+				}
+				if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+					fmt.Fprintln(out, "Nothing was deleted")
+					return false
+				}
+				confirmed = true
+				return true
+			})
+			if promptErr != nil {
+				return promptErr
+			}
+			if !confirmed {
+				return err
+			}
+			if werr := outfmt.Write(out, &orphansPrinter{orphans, false, client.IncludeKept}); werr != nil {
+				return werr
+			}
+			return err
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&client.DryRun, "dry-run", false, "list the orphaned objects without deleting them")
+	f.BoolVarP(&yes, "yes", "y", false, "delete the orphaned objects without asking for confirmation")
+	f.BoolVar(&client.IncludeKept, "include-kept", false, "also delete the objects with the keep resource policy")
+	bindOutputFlag(cmd, &outfmt)
+
+	return cmd
+}
+
+func hasDeletableOrphans(orphans []*action.Orphan, includeKept bool) bool {
+	for _, o := range orphans {
+		if !o.Kept || includeKept {
+			return true
+		}
+	}
+	return false
+}
+
+type orphansPrinter struct {
+	orphans     []*action.Orphan
+	dryRun      bool
+	includeKept bool
+}
+
+func (p orphansPrinter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, p.list())
+}
+
+func (p orphansPrinter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, p.list())
+}
+
+func (p orphansPrinter) WriteTable(out io.Writer) error {
+	if len(p.orphans) == 0 {
+		fmt.Fprintln(out, "No orphaned objects found")
+		return nil
+	}
+	tbl := uitable.New()
+	tbl.AddRow("KIND", "NAMESPACE", "NAME", "STATUS")
+	for _, o := range p.orphans {
+		status := "delete failed"
+		switch {
+		case o.Deleted:
+			status = "deleted"
+		case o.Kept && !p.includeKept:
+			status = "kept"
+		case p.dryRun:
+			status = "orphaned"
+		}
+		tbl.AddRow(o.Kind, o.Namespace, o.Name, status)
+	}
+	return output.EncodeTable(out, tbl)
+}
+
+func (p orphansPrinter) list() []*action.Orphan {
+	if p.orphans == nil {
+		return []*action.Orphan{}
+	}
+	return p.orphans
+}
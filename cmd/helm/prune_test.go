@@ -0,0 +1,64 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"helm.sh/helm/v3/internal/test"
+	"helm.sh/helm/v3/pkg/action"
+)
+
+func TestPruneCmd(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:      "prune without a release",
+		cmd:       "prune",
+		golden:    "output/prune-no-args.txt",
+		wantError: true,
+	}, {
+		name:      "prune with structured output and no confirmation",
+		cmd:       "prune funny-bunny -o json",
+		golden:    "output/prune-output-no-yes.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestOrphansPrinter(t *testing.T) {
+	orphans := []*action.Orphan{
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "kept", Kept: true},
+		{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default", Name: "orphan", Deleted: true},
+		{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "other", Name: "web"},
+	}
+	var buf bytes.Buffer
+	if err := (orphansPrinter{orphans: orphans}).WriteTable(&buf); err != nil {
+		t.Fatal(err)
+	}
+	test.AssertGoldenString(t, buf.String(), "output/prune-table.txt")
+
+	buf.Reset()
+	if err := (orphansPrinter{orphans: orphans[2:], dryRun: true}).WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	test.AssertGoldenString(t, buf.String(), "output/prune-dry-run.json")
+}
+
+func TestPruneFileCompletion(t *testing.T) {
+	checkFileCompletion(t, "prune", false)
+	checkFileCompletion(t, "prune myrelease", false)
+}
@@ -0,0 +1,100 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli/output"
+)
+
+const reconcileDesc = `
+This command re-applies the manifest of the deployed revision of a release to
+the cluster, which reverts the changes made to its objects outside of Helm and
+recreates the objects that were deleted. It does not need the chart or the
+values of the release, and does not run hooks. The result is recorded as a new
+revision of the release.
+
+Use 'helm status --drift' to see what would be reverted. With '--prune', the
+objects of other revisions of the release that are still in the cluster but no
+longer in the manifest, e.g. left over by a failed upgrade, are deleted too.
+`
+
+func newReconcileCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewReconcile(cfg)
+	var outfmt output.Format
+
+	cmd := &cobra.Command{
+		Use:   "reconcile RELEASE",
+		Short: "re-apply the deployed revision of a release",
+		Long:  reconcileDesc,
+		Args:  require.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			watchProgress(cfg)
+			ctx, cancel := interruptContext()
+			defer cancel()
+			result, err := client.RunWithContext(ctx, args[0])
+			if err != nil {
+				return err
+			}
+
+			if outfmt == output.Table {
+				writeReconcileResult(out, result, client.DryRun)
+				if !client.DryRun {
+					fmt.Fprintf(out, "Release %q has been reconciled. Happy Helming!\n", args[0])
+				}
+			}
+			return outfmt.Write(out, &statusPrinter{result.Release, settings.Debug, false})
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&client.Prune, "prune", false, "delete the objects of other revisions of the release that are no longer in its manifest")
+	f.BoolVar(&client.DryRun, "dry-run", false, "report the objects that would be re-applied without changing anything")
+	f.BoolVar(&client.Force, "force", false, "force resource updates through a replacement strategy. Cannot be used with --server-side, see --force-conflicts")
+	f.BoolVar(&client.Wait, "wait", false, "if set, will wait until all resources are in a ready state before marking the release as successful. It will wait for as long as --timeout")
+	f.BoolVar(&client.WaitForJobs, "wait-for-jobs", false, "if set and --wait enabled, will wait until all Jobs have been completed before marking the release as successful. It will wait for as long as --timeout")
+	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation")
+	addServerSideApplyFlags(f, &client.ServerSideApply, &client.ForceConflicts)
+	bindOutputFlag(cmd, &outfmt)
+
+	return cmd
+}
+
+func writeReconcileResult(out io.Writer, result *action.ReconcileResult, dryRun bool) {
+	verbs := []string{"Recreated", "Updated", "Pruned"}
+	if dryRun {
+		verbs = []string{"Would recreate", "Would update", "Would prune"}
+	}
+	for i, objects := range [][]string{result.Created, result.Updated, result.Pruned} {
+		for _, obj := range objects {
+			fmt.Fprintf(out, "%s %s\n", verbs[i], obj)
+		}
+	}
+}
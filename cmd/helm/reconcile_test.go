@@ -0,0 +1,60 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestReconcileCmd(t *testing.T) {
+	rels := func() []*release.Release {
+		return []*release.Release{
+			release.Mock(&release.MockReleaseOptions{Name: "funny-bunny", Version: 1, Status: release.StatusSuperseded}),
+			release.Mock(&release.MockReleaseOptions{Name: "funny-bunny", Version: 2}),
+		}
+	}
+	tests := []cmdTestCase{{
+		name:   "reconcile a release",
+		cmd:    "reconcile funny-bunny",
+		rels:   rels(),
+		golden: "output/reconcile.txt",
+	}, {
+		name:   "reconcile a release with a dry run",
+		cmd:    "reconcile funny-bunny --dry-run --prune",
+		rels:   rels(),
+		golden: "output/reconcile-dry-run.txt",
+	}, {
+		name:      "reconcile a release without a deployed revision",
+		cmd:       "reconcile funny-bunny",
+		rels:      []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "funny-bunny", Status: release.StatusFailed})},
+		golden:    "output/reconcile-not-deployed.txt",
+		wantError: true,
+	}, {
+		name:      "reconcile without a release",
+		cmd:       "reconcile",
+		golden:    "output/reconcile-no-args.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestReconcileFileCompletion(t *testing.T) {
+	checkFileCompletion(t, "reconcile", false)
+	checkFileCompletion(t, "reconcile myrelease", false)
+}
@@ -0,0 +1,70 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/pkg/action"
+)
+
+const releaseRotateKeysDesc = `
+This command re-encrypts the stored revisions of releases with the current key
+of HELM_DRIVER_ENCRYPTION. With no arguments, all the releases of the namespace
+are re-encrypted.
+
+To rotate a local key, add the new key as the first line of the key file, run
+this command, and then remove the old key. Revisions that were stored before
+encryption was enabled are encrypted as well.
+
+If a revision cannot be decrypted, e.g. because its key was already removed
+from the key file, the other revisions are still re-encrypted and the command
+fails with the number of revisions left behind.
+`
+
+func newReleaseRotateKeysCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewRotateKeys(cfg)
+
+	cmd := &cobra.Command{
+		Use:   "rotate-keys [RELEASE...]",
+		Short: "re-encrypt stored releases with the current key",
+		Long:  releaseRotateKeysDesc,
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			keyID, err := client.KeyID()
+			if err != nil {
+				return err
+			}
+			rotated, err := client.Run(args...)
+			for _, rls := range rotated {
+				fmt.Fprintf(out, "Re-encrypted release %q revision %d\n", rls.Name, rls.Version)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "%d revisions are encrypted with key %s\n", len(rotated), keyID)
+			return nil
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,36 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestReleaseRotateKeysCmd(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:      "rotate keys of unencrypted storage",
+		cmd:       "release rotate-keys",
+		golden:    "output/release-rotate-keys-not-encrypted.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestReleaseRotateKeysFileCompletion(t *testing.T) {
+	checkFileCompletion(t, "release rotate-keys", false)
+	checkFileCompletion(t, "release rotate-keys myrelease", false)
+}
@@ -0,0 +1,107 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+)
+
+const releaseTransferDesc = `
+This command moves resources from a release to another release, e.g. when
+splitting a chart into several charts:
+
+    $ helm release transfer monolith frontend --resource Deployment/web --resource Service/web
+
+The resources are removed from the manifest of the deployed revision of the
+release and added to the manifest of the target release, and a new revision of
+both releases is stored. If the target release does not exist, it is created
+with the chart and values of the release, and can then be upgraded with its own
+chart. The ownership labels and annotations of the live resources are rewritten
+to name the target release.
+
+Without '--resource', the whole history of the release is moved, which renames
+the release or, with '--target-namespace', moves it to another namespace. The
+resources of the release stay where they are, so moving to another namespace is
+refused if a namespaced resource does not set metadata.namespace.
+
+Both releases are locked during the transfer, and if any step fails the stored
+revisions and the ownership metadata are restored.
+`
+
+func newReleaseTransferCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewTransfer(cfg)
+
+	cmd := &cobra.Command{
+		Use:   "transfer RELEASE TARGET",
+		Short: "move resources or a whole release to another release",
+		Long:  releaseTransferDesc,
+		Args:  require.ExactArgs(2),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if client.TargetNamespace != "" {
+				target := new(action.Configuration)
+				if err := target.Init(settings.RESTClientGetter(), client.TargetNamespace, os.Getenv("HELM_DRIVER"), debug); err != nil {
+					return errors.Wrap(err, "target storage")
+				}
+				client.Target = target.Releases
+			}
+
+			result, err := client.Run(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			verb := "Transferred"
+			if client.DryRun {
+				verb = "Would transfer"
+			}
+			for _, r := range result.Resources {
+				fmt.Fprintf(out, "%s %s\n", verb, r)
+			}
+			for _, r := range result.Missing {
+				fmt.Fprintf(out, "Not found in the cluster: %s\n", r)
+			}
+			verb = "Stored"
+			if client.DryRun {
+				verb = "Would store"
+			}
+			for _, rls := range result.Revisions {
+				fmt.Fprintf(out, "%s release %q revision %d in namespace %q\n", verb, rls.Name, rls.Version, rls.Namespace)
+			}
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringArrayVar(&client.Resources, "resource", nil, "a resource to move, as KIND/NAME. Can be specified multiple times. If not set, the whole release is moved")
+	f.StringVar(&client.TargetNamespace, "target-namespace", "", "the namespace of the target release. Defaults to the namespace of the release")
+	f.BoolVar(&client.DryRun, "dry-run", false, "report what would be moved without changing anything")
+
+	return cmd
+}
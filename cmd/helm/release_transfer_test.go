@@ -0,0 +1,65 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestReleaseTransferCmd(t *testing.T) {
+	rels := func() []*release.Release {
+		return []*release.Release{
+			release.Mock(&release.MockReleaseOptions{Name: "monolith", Version: 1, Status: release.StatusSuperseded}),
+			release.Mock(&release.MockReleaseOptions{Name: "monolith", Version: 2}),
+		}
+	}
+	tests := []cmdTestCase{{
+		name:   "transfer a release",
+		cmd:    "release transfer monolith renamed",
+		rels:   rels(),
+		golden: "output/release-transfer.txt",
+	}, {
+		name:   "transfer resources",
+		cmd:    "release transfer monolith frontend --resource Secret/fixture",
+		rels:   rels(),
+		golden: "output/release-transfer-resources.txt",
+	}, {
+		name:   "transfer resources dry run",
+		cmd:    "release transfer monolith frontend --resource Secret/fixture --dry-run",
+		rels:   rels(),
+		golden: "output/release-transfer-dry-run.txt",
+	}, {
+		name:      "transfer unknown resource",
+		cmd:       "release transfer monolith frontend --resource Secret/other",
+		rels:      rels(),
+		golden:    "output/release-transfer-not-found.txt",
+		wantError: true,
+	}, {
+		name:      "transfer without target",
+		cmd:       "release transfer monolith",
+		golden:    "output/release-transfer-no-args.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestReleaseTransferFileCompletion(t *testing.T) {
+	checkFileCompletion(t, "release transfer", false)
+	checkFileCompletion(t, "release transfer myrelease", false)
+}
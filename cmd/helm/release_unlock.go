@@ -0,0 +1,83 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+)
+
+const releaseUnlockDesc = `
+This command recovers a release whose latest revision was left in a pending
+state (pending-install, pending-upgrade or pending-rollback), for example
+because helm was interrupted. Such a release blocks further operations with
+"another operation is in progress".
+
+The pending revision is marked as failed. Running operations regularly record
+a heartbeat, and a release is only unlocked once its heartbeat is older than
+'--stale-after', so that an operation that is still running is not disturbed.
+Use '--force' to unlock the release regardless.
+
+With '--rollback', the release is then rolled back to its last successfully
+deployed revision.
+`
+
+func newReleaseUnlockCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewRecover(cfg)
+
+	cmd := &cobra.Command{
+		Use:   "unlock RELEASE",
+		Short: "recover a release stuck in a pending state",
+		Long:  releaseUnlockDesc,
+		Args:  require.ExactArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rel, err := client.Run(args[0])
+			if rel != nil {
+				fmt.Fprintf(out, "Release %q has been unlocked: revision %d was marked as failed\n", rel.Name, rel.Version)
+			}
+			if err != nil {
+				return err
+			}
+			if client.Rollback {
+				fmt.Fprintf(out, "Release %q has been rolled back to its last successful revision\n", rel.Name)
+			}
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.DurationVar(&client.StaleAfter, "stale-after", action.DefaultRecoverStaleAfter, "only unlock the release if its pending operation has not recorded a heartbeat for this long")
+	f.BoolVar(&client.Force, "force", false, "unlock the release even if its pending operation may still be running")
+	f.BoolVar(&client.Rollback, "rollback", false, "roll back to the last successfully deployed revision after unlocking")
+	f.BoolVar(&client.Wait, "wait", false, "if set with --rollback, will wait until all resources of the rollback are in a ready state. It will wait for as long as --timeout")
+	f.BoolVar(&client.WaitForJobs, "wait-for-jobs", false, "if set and --wait enabled, will wait until all Jobs have been completed. It will wait for as long as --timeout")
+	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation of the rollback")
+
+	return cmd
+}
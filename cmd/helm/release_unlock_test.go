@@ -0,0 +1,75 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestReleaseUnlockCmd(t *testing.T) {
+	// The releases are mutated by the command, so each test gets its own.
+	rels := func(statuses ...release.Status) []*release.Release {
+		var rels []*release.Release
+		for i, status := range statuses {
+			rels = append(rels, &release.Release{
+				Name:    "funny-honey",
+				Info:    &release.Info{Status: status},
+				Chart:   &chart.Chart{},
+				Version: i + 1,
+			})
+		}
+		return rels
+	}
+
+	tests := []cmdTestCase{{
+		name:   "unlock a stale release",
+		cmd:    "release unlock funny-honey --stale-after 0s",
+		golden: "output/release-unlock.txt",
+		rels:   rels(release.StatusDeployed, release.StatusPendingUpgrade),
+	}, {
+		name:   "unlock and roll back a stale release",
+		cmd:    "release unlock funny-honey --stale-after 0s --rollback",
+		golden: "output/release-unlock-rollback.txt",
+		rels:   rels(release.StatusDeployed, release.StatusPendingUpgrade),
+	}, {
+		name:      "unlock a release that may still be running",
+		cmd:       "release unlock funny-honey",
+		golden:    "output/release-unlock-live.txt",
+		rels:      rels(release.StatusDeployed, release.StatusPendingUpgrade),
+		wantError: true,
+	}, {
+		name:      "unlock a release that is not pending",
+		cmd:       "release unlock funny-honey",
+		golden:    "output/release-unlock-not-pending.txt",
+		rels:      rels(release.StatusDeployed),
+		wantError: true,
+	}, {
+		name:      "unlock without release name",
+		cmd:       "release unlock",
+		golden:    "output/release-unlock-no-args.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestReleaseUnlockFileCompletion(t *testing.T) {
+	checkFileCompletion(t, "release unlock", false)
+	checkFileCompletion(t, "release unlock myrelease", false)
+}
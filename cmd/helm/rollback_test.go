@@ -64,6 +64,12 @@ func TestRollbackCmd(t *testing.T) {
 		cmd:    "rollback funny-honey",
 		golden: "output/rollback-no-revision.txt",
 		rels:   rels,
+	}, {
+		name:      "rollback a release with force and server-side apply",
+		cmd:       "rollback funny-honey 1 --force --server-side",
+		golden:    "output/rollback-force-server-side.txt",
+		rels:      rels,
+		wantError: true,
 	}, {
 		name:      "rollback a release without release name",
 		cmd:       "rollback",
@@ -51,7 +51,10 @@ Environment variables:
 | $HELM_CONFIG_HOME                  | set an alternative location for storing Helm configuration.                       |
 | $HELM_DATA_HOME                    | set an alternative location for storing Helm data.                                |
 | $HELM_DEBUG                        | indicate whether or not Helm is running in Debug mode                             |
-| $HELM_DRIVER                       | set the backend storage driver. Values are: configmap, secret, memory, postgres   |
+| $HELM_DRIVER                       | set the backend storage driver. Values are: configmap, secret, memory, sql, file  |
+| $HELM_DRIVER_COMPRESSION           | set the compression of releases in configmap, secret and file storage: gzip, zstd |
+| $HELM_DRIVER_ENCRYPTION            | encrypt stored releases with keys from file:, pgp:, age:<path> or exec:<command>  |
+| $HELM_DRIVER_FILE_PATH             | set the directory of the file storage driver, default $HELM_DATA_HOME/releases    |
 | $HELM_DRIVER_SQL_CONNECTION_STRING | set the connection string the SQL storage driver should use.                      |
 | $HELM_MAX_HISTORY                  | set the maximum number of helm release history.                                   |
 | $HELM_NAMESPACE                    | set the namespace used for the helm operations.                                   |
@@ -173,17 +176,24 @@ func newRootCmd(actionConfig *action.Configuration, out io.Writer, args []string
 		newLintCmd(out),
 		newPackageCmd(out),
 		newRepoCmd(out),
+		newSchemaCmd(out),
 		newSearchCmd(out),
 		newVerifyCmd(out),
 
 		// release commands
+		newAdoptCmd(actionConfig, out),
+		newDiffCmd(actionConfig, out),
 		newGetCmd(actionConfig, out),
 		newHistoryCmd(actionConfig, out),
 		newInstallCmd(actionConfig, out),
 		newListCmd(actionConfig, out),
+		newPruneCmd(actionConfig, out),
+		newReconcileCmd(actionConfig, out),
+		newReleaseCmd(actionConfig, out),
 		newReleaseTestCmd(actionConfig, out),
 		newRollbackCmd(actionConfig, out),
 		newStatusCmd(actionConfig, out),
+		newStorageCmd(actionConfig, out),
 		newTemplateCmd(actionConfig, out),
 		newUninstallCmd(actionConfig, out),
 		newUpgradeCmd(actionConfig, out),
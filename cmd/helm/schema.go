@@ -0,0 +1,127 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+const schemaHelp = `
+This command consists of multiple subcommands to work with the values schema
+of a chart.
+`
+
+const schemaGenerateHelp = `
+This command infers a JSON schema for the values of a chart from its values.yaml.
+
+The type of each value is taken from values.yaml. The comment above a value, or
+at the end of its line, becomes its description, and may carry annotations:
+
+    # Type of the service.
+    # @enum: [ClusterIP, NodePort, LoadBalancer]
+    type: ClusterIP
+
+    # @type: [string, "null"]
+    # @required
+    host: ~
+
+If the chart already has a values.schema.json, the inferred schema is merged
+with it, keeping everything written by hand. The result is printed, or written
+to the chart with '--write'.
+`
+
+func newSchemaCmd(out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "work with the values schema of a chart",
+		Long:  schemaHelp,
+		Args:  require.NoArgs,
+	}
+
+	cmd.AddCommand(
+		newSchemaGenerateCmd(out),
+	)
+
+	return cmd
+}
+
+func newSchemaGenerateCmd(out io.Writer) *cobra.Command {
+	var write bool
+
+	cmd := &cobra.Command{
+		Use:   "generate CHART",
+		Short: "generate a values schema from values.yaml",
+		Long:  schemaGenerateHelp,
+		Args:  require.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := args[0]
+			if write {
+				if fi, err := os.Stat(path); err != nil {
+					return err
+				} else if !fi.IsDir() {
+					return errors.New("--write requires an unpacked chart directory")
+				}
+			}
+
+			ch, err := loader.Load(path)
+			if err != nil {
+				return err
+			}
+			schema, err := chartutil.GenerateSchema(chartValuesFile(ch), ch.Schema)
+			if err != nil {
+				return err
+			}
+
+			if !write {
+				_, err := out.Write(schema)
+				return err
+			}
+			dest := filepath.Join(path, chartutil.SchemafileName)
+			if err := ioutil.WriteFile(dest, schema, 0644); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Wrote %s\n", dest)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&write, "write", false, "write the schema to values.schema.json in the chart directory instead of printing it")
+
+	return cmd
+}
+
+// chartValuesFile returns the contents of the values.yaml file of a chart.
+func chartValuesFile(ch *chart.Chart) []byte {
+	for _, f := range ch.Raw {
+		if f.Name == chartutil.ValuesfileName {
+			return f.Data
+		}
+	}
+	return nil
+}
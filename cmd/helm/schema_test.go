@@ -0,0 +1,94 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"helm.sh/helm/v3/internal/test"
+	"helm.sh/helm/v3/internal/test/ensure"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestSchemaGenerateCmd(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:   "generate a schema merged with the existing one",
+		cmd:    "schema generate testdata/testcharts/chart-with-value-docs",
+		golden: "output/schema-generate.txt",
+	}, {
+		name:   "generate a schema for a chart without values",
+		cmd:    "schema generate testdata/testcharts/chart-with-only-crds",
+		golden: "output/schema-generate-no-values.txt",
+	}, {
+		name:      "write requires a chart directory",
+		cmd:       "schema generate testdata/testcharts/compressedchart-0.1.0.tgz --write",
+		golden:    "output/schema-generate-write-archive.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestSchemaGenerateCmdWrite(t *testing.T) {
+	dir := ensure.TempDir(t)
+	defer os.RemoveAll(dir)
+
+	src := "testdata/testcharts/chart-with-value-docs"
+	for _, name := range []string{"Chart.yaml", "values.yaml", chartutil.SchemafileName} {
+		data, err := ioutil.ReadFile(filepath.Join(src, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, out, err := executeActionCommand("schema generate --write " + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(dir, chartutil.SchemafileName)
+	if out != "Wrote "+dest+"\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+	schema, err := ioutil.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.AssertGoldenString(t, string(schema), "output/schema-generate.txt")
+}
+
+func TestDocsValuesCmd(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:   "markdown reference",
+		cmd:    "docs values testdata/testcharts/chart-with-value-docs",
+		golden: "output/docs-values.txt",
+	}, {
+		name:   "html reference",
+		cmd:    "docs values testdata/testcharts/chart-with-value-docs --format html",
+		golden: "output/docs-values-html.txt",
+	}, {
+		name:      "unknown format",
+		cmd:       "docs values testdata/testcharts/chart-with-value-docs --format pdf",
+		golden:    "output/docs-values-unknown-format.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
@@ -17,12 +17,14 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"strings"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"helm.sh/helm/v3/cmd/helm/require"
@@ -44,11 +46,25 @@ The status consists of:
 - list of resources that this release consists of, sorted by kind
 - details on last test suite run, if applicable
 - additional notes provided by the chart
+
+With '--drift', the command instead compares the live objects of the deployed
+revision of the release with its manifest, and reports the fields that were
+changed outside of Helm, e.g. by 'kubectl edit'. Only the fields the manifest
+sets are compared, so fields defaulted by the API server are ignored, and so are
+the fields owned by the Kubernetes controllers, such as the replicas of a
+Deployment scaled by a HorizontalPodAutoscaler. The command exits with status 2
+when drift is found, which makes it usable as a check in CI.
 `
 
+// errDriftDetected is returned by 'helm status --drift' when the release
+// drifted, to exit with status 2.
+var errDriftDetected = exitCodeError{errors.New("drift detected"), 2}
+
 func newStatusCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	client := action.NewStatus(cfg)
+	drift := action.NewDrift(cfg)
 	var outfmt output.Format
+	var detectDrift bool
 
 	cmd := &cobra.Command{
 		Use:   "status RELEASE_NAME",
@@ -62,6 +78,23 @@ func newStatusCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			return compListReleases(toComplete, args, cfg)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if detectDrift {
+				if client.Version > 0 {
+					return errors.New("--revision cannot be used with --drift")
+				}
+				res, err := drift.Run(args[0])
+				if err != nil {
+					return err
+				}
+				if err := outfmt.Write(out, &driftPrinter{res}); err != nil {
+					return err
+				}
+				if res.HasDrift() {
+					return silenceExitCode(cmd, errDriftDetected)
+				}
+				return nil
+			}
+
 			rel, err := client.Run(args[0])
 			if err != nil {
 				return err
@@ -91,6 +124,8 @@ func newStatusCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 
 	bindOutputFlag(cmd, &outfmt)
 	f.BoolVar(&client.ShowDescription, "show-desc", false, "if set, display the description message of the named release")
+	f.BoolVar(&detectDrift, "drift", false, "report the changes made to the live objects of the release outside of Helm, and exit with status 2 if there are any")
+	f.BoolVar(&drift.ShowSecrets, "show-secrets", false, "with --drift, do not redact the data of Secrets in the output")
 
 	return cmd
 }
@@ -102,11 +137,22 @@ type statusPrinter struct {
 }
 
 func (s statusPrinter) WriteJSON(out io.Writer) error {
-	return output.EncodeJSON(out, s.release)
+	return output.EncodeJSON(out, redactedRelease(s.release))
 }
 
 func (s statusPrinter) WriteYAML(out io.Writer) error {
-	return output.EncodeYAML(out, s.release)
+	return output.EncodeYAML(out, redactedRelease(s.release))
+}
+
+// redactedRelease returns a copy of rel whose sensitive values are redacted,
+// or rel itself if it has none.
+func redactedRelease(rel *release.Release) *release.Release {
+	if rel == nil || len(rel.SensitiveValues) == 0 {
+		return rel
+	}
+	redacted := *rel
+	redacted.Config = chartutil.RedactValues(rel.Config, rel.SensitiveValues)
+	return &redacted
 }
 
 func (s statusPrinter) WriteTable(out io.Writer) error {
@@ -144,7 +190,7 @@ func (s statusPrinter) WriteTable(out io.Writer) error {
 
 	if s.debug {
 		fmt.Fprintln(out, "USER-SUPPLIED VALUES:")
-		err := output.EncodeYAML(out, s.release.Config)
+		err := output.EncodeYAML(out, chartutil.RedactValues(s.release.Config, s.release.SensitiveValues))
 		if err != nil {
 			return err
 		}
@@ -157,7 +203,7 @@ func (s statusPrinter) WriteTable(out io.Writer) error {
 		}
 
 		fmt.Fprintln(out, "COMPUTED VALUES:")
-		err = output.EncodeYAML(out, cfg.AsMap())
+		err = output.EncodeYAML(out, chartutil.RedactValues(cfg, s.release.SensitiveValues))
 		if err != nil {
 			return err
 		}
@@ -179,6 +225,47 @@ func (s statusPrinter) WriteTable(out io.Writer) error {
 	return nil
 }
 
+type driftPrinter struct {
+	result *action.DriftResult
+}
+
+func (p driftPrinter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, p.result)
+}
+
+func (p driftPrinter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, p.result)
+}
+
+func (p driftPrinter) WriteTable(out io.Writer) error {
+	if !p.result.HasDrift() {
+		fmt.Fprintf(out, "No drift detected for release %q revision %d\n", p.result.Release, p.result.Revision)
+		return nil
+	}
+	for _, r := range p.result.Resources {
+		id := r.Name
+		if r.Namespace != "" {
+			id = r.Namespace + "/" + r.Name
+		}
+		if r.Missing {
+			fmt.Fprintf(out, "%s %s (%s): not found in the cluster\n", r.Kind, id, r.APIVersion)
+			continue
+		}
+		fmt.Fprintf(out, "%s %s (%s):\n", r.Kind, id, r.APIVersion)
+		for _, f := range r.Fields {
+			expected, _ := json.Marshal(f.Expected)
+			if f.Missing {
+				fmt.Fprintf(out, "  %s: expected %s, not set\n", f.Path, expected)
+				continue
+			}
+			actual, _ := json.Marshal(f.Actual)
+			fmt.Fprintf(out, "  %s: expected %s, found %s\n", f.Path, expected, actual)
+		}
+	}
+	fmt.Fprintf(out, "Release %q revision %d: %d resource(s) drifted\n", p.result.Release, p.result.Revision, len(p.result.Resources))
+	return nil
+}
+
 func executionsByHookEvent(rel *release.Release) map[release.HookEvent][]*release.Hook {
 	result := make(map[release.HookEvent][]*release.Hook)
 	for _, h := range rel.Hooks {
@@ -17,9 +17,12 @@ limitations under the License.
 package main
 
 import (
+	"bytes"
 	"testing"
 	"time"
 
+	"helm.sh/helm/v3/internal/test"
+	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/release"
 	helmtime "helm.sh/helm/v3/pkg/time"
@@ -108,10 +111,59 @@ func TestStatusCmd(t *testing.T) {
 				},
 			},
 		),
+	}, {
+		name:   "get drift of a release",
+		cmd:    "status --drift flummoxed-chickadee",
+		golden: "output/status-drift.txt",
+		rels:   []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "flummoxed-chickadee"})},
+	}, {
+		name:   "get drift of a release in JSON",
+		cmd:    "status --drift flummoxed-chickadee -o json",
+		golden: "output/status-drift.json",
+		rels:   []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "flummoxed-chickadee"})},
+	}, {
+		name:      "get drift of a revision",
+		cmd:       "status --drift --revision 1 flummoxed-chickadee",
+		golden:    "output/status-drift-revision.txt",
+		wantError: true,
+	}, {
+		name:   "get status of a release with sensitive values in YAML",
+		cmd:    "status flummoxed-chickadee -o yaml",
+		golden: "output/status-sensitive.yaml",
+		rels:   []*release.Release{sensitiveRelease("flummoxed-chickadee")},
 	}}
 	runTestCmd(t, tests)
 }
 
+func TestDriftPrinter(t *testing.T) {
+	res := &action.DriftResult{
+		Release:   "web",
+		Namespace: "default",
+		Revision:  3,
+		Resources: []action.ResourceDrift{{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Namespace:  "default",
+			Name:       "web",
+			Fields: []action.FieldDrift{
+				{Path: "spec.template.spec.containers[0].image", Expected: "nginx:1.19", Actual: "nginx:1.20"},
+				{Path: "spec.paused", Expected: false, Missing: true},
+			},
+		}, {
+			APIVersion: "v1",
+			Kind:       "Service",
+			Namespace:  "default",
+			Name:       "web",
+			Missing:    true,
+		}},
+	}
+	var buf bytes.Buffer
+	if err := (driftPrinter{res}).WriteTable(&buf); err != nil {
+		t.Fatal(err)
+	}
+	test.AssertGoldenString(t, buf.String(), "output/status-drift-table.txt")
+}
+
 func mustParseTime(t string) helmtime.Time {
 	res, _ := helmtime.Parse(time.RFC3339, t)
 	return res
@@ -200,3 +252,9 @@ func TestStatusFileCompletion(t *testing.T) {
 	checkFileCompletion(t, "status", false)
 	checkFileCompletion(t, "status myrelease", false)
 }
+
+func sensitiveRelease(name string) *release.Release {
+	rel := release.Mock(&release.MockReleaseOptions{Name: name})
+	rel.SensitiveValues = []string{"/name"}
+	return rel
+}
@@ -0,0 +1,171 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/gosuri/uitable"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli/output"
+	"helm.sh/helm/v3/pkg/helmpath"
+)
+
+const storageMigrateDesc = `
+This command copies every revision of every release of the namespace from one
+storage driver to another, e.g. from configmap to sql:
+
+    $ helm storage migrate --from configmap --to sql
+
+The drivers are configured by the same environment variables as HELM_DRIVER,
+e.g. HELM_DRIVER_SQL_CONNECTION_STRING for sql. '--from' defaults to the
+driver of HELM_DRIVER.
+
+Once copied, every revision is read back from the target storage and compared
+with the source by its SHA-256 digest, and the history of every release is
+queried to verify the labels of the revisions. Revisions that already are in
+the target storage are skipped if they are identical, so an interrupted
+migration can be run again. With '--delete-source', the revisions of each
+release are deleted from the source storage once they have been verified.
+
+The target driver must use another storage than the source driver: secret and
+configmap are different storages, but two sql or file drivers configured by
+the same environment variables are not.
+`
+
+func newStorageMigrateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewStorageMigrate(cfg)
+	var from, to string
+
+	cmd := &cobra.Command{
+		Use:               "migrate --to DRIVER",
+		Short:             "copy the releases to another storage driver",
+		Long:              storageMigrateDesc,
+		Args:              require.NoArgs,
+		ValidArgsFunction: noCompletions,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if to == "" {
+				return errors.New("the target driver is required: set --to")
+			}
+			source := from
+			if source == "" {
+				source = os.Getenv("HELM_DRIVER")
+			}
+			sourceBackend, targetBackend := storageBackend(source), storageBackend(to)
+			if sourceBackend != "" && sourceBackend == targetBackend {
+				return errors.Errorf("the target driver %q uses the same storage as the source driver %q", to, source)
+			}
+			if from != "" {
+				if err := cfg.Init(settings.RESTClientGetter(), settings.Namespace(), from, debug); err != nil {
+					return errors.Wrap(err, "source storage")
+				}
+			}
+			target := new(action.Configuration)
+			if err := target.Init(settings.RESTClientGetter(), settings.Namespace(), to, debug); err != nil {
+				return errors.Wrap(err, "target storage")
+			}
+			client.Target = target.Releases
+			if isKubernetesBackend(sourceBackend) && isKubernetesBackend(targetBackend) {
+				// Both drivers lock releases with the Leases of the same
+				// namespace, which must only be acquired once.
+				client.Target.Locker = cfg.Releases.Locker
+			}
+
+			migrated, err := client.Run()
+			if len(migrated) > 0 {
+				if err := writeMigratedRevisions(out, migrated, client.DryRun, "copied", "would copy"); err != nil {
+					return err
+				}
+			}
+			if err != nil {
+				return err
+			}
+			switch {
+			case len(migrated) == 0:
+				fmt.Fprintln(out, "No releases to migrate")
+			case client.DryRun:
+				fmt.Fprintf(out, "Dry run: %d revisions would be migrated\n", len(migrated))
+			default:
+				fmt.Fprintf(out, "Migrated and verified %d revisions\n", len(migrated))
+			}
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&from, "from", "", "the storage driver to copy the releases from. Defaults to HELM_DRIVER")
+	f.StringVar(&to, "to", "", "the storage driver to copy the releases to: configmap, secret, sql or file")
+	f.BoolVar(&client.DryRun, "dry-run", false, "report the revisions that would be migrated without copying them")
+	f.BoolVar(&client.DeleteSource, "delete-source", false, "delete the revisions from the source storage once they have been copied and verified")
+
+	return cmd
+}
+
+// storageBackend identifies the storage the named driver keeps releases in,
+// with the configuration from the environment. It is empty for the memory
+// driver, whose storage is never shared between configurations.
+func storageBackend(helmDriver string) string {
+	switch helmDriver {
+	case "secret", "secrets", "":
+		return "secret"
+	case "configmap", "configmaps":
+		return "configmap"
+	case "sql":
+		return "sql:" + os.Getenv("HELM_DRIVER_SQL_CONNECTION_STRING")
+	case "file":
+		dir := os.Getenv("HELM_DRIVER_FILE_PATH")
+		if dir == "" {
+			dir = helmpath.DataPath("releases")
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		return "file:" + dir
+	}
+	return ""
+}
+
+// isKubernetesBackend reports whether backend keeps releases in Kubernetes
+// objects, which are locked with Leases.
+func isKubernetesBackend(backend string) bool {
+	return backend == "secret" || backend == "configmap"
+}
+
+// writeMigratedRevisions writes a table of the revisions, whose status is
+// done, or pending in a dry run.
+func writeMigratedRevisions(out io.Writer, migrated []*action.MigratedRevision, dryRun bool, done, pending string) error {
+	tbl := uitable.New()
+	tbl.AddRow("NAME", "NAMESPACE", "REVISION", "DIGEST", "STATUS")
+	for _, rev := range migrated {
+		status := done
+		switch {
+		case rev.Existing:
+			status = "already present"
+		case dryRun:
+			status = pending
+		}
+		tbl.AddRow(rev.Name, rev.Namespace, rev.Version, rev.Digest, status)
+	}
+	return output.EncodeTable(out, tbl)
+}
@@ -0,0 +1,116 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"os"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func TestStorageMigrateCmd(t *testing.T) {
+	rels := []*release.Release{
+		release.Mock(&release.MockReleaseOptions{Name: "angry-panda", Version: 1, Status: release.StatusSuperseded}),
+		release.Mock(&release.MockReleaseOptions{Name: "angry-panda", Version: 2}),
+		release.Mock(&release.MockReleaseOptions{Name: "happy-bunny", Version: 1}),
+	}
+
+	tests := []cmdTestCase{{
+		name:   "migrate releases",
+		cmd:    "storage migrate --to memory",
+		golden: "output/storage-migrate.txt",
+		rels:   rels,
+	}, {
+		name:   "migrate releases with dry run",
+		cmd:    "storage migrate --to memory --dry-run",
+		golden: "output/storage-migrate-dry-run.txt",
+		rels:   rels,
+	}, {
+		name:   "migrate no releases",
+		cmd:    "storage migrate --to memory",
+		golden: "output/storage-migrate-empty.txt",
+	}, {
+		name:      "migrate without target",
+		cmd:       "storage migrate",
+		golden:    "output/storage-migrate-no-target.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestStorageMigrateCmdToFile(t *testing.T) {
+	defer resetEnv()()
+	dir := t.TempDir()
+	os.Setenv("HELM_DRIVER_FILE_PATH", dir)
+
+	store := storageFixture()
+	for _, rls := range []*release.Release{
+		release.Mock(&release.MockReleaseOptions{Name: "angry-panda", Version: 1, Status: release.StatusSuperseded}),
+		release.Mock(&release.MockReleaseOptions{Name: "angry-panda", Version: 2}),
+	} {
+		if err := store.Create(rls); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := executeActionCommandC(store, "storage migrate --to file --delete-source"); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := driver.NewFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.SetNamespace("default")
+	history, err := f.Query(map[string]string{"name": "angry-panda", "owner": "helm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 {
+		t.Errorf("expected 2 revisions in the file storage, got %d", len(history))
+	}
+	if source, _ := store.ListReleases(); len(source) != 0 {
+		t.Errorf("expected the source revisions to be deleted, got %d", len(source))
+	}
+}
+
+func TestStorageMigrateCmdSameStorage(t *testing.T) {
+	defer resetEnv()()
+	os.Setenv("HELM_DRIVER", "secret")
+
+	rels := []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "angry-panda"})}
+	tests := []cmdTestCase{{
+		name:      "migrate to the driver of HELM_DRIVER",
+		cmd:       "storage migrate --to secrets --delete-source",
+		golden:    "output/storage-migrate-same-storage.txt",
+		rels:      rels,
+		wantError: true,
+	}, {
+		name:      "migrate between the same drivers",
+		cmd:       "storage migrate --from configmaps --to configmap --delete-source",
+		golden:    "output/storage-migrate-same-storage-from.txt",
+		rels:      rels,
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestStorageMigrateFileCompletion(t *testing.T) {
+	checkFileCompletion(t, "storage", false)
+	checkFileCompletion(t, "storage migrate", false)
+}
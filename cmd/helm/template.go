@@ -33,8 +33,10 @@ import (
 
 	"helm.sh/helm/v3/cmd/helm/require"
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
 	"helm.sh/helm/v3/pkg/releaseutil"
 )
 
@@ -44,12 +46,18 @@ Render chart templates locally and display the output.
 Any values that would normally be looked up or retrieved in-cluster will be
 faked locally. Additionally, none of the server-side testing of chart validity
 (e.g. whether an API is supported) is done.
+
+With '--explain-values', the chart is not rendered. Instead, the values it would
+be rendered with are shown, each annotated with where it came from: the values
+file or flag that supplied it, a chart or subchart default, a global propagated
+from a parent chart, a value imported from a subchart, or a schema default.
 `
 
 func newTemplateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	var validate bool
 	var includeCrds bool
 	var skipTests bool
+	var explainValues bool
 	client := action.NewInstall(cfg)
 	valueOpts := &values.Options{}
 	var extraAPIs []string
@@ -70,6 +78,9 @@ func newTemplateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			client.ClientOnly = !validate
 			client.APIVersions = chartutil.VersionSet(extraAPIs)
 			client.IncludeCRDs = includeCrds
+			if explainValues {
+				return runExplainValues(args, client, valueOpts, out)
+			}
 			rel, err := runInstall(args, client, valueOpts, out)
 
 			if err != nil && !settings.Debug {
@@ -170,6 +181,7 @@ func newTemplateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.BoolVar(&validate, "validate", false, "validate your manifests against the Kubernetes cluster you are currently pointing at. This is the same validation performed on an install")
 	f.BoolVar(&includeCrds, "include-crds", false, "include CRDs in the templated output")
 	f.BoolVar(&skipTests, "skip-tests", false, "skip tests from templated output")
+	f.BoolVar(&explainValues, "explain-values", false, "instead of rendering the chart, show the values it would be rendered with, annotated with where each of them came from")
 	f.BoolVar(&client.IsUpgrade, "is-upgrade", false, "set .Release.IsUpgrade instead of .Release.IsInstall")
 	f.StringArrayVarP(&extraAPIs, "api-versions", "a", []string{}, "Kubernetes api versions used for Capabilities.APIVersions")
 	f.BoolVar(&client.UseReleaseName, "release-name", false, "use release name in the output-dir path.")
@@ -178,6 +190,36 @@ func newTemplateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	return cmd
 }
 
+// runExplainValues shows the values a chart would be rendered with, and where
+// each of them came from.
+func runExplainValues(args []string, client *action.Install, valueOpts *values.Options, out io.Writer) error {
+	if client.Version == "" && client.Devel {
+		client.Version = ">0.0.0-0"
+	}
+	_, chart, err := client.NameAndChart(args)
+	if err != nil {
+		return err
+	}
+	cp, err := client.ChartPathOptions.LocateChart(chart, settings)
+	if err != nil {
+		return err
+	}
+	vals, sources, sensitive, err := valueOpts.MergeValuesWithSources(getter.ValuesProviders(settings))
+	if err != nil {
+		return err
+	}
+	ch, err := loader.Load(cp)
+	if err != nil {
+		return err
+	}
+	explained, origins, err := chartutil.ExplainValues(ch, vals, sources)
+	if err != nil {
+		return err
+	}
+	redacted := chartutil.RedactValues(explained, sensitive)
+	return explainedValuesWriter{redacted, origins}.WriteTable(out)
+}
+
 func isTestHook(h *release.Hook) bool {
 	for _, e := range h.Events {
 		if e == release.HookTest {
@@ -36,11 +36,26 @@ func TestTemplateCmd(t *testing.T) {
 			cmd:    fmt.Sprintf("template '%s' --set service.name=apache", chartPath),
 			golden: "output/template-set.txt",
 		},
+		{
+			name:   "check set-json name",
+			cmd:    fmt.Sprintf(`template '%s' --set-json 'service={"name":"apache"}'`, chartPath),
+			golden: "output/template-set.txt",
+		},
 		{
 			name:   "check values files",
 			cmd:    fmt.Sprintf("template '%s' --values '%s'", chartPath, filepath.Join(chartPath, "/charts/subchartA/values.yaml")),
 			golden: "output/template-values-files.txt",
 		},
+		{
+			name:   "explain values",
+			cmd:    fmt.Sprintf("template '%s' --set service.name=apache --values '%s' --explain-values", chartPath, filepath.Join(chartPath, "/charts/subchartA/values.yaml")),
+			golden: "output/template-explain-values.txt",
+		},
+		{
+			name:   "explain values with an encrypted values file",
+			cmd:    "template testdata/testcharts/alpine -f testdata/testcharts/alpine/secret_values.sops.yaml --values-keyring testdata/helm-test-key.secret --explain-values",
+			golden: "output/template-explain-values-sensitive.txt",
+		},
 		{
 			name:   "check name template",
 			cmd:    fmt.Sprintf(`template '%s' --name-template='foobar-{{ b64enc "abc" }}-baz'`, chartPath),
@@ -121,6 +136,23 @@ func TestTemplateCmd(t *testing.T) {
 			wantError: true,
 			golden:    "output/template-with-invalid-yaml-debug.txt",
 		},
+		{
+			name:   "template with built-in post-renderers",
+			cmd:    fmt.Sprintf("template '%s' --show-only templates/service.yaml --post-renderer builtin:labels:team=payments --post-renderer builtin:patch:testdata/postrender/service-patch.yaml", chartPath),
+			golden: "output/template-post-renderers.txt",
+		},
+		{
+			name:      "template with post-renderer patch target not found",
+			cmd:       fmt.Sprintf("template '%s' --post-renderer builtin:json-patch:testdata/postrender/missing-patch.yaml", chartPath),
+			wantError: true,
+			golden:    "output/template-post-renderer-error.txt",
+		},
+		{
+			name:      "template with invalid built-in post-renderer",
+			cmd:       fmt.Sprintf("template '%s' --post-renderer builtin:labels:team", chartPath),
+			wantError: true,
+			golden:    "output/template-post-renderer-invalid.txt",
+		},
 		{
 			name:   "template skip-tests",
 			cmd:    fmt.Sprintf(`template '%s' --skip-tests`, chartPath),
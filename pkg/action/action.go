@@ -36,6 +36,7 @@ import (
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/helmpath"
 	"helm.sh/helm/v3/pkg/kube"
 	"helm.sh/helm/v3/pkg/postrender"
 	"helm.sh/helm/v3/pkg/release"
@@ -60,6 +61,8 @@ var (
 	errInvalidRevision = errors.New("invalid release revision")
 	// errPending indicates that another instance of Helm is already applying an operation on a release.
 	errPending = errors.New("another operation (install/upgrade/rollback) is in progress")
+	// errForceServerSide indicates that resources were to be both replaced and sent with server-side apply.
+	errForceServerSide = errors.New("force cannot be used with server-side apply: use force conflicts to take ownership of the fields managed by others")
 )
 
 // ValidName is a regular expression for resource names.
@@ -94,6 +97,13 @@ type Configuration struct {
 	// Capabilities describes the capabilities of the Kubernetes cluster.
 	Capabilities *chartutil.Capabilities
 
+	// Progress, if set, receives progress events while an operation waits
+	// for its resources or runs its hooks. It requires a KubeClient that
+	// implements kube.InterfaceWithContext to report on individual resources.
+	// Hooks of the same weight may run concurrently, so it must be safe to
+	// call from multiple goroutines.
+	Progress kube.ProgressFunc
+
 	Log func(string, ...interface{})
 }
 
@@ -361,6 +371,46 @@ func (c *Configuration) recordRelease(r *release.Release) {
 	}
 }
 
+// applyOptions returns the kube.ApplyOptions for an operation. Server-side
+// dry run is only used together with server-side apply.
+func applyOptions(serverSideApply, forceConflicts, dryRun bool) kube.ApplyOptions {
+	if !serverSideApply {
+		return kube.ApplyOptions{}
+	}
+	return kube.ApplyOptions{
+		ServerSideApply: true,
+		FieldManager:    kube.DefaultFieldManager,
+		ForceConflicts:  forceConflicts,
+		DryRun:          dryRun,
+	}
+}
+
+// createResources creates the resources with the KubeClient, going through
+// kube.InterfaceApply when non-default options are requested.
+func (c *Configuration) createResources(resources kube.ResourceList, opts kube.ApplyOptions) (*kube.Result, error) {
+	if opts == (kube.ApplyOptions{}) {
+		return c.KubeClient.Create(resources)
+	}
+	kc, ok := c.KubeClient.(kube.InterfaceApply)
+	if !ok {
+		return nil, errors.Errorf("kubernetes client %T does not support server-side apply", c.KubeClient)
+	}
+	return kc.CreateWithOptions(resources, opts)
+}
+
+// updateResources updates the resources with the KubeClient, going through
+// kube.InterfaceApply when non-default options are requested.
+func (c *Configuration) updateResources(original, target kube.ResourceList, force bool, opts kube.ApplyOptions) (*kube.Result, error) {
+	if opts == (kube.ApplyOptions{}) {
+		return c.KubeClient.Update(original, target, force)
+	}
+	kc, ok := c.KubeClient.(kube.InterfaceApply)
+	if !ok {
+		return &kube.Result{}, errors.Errorf("kubernetes client %T does not support server-side apply", c.KubeClient)
+	}
+	return kc.UpdateWithOptions(original, target, force, opts)
+}
+
 // Init initializes the action configuration
 func (c *Configuration) Init(getter genericclioptions.RESTClientGetter, namespace, helmDriver string, log DebugLog) error {
 	kc := kube.New(getter)
@@ -374,13 +424,25 @@ func (c *Configuration) Init(getter genericclioptions.RESTClientGetter, namespac
 	var store *storage.Storage
 	switch helmDriver {
 	case "secret", "secrets", "":
+		compression, err := driver.ParseCompression(os.Getenv("HELM_DRIVER_COMPRESSION"))
+		if err != nil {
+			return errors.Wrap(err, "invalid HELM_DRIVER_COMPRESSION")
+		}
 		d := driver.NewSecrets(newSecretClient(lazyClient))
 		d.Log = log
+		d.Compression = compression
 		store = storage.Init(d)
+		store.Locker = newLeases(lazyClient, log)
 	case "configmap", "configmaps":
+		compression, err := driver.ParseCompression(os.Getenv("HELM_DRIVER_COMPRESSION"))
+		if err != nil {
+			return errors.Wrap(err, "invalid HELM_DRIVER_COMPRESSION")
+		}
 		d := driver.NewConfigMaps(newConfigMapClient(lazyClient))
 		d.Log = log
+		d.Compression = compression
 		store = storage.Init(d)
+		store.Locker = newLeases(lazyClient, log)
 	case "memory":
 		var d *driver.Memory
 		if c.Releases != nil {
@@ -406,11 +468,40 @@ func (c *Configuration) Init(getter genericclioptions.RESTClientGetter, namespac
 			panic(fmt.Sprintf("Unable to instantiate SQL driver: %v", err))
 		}
 		store = storage.Init(d)
+		store.Locker = d
+	case "file":
+		compression, err := driver.ParseCompression(os.Getenv("HELM_DRIVER_COMPRESSION"))
+		if err != nil {
+			return errors.Wrap(err, "invalid HELM_DRIVER_COMPRESSION")
+		}
+		dir := os.Getenv("HELM_DRIVER_FILE_PATH")
+		if dir == "" {
+			dir = helmpath.DataPath("releases")
+		}
+		d, err := driver.NewFile(dir)
+		if err != nil {
+			return err
+		}
+		d.SetNamespace(namespace)
+		d.Log = log
+		d.Compression = compression
+		store = storage.Init(d)
+		store.Locker = d
 	default:
 		// Not sure what to do here.
 		panic("Unknown driver in HELM_DRIVER: " + helmDriver)
 	}
 
+	if spec := os.Getenv("HELM_DRIVER_ENCRYPTION"); spec != "" && helmDriver != "memory" {
+		keys, err := driver.NewKeyProvider(spec)
+		if err != nil {
+			return errors.Wrap(err, "invalid HELM_DRIVER_ENCRYPTION")
+		}
+		d := driver.NewEncrypted(store.Driver, keys)
+		d.Log = log
+		store.Driver = d
+	}
+
 	c.RESTClientGetter = getter
 	c.KubeClient = kc
 	c.Releases = store
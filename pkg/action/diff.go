@@ -0,0 +1,436 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/yaml"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// DiffChange describes how a single resource differs between the two sides of a diff.
+type DiffChange string
+
+const (
+	// DiffAdded indicates a resource that only exists on the target side.
+	DiffAdded DiffChange = "added"
+	// DiffRemoved indicates a resource that only exists on the current side.
+	DiffRemoved DiffChange = "removed"
+	// DiffModified indicates a resource that exists on both sides with different content.
+	DiffModified DiffChange = "modified"
+)
+
+// ResourceDiff is the difference for a single Kubernetes object.
+type ResourceDiff struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Namespace  string     `json:"namespace,omitempty"`
+	Name       string     `json:"name"`
+	Change     DiffChange `json:"change"`
+	// Diff is a unified diff of the YAML representation of the object.
+	Diff string `json:"diff"`
+}
+
+// DiffResult is the set of changes between two states of a release.
+type DiffResult struct {
+	Release   string `json:"release"`
+	Namespace string `json:"namespace"`
+	// From describes the side the changes are computed against, such as
+	// "revision 3" or "live".
+	From string `json:"from"`
+	// To describes the side the changes lead to.
+	To        string         `json:"to"`
+	Resources []ResourceDiff `json:"resources"`
+}
+
+// HasChanges returns true if at least one resource differs.
+func (r *DiffResult) HasChanges() bool {
+	return len(r.Resources) > 0
+}
+
+// Diff is the action for previewing the changes an operation would make to a release.
+//
+// It provides the implementation of 'helm diff' and its subcommands.
+type Diff struct {
+	cfg *Configuration
+
+	// Live compares against the objects currently in the cluster instead of
+	// the manifest of the last stored release.
+	Live bool
+	// Context is the number of unchanged lines shown around each change.
+	Context int
+	// ShowSecrets disables the redaction of Secret data.
+	ShowSecrets bool
+}
+
+// NewDiff creates a new Diff object with the given configuration.
+func NewDiff(cfg *Configuration) *Diff {
+	return &Diff{
+		cfg:     cfg,
+		Context: 3,
+	}
+}
+
+// RunUpgrade renders the chart exactly as the given upgrade would and returns
+// the changes it would apply to the release.
+func (d *Diff) RunUpgrade(u *Upgrade, name string, ch *chart.Chart, vals map[string]interface{}) (*DiffResult, error) {
+	if err := d.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return nil, errors.Errorf("release name is invalid: %s", name)
+	}
+
+	d.cfg.Log("preparing upgrade diff for %s", name)
+	current, target, err := u.prepareUpgrade(name, ch, vals)
+	if err != nil {
+		return nil, err
+	}
+	return d.diffReleases(current, target)
+}
+
+// RunRollback returns the changes a rollback of the named release to the given
+// revision would apply. A version of 0 selects the previous revision.
+func (d *Diff) RunRollback(name string, version int) (*DiffResult, error) {
+	if err := d.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	rb := NewRollback(d.cfg)
+	rb.Version = version
+	current, target, err := rb.prepareRollback(name)
+	if err != nil {
+		return nil, err
+	}
+	return d.diffReleases(current, target)
+}
+
+// RunRevision returns the changes between two stored revisions of a release.
+// A to revision of 0 selects the latest revision.
+func (d *Diff) RunRevision(name string, from, to int) (*DiffResult, error) {
+	if err := d.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	if d.Live {
+		return nil, errors.New("comparing against live objects is not supported when diffing two revisions")
+	}
+	if from <= 0 || to < 0 {
+		return nil, errInvalidRevision
+	}
+
+	fromRelease, err := d.cfg.releaseContent(name, from)
+	if err != nil {
+		return nil, err
+	}
+	toRelease, err := d.cfg.releaseContent(name, to)
+	if err != nil {
+		return nil, err
+	}
+	return d.diffReleases(fromRelease, toRelease)
+}
+
+func (d *Diff) diffReleases(current, target *release.Release) (*DiffResult, error) {
+	from, err := d.cfg.KubeClient.Build(bytes.NewBufferString(current.Manifest), false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build kubernetes objects from current release manifest")
+	}
+	to, err := d.cfg.KubeClient.Build(bytes.NewBufferString(target.Manifest), false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build kubernetes objects from target release manifest")
+	}
+
+	result := &DiffResult{
+		Release:   target.Name,
+		Namespace: target.Namespace,
+		From:      fmt.Sprintf("revision %d", current.Version),
+		To:        fmt.Sprintf("revision %d", target.Version),
+	}
+
+	if d.Live {
+		// The live objects carry the ownership metadata added at install time,
+		// so the target has to carry it too to be comparable.
+		if err := to.Visit(setMetadataVisitor(target.Name, target.Namespace, true)); err != nil {
+			return nil, err
+		}
+		from, err = liveResources(from, to)
+		if err != nil {
+			return nil, err
+		}
+		result.From = "live"
+	}
+
+	result.Resources, err = diffResourceLists(from, to, result.From, result.To, d.Context, d.ShowSecrets)
+	return result, err
+}
+
+// liveResources fetches the cluster state of every object in stored and
+// target. Objects that do not exist in the cluster are left out. Fields that
+// neither side of the comparison manages are pruned, so that server defaults
+// and fields owned by other controllers do not show up as changes.
+func liveResources(stored, target kube.ResourceList) (kube.ResourceList, error) {
+	managed := make(map[string][]map[string]interface{})
+	var all kube.ResourceList
+	for _, info := range append(append(kube.ResourceList{}, stored...), target...) {
+		key := objectKey(info)
+		obj, err := toUnstructuredMap(info.Object)
+		if err != nil {
+			return nil, err
+		}
+		if _, seen := managed[key]; !seen {
+			all = append(all, info)
+		}
+		managed[key] = append(managed[key], obj)
+	}
+
+	var live kube.ResourceList
+	for _, info := range all {
+		helper := resource.NewHelper(info.Client, info.Mapping)
+		obj, err := helper.Get(info.Namespace, info.Name)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "could not get live state of %s", resourceString(info))
+		}
+		content, err := toUnstructuredMap(obj)
+		if err != nil {
+			return nil, err
+		}
+		stripServerFields(content)
+		content = pruneUnmanaged(content, managed[objectKey(info)]).(map[string]interface{})
+
+		li := *info
+		li.Object = &unstructured.Unstructured{Object: content}
+		live = append(live, &li)
+	}
+	return live, nil
+}
+
+// diffResourceLists compares two resource lists by group, version, kind,
+// namespace and name and returns the resources that differ. Resources are
+// reported in the order of the target list, followed by removed resources.
+func diffResourceLists(from, to kube.ResourceList, fromLabel, toLabel string, context int, showSecrets bool) ([]ResourceDiff, error) {
+	fromObjs := make(map[string]*resource.Info, len(from))
+	for _, info := range from {
+		fromObjs[objectKey(info)] = info
+	}
+	toObjs := make(map[string]*resource.Info, len(to))
+	for _, info := range to {
+		toObjs[objectKey(info)] = info
+	}
+
+	diffs := []ResourceDiff{}
+	add := func(info *resource.Info, change DiffChange, before, after map[string]interface{}) error {
+		if !showSecrets && isSecret(info) {
+			redactSecret(before, after)
+		}
+		beforeText, err := objectYAML(before)
+		if err != nil {
+			return err
+		}
+		afterText, err := objectYAML(after)
+		if err != nil {
+			return err
+		}
+		if change == DiffModified && beforeText == afterText {
+			return nil
+		}
+
+		gvk := info.Mapping.GroupVersionKind
+		id := fmt.Sprintf("%s/%s", gvk.Kind, info.Name)
+		if info.Namespace != "" {
+			id = fmt.Sprintf("%s/%s", info.Namespace, id)
+		}
+		text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(beforeText),
+			B:        difflib.SplitLines(afterText),
+			FromFile: fmt.Sprintf("%s (%s)", id, fromLabel),
+			ToFile:   fmt.Sprintf("%s (%s)", id, toLabel),
+			Context:  context,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "unable to diff %s", resourceString(info))
+		}
+
+		diffs = append(diffs, ResourceDiff{
+			APIVersion: gvk.GroupVersion().String(),
+			Kind:       gvk.Kind,
+			Namespace:  info.Namespace,
+			Name:       info.Name,
+			Change:     change,
+			Diff:       text,
+		})
+		return nil
+	}
+
+	for _, info := range to {
+		after, err := toUnstructuredMap(info.Object)
+		if err != nil {
+			return nil, err
+		}
+		current, ok := fromObjs[objectKey(info)]
+		if !ok {
+			if err := add(info, DiffAdded, nil, after); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		before, err := toUnstructuredMap(current.Object)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(info, DiffModified, before, after); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, info := range from {
+		if _, ok := toObjs[objectKey(info)]; ok {
+			continue
+		}
+		before, err := toUnstructuredMap(info.Object)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(info, DiffRemoved, before, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return diffs, nil
+}
+
+// toUnstructuredMap returns a deep copy of the object as a generic map.
+func toUnstructuredMap(obj runtime.Object) (map[string]interface{}, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to convert object to unstructured")
+	}
+	return runtime.DeepCopyJSON(content), nil
+}
+
+func objectYAML(obj map[string]interface{}) (string, error) {
+	if obj == nil {
+		return "", nil
+	}
+	b, err := yaml.Marshal(obj)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to serialize object")
+	}
+	return string(b), nil
+}
+
+// stripServerFields removes the fields the API server maintains on every
+// object and that never appear in a chart.
+func stripServerFields(obj map[string]interface{}) {
+	delete(obj, "status")
+	for _, f := range []string{"managedFields", "resourceVersion", "uid", "creationTimestamp", "generation", "selfLink"} {
+		unstructured.RemoveNestedField(obj, "metadata", f)
+	}
+	unstructured.RemoveNestedField(obj, "metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration")
+	if annos, ok, _ := unstructured.NestedMap(obj, "metadata", "annotations"); ok && len(annos) == 0 {
+		unstructured.RemoveNestedField(obj, "metadata", "annotations")
+	}
+}
+
+// pruneUnmanaged drops every map key from live that is not present in at
+// least one of the managed objects. Lists and scalars are kept as a whole.
+func pruneUnmanaged(live interface{}, managed []map[string]interface{}) interface{} {
+	liveMap, ok := live.(map[string]interface{})
+	if !ok {
+		return live
+	}
+	out := make(map[string]interface{}, len(liveMap))
+	for k, v := range liveMap {
+		var children []map[string]interface{}
+		found := false
+		for _, m := range managed {
+			mv, ok := m[k]
+			if !ok {
+				continue
+			}
+			found = true
+			if child, ok := mv.(map[string]interface{}); ok {
+				children = append(children, child)
+			}
+		}
+		if !found {
+			continue
+		}
+		if _, isMap := v.(map[string]interface{}); isMap && len(children) > 0 {
+			out[k] = pruneUnmanaged(v, children)
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+func isSecret(info *resource.Info) bool {
+	gvk := info.Mapping.GroupVersionKind
+	return gvk.Group == "" && gvk.Kind == "Secret"
+}
+
+// redactSecret replaces the values of a Secret's data with placeholders. A
+// value that differs between both sides gets a different placeholder on each
+// side so that the change stays visible without revealing the content.
+func redactSecret(before, after map[string]interface{}) {
+	const (
+		unchanged = "(redacted)"
+		previous  = "(redacted, previous value)"
+		updated   = "(redacted, new value)"
+	)
+	for _, field := range []string{"data", "stringData"} {
+		b, _, _ := unstructured.NestedMap(before, field)
+		a, _, _ := unstructured.NestedMap(after, field)
+		for k, v := range b {
+			if av, ok := a[k]; ok && reflect.DeepEqual(av, v) {
+				b[k] = unchanged
+				continue
+			}
+			b[k] = previous
+		}
+		for k := range a {
+			if b[k] == unchanged {
+				a[k] = unchanged
+				continue
+			}
+			a[k] = updated
+		}
+		if len(b) > 0 {
+			unstructured.SetNestedMap(before, b, field)
+		}
+		if len(a) > 0 {
+			unstructured.SetNestedMap(after, a, field)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func newUnstructuredResource(gvk schema.GroupVersionKind, name, namespace string, fields map[string]interface{}) *resource.Info {
+	obj := &unstructured.Unstructured{Object: fields}
+	obj.SetGroupVersionKind(gvk)
+	obj.SetName(name)
+	obj.SetNamespace(namespace)
+	return &resource.Info{
+		Name:      name,
+		Namespace: namespace,
+		Mapping:   &meta.RESTMapping{GroupVersionKind: gvk},
+		Object:    obj,
+	}
+}
+
+var (
+	configMapGVK = schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	secretGVK    = schema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+)
+
+func TestDiffResourceLists(t *testing.T) {
+	is := assert.New(t)
+
+	from := kube.ResourceList{
+		newUnstructuredResource(configMapGVK, "same", "default", map[string]interface{}{"data": map[string]interface{}{"a": "1"}}),
+		newUnstructuredResource(configMapGVK, "changed", "default", map[string]interface{}{"data": map[string]interface{}{"a": "1"}}),
+		newUnstructuredResource(configMapGVK, "gone", "default", map[string]interface{}{}),
+	}
+	to := kube.ResourceList{
+		newUnstructuredResource(configMapGVK, "same", "default", map[string]interface{}{"data": map[string]interface{}{"a": "1"}}),
+		newUnstructuredResource(configMapGVK, "changed", "default", map[string]interface{}{"data": map[string]interface{}{"a": "2"}}),
+		newUnstructuredResource(configMapGVK, "new", "default", map[string]interface{}{}),
+	}
+
+	diffs, err := diffResourceLists(from, to, "revision 1", "revision 2", 3, false)
+	is.NoError(err)
+	is.Len(diffs, 3)
+
+	is.Equal("changed", diffs[0].Name)
+	is.Equal(DiffModified, diffs[0].Change)
+	is.Contains(diffs[0].Diff, "--- default/ConfigMap/changed (revision 1)")
+	is.Contains(diffs[0].Diff, "+++ default/ConfigMap/changed (revision 2)")
+	is.Contains(diffs[0].Diff, "-  a: \"1\"")
+	is.Contains(diffs[0].Diff, "+  a: \"2\"")
+
+	is.Equal("new", diffs[1].Name)
+	is.Equal(DiffAdded, diffs[1].Change)
+	is.Equal("v1", diffs[1].APIVersion)
+
+	is.Equal("gone", diffs[2].Name)
+	is.Equal(DiffRemoved, diffs[2].Change)
+}
+
+func TestDiffResourceListsRedactsSecrets(t *testing.T) {
+	is := assert.New(t)
+
+	from := kube.ResourceList{
+		newUnstructuredResource(secretGVK, "creds", "default", map[string]interface{}{"data": map[string]interface{}{
+			"user":     "YWRtaW4=",
+			"password": "c2VjcmV0",
+		}}),
+	}
+	to := kube.ResourceList{
+		newUnstructuredResource(secretGVK, "creds", "default", map[string]interface{}{"data": map[string]interface{}{
+			"user":     "YWRtaW4=",
+			"password": "aHVudGVyMg==",
+		}}),
+	}
+
+	diffs, err := diffResourceLists(from, to, "revision 1", "revision 2", 3, false)
+	is.NoError(err)
+	is.Len(diffs, 1)
+	is.NotContains(diffs[0].Diff, "c2VjcmV0")
+	is.NotContains(diffs[0].Diff, "aHVudGVyMg==")
+	is.Contains(diffs[0].Diff, "-  password: (redacted, previous value)")
+	is.Contains(diffs[0].Diff, "+  password: (redacted, new value)")
+	is.Contains(diffs[0].Diff, "   user: (redacted)")
+
+	diffs, err = diffResourceLists(from, to, "revision 1", "revision 2", 3, true)
+	is.NoError(err)
+	is.Contains(diffs[0].Diff, "+  password: aHVudGVyMg==")
+}
+
+func TestPruneUnmanaged(t *testing.T) {
+	live := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":   "foo",
+			"labels": map[string]interface{}{"app": "foo", "injected": "true"},
+		},
+		"spec": map[string]interface{}{
+			"replicas":                3,
+			"revisionHistoryLimit":    10,
+			"progressDeadlineSeconds": 600,
+		},
+	}
+	managed := []map[string]interface{}{{
+		"metadata": map[string]interface{}{
+			"name":   "foo",
+			"labels": map[string]interface{}{"app": "foo"},
+		},
+		"spec": map[string]interface{}{"replicas": 1},
+	}}
+
+	assert.Equal(t, map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":   "foo",
+			"labels": map[string]interface{}{"app": "foo"},
+		},
+		"spec": map[string]interface{}{"replicas": 3},
+	}, pruneUnmanaged(live, managed))
+}
+
+func TestDiffRunRevision(t *testing.T) {
+	is := assert.New(t)
+	config := actionConfigFixture(t)
+
+	for _, version := range []int{1, 2} {
+		rel := namedReleaseStub("diff", release.StatusSuperseded)
+		rel.Version = version
+		is.NoError(config.Releases.Create(rel))
+	}
+
+	client := NewDiff(config)
+	res, err := client.RunRevision("diff", 1, 0)
+	is.NoError(err)
+	is.Equal("revision 1", res.From)
+	is.Equal("revision 2", res.To)
+	is.False(res.HasChanges())
+
+	_, err = client.RunRevision("diff", 0, 2)
+	is.Equal(errInvalidRevision, err)
+
+	client.Live = true
+	_, err = client.RunRevision("diff", 1, 2)
+	is.Error(err)
+}
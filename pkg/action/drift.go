@@ -0,0 +1,328 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// DefaultDriftIgnoredManagers are the field managers of the Kubernetes
+// controllers. Fields they own, such as the replicas of a Deployment scaled by
+// a HorizontalPodAutoscaler, are not reported as drift.
+var DefaultDriftIgnoredManagers = []string{
+	"kube-controller-manager",
+	"kube-scheduler",
+	"kubelet",
+}
+
+// FieldDrift is a field of a live object that differs from the manifest.
+type FieldDrift struct {
+	// Path is the path of the field, e.g. spec.template.spec.containers[0].image.
+	Path     string      `json:"path"`
+	Expected interface{} `json:"expected"`
+	Actual   interface{} `json:"actual,omitempty"`
+	// Missing is true if the live object does not have the field.
+	Missing bool `json:"missing,omitempty"`
+}
+
+// ResourceDrift is the drift of a single Kubernetes object.
+type ResourceDrift struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	// Missing is true if the object does not exist in the cluster.
+	Missing bool         `json:"missing,omitempty"`
+	Fields  []FieldDrift `json:"fields,omitempty"`
+}
+
+// DriftResult is the drift of the live objects of a release from the manifest
+// of its deployed revision.
+type DriftResult struct {
+	Release   string          `json:"release"`
+	Namespace string          `json:"namespace"`
+	Revision  int             `json:"revision"`
+	Resources []ResourceDrift `json:"resources"`
+}
+
+// HasDrift returns true if at least one object drifted.
+func (r *DriftResult) HasDrift() bool {
+	return len(r.Resources) > 0
+}
+
+// Drift is the action for detecting changes made to the objects of a release
+// outside of Helm.
+//
+// It provides the implementation of 'helm status --drift'.
+type Drift struct {
+	cfg *Configuration
+
+	// IgnoredManagers are the field managers whose fields are not compared.
+	IgnoredManagers []string
+	// ShowSecrets disables the redaction of Secret data.
+	ShowSecrets bool
+}
+
+// NewDrift creates a new Drift object with the given configuration.
+func NewDrift(cfg *Configuration) *Drift {
+	return &Drift{
+		cfg:             cfg,
+		IgnoredManagers: DefaultDriftIgnoredManagers,
+	}
+}
+
+// Run compares the live objects of the deployed revision of the named release
+// with its manifest.
+//
+// Only the fields the manifest sets are compared, so fields defaulted by the
+// API server are not reported, and neither are the fields owned by the
+// ignored field managers.
+func (d *Drift) Run(name string) (*DriftResult, error) {
+	if err := d.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return nil, errors.Errorf("release name is invalid: %s", name)
+	}
+
+	rel, err := d.cfg.Releases.Deployed(name)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := d.cfg.KubeClient.Build(bytes.NewBufferString(rel.Manifest), false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build kubernetes objects from release manifest")
+	}
+	// The live objects carry the ownership metadata added at install time,
+	// so the manifest has to carry it too to be comparable.
+	if err := resources.Visit(setMetadataVisitor(rel.Name, rel.Namespace, true)); err != nil {
+		return nil, err
+	}
+
+	result := &DriftResult{
+		Release:   rel.Name,
+		Namespace: rel.Namespace,
+		Revision:  rel.Version,
+		Resources: []ResourceDrift{},
+	}
+	for _, info := range resources {
+		drift, err := d.resourceDrift(info)
+		if err != nil {
+			return nil, err
+		}
+		if drift != nil {
+			result.Resources = append(result.Resources, *drift)
+		}
+	}
+	return result, nil
+}
+
+// resourceDrift compares the live object of info with it, and returns nil if
+// it did not drift.
+func (d *Drift) resourceDrift(info *resource.Info) (*ResourceDrift, error) {
+	gvk := info.Mapping.GroupVersionKind
+	drift := &ResourceDrift{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Namespace:  info.Namespace,
+		Name:       info.Name,
+	}
+
+	helper := resource.NewHelper(info.Client, info.Mapping)
+	obj, err := helper.Get(info.Namespace, info.Name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			drift.Missing = true
+			return drift, nil
+		}
+		return nil, errors.Wrapf(err, "could not get live state of %s", resourceString(info))
+	}
+	live, err := toUnstructuredMap(obj)
+	if err != nil {
+		return nil, err
+	}
+	expected, err := toUnstructuredMap(info.Object)
+	if err != nil {
+		return nil, err
+	}
+
+	ignored := managedFieldPaths(live, d.IgnoredManagers)
+	stripServerFields(live)
+	stripServerFields(expected)
+	if isSecret(info) {
+		encodeStringData(expected)
+	}
+
+	drift.Fields = compareFields(nil, expected, live, ignored)
+	if !d.ShowSecrets && isSecret(info) {
+		for i, f := range drift.Fields {
+			if strings.HasPrefix(f.Path, "data") {
+				drift.Fields[i].Expected = "(redacted)"
+				if !f.Missing {
+					drift.Fields[i].Actual = "(redacted)"
+				}
+			}
+		}
+	}
+	if len(drift.Fields) == 0 {
+		return nil, nil
+	}
+	return drift, nil
+}
+
+// compareFields returns the fields of expected that differ in live. Fields
+// that only live has are not compared. Lists of the same length are compared
+// item by item, so that the fields the server defaults in list items, such as
+// containers, are not reported either.
+func compareFields(path []string, expected, live interface{}, ignored map[string]bool) []FieldDrift {
+	p := fieldPath(path)
+	if ignored[p] {
+		return nil
+	}
+
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		if l, ok := live.(map[string]interface{}); ok {
+			var drift []FieldDrift
+			for _, k := range sortedKeys(e) {
+				lv, ok := l[k]
+				child := append(append([]string{}, path...), k)
+				if !ok {
+					if !ignored[fieldPath(child)] {
+						drift = append(drift, FieldDrift{Path: fieldPath(child), Expected: e[k], Missing: true})
+					}
+					continue
+				}
+				drift = append(drift, compareFields(child, e[k], lv, ignored)...)
+			}
+			return drift
+		}
+	case []interface{}:
+		if l, ok := live.([]interface{}); ok && len(l) == len(e) {
+			var drift []FieldDrift
+			for i := range e {
+				child := append([]string{}, path...)
+				if len(child) == 0 {
+					child = append(child, fmt.Sprintf("[%d]", i))
+				} else {
+					child[len(child)-1] += fmt.Sprintf("[%d]", i)
+				}
+				drift = append(drift, compareFields(child, e[i], l[i], ignored)...)
+			}
+			return drift
+		}
+	default:
+		if reflect.DeepEqual(expected, live) {
+			return nil
+		}
+	}
+	return []FieldDrift{{Path: p, Expected: expected, Actual: live}}
+}
+
+// fieldPath joins the segments of a field path with dots. Segments that
+// contain dots or slashes, such as annotation keys, are quoted in brackets.
+func fieldPath(path []string) string {
+	var b strings.Builder
+	for i, seg := range path {
+		if strings.ContainsAny(seg, "./") && !strings.HasSuffix(seg, "]") {
+			fmt.Fprintf(&b, "[%q]", seg)
+			continue
+		}
+		if i > 0 {
+			b.WriteString(".")
+		}
+		b.WriteString(seg)
+	}
+	return b.String()
+}
+
+// managedFieldPaths returns the paths of the fields of the object that are
+// owned by the given field managers, according to its managedFields. Fields
+// of list items are not tracked.
+func managedFieldPaths(obj map[string]interface{}, managers []string) map[string]bool {
+	paths := map[string]bool{}
+	entries, _, _ := unstructured.NestedSlice(obj, "metadata", "managedFields")
+	for _, e := range entries {
+		entry, ok := e.(map[string]interface{})
+		if !ok || !contains(managers, fmt.Sprint(entry["manager"])) {
+			continue
+		}
+		fields, ok := entry["fieldsV1"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		collectFieldPaths(nil, fields, paths)
+	}
+	return paths
+}
+
+func collectFieldPaths(path []string, fields map[string]interface{}, paths map[string]bool) {
+	if _, ok := fields["."]; ok || len(fields) == 0 {
+		if len(path) > 0 {
+			paths[fieldPath(path)] = true
+		}
+	}
+	for k, v := range fields {
+		if !strings.HasPrefix(k, "f:") {
+			continue
+		}
+		child, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		collectFieldPaths(append(append([]string{}, path...), strings.TrimPrefix(k, "f:")), child, paths)
+	}
+}
+
+// encodeStringData moves the stringData of a Secret into its data, the way
+// the API server stores it.
+func encodeStringData(obj map[string]interface{}) {
+	stringData, ok, _ := unstructured.NestedStringMap(obj, "stringData")
+	if !ok {
+		return
+	}
+	data, _, _ := unstructured.NestedMap(obj, "data")
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	for k, v := range stringData {
+		data[k] = base64.StdEncoding.EncodeToString([]byte(v))
+	}
+	unstructured.SetNestedMap(obj, data, "data")
+	delete(obj, "stringData")
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
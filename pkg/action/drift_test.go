@@ -0,0 +1,164 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestCompareFields(t *testing.T) {
+	expected := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":        "web",
+			"annotations": map[string]interface{}{"example.com/owner": "team-a"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(2),
+			"paused":   false,
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "web", "image": "nginx:1.19"},
+					},
+				},
+			},
+		},
+	}
+	live := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"name":        "web",
+			"namespace":   "default",
+			"annotations": map[string]interface{}{"example.com/owner": "team-b"},
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(5),
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "web", "image": "nginx:1.20", "imagePullPolicy": "IfNotPresent"},
+					},
+				},
+			},
+		},
+	}
+
+	drift := compareFields(nil, expected, live, map[string]bool{})
+	assert.Equal(t, []FieldDrift{
+		{Path: `metadata.annotations["example.com/owner"]`, Expected: "team-a", Actual: "team-b"},
+		{Path: "spec.paused", Expected: false, Missing: true},
+		{Path: "spec.replicas", Expected: int64(2), Actual: int64(5)},
+		{Path: "spec.template.spec.containers[0].image", Expected: "nginx:1.19", Actual: "nginx:1.20"},
+	}, drift)
+
+	drift = compareFields(nil, expected, live, map[string]bool{"spec.replicas": true, "spec.template": true, "spec.paused": true})
+	assert.Len(t, drift, 1)
+	assert.Equal(t, `metadata.annotations["example.com/owner"]`, drift[0].Path)
+
+	// Lists of different lengths are reported as a whole
+	live["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"] = []interface{}{}
+	drift = compareFields(nil, expected, live, map[string]bool{})
+	assert.Equal(t, "spec.template.spec.containers", drift[len(drift)-1].Path)
+}
+
+func TestManagedFieldPaths(t *testing.T) {
+	obj := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"managedFields": []interface{}{
+				map[string]interface{}{
+					"manager": "kube-controller-manager",
+					"fieldsV1": map[string]interface{}{
+						"f:spec": map[string]interface{}{"f:replicas": map[string]interface{}{}},
+						"f:metadata": map[string]interface{}{
+							"f:annotations": map[string]interface{}{
+								".":                                   map[string]interface{}{},
+								"f:deployment.kubernetes.io/revision": map[string]interface{}{},
+							},
+						},
+					},
+				},
+				map[string]interface{}{
+					"manager": "kubectl-edit",
+					"fieldsV1": map[string]interface{}{
+						"f:spec": map[string]interface{}{"f:paused": map[string]interface{}{}},
+					},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, map[string]bool{
+		"spec.replicas":        true,
+		"metadata.annotations": true,
+		`metadata.annotations["deployment.kubernetes.io/revision"]`: true,
+	}, managedFieldPaths(obj, DefaultDriftIgnoredManagers))
+}
+
+func TestDriftRun(t *testing.T) {
+	is := assert.New(t)
+	cfg := actionConfigFixture(t)
+	rel := namedReleaseStub("web", release.StatusDeployed)
+	rel.Namespace = "ns-a"
+	if err := cfg.Releases.Create(rel); err != nil {
+		t.Fatal(err)
+	}
+
+	owned := func(name string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "ns-a",
+			Labels:      map[string]string{appManagedByLabel: appManagedByHelm},
+			Annotations: map[string]string{helmReleaseNameAnnotation: "web", helmReleaseNamespaceAnnotation: "ns-a"},
+		}
+	}
+	live := map[string]*corev1.ConfigMap{
+		"same":    {ObjectMeta: owned("same"), Data: map[string]string{"key": "value"}},
+		"drifted": {ObjectMeta: owned("drifted"), Data: map[string]string{"key": "edited", "extra": "value"}},
+	}
+	client := &liveKubeClient{PrintingKubeClient: kubefake.PrintingKubeClient{Out: ioutil.Discard}}
+	for _, name := range []string{"same", "drifted", "missing"} {
+		info := newLiveConfigMapResource(name, live, map[string]string{})
+		info.Object = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns-a"}, Data: map[string]string{"key": "value"}}
+		client.resources.Append(info)
+	}
+	cfg.KubeClient = client
+
+	result, err := NewDrift(cfg).Run("web")
+	is.NoError(err)
+	is.True(result.HasDrift())
+	is.Equal(1, result.Revision)
+	is.Equal([]ResourceDrift{{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Namespace:  "ns-a",
+		Name:       "drifted",
+		Fields:     []FieldDrift{{Path: "data.key", Expected: "value", Actual: "edited"}},
+	}, {
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Namespace:  "ns-a",
+		Name:       "missing",
+		Missing:    true,
+	}}, result.Resources)
+}
@@ -38,6 +38,8 @@ func NewGetValues(cfg *Configuration) *GetValues {
 }
 
 // Run executes 'helm get values' against the given release.
+//
+// Sensitive values, those that came from encrypted values files, are redacted.
 func (g *GetValues) Run(name string) (map[string]interface{}, error) {
 	if err := g.cfg.KubeClient.IsReachable(); err != nil {
 		return nil, err
@@ -54,7 +56,25 @@ func (g *GetValues) Run(name string) (map[string]interface{}, error) {
 		if err != nil {
 			return nil, err
 		}
-		return cfg, nil
+		return chartutil.RedactValues(cfg, rel.SensitiveValues), nil
+	}
+	return chartutil.RedactValues(rel.Config, rel.SensitiveValues), nil
+}
+
+// Explain computes all the values of the given release, and reports where
+// each of them came from. Sensitive values are redacted.
+func (g *GetValues) Explain(name string) (map[string]interface{}, chartutil.ValueOrigins, error) {
+	if err := g.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, nil, err
+	}
+
+	rel, err := g.cfg.releaseContent(name, g.Version)
+	if err != nil {
+		return nil, nil, err
+	}
+	vals, origins, err := chartutil.ExplainValues(rel.Chart, rel.Config, nil)
+	if err != nil {
+		return nil, nil, err
 	}
-	return rel.Config, nil
+	return chartutil.RedactValues(vals, rel.SensitiveValues), origins, nil
 }
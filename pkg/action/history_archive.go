@@ -0,0 +1,277 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+// ReleaseArchiveAPIVersion is the version of the format of release archives.
+const ReleaseArchiveAPIVersion = "helm.sh/release-archive/v1"
+
+// ReleaseArchive is a portable backup of the revisions of releases, which can
+// be restored into any storage driver. It is stored as gzipped JSON.
+type ReleaseArchive struct {
+	APIVersion string             `json:"apiVersion"`
+	Created    helmtime.Time      `json:"created"`
+	Releases   []*release.Release `json:"releases"`
+}
+
+// Write writes the archive to w.
+func (a *ReleaseArchive) Write(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	if err := json.NewEncoder(zw).Encode(a); err != nil {
+		return errors.Wrap(err, "failed to write release archive")
+	}
+	return errors.Wrap(zw.Close(), "failed to write release archive")
+}
+
+// ReadReleaseArchive reads an archive written by ReleaseArchive.Write. An
+// archive that is not gzipped is read as plain JSON.
+func ReadReleaseArchive(r io.Reader) (*ReleaseArchive, error) {
+	br := bufio.NewReader(r)
+	var in io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read release archive")
+		}
+		defer zr.Close()
+		in = zr
+	}
+	var a ReleaseArchive
+	if err := json.NewDecoder(in).Decode(&a); err != nil {
+		return nil, errors.Wrap(err, "failed to read release archive")
+	}
+	if a.APIVersion != ReleaseArchiveAPIVersion {
+		return nil, errors.Errorf("unsupported release archive version %q, expected %q", a.APIVersion, ReleaseArchiveAPIVersion)
+	}
+	for i, rls := range a.Releases {
+		if rls == nil || rls.Name == "" || rls.Version <= 0 || rls.Info == nil {
+			return nil, errors.Errorf("invalid release archive: release %d is incomplete", i+1)
+		}
+	}
+	return &a, nil
+}
+
+// Namespaces returns the namespaces of the releases of the archive.
+func (a *ReleaseArchive) Namespaces() []string {
+	seen := map[string]bool{}
+	var namespaces []string
+	for _, rls := range a.Releases {
+		if ns := releaseNamespace(rls); !seen[ns] {
+			seen[ns] = true
+			namespaces = append(namespaces, ns)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// releaseNamespace returns the namespace of the release. Releases stored
+// without a namespace are in the default namespace.
+func releaseNamespace(rls *release.Release) string {
+	if rls.Namespace == "" {
+		return "default"
+	}
+	return rls.Namespace
+}
+
+// HistoryExport is the action for backing up the revisions of releases.
+//
+// It provides the implementation of 'helm history export'.
+type HistoryExport struct {
+	cfg *Configuration
+}
+
+// NewHistoryExport creates a new HistoryExport object with the given
+// configuration.
+func NewHistoryExport(cfg *Configuration) *HistoryExport {
+	return &HistoryExport{
+		cfg: cfg,
+	}
+}
+
+// Run returns the archive of every revision of the named releases, or of all
+// the releases of the storage if no name is given.
+func (h *HistoryExport) Run(names ...string) (*ReleaseArchive, error) {
+	var revisions []*release.Release
+	if len(names) == 0 {
+		all, err := h.cfg.Releases.ListReleases()
+		if err != nil {
+			return nil, err
+		}
+		revisions = all
+	}
+	for _, name := range names {
+		if err := chartutil.ValidateReleaseName(name); err != nil {
+			return nil, errors.Errorf("release name is invalid: %s", name)
+		}
+		history, err := h.cfg.Releases.History(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the history of release %q", name)
+		}
+		revisions = append(revisions, history...)
+	}
+
+	sort.SliceStable(revisions, func(i, j int) bool {
+		a, b := revisions[i], revisions[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Version < b.Version
+	})
+	return &ReleaseArchive{
+		APIVersion: ReleaseArchiveAPIVersion,
+		Created:    helmtime.Now(),
+		Releases:   revisions,
+	}, nil
+}
+
+// HistoryImport is the action for restoring the revisions of releases from a
+// ReleaseArchive.
+//
+// It provides the implementation of 'helm history import'.
+type HistoryImport struct {
+	cfg *Configuration
+
+	// Namespace is the namespace of the storage of the configuration. Only
+	// the releases of the archive in that namespace are imported, so an
+	// archive of several namespaces is imported once for each of its
+	// Namespaces.
+	Namespace string
+	// Adopt sets the ownership metadata of the imported releases on the live
+	// resources of their deployed revision, so that they can be upgraded
+	// even if the metadata was lost, e.g. when they were restored separately.
+	Adopt bool
+	// Force adopts resources that are owned by another release.
+	Force bool
+	// DryRun reports what would be imported without writing anything.
+	DryRun bool
+}
+
+// HistoryImportResult is the outcome of HistoryImport.
+type HistoryImportResult struct {
+	// Revisions are the imported revisions.
+	Revisions []*MigratedRevision
+	// Adopted are the live resources whose ownership metadata was set.
+	Adopted []string
+	// Missing are the resources of the deployed revisions that do not exist
+	// in the cluster. They are created by the next upgrade.
+	Missing []string
+}
+
+// NewHistoryImport creates a new HistoryImport object with the given
+// configuration.
+func NewHistoryImport(cfg *Configuration) *HistoryImport {
+	return &HistoryImport{
+		cfg: cfg,
+	}
+}
+
+// Run stores the revisions of the archive. Revisions that already are in the
+// storage are left as they are if they are identical, and fail the import
+// otherwise.
+func (h *HistoryImport) Run(archive *ReleaseArchive) (*HistoryImportResult, error) {
+	if h.Namespace == "" {
+		return nil, errors.New("no namespace to import the releases into")
+	}
+
+	byName := map[string][]*release.Release{}
+	var names []string
+	for _, rls := range archive.Releases {
+		if releaseNamespace(rls) != h.Namespace {
+			continue
+		}
+		if _, ok := byName[rls.Name]; !ok {
+			names = append(names, rls.Name)
+		}
+		byName[rls.Name] = append(byName[rls.Name], rls)
+	}
+	sort.Strings(names)
+
+	result := &HistoryImportResult{}
+	for _, name := range names {
+		if err := h.importRelease(name, byName[name], result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (h *HistoryImport) importRelease(name string, revisions []*release.Release, result *HistoryImportResult) error {
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return errors.Errorf("release name is invalid: %s", name)
+	}
+	if !h.DryRun {
+		unlock, err := h.cfg.lockRelease(context.Background(), name, 0)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
+	releaseutil.SortByRevision(revisions)
+	for _, rls := range revisions {
+		rev, err := copyRevision(h.cfg.Releases, rls, h.DryRun, h.cfg.Log)
+		if err != nil {
+			return err
+		}
+		result.Revisions = append(result.Revisions, rev)
+	}
+
+	if !h.Adopt {
+		return nil
+	}
+	var deployed *release.Release
+	for _, rls := range revisions {
+		if rls.Info.Status == release.StatusDeployed {
+			deployed = rls
+		}
+	}
+	if deployed == nil {
+		return nil
+	}
+	resources, err := h.cfg.KubeClient.Build(bytes.NewBufferString(deployed.Manifest), false)
+	if err != nil {
+		return errors.Wrapf(err, "unable to build kubernetes objects of release %q", name)
+	}
+	adopted, missing, err := adoptResources(resources, deployed.Name, deployed.Namespace, h.Force, h.DryRun)
+	for _, info := range adopted {
+		result.Adopted = append(result.Adopted, resourceString(info))
+	}
+	for _, info := range missing {
+		result.Missing = append(result.Missing, resourceString(info))
+	}
+	return errors.Wrapf(err, "failed to adopt the resources of release %q", name)
+}
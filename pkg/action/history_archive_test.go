@@ -0,0 +1,193 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func historyArchiveFixture(t *testing.T) *Configuration {
+	config := actionConfigFixture(t)
+	for _, rel := range []*release.Release{
+		namespacedReleaseStub("happy-bunny", "ns-b", 1, release.StatusDeployed),
+		namespacedReleaseStub("angry-panda", "ns-a", 2, release.StatusDeployed),
+		namespacedReleaseStub("angry-panda", "ns-a", 1, release.StatusSuperseded),
+	} {
+		require.NoError(t, config.Releases.Create(rel))
+	}
+	allNamespaces(config)
+	return config
+}
+
+// allNamespaces makes the memory storage of the configuration list the
+// releases of all namespaces, instead of the namespace of the last release
+// it stored.
+func allNamespaces(config *Configuration) {
+	config.Releases.Driver.(*driver.Memory).SetNamespace("")
+}
+
+func namespacedReleaseStub(name, namespace string, version int, status release.Status) *release.Release {
+	rel := namedReleaseStub(name, status)
+	rel.Namespace = namespace
+	rel.Version = version
+	return rel
+}
+
+// roundTrip writes and reads back the archive.
+func roundTrip(t *testing.T, archive *ReleaseArchive) *ReleaseArchive {
+	var buf bytes.Buffer
+	require.NoError(t, archive.Write(&buf))
+	read, err := ReadReleaseArchive(&buf)
+	require.NoError(t, err)
+	return read
+}
+
+func TestHistoryExport(t *testing.T) {
+	is := assert.New(t)
+	config := historyArchiveFixture(t)
+
+	archive, err := NewHistoryExport(config).Run()
+	is.NoError(err)
+	is.Equal(ReleaseArchiveAPIVersion, archive.APIVersion)
+	is.Len(archive.Releases, 3)
+	is.Equal("angry-panda", archive.Releases[0].Name)
+	is.Equal(1, archive.Releases[0].Version)
+	is.Equal("happy-bunny", archive.Releases[2].Name)
+
+	read := roundTrip(t, archive)
+	is.Len(read.Releases, 3)
+	is.Equal(archive.Releases[1].Manifest, read.Releases[1].Manifest)
+	is.Equal(archive.Releases[1].Config, read.Releases[1].Config)
+
+	archive, err = NewHistoryExport(config).Run("angry-panda")
+	is.NoError(err)
+	is.Len(archive.Releases, 2)
+
+	_, err = NewHistoryExport(config).Run("no-such-release")
+	is.Error(err)
+	_, err = NewHistoryExport(config).Run("Invalid_Name")
+	is.EqualError(err, "release name is invalid: Invalid_Name")
+}
+
+func TestReadReleaseArchive(t *testing.T) {
+	is := assert.New(t)
+
+	archive, err := ReadReleaseArchive(strings.NewReader(`{"apiVersion":"helm.sh/release-archive/v1","releases":[{"name":"angry-panda","version":1,"info":{"status":"deployed"}}]}`))
+	is.NoError(err)
+	is.Len(archive.Releases, 1)
+
+	_, err = ReadReleaseArchive(strings.NewReader(`{"apiVersion":"helm.sh/release-archive/v2"}`))
+	is.EqualError(err, `unsupported release archive version "helm.sh/release-archive/v2", expected "helm.sh/release-archive/v1"`)
+	_, err = ReadReleaseArchive(strings.NewReader(`{"apiVersion":"helm.sh/release-archive/v1","releases":[{"name":"angry-panda"}]}`))
+	is.EqualError(err, "invalid release archive: release 1 is incomplete")
+	_, err = ReadReleaseArchive(strings.NewReader(`not an archive`))
+	is.Error(err)
+}
+
+func TestHistoryImport(t *testing.T) {
+	is := assert.New(t)
+	archive, err := NewHistoryExport(historyArchiveFixture(t)).Run()
+	require.NoError(t, err)
+	archive = roundTrip(t, archive)
+
+	is.Equal([]string{"ns-a", "ns-b"}, archive.Namespaces())
+
+	config := actionConfigFixture(t)
+	client := NewHistoryImport(config)
+	client.Adopt = true
+	for _, ns := range archive.Namespaces() {
+		config.Releases.Driver.(*driver.Memory).SetNamespace(ns)
+		client.Namespace = ns
+		result, err := client.Run(archive)
+		is.NoError(err)
+		for _, rev := range result.Revisions {
+			is.False(rev.Existing)
+			is.Equal(ns, rev.Namespace)
+		}
+	}
+
+	allNamespaces(config)
+	stored, err := config.Releases.ListReleases()
+	is.NoError(err)
+	is.Len(stored, 3)
+
+	config.Releases.Driver.(*driver.Memory).SetNamespace("ns-a")
+	client.Namespace = "ns-a"
+	last, err := config.Releases.Last("angry-panda")
+	is.NoError(err)
+	is.Equal(2, last.Version)
+	is.Equal(release.StatusDeployed, last.Info.Status)
+
+	// Importing again leaves the identical revisions as they are
+	result, err := client.Run(archive)
+	is.NoError(err)
+	is.Len(result.Revisions, 2)
+	for _, rev := range result.Revisions {
+		is.True(rev.Existing)
+	}
+
+	// A revision that differs is not replaced
+	archive = roundTrip(t, archive)
+	archive.Releases[0].Info.Description = "changed"
+	_, err = client.Run(archive)
+	is.EqualError(err, `release "angry-panda" revision 1 already exists in the target storage with different content`)
+}
+
+func TestHistoryImportNamespace(t *testing.T) {
+	is := assert.New(t)
+	archive, err := NewHistoryExport(historyArchiveFixture(t)).Run()
+	require.NoError(t, err)
+
+	config := actionConfigFixture(t)
+	client := NewHistoryImport(config)
+	client.Namespace = "ns-b"
+	result, err := client.Run(archive)
+	is.NoError(err)
+	is.Len(result.Revisions, 1)
+	is.Equal("happy-bunny", result.Revisions[0].Name)
+
+	client.Namespace = ""
+	_, err = client.Run(archive)
+	is.EqualError(err, "no namespace to import the releases into")
+}
+
+func TestHistoryImportDryRun(t *testing.T) {
+	is := assert.New(t)
+	archive, err := NewHistoryExport(historyArchiveFixture(t)).Run()
+	require.NoError(t, err)
+
+	config := actionConfigFixture(t)
+	client := NewHistoryImport(config)
+	client.Namespace = "ns-a"
+	client.DryRun = true
+	result, err := client.Run(archive)
+	is.NoError(err)
+	is.Len(result.Revisions, 2)
+
+	allNamespaces(config)
+	stored, err := config.Releases.ListReleases()
+	is.NoError(err)
+	is.Empty(stored)
+}
@@ -0,0 +1,222 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes"
+
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+var (
+	// hookLogTailLines is how many lines of the log of a hook container are
+	// fetched.
+	hookLogTailLines int64 = 200
+	// hookLogMaxBytes bounds the log stored for each hook container. Release
+	// records are limited in size, e.g. to 1MiB for the secrets driver.
+	hookLogMaxBytes = 8 * 1024
+	// hookEventsMax bounds the number of Kubernetes events stored for a hook.
+	hookEventsMax = 20
+	// hookOutputMaxBytes bounds the logs and events stored for all the hooks
+	// of a release, however many hooks it runs.
+	hookOutputMaxBytes = 64 * 1024
+)
+
+// collectHookOutput records the container logs and Kubernetes events of the
+// pods run by the hook in its last run, so that they are kept after the hook
+// resources are deleted. Failing to collect them is not an error.
+func (cfg *Configuration) collectHookOutput(h *release.Hook, resources kube.ResourceList) {
+	if !hasHookPods(resources) || cfg.RESTClientGetter == nil {
+		return
+	}
+	client, err := cfg.KubernetesClientSet()
+	if err != nil {
+		cfg.Log("warning: unable to collect the output of hook %s: %s", h.Path, err)
+		return
+	}
+	h.LastRun.Logs, h.LastRun.KubeEvents = hookOutput(client, resources, cfg.Log)
+}
+
+// hasHookPods reports whether any of the resources runs pods.
+func hasHookPods(resources kube.ResourceList) bool {
+	for _, info := range resources {
+		if kind := resourceKind(info); kind == "Pod" || kind == "Job" {
+			return true
+		}
+	}
+	return false
+}
+
+func resourceKind(info *resource.Info) string {
+	if info.Mapping == nil {
+		return ""
+	}
+	return info.Mapping.GroupVersionKind.Kind
+}
+
+// hookOutput returns the logs of the containers and the events of the pods
+// run by the resources, which are Pods or Jobs.
+func hookOutput(client kubernetes.Interface, resources kube.ResourceList, log func(string, ...interface{})) ([]release.HookLog, []string) {
+	var (
+		logs   []release.HookLog
+		events []v1.Event
+	)
+	for _, info := range resources {
+		kind := resourceKind(info)
+		var pods []v1.Pod
+		switch kind {
+		case "Pod":
+			pod, err := client.CoreV1().Pods(info.Namespace).Get(context.Background(), info.Name, metav1.GetOptions{})
+			if err != nil {
+				log("warning: unable to get hook pod %s: %s", info.Name, err)
+				continue
+			}
+			pods = []v1.Pod{*pod}
+		case "Job":
+			// The job controller labels the pods of a job with its name
+			list, err := client.CoreV1().Pods(info.Namespace).List(context.Background(), metav1.ListOptions{
+				LabelSelector: labels.Set{"job-name": info.Name}.String(),
+			})
+			if err != nil {
+				log("warning: unable to list the pods of hook job %s: %s", info.Name, err)
+				continue
+			}
+			pods = list.Items
+		default:
+			continue
+		}
+
+		events = append(events, objectEvents(client, info.Namespace, kind, info.Name, log)...)
+		for _, pod := range pods {
+			events = append(events, objectEvents(client, pod.Namespace, "Pod", pod.Name, log)...)
+			logs = append(logs, podLogs(client, &pod, log)...)
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].LastTimestamp.Before(&events[j].LastTimestamp)
+	})
+	if len(events) > hookEventsMax {
+		events = events[len(events)-hookEventsMax:]
+	}
+	var messages []string
+	for _, e := range events {
+		messages = append(messages, fmt.Sprintf("%s %s %s/%s: %s", e.Type, e.Reason, e.InvolvedObject.Kind, e.InvolvedObject.Name, e.Message))
+	}
+	return logs, messages
+}
+
+// podLogs returns the end of the logs of the containers of the pod.
+func podLogs(client kubernetes.Interface, pod *v1.Pod, log func(string, ...interface{})) []release.HookLog {
+	var logs []release.HookLog
+	for _, c := range append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+		req := client.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+			Container: c.Name,
+			TailLines: &hookLogTailLines,
+		})
+		raw, err := req.DoRaw(context.Background())
+		if err != nil {
+			// e.g. the container has not been started
+			log("warning: unable to get the logs of container %s of hook pod %s: %s", c.Name, pod.Name, err)
+			continue
+		}
+		l := release.HookLog{Pod: pod.Name, Container: c.Name, Log: string(raw)}
+		if len(raw) > hookLogMaxBytes {
+			l.Log = logTail(l.Log, hookLogMaxBytes)
+			l.Truncated = true
+		}
+		logs = append(logs, l)
+	}
+	return logs
+}
+
+// limitHookOutput drops the output recorded for the hooks beyond
+// hookOutputMaxBytes. The output of failed hooks is kept first, and the end of
+// the logs and the latest events are kept.
+func limitHookOutput(hooks []*release.Hook) {
+	ordered := append([]*release.Hook{}, hooks...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].LastRun.Phase == release.HookPhaseFailed && ordered[j].LastRun.Phase != release.HookPhaseFailed
+	})
+
+	budget := hookOutputMaxBytes
+	for _, h := range ordered {
+		run := &h.LastRun
+		for i := range run.Logs {
+			l := &run.Logs[i]
+			if len(l.Log) > budget {
+				l.Log = logTail(l.Log, budget)
+				l.Truncated = true
+			}
+			budget -= len(l.Log)
+		}
+		size := 0
+		for _, e := range run.KubeEvents {
+			size += len(e)
+		}
+		for size > budget {
+			size -= len(run.KubeEvents[0])
+			run.KubeEvents = run.KubeEvents[1:]
+		}
+		if len(run.KubeEvents) == 0 {
+			run.KubeEvents = nil
+		}
+		budget -= size
+	}
+}
+
+// logTail returns the last n bytes of log at most, without splitting a rune.
+func logTail(log string, n int) string {
+	if len(log) <= n {
+		return log
+	}
+	start := len(log) - n
+	for start < len(log) && !utf8.RuneStart(log[start]) {
+		start++
+	}
+	return log[start:]
+}
+
+// objectEvents returns the events of the named object.
+func objectEvents(client kubernetes.Interface, namespace, kind, name string, log func(string, ...interface{})) []v1.Event {
+	list, err := client.CoreV1().Events(namespace).List(context.Background(), metav1.ListOptions{
+		FieldSelector: fields.Set{"involvedObject.kind": kind, "involvedObject.name": name}.String(),
+	})
+	if err != nil {
+		log("warning: unable to list the events of %s %s: %s", kind, name, err)
+		return nil
+	}
+	var events []v1.Event
+	for _, e := range list.Items {
+		// Field selectors are not supported by every client, e.g. fakes
+		if e.InvolvedObject.Kind == kind && e.InvolvedObject.Name == name {
+			events = append(events, e)
+		}
+	}
+	return events
+}
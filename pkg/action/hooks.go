@@ -17,17 +17,22 @@ package action
 
 import (
 	"bytes"
+	"context"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 
+	"helm.sh/helm/v3/pkg/kube"
 	"helm.sh/helm/v3/pkg/release"
 	helmtime "helm.sh/helm/v3/pkg/time"
 )
 
-// execHook executes all of the hooks for the given hook event.
-func (cfg *Configuration) execHook(rl *release.Release, hook release.HookEvent, timeout time.Duration) error {
+// execHook executes all of the hooks for the given hook event. Hooks run in
+// order of weight, and up to parallelism hooks of the same weight run at the
+// same time. No further hooks are started once ctx is done.
+func (cfg *Configuration) execHook(ctx context.Context, rl *release.Release, hook release.HookEvent, timeout time.Duration, parallelism int) error {
 	executingHooks := []*release.Hook{}
 
 	for _, h := range rl.Hooks {
@@ -41,7 +46,56 @@ func (cfg *Configuration) execHook(rl *release.Release, hook release.HookEvent,
 	// hooke are pre-ordered by kind, so keep order stable
 	sort.Stable(hookByWeight(executingHooks))
 
+	// Tag the progress events of the hook resources with the hook event
+	if cfg.Progress != nil {
+		ctx = kube.WithProgress(ctx, func(e kube.ProgressEvent) {
+			e.Hook = string(hook)
+			cfg.Progress(e)
+		})
+	}
+
+	for _, group := range hookGroups(executingHooks, parallelism) {
+		if err := ctx.Err(); err != nil {
+			return errors.Wrapf(err, "%s hooks aborted", hook)
+		}
+		if err := cfg.execHookGroup(ctx, rl, hook, group, timeout, parallelism); err != nil {
+			return err
+		}
+	}
+
+	// If all hooks are successful, check the annotation of each hook to determine whether the hook should be deleted
+	// under succeeded condition. If so, then clear the corresponding resource object in each hook
 	for _, h := range executingHooks {
+		if err := cfg.deleteHookByPolicy(h, release.HookSucceeded); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hookGroups splits hooks sorted by weight into the groups that run at the
+// same time: the hooks of each weight, or every hook on its own if
+// parallelism does not allow more than one hook at a time.
+func hookGroups(hooks []*release.Hook, parallelism int) [][]*release.Hook {
+	var groups [][]*release.Hook
+	for i, h := range hooks {
+		if i > 0 && parallelism > 1 && h.Weight == hooks[i-1].Weight {
+			groups[len(groups)-1] = append(groups[len(groups)-1], h)
+			continue
+		}
+		groups = append(groups, []*release.Hook{h})
+	}
+	return groups
+}
+
+// execHookGroup executes hooks of the same weight, running up to parallelism
+// of them at the same time, and waits for all of them to complete. If a hook
+// fails, the hooks of the group that have not started yet are not run, and
+// the error of the first hook that failed is returned.
+func (cfg *Configuration) execHookGroup(ctx context.Context, rl *release.Release, hook release.HookEvent, group []*release.Hook, timeout time.Duration, parallelism int) error {
+	resources := make([]kube.ResourceList, len(group))
+	for i, h := range group {
 		// Set default delete policy to before-hook-creation
 		if h.DeletePolicies == nil || len(h.DeletePolicies) == 0 {
 			// TODO(jlegrone): Only apply before-hook-creation delete policy to run to completion
@@ -55,7 +109,8 @@ func (cfg *Configuration) execHook(rl *release.Release, hook release.HookEvent,
 			return err
 		}
 
-		resources, err := cfg.KubeClient.Build(bytes.NewBufferString(h.Manifest), true)
+		var err error
+		resources[i], err = cfg.KubeClient.Build(bytes.NewBufferString(h.Manifest), true)
 		if err != nil {
 			return errors.Wrapf(err, "unable to build kubernetes object for %s hook %s", hook, h.Path)
 		}
@@ -65,48 +120,110 @@ func (cfg *Configuration) execHook(rl *release.Release, hook release.HookEvent,
 			StartedAt: helmtime.Now(),
 			Phase:     release.HookPhaseRunning,
 		}
-		cfg.recordRelease(rl)
-
-		// As long as the implementation of WatchUntilReady does not panic, HookPhaseFailed or HookPhaseSucceeded
-		// should always be set by this function. If we fail to do that for any reason, then HookPhaseUnknown is
-		// the most appropriate value to surface.
-		h.LastRun.Phase = release.HookPhaseUnknown
-
-		// Create hook resources
-		if _, err := cfg.KubeClient.Create(resources); err != nil {
-			h.LastRun.CompletedAt = helmtime.Now()
-			h.LastRun.Phase = release.HookPhaseFailed
-			return errors.Wrapf(err, "warning: Hook %s %s failed", hook, h.Path)
+	}
+	// The release is recorded once for the whole group, as it must not be
+	// read while the hooks below update their last run.
+	cfg.recordRelease(rl)
+	defer limitHookOutput(rl.Hooks)
+
+	if len(group) == 1 {
+		return cfg.runHook(ctx, rl.Namespace, hook, group[0], resources[0], timeout)
+	}
+
+	// The first failure cancels the hooks that are still running, and the
+	// hooks that have not started yet never run.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var firstErr error
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr != nil {
+			cfg.Log("%s", err)
+			return
 		}
+		firstErr = err
+		cancel()
+	}
 
-		// Watch hook resources until they have completed
-		err = cfg.KubeClient.WatchUntilReady(resources, timeout)
-		// Note the time of success/failure
-		h.LastRun.CompletedAt = helmtime.Now()
-		// Mark hook as succeeded or failed
-		if err != nil {
-			h.LastRun.Phase = release.HookPhaseFailed
-			// If a hook is failed, check the annotation of the hook to determine whether the hook should be deleted
-			// under failed condition. If so, then clear the corresponding resource object in the hook
-			if err := cfg.deleteHookByPolicy(h, release.HookFailed); err != nil {
-				return err
-			}
-			return err
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallelism)
+	for i, h := range group {
+		sem <- struct{}{}
+		if err := ctx.Err(); err != nil {
+			// The hook never ran
+			<-sem
+			h.LastRun = release.HookExecution{}
+			continue
 		}
-		h.LastRun.Phase = release.HookPhaseSucceeded
+		wg.Add(1)
+		go func(i int, h *release.Hook) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := cfg.runHook(ctx, rl.Namespace, hook, h, resources[i], timeout); err != nil {
+				fail(err)
+			}
+		}(i, h)
 	}
+	wg.Wait()
 
-	// If all hooks are successful, check the annotation of each hook to determine whether the hook should be deleted
-	// under succeeded condition. If so, then clear the corresponding resource object in each hook
-	for _, h := range executingHooks {
-		if err := cfg.deleteHookByPolicy(h, release.HookSucceeded); err != nil {
+	if firstErr == nil && ctx.Err() != nil {
+		return errors.Wrapf(ctx.Err(), "%s hooks aborted", hook)
+	}
+	return firstErr
+}
+
+// runHook creates the resources of a hook and watches them until they have
+// completed, recording the outcome in the last run of the hook. Hooks of the
+// same weight may run concurrently, so it must only update h.
+func (cfg *Configuration) runHook(ctx context.Context, namespace string, hook release.HookEvent, h *release.Hook, resources kube.ResourceList, timeout time.Duration) error {
+	// As long as the implementation of WatchUntilReady does not panic, HookPhaseFailed or HookPhaseSucceeded
+	// should always be set by this function. If we fail to do that for any reason, then HookPhaseUnknown is
+	// the most appropriate value to surface.
+	h.LastRun.Phase = release.HookPhaseUnknown
+
+	progress := kube.ProgressEvent{Kind: h.Kind, Namespace: namespace, Name: h.Name, Hook: string(hook)}
+	cfg.reportProgress(withPhase(progress, kube.ProgressPending, "hook running"))
+
+	// Create hook resources
+	if _, err := cfg.KubeClient.Create(resources); err != nil {
+		h.LastRun.CompletedAt = helmtime.Now()
+		h.LastRun.Phase = release.HookPhaseFailed
+		cfg.reportProgress(withPhase(progress, kube.ProgressFailed, err.Error()))
+		return errors.Wrapf(err, "warning: Hook %s %s failed", hook, h.Path)
+	}
+
+	// Watch hook resources until they have completed
+	err := cfg.watchUntilReady(ctx, resources, timeout)
+	// Note the time of success/failure
+	h.LastRun.CompletedAt = helmtime.Now()
+	// Keep the output of the hook pods, which may be deleted below
+	cfg.collectHookOutput(h, resources)
+	// Mark hook as succeeded or failed
+	if err != nil {
+		h.LastRun.Phase = release.HookPhaseFailed
+		cfg.reportProgress(withPhase(progress, kube.ProgressFailed, err.Error()))
+		// If a hook is failed, check the annotation of the hook to determine whether the hook should be deleted
+		// under failed condition. If so, then clear the corresponding resource object in the hook
+		if err := cfg.deleteHookByPolicy(h, release.HookFailed); err != nil {
 			return err
 		}
+		return err
 	}
-
+	h.LastRun.Phase = release.HookPhaseSucceeded
+	cfg.reportProgress(withPhase(progress, kube.ProgressReady, "hook succeeded"))
 	return nil
 }
 
+func withPhase(e kube.ProgressEvent, phase kube.ProgressPhase, message string) kube.ProgressEvent {
+	e.Phase, e.Message = phase, message
+	return e
+}
+
 // hookByWeight is a sorter for hooks
 type hookByWeight []*release.Hook
 
@@ -0,0 +1,405 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"helm.sh/helm/v3/pkg/kube"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestExecHookProgress(t *testing.T) {
+	is := assert.New(t)
+	instAction := installAction(t)
+	var events []kube.ProgressEvent
+	instAction.cfg.Progress = func(e kube.ProgressEvent) { events = append(events, e) }
+
+	_, err := instAction.Run(buildChart(), nil)
+	is.NoError(err)
+
+	is.Equal([]kube.ProgressEvent{
+		{Kind: "ConfigMap", Namespace: "spaced", Name: "test-cm", Hook: "post-install", Phase: kube.ProgressPending, Message: "hook running"},
+		{Kind: "ConfigMap", Namespace: "spaced", Name: "test-cm", Hook: "post-install", Phase: kube.ProgressReady, Message: "hook succeeded"},
+	}, events)
+}
+
+func TestExecHookProgressFailed(t *testing.T) {
+	is := assert.New(t)
+	instAction := installAction(t)
+	failer := instAction.cfg.KubeClient.(*kubefake.FailingKubeClient)
+	failer.WatchUntilReadyError = fmt.Errorf("hook job failed")
+	var events []kube.ProgressEvent
+	instAction.cfg.Progress = func(e kube.ProgressEvent) { events = append(events, e) }
+
+	_, err := instAction.Run(buildChart(), nil)
+	is.Error(err)
+
+	if is.Len(events, 2) {
+		is.Equal(kube.ProgressFailed, events[1].Phase)
+		is.Equal("hook job failed", events[1].Message)
+		is.Equal("post-install", events[1].Hook)
+	}
+}
+
+func TestHookOutput(t *testing.T) {
+	is := assert.New(t)
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "migrate-abcde",
+			Namespace: "spaced",
+			Labels:    map[string]string{"job-name": "migrate"},
+		},
+		Spec: v1.PodSpec{
+			InitContainers: []v1.Container{{Name: "wait"}},
+			Containers:     []v1.Container{{Name: "migrate"}},
+		},
+	}
+	other := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "spaced"}}
+	event := func(name, kind, objName, reason string, ts int64) *v1.Event {
+		return &v1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "spaced"},
+			InvolvedObject: v1.ObjectReference{Kind: kind, Name: objName},
+			Type:           v1.EventTypeWarning,
+			Reason:         reason,
+			Message:        reason + " happened",
+			LastTimestamp:  metav1.Unix(ts, 0),
+		}
+	}
+	client := fake.NewSimpleClientset(
+		pod, other,
+		event("e1", "Job", "migrate", "BackoffLimitExceeded", 20),
+		event("e2", "Pod", "migrate-abcde", "BackOff", 10),
+		event("e3", "Pod", "web", "Unrelated", 15),
+	)
+	resources := kube.ResourceList{
+		{Name: "migrate", Namespace: "spaced", Mapping: &meta.RESTMapping{GroupVersionKind: batchv1.SchemeGroupVersion.WithKind("Job")}},
+		{Name: "config", Namespace: "spaced", Mapping: &meta.RESTMapping{GroupVersionKind: v1.SchemeGroupVersion.WithKind("ConfigMap")}},
+	}
+
+	logs, events := hookOutput(client, resources, func(string, ...interface{}) {})
+	// the fake client returns "fake logs" for every container
+	is.Equal([]release.HookLog{
+		{Pod: "migrate-abcde", Container: "wait", Log: "fake logs"},
+		{Pod: "migrate-abcde", Container: "migrate", Log: "fake logs"},
+	}, logs)
+	is.Equal([]string{
+		"Warning BackOff Pod/migrate-abcde: BackOff happened",
+		"Warning BackoffLimitExceeded Job/migrate: BackoffLimitExceeded happened",
+	}, events)
+}
+
+func TestHookOutputLimits(t *testing.T) {
+	is := assert.New(t)
+	defer func(b, e int) { hookLogMaxBytes, hookEventsMax = b, e }(hookLogMaxBytes, hookEventsMax)
+	hookLogMaxBytes, hookEventsMax = 4, 1
+
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "hook", Namespace: "spaced"},
+		Spec:       v1.PodSpec{Containers: []v1.Container{{Name: "main"}}},
+	}
+	client := fake.NewSimpleClientset(pod,
+		&v1.Event{ObjectMeta: metav1.ObjectMeta{Name: "e1", Namespace: "spaced"}, InvolvedObject: v1.ObjectReference{Kind: "Pod", Name: "hook"}, Reason: "Old", LastTimestamp: metav1.Unix(1, 0)},
+		&v1.Event{ObjectMeta: metav1.ObjectMeta{Name: "e2", Namespace: "spaced"}, InvolvedObject: v1.ObjectReference{Kind: "Pod", Name: "hook"}, Reason: "New", LastTimestamp: metav1.Unix(2, 0)},
+	)
+	resources := kube.ResourceList{
+		{Name: "hook", Namespace: "spaced", Mapping: &meta.RESTMapping{GroupVersionKind: v1.SchemeGroupVersion.WithKind("Pod")}},
+	}
+
+	logs, events := hookOutput(client, resources, func(string, ...interface{}) {})
+	if is.Len(logs, 1) {
+		is.Equal("logs", logs[0].Log)
+		is.True(logs[0].Truncated)
+	}
+	if is.Len(events, 1) {
+		is.True(strings.Contains(events[0], "New"))
+	}
+}
+
+func TestLimitHookOutput(t *testing.T) {
+	is := assert.New(t)
+	defer func(b int) { hookOutputMaxBytes = b }(hookOutputMaxBytes)
+	hookOutputMaxBytes = 10
+
+	succeeded := &release.Hook{Name: "a", LastRun: release.HookExecution{
+		Phase:      release.HookPhaseSucceeded,
+		Logs:       []release.HookLog{{Pod: "a", Container: "main", Log: "done"}},
+		KubeEvents: []string{"Normal Completed"},
+	}}
+	failed := &release.Hook{Name: "b", LastRun: release.HookExecution{
+		Phase:      release.HookPhaseFailed,
+		Logs:       []release.HookLog{{Pod: "b", Container: "main", Log: "failure"}},
+		KubeEvents: []string{"old", "new"},
+	}}
+	limitHookOutput([]*release.Hook{succeeded, failed})
+
+	// the output of the failed hook is kept first
+	is.Equal([]release.HookLog{{Pod: "b", Container: "main", Log: "failure"}}, failed.LastRun.Logs)
+	is.Equal([]string{"new"}, failed.LastRun.KubeEvents)
+	is.Equal([]release.HookLog{{Pod: "a", Container: "main", Log: "", Truncated: true}}, succeeded.LastRun.Logs)
+	is.Nil(succeeded.LastRun.KubeEvents)
+}
+
+func TestLogTail(t *testing.T) {
+	is := assert.New(t)
+	is.Equal("abc", logTail("abc", 4))
+	is.Equal("bc", logTail("abc", 2))
+	// "é" is two bytes, and is not split
+	is.Equal("b", logTail("éb", 2))
+	is.Equal("éb", logTail("aéb", 3))
+}
+
+func TestHasHookPods(t *testing.T) {
+	is := assert.New(t)
+	is.False(hasHookPods(kube.ResourceList{&resource.Info{}}))
+	is.False(hasHookPods(kube.ResourceList{{Mapping: &meta.RESTMapping{GroupVersionKind: v1.SchemeGroupVersion.WithKind("ConfigMap")}}}))
+	is.True(hasHookPods(kube.ResourceList{{Mapping: &meta.RESTMapping{GroupVersionKind: v1.SchemeGroupVersion.WithKind("Pod")}}}))
+}
+
+// hookKubeClient runs every hook resource for a short while and records how
+// many of them ran at the same time. The manifest of a hook is its name.
+type hookKubeClient struct {
+	kubefake.PrintingKubeClient
+	fail map[string]bool
+	// delay overrides how long the named hooks run
+	delay map[string]time.Duration
+
+	mu      sync.Mutex
+	running int
+	max     int
+	started []string
+	deleted []string
+}
+
+func newHookKubeClient(fail ...string) *hookKubeClient {
+	c := &hookKubeClient{PrintingKubeClient: kubefake.PrintingKubeClient{Out: ioutil.Discard}, fail: map[string]bool{}, delay: map[string]time.Duration{}}
+	for _, name := range fail {
+		c.fail[name] = true
+	}
+	return c
+}
+
+func (c *hookKubeClient) Build(r io.Reader, _ bool) (kube.ResourceList, error) {
+	name, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return kube.ResourceList{{Name: string(name)}}, nil
+}
+
+func (c *hookKubeClient) Create(resources kube.ResourceList) (*kube.Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.started = append(c.started, resources[0].Name)
+	return &kube.Result{Created: resources}, nil
+}
+
+func (c *hookKubeClient) WatchUntilReady(resources kube.ResourceList, _ time.Duration) error {
+	c.mu.Lock()
+	c.running++
+	if c.running > c.max {
+		c.max = c.running
+	}
+	delay, ok := c.delay[resources[0].Name]
+	if !ok {
+		delay = 20 * time.Millisecond
+	}
+	c.mu.Unlock()
+
+	time.Sleep(delay)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running--
+	if c.fail[resources[0].Name] {
+		return fmt.Errorf("hook %s failed", resources[0].Name)
+	}
+	return nil
+}
+
+func (c *hookKubeClient) WatchUntilReadyWithContext(_ context.Context, resources kube.ResourceList, d time.Duration) error {
+	return c.WatchUntilReady(resources, d)
+}
+
+func (c *hookKubeClient) Delete(resources kube.ResourceList) (*kube.Result, []error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleted = append(c.deleted, resources[0].Name)
+	return &kube.Result{Deleted: resources}, nil
+}
+
+func hookRelease(hooks ...*release.Hook) *release.Release {
+	rel := releaseStub()
+	for _, h := range hooks {
+		h.Kind = "Job"
+		h.Path = "templates/" + h.Name
+		h.Manifest = h.Name
+		h.Events = []release.HookEvent{release.HookPreInstall}
+	}
+	rel.Hooks = hooks
+	return rel
+}
+
+func TestExecHookParallel(t *testing.T) {
+	is := assert.New(t)
+	cfg := actionConfigFixture(t)
+	client := newHookKubeClient()
+	cfg.KubeClient = client
+	var mu sync.Mutex
+	var events []kube.ProgressEvent
+	cfg.Progress = func(e kube.ProgressEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}
+
+	rel := hookRelease(
+		&release.Hook{Name: "last", Weight: 1},
+		&release.Hook{Name: "a", Weight: 0},
+		&release.Hook{Name: "b", Weight: 0},
+		&release.Hook{Name: "c", Weight: 0},
+		&release.Hook{Name: "first", Weight: -1},
+	)
+	is.NoError(cfg.execHook(context.Background(), rel, release.HookPreInstall, time.Minute, 2))
+
+	is.Equal(2, client.max)
+	if is.Len(client.started, 5) {
+		is.Equal("first", client.started[0])
+		is.ElementsMatch([]string{"a", "b", "c"}, client.started[1:4])
+		is.Equal("last", client.started[4])
+	}
+	for _, h := range rel.Hooks {
+		is.Equal(release.HookPhaseSucceeded, h.LastRun.Phase, h.Name)
+	}
+	// every hook is deleted before it is created, by the default policy
+	is.ElementsMatch([]string{"first", "a", "b", "c", "last"}, client.deleted)
+	is.Len(events, 10)
+}
+
+func TestExecHookSequential(t *testing.T) {
+	is := assert.New(t)
+	cfg := actionConfigFixture(t)
+	client := newHookKubeClient()
+	cfg.KubeClient = client
+
+	rel := hookRelease(
+		&release.Hook{Name: "b", Weight: 0},
+		&release.Hook{Name: "a", Weight: 0},
+		&release.Hook{Name: "c", Weight: 0},
+	)
+	is.NoError(cfg.execHook(context.Background(), rel, release.HookPreInstall, time.Minute, 1))
+
+	is.Equal(1, client.max)
+	is.Equal([]string{"a", "b", "c"}, client.started)
+}
+
+func TestExecHookParallelFailed(t *testing.T) {
+	is := assert.New(t)
+	cfg := actionConfigFixture(t)
+	client := newHookKubeClient("a", "b")
+	client.delay["b"] = 40 * time.Millisecond
+	cfg.KubeClient = client
+
+	failed := []release.HookDeletePolicy{release.HookFailed}
+	succeeded := []release.HookDeletePolicy{release.HookSucceeded}
+	rel := hookRelease(
+		&release.Hook{Name: "a", Weight: 0, DeletePolicies: failed},
+		&release.Hook{Name: "b", Weight: 0, DeletePolicies: succeeded},
+		&release.Hook{Name: "c", Weight: 0, DeletePolicies: succeeded},
+		&release.Hook{Name: "d", Weight: 1},
+	)
+	err := cfg.execHook(context.Background(), rel, release.HookPreInstall, time.Minute, 3)
+	if is.Error(err) {
+		is.Contains(err.Error(), "hook a failed")
+	}
+
+	is.Equal(3, client.max)
+	is.Equal(release.HookPhaseFailed, rel.Hooks[0].LastRun.Phase)
+	is.Equal(release.HookPhaseFailed, rel.Hooks[1].LastRun.Phase)
+	is.Equal(release.HookPhaseSucceeded, rel.Hooks[2].LastRun.Phase)
+	// hooks of later weights do not run
+	is.Equal(release.HookExecution{}, rel.Hooks[3].LastRun)
+	is.NotContains(client.started, "d")
+	// only the failed hook with the hook-failed policy is deleted
+	is.Equal([]string{"a"}, client.deleted)
+}
+
+func TestExecHookGroupFailedQueued(t *testing.T) {
+	is := assert.New(t)
+	cfg := actionConfigFixture(t)
+	client := newHookKubeClient("a")
+	cfg.KubeClient = client
+
+	rel := hookRelease(
+		&release.Hook{Name: "a", Weight: 0},
+		&release.Hook{Name: "b", Weight: 0},
+		&release.Hook{Name: "c", Weight: 0},
+	)
+	err := cfg.execHookGroup(context.Background(), rel, release.HookPreInstall, rel.Hooks, time.Minute, 1)
+	if is.Error(err) {
+		is.Contains(err.Error(), "hook a failed")
+	}
+
+	// the queued hooks never run once a hook has failed
+	is.Equal([]string{"a"}, client.started)
+	is.Equal(release.HookPhaseFailed, rel.Hooks[0].LastRun.Phase)
+	is.Equal(release.HookExecution{}, rel.Hooks[1].LastRun)
+	is.Equal(release.HookExecution{}, rel.Hooks[2].LastRun)
+}
+
+func TestHookGroups(t *testing.T) {
+	is := assert.New(t)
+	hooks := []*release.Hook{
+		{Name: "a", Weight: -1},
+		{Name: "b", Weight: 0},
+		{Name: "c", Weight: 0},
+		{Name: "d", Weight: 2},
+	}
+	names := func(groups [][]*release.Hook) [][]string {
+		var out [][]string
+		for _, g := range groups {
+			var n []string
+			for _, h := range g {
+				n = append(n, h.Name)
+			}
+			out = append(out, n)
+		}
+		return out
+	}
+
+	is.Equal([][]string{{"a"}, {"b", "c"}, {"d"}}, names(hookGroups(hooks, 4)))
+	is.Equal([][]string{{"a"}, {"b"}, {"c"}, {"d"}}, names(hookGroups(hooks, 1)))
+	is.Equal([][]string{{"a"}, {"b"}, {"c"}, {"d"}}, names(hookGroups(hooks, 0)))
+	is.Empty(hookGroups(nil, 4))
+}
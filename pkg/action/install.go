@@ -18,6 +18,7 @@ package action
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -101,6 +102,34 @@ type Install struct {
 	// OutputDir/<ReleaseName>
 	UseReleaseName bool
 	PostRenderer   postrender.PostRenderer
+	// ValueSources names the values file or flag of each user-supplied
+	// value in schema validation errors.
+	ValueSources chartutil.ValueSources
+	// SensitiveValues are the JSON pointers of the values that came from
+	// encrypted values files. They are stored with the release, so that they
+	// are redacted when its values are shown.
+	SensitiveValues []string
+	// ServerSideApply sends resources with server-side apply instead of
+	// client-side patches. Combined with DryRun, the resources are validated
+	// by the API server with a server-side dry run.
+	ServerSideApply bool
+	// ForceConflicts takes ownership of fields managed by other field
+	// managers. It only applies when ServerSideApply is set.
+	ForceConflicts bool
+	// HookParallelism is the maximum number of hooks of the same weight that
+	// run at the same time. Hooks of different weights always run in order.
+	// Zero or one runs the hooks one at a time.
+	HookParallelism int
+	// Adopt takes over live objects rendered by the chart that lack the
+	// ownership metadata of a release, instead of refusing to install over
+	// them. The ownership metadata is set before the release is stored.
+	Adopt bool
+	// ForceAdopt also takes over objects owned by another release. It only
+	// applies when Adopt is set.
+	ForceAdopt bool
+	// Adopted holds the objects taken over by the last run, or the objects
+	// that would be taken over when DryRun is set.
+	Adopted kube.ResourceList
 }
 
 // ChartPathOptions captures common options used for controlling chart paths
@@ -171,6 +200,13 @@ func (i *Install) installCRDs(crds []chart.CRD) error {
 //
 // If DryRun is set to true, this will prepare the release, but not install it
 func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.Release, error) {
+	return i.RunWithContext(context.Background(), chrt, vals)
+}
+
+// RunWithContext executes the installation like Run, but aborts it when ctx
+// is done. An aborted installation is marked as failed and, if Atomic is set,
+// uninstalled.
+func (i *Install) RunWithContext(ctx context.Context, chrt *chart.Chart, vals map[string]interface{}) (*release.Release, error) {
 	// Check reachability of cluster unless in client-only mode (e.g. `helm template` without `--validate`)
 	if !i.ClientOnly {
 		if err := i.cfg.KubeClient.IsReachable(); err != nil {
@@ -182,6 +218,18 @@ func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.
 		return nil, err
 	}
 
+	if !i.ClientOnly && !i.DryRun {
+		unlock, err := i.cfg.lockRelease(ctx, i.ReleaseName, i.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+		// Another client may have taken the name while we waited for the lock.
+		if err := i.availableName(); err != nil {
+			return nil, err
+		}
+	}
+
 	// Pre-install anything in the crd/ directory. We do this before Helm
 	// contacts the upstream server and builds the capabilities object.
 	if crds := chrt.CRDObjects(); !i.ClientOnly && !i.SkipCRDs && len(crds) > 0 {
@@ -223,11 +271,12 @@ func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.
 	//special case for helm template --is-upgrade
 	isUpgrade := i.IsUpgrade && i.DryRun
 	options := chartutil.ReleaseOptions{
-		Name:      i.ReleaseName,
-		Namespace: i.Namespace,
-		Revision:  1,
-		IsInstall: !isUpgrade,
-		IsUpgrade: isUpgrade,
+		Name:         i.ReleaseName,
+		Namespace:    i.Namespace,
+		Revision:     1,
+		IsInstall:    !isUpgrade,
+		IsUpgrade:    isUpgrade,
+		ValueSources: i.ValueSources,
 	}
 	valuesToRender, err := chartutil.ToRenderValues(chrt, vals, options, caps)
 	if err != nil {
@@ -270,15 +319,30 @@ func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.
 	// we'll end up in a state where we will delete those resources upon
 	// deleting the release because the manifest will be pointing at that
 	// resource
+	i.Adopted = nil
 	if !i.ClientOnly && !isUpgrade && len(resources) > 0 {
-		toBeAdopted, err = existingResourceConflict(resources, rel.Name, rel.Namespace)
+		if i.Adopt {
+			i.Adopted, _, err = adoptResources(resources, rel.Name, rel.Namespace, i.ForceAdopt, true)
+			if err != nil {
+				return nil, errors.Wrap(err, "unable to adopt the existing resources")
+			}
+		}
+		toBeAdopted, err = existingResourceConflict(resources.Difference(i.Adopted), rel.Name, rel.Namespace)
 		if err != nil {
 			return nil, errors.Wrap(err, "rendered manifests contain a resource that already exists. Unable to continue with install")
 		}
+		toBeAdopted = append(toBeAdopted, i.Adopted...)
 	}
 
 	// Bail out here if it is a dry run
 	if i.DryRun {
+		// With server-side apply, let the API server and its admission
+		// webhooks validate the resources without persisting them.
+		if i.ServerSideApply && !i.ClientOnly && len(resources) > 0 {
+			if _, err := i.cfg.createResources(resources, applyOptions(true, i.ForceConflicts, true)); err != nil {
+				return rel, errors.Wrap(err, "server-side dry run failed")
+			}
+		}
 		rel.Info.Description = "Dry run complete"
 		return rel, nil
 	}
@@ -316,6 +380,19 @@ func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.
 		}
 	}
 
+	// Nothing has been stored yet, so there is nothing to clean up.
+	if err := ctx.Err(); err != nil {
+		return rel, err
+	}
+
+	// Stamp the ownership metadata on the adopted objects before the first
+	// revision is stored, so that the release owns them from the start.
+	if len(i.Adopted) > 0 {
+		if _, _, err := adoptResources(i.Adopted, rel.Name, rel.Namespace, i.ForceAdopt, false); err != nil {
+			return nil, errors.Wrap(err, "unable to adopt the existing resources")
+		}
+	}
+
 	// Store the release in history before continuing (new in Helm 3). We always know
 	// that this is a create operation.
 	if err := i.cfg.Releases.Create(rel); err != nil {
@@ -324,10 +401,11 @@ func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.
 		// not working.
 		return rel, err
 	}
+	defer i.cfg.startHeartbeat(rel)()
 
 	// pre-install hooks
 	if !i.DisableHooks {
-		if err := i.cfg.execHook(rel, release.HookPreInstall, i.Timeout); err != nil {
+		if err := i.cfg.execHook(ctx, rel, release.HookPreInstall, i.Timeout, i.HookParallelism); err != nil {
 			return i.failRelease(rel, fmt.Errorf("failed pre-install: %s", err))
 		}
 	}
@@ -335,30 +413,28 @@ func (i *Install) Run(chrt *chart.Chart, vals map[string]interface{}) (*release.
 	// At this point, we can do the install. Note that before we were detecting whether to
 	// do an update, but it's not clear whether we WANT to do an update if the re-use is set
 	// to true, since that is basically an upgrade operation.
+	opts := applyOptions(i.ServerSideApply, i.ForceConflicts, false)
 	if len(toBeAdopted) == 0 && len(resources) > 0 {
-		if _, err := i.cfg.KubeClient.Create(resources); err != nil {
+		if _, err := i.cfg.createResources(resources, opts); err != nil {
 			return i.failRelease(rel, err)
 		}
 	} else if len(resources) > 0 {
-		if _, err := i.cfg.KubeClient.Update(toBeAdopted, resources, false); err != nil {
+		if _, err := i.cfg.updateResources(toBeAdopted, resources, false, opts); err != nil {
 			return i.failRelease(rel, err)
 		}
 	}
+	if err := ctx.Err(); err != nil {
+		return i.failRelease(rel, err)
+	}
 
 	if i.Wait {
-		if i.WaitForJobs {
-			if err := i.cfg.KubeClient.WaitWithJobs(resources, i.Timeout); err != nil {
-				return i.failRelease(rel, err)
-			}
-		} else {
-			if err := i.cfg.KubeClient.Wait(resources, i.Timeout); err != nil {
-				return i.failRelease(rel, err)
-			}
+		if err := i.cfg.waitForResources(ctx, resources, i.Timeout, i.WaitForJobs); err != nil {
+			return i.failRelease(rel, err)
 		}
 	}
 
 	if !i.DisableHooks {
-		if err := i.cfg.execHook(rel, release.HookPostInstall, i.Timeout); err != nil {
+		if err := i.cfg.execHook(ctx, rel, release.HookPostInstall, i.Timeout, i.HookParallelism); err != nil {
 			return i.failRelease(rel, fmt.Errorf("failed post-install: %s", err))
 		}
 	}
@@ -388,6 +464,7 @@ func (i *Install) failRelease(rel *release.Release, err error) (*release.Release
 	if i.Atomic {
 		i.cfg.Log("Install failed and atomic is set, uninstalling release")
 		uninstall := NewUninstall(i.cfg)
+		uninstall.lockHeld = true
 		uninstall.DisableHooks = i.DisableHooks
 		uninstall.KeepHistory = false
 		uninstall.Timeout = i.Timeout
@@ -448,7 +525,8 @@ func (i *Install) createRelease(chrt *chart.Chart, rawVals map[string]interface{
 			LastDeployed:  ts,
 			Status:        release.StatusUnknown,
 		},
-		Version: 1,
+		Version:         1,
+		SensitiveValues: i.SensitiveValues,
 	}
 }
 
@@ -17,7 +17,9 @@ limitations under the License.
 package action
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
@@ -25,12 +27,17 @@ import (
 	"regexp"
 	"strings"
 	"testing"
+	stdtime "time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	"helm.sh/helm/v3/internal/test"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/kube"
 	kubefake "helm.sh/helm/v3/pkg/kube/fake"
 	"helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/storage/driver"
@@ -420,6 +427,65 @@ func TestInstallRelease_Atomic(t *testing.T) {
 	})
 }
 
+// cancellingKubeClient cancels the running operation while it waits for the
+// resources, as an interrupt or an upstream cancel would.
+type cancellingKubeClient struct {
+	*kubefake.FailingKubeClient
+	cancel context.CancelFunc
+}
+
+func (c *cancellingKubeClient) WaitWithContext(ctx context.Context, _ kube.ResourceList, _ stdtime.Duration) error {
+	c.cancel()
+	return ctx.Err()
+}
+
+func TestInstallRelease_Cancelled(t *testing.T) {
+	is := assert.New(t)
+
+	t.Run("release is marked failed", func(t *testing.T) {
+		instAction := installAction(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		instAction.cfg.KubeClient = &cancellingKubeClient{instAction.cfg.KubeClient.(*kubefake.FailingKubeClient), cancel}
+		instAction.Wait = true
+
+		res, err := instAction.RunWithContext(ctx, buildChart(), map[string]interface{}{})
+		is.Error(err)
+		is.Equal(context.Canceled, errors.Cause(err))
+
+		rel, err := instAction.cfg.Releases.Get(res.Name, res.Version)
+		is.NoError(err)
+		is.Equal(release.StatusFailed, rel.Info.Status)
+	})
+
+	t.Run("atomic uninstall succeeds", func(t *testing.T) {
+		instAction := installAction(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		instAction.cfg.KubeClient = &cancellingKubeClient{instAction.cfg.KubeClient.(*kubefake.FailingKubeClient), cancel}
+		instAction.Atomic = true
+
+		res, err := instAction.RunWithContext(ctx, buildChart(), map[string]interface{}{})
+		is.Error(err)
+		is.Contains(err.Error(), "atomic")
+
+		_, err = instAction.cfg.Releases.Get(res.Name, res.Version)
+		is.Equal(driver.ErrReleaseNotFound, err)
+	})
+
+	t.Run("nothing is stored when already cancelled", func(t *testing.T) {
+		instAction := installAction(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		res, err := instAction.RunWithContext(ctx, buildChart(), map[string]interface{}{})
+		is.Equal(context.Canceled, err)
+
+		_, err = instAction.cfg.Releases.Get(res.Name, res.Version)
+		is.Equal(driver.ErrReleaseNotFound, err)
+	})
+}
+
 func TestNameTemplate(t *testing.T) {
 	testCases := []nameTemplateTestCase{
 		// Just a straight up nop please
@@ -668,3 +734,74 @@ func TestNameAndChartGenerateName(t *testing.T) {
 		})
 	}
 }
+
+// liveKubeClient builds the given resources whatever the manifest.
+type liveKubeClient struct {
+	kubefake.PrintingKubeClient
+	resources kube.ResourceList
+}
+
+func (c *liveKubeClient) Build(_ io.Reader, _ bool) (kube.ResourceList, error) {
+	return c.resources, nil
+}
+
+func TestInstallRelease_Adopt(t *testing.T) {
+	is := assert.New(t)
+	live := map[string]*corev1.ConfigMap{
+		"unowned": {},
+		"other": {ObjectMeta: metav1.ObjectMeta{
+			Labels:      map[string]string{appManagedByLabel: appManagedByHelm},
+			Annotations: map[string]string{helmReleaseNameAnnotation: "other-release", helmReleaseNamespaceAnnotation: "ns-a"},
+		}},
+	}
+	patches := map[string]string{}
+	newAction := func(names ...string) *Install {
+		instAction := installAction(t)
+		instAction.Namespace = "ns-a"
+		instAction.DisableHooks = true
+		client := &liveKubeClient{PrintingKubeClient: kubefake.PrintingKubeClient{Out: ioutil.Discard}}
+		for _, name := range names {
+			client.resources.Append(newLiveConfigMapResource(name, live, patches))
+		}
+		instAction.cfg.KubeClient = client
+		return instAction
+	}
+
+	// Without Adopt, existing objects block the install
+	_, err := newAction("unowned", "missing").Run(buildChart(), nil)
+	is.Error(err)
+	is.Contains(err.Error(), "rendered manifests contain a resource that already exists")
+
+	// A dry run reports the objects that would be adopted without patching them
+	instAction := newAction("unowned", "missing")
+	instAction.Adopt = true
+	instAction.DryRun = true
+	_, err = instAction.Run(buildChart(), nil)
+	is.NoError(err)
+	is.Len(instAction.Adopted, 1)
+	is.Equal("unowned", instAction.Adopted[0].Name)
+	is.Empty(patches)
+
+	instAction = newAction("unowned", "missing")
+	instAction.Adopt = true
+	res, err := instAction.Run(buildChart(), nil)
+	is.NoError(err)
+	is.Len(instAction.Adopted, 1)
+	is.Contains(patches, "unowned")
+	is.Equal(release.StatusDeployed, res.Info.Status)
+
+	// Objects owned by another release are only adopted with ForceAdopt
+	instAction = newAction("other")
+	instAction.Adopt = true
+	_, err = instAction.Run(buildChart(), nil)
+	is.Error(err)
+	is.Contains(err.Error(), "cannot be adopted")
+	is.NotContains(patches, "other")
+
+	instAction = newAction("other")
+	instAction.Adopt = true
+	instAction.ForceAdopt = true
+	_, err = instAction.Run(buildChart(), nil)
+	is.NoError(err)
+	is.Contains(patches, "other")
+}
@@ -0,0 +1,47 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"time"
+
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// defaultLockTimeout is how long an operation without a timeout waits for the
+// lock of a release held by another client.
+const defaultLockTimeout = 5 * time.Minute
+
+// newLeases returns the Locker of the Kubernetes storage drivers.
+func newLeases(lc *lazyClient, log DebugLog) *driver.Leases {
+	l := driver.NewLeases(newLeaseClient(lc))
+	l.Log = log
+	return l
+}
+
+// lockRelease acquires the operation lock of the named release, so that no
+// other Helm client operates on the release until the returned function is
+// called. While the lock is held by another client, it waits up to timeout.
+func (c *Configuration) lockRelease(ctx context.Context, name string, timeout time.Duration) (func(), error) {
+	if timeout <= 0 {
+		timeout = defaultLockTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return c.Releases.Lock(ctx, name)
+}
@@ -0,0 +1,163 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// testLocker is a non-reentrant storage.Locker that fails instead of waiting
+// for a lock held by someone else.
+type testLocker struct {
+	mu     sync.Mutex
+	held   map[string]bool
+	locked []string
+}
+
+func newTestLocker() *testLocker {
+	return &testLocker{held: map[string]bool{}}
+}
+
+func (l *testLocker) Lock(_ context.Context, name string) (func() error, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.held[name] {
+		return nil, errors.Errorf("release %q is locked by another client", name)
+	}
+	l.held[name] = true
+	l.locked = append(l.locked, name)
+	return func() error {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.held, name)
+		return nil
+	}, nil
+}
+
+func TestInstallRelease_Lock(t *testing.T) {
+	is := assert.New(t)
+	instAction := installAction(t)
+	locker := newTestLocker()
+	instAction.cfg.Releases.Locker = locker
+
+	_, err := instAction.Run(buildChart(), nil)
+	is.NoError(err)
+	is.Equal([]string{"test-install-release"}, locker.locked)
+	is.Empty(locker.held, "the lock should be released")
+
+	// a dry run does not lock the release
+	instAction = installAction(t)
+	instAction.cfg.Releases.Locker = locker
+	instAction.DryRun = true
+	_, err = instAction.Run(buildChart(), nil)
+	is.NoError(err)
+	is.Len(locker.locked, 1)
+}
+
+func TestInstallRelease_Locked(t *testing.T) {
+	is := assert.New(t)
+	instAction := installAction(t)
+	locker := newTestLocker()
+	locker.held["test-install-release"] = true
+	instAction.cfg.Releases.Locker = locker
+
+	_, err := instAction.Run(buildChart(), nil)
+	is.Error(err)
+	is.Contains(err.Error(), "locked by another client")
+
+	_, err = instAction.cfg.Releases.Last("test-install-release")
+	is.Error(err, "no release should have been stored")
+}
+
+func TestInstallRelease_AtomicLock(t *testing.T) {
+	is := assert.New(t)
+	instAction := installAction(t)
+	locker := newTestLocker()
+	instAction.cfg.Releases.Locker = locker
+	failer := instAction.cfg.KubeClient.(*kubefake.FailingKubeClient)
+	failer.WaitError = fmt.Errorf("I timed out")
+	instAction.Atomic = true
+
+	// the atomic uninstall runs under the lock of the install
+	_, err := instAction.Run(buildChart(), nil)
+	is.Error(err)
+	is.Contains(err.Error(), "has been uninstalled due to atomic being set")
+	is.Len(locker.locked, 1)
+	is.Empty(locker.held)
+}
+
+func TestUpgradeRelease_AtomicLock(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+	upAction := upgradeAction(t)
+	locker := newTestLocker()
+	upAction.cfg.Releases.Locker = locker
+
+	rel := releaseStub()
+	rel.Name = "nuketown"
+	rel.Info.Status = release.StatusDeployed
+	req.NoError(upAction.cfg.Releases.Create(rel))
+
+	failer := upAction.cfg.KubeClient.(*kubefake.FailingKubeClient)
+	failer.WatchUntilReadyError = fmt.Errorf("arming key removed")
+	upAction.Atomic = true
+
+	// the atomic rollback runs under the lock of the upgrade
+	_, err := upAction.Run(rel.Name, buildChart(), nil)
+	req.Error(err)
+	is.Contains(err.Error(), "has been rolled back due to atomic being set")
+	is.Equal([]string{"nuketown"}, locker.locked)
+	is.Empty(locker.held)
+
+	locker.held["nuketown"] = true
+	_, err = upAction.Run(rel.Name, buildChart(), nil)
+	req.Error(err)
+	is.Contains(err.Error(), "locked by another client")
+}
+
+func TestRollbackAndUninstall_Lock(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+	config := actionConfigFixture(t)
+	locker := newTestLocker()
+	config.Releases.Locker = locker
+
+	for version, status := range []release.Status{release.StatusSuperseded, release.StatusDeployed} {
+		rel := namedReleaseStub("locked", status)
+		rel.Version = version + 1
+		req.NoError(config.Releases.Create(rel))
+	}
+
+	rollback := NewRollback(config)
+	req.NoError(rollback.Run("locked"))
+
+	_, err := NewUninstall(config).Run("locked")
+	req.NoError(err)
+
+	is.Equal([]string{"locked", "locked"}, locker.locked)
+	is.Empty(locker.held)
+}
@@ -0,0 +1,295 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// Orphan is an object of the cluster that carries the ownership metadata of a
+// release but is not in its manifest.
+type Orphan struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	// Kept is true if the object has the helm.sh/resource-policy: keep
+	// annotation. Kept objects are only deleted with Prune.IncludeKept.
+	Kept bool `json:"kept,omitempty"`
+	// Deleted is true if the object was deleted.
+	Deleted bool `json:"deleted,omitempty"`
+
+	resource schema.GroupVersionResource
+}
+
+// Prune is the action for finding and deleting the objects of a release that
+// are no longer in its manifest, e.g. those left over by a failed upgrade, by
+// hooks that were skipped, or by the keep resource policy.
+//
+// It provides the implementation of 'helm prune'.
+type Prune struct {
+	cfg *Configuration
+
+	// Namespace is the namespace of the release.
+	Namespace string
+	// DryRun finds the orphaned objects without deleting them.
+	DryRun bool
+	// IncludeKept also deletes the objects with the keep resource policy.
+	IncludeKept bool
+
+	discovery discovery.DiscoveryInterface
+	dynamic   dynamic.Interface
+}
+
+// NewPrune creates a new Prune object with the given configuration.
+func NewPrune(cfg *Configuration) *Prune {
+	return &Prune{
+		cfg: cfg,
+	}
+}
+
+// Run finds the orphaned objects of the named release, and deletes them
+// unless DryRun is set.
+func (p *Prune) Run(name string) ([]*Orphan, error) {
+	return p.RunWithConfirm(name, nil)
+}
+
+// RunWithConfirm is like Run, but only deletes the orphaned objects if confirm
+// returns true for them. The release stays locked from finding the objects
+// until they are deleted, so that they cannot be adopted by an upgrade while
+// the deletion is confirmed.
+func (p *Prune) RunWithConfirm(name string, confirm func([]*Orphan) bool) ([]*Orphan, error) {
+	if !p.DryRun {
+		unlock, err := p.cfg.lockRelease(context.Background(), name, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	orphans, err := p.Find(name)
+	if err != nil || p.DryRun {
+		return orphans, err
+	}
+	if confirm != nil && !confirm(orphans) {
+		return orphans, nil
+	}
+	return orphans, p.Delete(orphans)
+}
+
+// Find returns the objects of every namespace and of every kind served by the
+// API server that carry the ownership metadata of the named release, but are
+// not in the manifest of its deployed revision. If the release has no
+// revision left, every object it owns is orphaned.
+func (p *Prune) Find(name string) ([]*Orphan, error) {
+	if err := p.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return nil, errors.Errorf("release name is invalid: %s", name)
+	}
+	if p.Namespace == "" {
+		return nil, errors.New("no namespace for the release")
+	}
+	if err := p.initClients(); err != nil {
+		return nil, err
+	}
+
+	manifest, err := p.currentManifest(name)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := p.cfg.KubeClient.Build(bytes.NewBufferString(manifest), false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build kubernetes objects from release manifest")
+	}
+	current := map[string]bool{}
+	for _, info := range resources {
+		current[orphanKey(info.Mapping.GroupVersionKind.Kind, info.Namespace, info.Name)] = true
+	}
+
+	lists, err := discovery.ServerPreferredResources(p.discovery)
+	if err != nil {
+		if !discovery.IsGroupDiscoveryFailedError(err) {
+			return nil, errors.Wrap(err, "could not discover the resources of the cluster")
+		}
+		p.cfg.Log("WARNING: some resources of the cluster could not be discovered: %s", err)
+	}
+
+	selector := fmt.Sprintf("%s=%s", appManagedByLabel, appManagedByHelm)
+	seen := map[string]bool{}
+	var orphans []*Orphan
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if strings.Contains(r.Name, "/") || !hasVerbs(r, "list", "delete") {
+				continue
+			}
+			gvr := gv.WithResource(r.Name)
+			objs, err := p.dynamic.Resource(gvr).List(context.Background(), metav1.ListOptions{LabelSelector: selector})
+			if err != nil {
+				// Resources the user may not list cannot be pruned either.
+				p.cfg.Log("skipping %s: %s", gvr, err)
+				continue
+			}
+			for _, obj := range objs.Items {
+				// Objects are served by every group that has their kind, so
+				// they are told apart by their UID.
+				if uid := string(obj.GetUID()); uid != "" {
+					if seen[uid] {
+						continue
+					}
+					seen[uid] = true
+				}
+				annos := obj.GetAnnotations()
+				if annos[helmReleaseNameAnnotation] != name || annos[helmReleaseNamespaceAnnotation] != p.Namespace {
+					continue
+				}
+				if current[orphanKey(r.Kind, obj.GetNamespace(), obj.GetName())] {
+					continue
+				}
+				orphans = append(orphans, &Orphan{
+					APIVersion: gv.String(),
+					Kind:       r.Kind,
+					Namespace:  obj.GetNamespace(),
+					Name:       obj.GetName(),
+					Kept:       strings.ToLower(strings.TrimSpace(annos[kube.ResourcePolicyAnno])) == kube.KeepPolicy,
+					resource:   gvr,
+				})
+			}
+		}
+	}
+
+	sort.Slice(orphans, func(i, j int) bool {
+		a, b := orphans[i], orphans[j]
+		return orphanKey(a.Kind, a.Namespace, a.Name) < orphanKey(b.Kind, b.Namespace, b.Name)
+	})
+	return orphans, nil
+}
+
+// Delete deletes the orphaned objects found by Find, except for the kept ones
+// unless IncludeKept is set.
+func (p *Prune) Delete(orphans []*Orphan) error {
+	if err := p.initClients(); err != nil {
+		return err
+	}
+
+	var errs []string
+	policy := metav1.DeletePropagationBackground
+	for _, o := range orphans {
+		if o.Kept && !p.IncludeKept {
+			continue
+		}
+		p.cfg.Log("deleting %s %q in namespace %q", o.Kind, o.Name, o.Namespace)
+		client := dynamic.ResourceInterface(p.dynamic.Resource(o.resource))
+		if o.Namespace != "" {
+			client = p.dynamic.Resource(o.resource).Namespace(o.Namespace)
+		}
+		err := client.Delete(context.Background(), o.Name, metav1.DeleteOptions{PropagationPolicy: &policy})
+		if err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, fmt.Sprintf("%s %q: %s", o.Kind, o.Name, err))
+			continue
+		}
+		o.Deleted = true
+	}
+	if len(errs) > 0 {
+		return errors.Errorf("failed to delete orphaned objects: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// currentManifest returns the manifest of the deployed revision of the
+// release or, if there is none, of its last revision unless it was
+// uninstalled.
+func (p *Prune) currentManifest(name string) (string, error) {
+	deployed, err := p.cfg.Releases.Deployed(name)
+	if err == nil {
+		return deployed.Manifest, nil
+	}
+	if !errors.Is(err, driver.ErrNoDeployedReleases) {
+		return "", err
+	}
+
+	history, err := p.cfg.Releases.History(name)
+	if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+		return "", err
+	}
+	if len(history) == 0 {
+		return "", nil
+	}
+	releaseutil.Reverse(history, releaseutil.SortByRevision)
+	if history[0].Info.Status == release.StatusUninstalled {
+		return "", nil
+	}
+	return history[0].Manifest, nil
+}
+
+func (p *Prune) initClients() error {
+	if p.discovery != nil && p.dynamic != nil {
+		return nil
+	}
+	if p.cfg.RESTClientGetter == nil {
+		return errors.New("pruning requires access to the Kubernetes API")
+	}
+	dc, err := p.cfg.RESTClientGetter.ToDiscoveryClient()
+	if err != nil {
+		return errors.Wrap(err, "could not get Kubernetes discovery client")
+	}
+	conf, err := p.cfg.RESTClientGetter.ToRESTConfig()
+	if err != nil {
+		return errors.Wrap(err, "unable to generate config for kubernetes client")
+	}
+	dyn, err := dynamic.NewForConfig(conf)
+	if err != nil {
+		return err
+	}
+	p.discovery, p.dynamic = dc, dyn
+	return nil
+}
+
+func hasVerbs(r metav1.APIResource, verbs ...string) bool {
+	for _, v := range verbs {
+		if !contains(r.Verbs, v) {
+			return false
+		}
+	}
+	return true
+}
+
+func orphanKey(kind, namespace, name string) string {
+	return kind + "/" + namespace + "/" + name
+}
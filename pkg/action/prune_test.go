@@ -0,0 +1,197 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"helm.sh/helm/v3/pkg/kube"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+var (
+	configMapsResource  = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	deploymentsResource = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+)
+
+func ownedObject(apiVersion, kind, namespace, name, releaseName string, annotations map[string]string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	obj.SetUID(types.UID(kind + "-" + namespace + "-" + name))
+	obj.SetLabels(map[string]string{appManagedByLabel: appManagedByHelm})
+	annos := map[string]string{helmReleaseNameAnnotation: releaseName, helmReleaseNamespaceAnnotation: "ns-a"}
+	for k, v := range annotations {
+		annos[k] = v
+	}
+	obj.SetAnnotations(annos)
+	return obj
+}
+
+func pruneFixture(t *testing.T, rels ...*release.Release) (*Prune, *fakedynamic.FakeDynamicClient) {
+	t.Helper()
+	cfg := actionConfigFixture(t)
+	cfg.KubeClient = &manifestKubeClient{
+		PrintingKubeClient: kubefake.PrintingKubeClient{Out: ioutil.Discard},
+		live:               map[string]*corev1.ConfigMap{},
+	}
+	for _, rls := range rels {
+		if err := cfg.Releases.Create(rls); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dyn := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{
+			configMapsResource:  "ConfigMapList",
+			deploymentsResource: "DeploymentList",
+		},
+		ownedObject("v1", "ConfigMap", "ns-a", "current", "web", nil),
+		ownedObject("v1", "ConfigMap", "ns-a", "orphan", "web", nil),
+		ownedObject("v1", "ConfigMap", "ns-a", "kept", "web", map[string]string{kube.ResourcePolicyAnno: kube.KeepPolicy}),
+		ownedObject("v1", "ConfigMap", "ns-a", "other", "other", nil),
+		ownedObject("apps/v1", "Deployment", "ns-b", "web", "web", nil),
+	)
+	verbs := metav1.Verbs{"get", "list", "delete"}
+	disc := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: []*metav1.APIResourceList{{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: verbs},
+			{Name: "pods/log", Kind: "Pod", Namespaced: true, Verbs: metav1.Verbs{"get"}},
+		},
+	}, {
+		GroupVersion: "apps/v1",
+		APIResources: []metav1.APIResource{
+			{Name: "deployments", Kind: "Deployment", Namespaced: true, Verbs: verbs},
+		},
+	}}}}
+
+	prune := NewPrune(cfg)
+	prune.Namespace = "ns-a"
+	prune.discovery = disc
+	prune.dynamic = dyn
+	return prune, dyn
+}
+
+func pruneRelease(status release.Status) *release.Release {
+	rls := namedReleaseStub("web", status)
+	rls.Namespace = "ns-a"
+	rls.Manifest = "kind: ConfigMap\nmetadata:\n  name: current\n"
+	return rls
+}
+
+func orphanNames(orphans []*Orphan) []string {
+	var names []string
+	for _, o := range orphans {
+		names = append(names, o.Kind+"/"+o.Namespace+"/"+o.Name)
+	}
+	return names
+}
+
+func TestPruneFind(t *testing.T) {
+	prune, _ := pruneFixture(t, pruneRelease(release.StatusDeployed))
+
+	orphans, err := prune.Find("web")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ConfigMap/ns-a/kept", "ConfigMap/ns-a/orphan", "Deployment/ns-b/web"}, orphanNames(orphans))
+	assert.True(t, orphans[0].Kept)
+	assert.Equal(t, "apps/v1", orphans[2].APIVersion)
+}
+
+func TestPruneFindUninstalled(t *testing.T) {
+	prune, _ := pruneFixture(t, pruneRelease(release.StatusUninstalled))
+
+	orphans, err := prune.Find("web")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ConfigMap/ns-a/current", "ConfigMap/ns-a/kept", "ConfigMap/ns-a/orphan", "Deployment/ns-b/web"}, orphanNames(orphans))
+}
+
+func TestPruneRun(t *testing.T) {
+	is := assert.New(t)
+	prune, dyn := pruneFixture(t, pruneRelease(release.StatusDeployed))
+
+	prune.DryRun = true
+	orphans, err := prune.Run("web")
+	is.NoError(err)
+	is.Len(orphans, 3)
+	for _, o := range orphans {
+		is.False(o.Deleted)
+	}
+
+	prune.DryRun = false
+	orphans, err = prune.Run("web")
+	is.NoError(err)
+	is.Equal([]bool{false, true, true}, []bool{orphans[0].Deleted, orphans[1].Deleted, orphans[2].Deleted})
+
+	_, err = dyn.Resource(configMapsResource).Namespace("ns-a").Get(context.Background(), "orphan", metav1.GetOptions{})
+	is.Error(err)
+	_, err = dyn.Resource(configMapsResource).Namespace("ns-a").Get(context.Background(), "kept", metav1.GetOptions{})
+	is.NoError(err)
+	_, err = dyn.Resource(deploymentsResource).Namespace("ns-b").Get(context.Background(), "web", metav1.GetOptions{})
+	is.Error(err)
+
+	prune.IncludeKept = true
+	orphans, err = prune.Run("web")
+	is.NoError(err)
+	is.Equal([]string{"ConfigMap/ns-a/kept"}, orphanNames(orphans))
+	is.True(orphans[0].Deleted)
+}
+
+func TestPruneRunWithConfirm(t *testing.T) {
+	is := assert.New(t)
+	prune, dyn := pruneFixture(t, pruneRelease(release.StatusDeployed))
+	locker := newTestLocker()
+	prune.cfg.Releases.Locker = locker
+
+	// The release stays locked while the deletion is confirmed
+	orphans, err := prune.RunWithConfirm("web", func(orphans []*Orphan) bool {
+		is.True(locker.held["web"], "the release should be locked")
+		is.Len(orphans, 3)
+		return false
+	})
+	is.NoError(err)
+	for _, o := range orphans {
+		is.False(o.Deleted)
+	}
+	_, err = dyn.Resource(configMapsResource).Namespace("ns-a").Get(context.Background(), "orphan", metav1.GetOptions{})
+	is.NoError(err, "nothing should be deleted without confirmation")
+	is.Empty(locker.held, "the lock should be released")
+
+	orphans, err = prune.RunWithConfirm("web", func([]*Orphan) bool {
+		return true
+	})
+	is.NoError(err)
+	is.Equal([]bool{false, true, true}, []bool{orphans[0].Deleted, orphans[1].Deleted, orphans[2].Deleted})
+	is.Equal([]string{"web", "web"}, locker.locked)
+	is.Empty(locker.held, "the lock should be released")
+}
@@ -0,0 +1,281 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// Reconcile is the action for re-applying the deployed revision of a release,
+// which reverts the changes made to its objects outside of Helm.
+//
+// It provides the implementation of 'helm reconcile'.
+type Reconcile struct {
+	cfg *Configuration
+
+	// Prune deletes the objects of other revisions of the release that are
+	// still in the cluster and owned by the release, but are not in the
+	// manifest of the deployed revision.
+	Prune bool
+	// Force forces resource updates through a replacement strategy.
+	Force       bool
+	Wait        bool
+	WaitForJobs bool
+	Timeout     time.Duration
+	// DryRun reports what would be re-applied without changing anything.
+	DryRun bool
+	// ServerSideApply sends resources with server-side apply instead of
+	// client-side patches.
+	ServerSideApply bool
+	// ForceConflicts takes ownership of fields managed by other field
+	// managers. It only applies when ServerSideApply is set, which cannot be
+	// combined with Force: replacing the resources would bypass server-side
+	// apply, so ForceConflicts stands for Force instead.
+	ForceConflicts bool
+}
+
+// ReconcileResult is the outcome of a reconcile.
+type ReconcileResult struct {
+	// Release is the revision recorded by the reconcile.
+	Release *release.Release
+	// Created are the objects that were missing from the cluster, as
+	// KIND/NAME.
+	Created []string
+	// Updated are the objects that were re-applied.
+	Updated []string
+	// Pruned are the objects that were deleted.
+	Pruned []string
+}
+
+// NewReconcile creates a new Reconcile object with the given configuration.
+func NewReconcile(cfg *Configuration) *Reconcile {
+	return &Reconcile{
+		cfg: cfg,
+	}
+}
+
+// Run re-applies the manifest of the deployed revision of the named release
+// and records it as a new revision. Hooks are not run.
+func (r *Reconcile) Run(name string) (*ReconcileResult, error) {
+	return r.RunWithContext(context.Background(), name)
+}
+
+// RunWithContext is Run with a context that interrupts waiting for the
+// resources.
+func (r *Reconcile) RunWithContext(ctx context.Context, name string) (*ReconcileResult, error) {
+	if err := r.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return nil, errors.Errorf("release name is invalid: %s", name)
+	}
+	if r.Force && r.ServerSideApply {
+		return nil, errForceServerSide
+	}
+
+	if !r.DryRun {
+		unlock, err := r.cfg.lockRelease(ctx, name, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	deployed, err := r.cfg.Releases.Deployed(name)
+	if err != nil {
+		return nil, err
+	}
+	last, err := r.cfg.Releases.Last(name)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := r.cfg.KubeClient.Build(bytes.NewBufferString(deployed.Manifest), false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build kubernetes objects from release manifest")
+	}
+	if err := target.Visit(setMetadataVisitor(deployed.Name, deployed.Namespace, true)); err != nil {
+		return nil, err
+	}
+	var extra kube.ResourceList
+	if r.Prune {
+		if extra, err = r.extraResources(deployed, target); err != nil {
+			return nil, err
+		}
+	}
+
+	rel := &release.Release{
+		Name:            deployed.Name,
+		Namespace:       deployed.Namespace,
+		Chart:           deployed.Chart,
+		Config:          deployed.Config,
+		SensitiveValues: deployed.SensitiveValues,
+		Manifest:        deployed.Manifest,
+		Hooks:           deployed.Hooks,
+		Version:         last.Version + 1,
+		Info: &release.Info{
+			FirstDeployed: deployed.Info.FirstDeployed,
+			LastDeployed:  r.cfg.Now(),
+			Status:        release.StatusPendingUpgrade,
+			Description:   "Reconcile in progress",
+			Notes:         deployed.Info.Notes,
+		},
+	}
+	result := &ReconcileResult{Release: rel}
+
+	if r.DryRun {
+		for _, info := range target {
+			exists, err := liveObjectExists(info)
+			if err != nil {
+				return nil, err
+			}
+			if exists {
+				result.Updated = append(result.Updated, kindName(info))
+			} else {
+				result.Created = append(result.Created, kindName(info))
+			}
+		}
+		for _, info := range extra {
+			result.Pruned = append(result.Pruned, kindName(info))
+		}
+		rel.Info.Description = "Dry run complete"
+		return result, nil
+	}
+
+	r.cfg.Log("creating reconciled release for %s", rel.Name)
+	if err := r.cfg.Releases.Create(rel); err != nil {
+		return nil, err
+	}
+	defer r.cfg.startHeartbeat(rel)()
+
+	// Objects in original but not in target are deleted, so the extra objects
+	// are only added to original.
+	original := append(append(kube.ResourceList{}, target...), extra...)
+	results, err := r.cfg.updateResources(original, target, r.Force, applyOptions(r.ServerSideApply, r.ForceConflicts, false))
+	if results != nil {
+		for _, info := range results.Created {
+			result.Created = append(result.Created, kindName(info))
+		}
+		for _, info := range results.Updated {
+			result.Updated = append(result.Updated, kindName(info))
+		}
+		for _, info := range results.Deleted {
+			result.Pruned = append(result.Pruned, kindName(info))
+		}
+	}
+	if err != nil {
+		return result, r.failRelease(rel, err)
+	}
+	if r.Wait {
+		if err := r.cfg.waitForResources(ctx, target, r.Timeout, r.WaitForJobs); err != nil {
+			return result, r.failRelease(rel, err)
+		}
+	}
+
+	deployed.Info.Status = release.StatusSuperseded
+	r.cfg.recordRelease(deployed)
+
+	rel.Info.Status = release.StatusDeployed
+	rel.Info.Description = fmt.Sprintf("Reconciled revision %d", deployed.Version)
+	r.cfg.recordRelease(rel)
+	return result, nil
+}
+
+func (r *Reconcile) failRelease(rel *release.Release, err error) error {
+	msg := fmt.Sprintf("Reconcile %q failed: %s", rel.Name, err)
+	r.cfg.Log("warning: %s", msg)
+	rel.Info.Status = release.StatusFailed
+	rel.Info.Description = msg
+	r.cfg.recordRelease(rel)
+	return err
+}
+
+// extraResources returns the objects of the other revisions of the release
+// that are not in target, exist in the cluster, and are owned by the release.
+func (r *Reconcile) extraResources(deployed *release.Release, target kube.ResourceList) (kube.ResourceList, error) {
+	history, err := r.cfg.Releases.History(deployed.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, info := range target {
+		seen[objectKey(info)] = true
+	}
+	var extra kube.ResourceList
+	for _, rls := range history {
+		if rls.Version == deployed.Version {
+			continue
+		}
+		resources, err := r.cfg.KubeClient.Build(bytes.NewBufferString(rls.Manifest), false)
+		if err != nil {
+			// Manifests of old revisions may use APIs the cluster no longer
+			// serves, whose objects cannot exist anymore.
+			r.cfg.Log("skipping revision %d of %s: %s", rls.Version, rls.Name, err)
+			continue
+		}
+		for _, info := range resources {
+			key := objectKey(info)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			helper := resource.NewHelper(info.Client, info.Mapping)
+			existing, err := helper.Get(info.Namespace, info.Name)
+			if err != nil {
+				if apierrors.IsNotFound(err) {
+					continue
+				}
+				return nil, errors.Wrapf(err, "could not get information about %s", resourceString(info))
+			}
+			if checkOwnership(existing, deployed.Name, deployed.Namespace) != nil {
+				continue
+			}
+			extra.Append(info)
+		}
+	}
+	return extra, nil
+}
+
+// liveObjectExists reports whether the object of info exists in the cluster.
+func liveObjectExists(info *resource.Info) (bool, error) {
+	helper := resource.NewHelper(info.Client, info.Mapping)
+	if _, err := helper.Get(info.Namespace, info.Name); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "could not get information about %s", resourceString(info))
+	}
+	return true, nil
+}
+
+// kindName returns the object of info as KIND/NAME.
+func kindName(info *resource.Info) string {
+	return info.Mapping.GroupVersionKind.Kind + "/" + info.Name
+}
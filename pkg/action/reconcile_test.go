@@ -0,0 +1,176 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"helm.sh/helm/v3/pkg/kube"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// manifestKubeClient builds the ConfigMaps of a manifest with clients that
+// serve the live objects, and records the updates.
+type manifestKubeClient struct {
+	kubefake.PrintingKubeClient
+	live      map[string]*corev1.ConfigMap
+	original  kube.ResourceList
+	target    kube.ResourceList
+	updateErr error
+}
+
+func (c *manifestKubeClient) Build(r io.Reader, _ bool) (kube.ResourceList, error) {
+	manifest, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var resources kube.ResourceList
+	for _, doc := range splitManifestDocs(string(manifest)) {
+		resources.Append(newLiveConfigMapResource(doc.name, c.live, map[string]string{}))
+	}
+	return resources, nil
+}
+
+func (c *manifestKubeClient) Update(original, target kube.ResourceList, _ bool) (*kube.Result, error) {
+	c.original, c.target = original, target
+	if c.updateErr != nil {
+		return &kube.Result{}, c.updateErr
+	}
+	return &kube.Result{Updated: target, Deleted: original.Difference(target)}, nil
+}
+
+func reconcileFixture(t *testing.T) (*Configuration, *manifestKubeClient) {
+	t.Helper()
+	cfg := actionConfigFixture(t)
+	owned := func(release string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Labels:      map[string]string{appManagedByLabel: appManagedByHelm},
+			Annotations: map[string]string{helmReleaseNameAnnotation: release, helmReleaseNamespaceAnnotation: "ns-a"},
+		}
+	}
+	client := &manifestKubeClient{
+		PrintingKubeClient: kubefake.PrintingKubeClient{Out: ioutil.Discard},
+		live: map[string]*corev1.ConfigMap{
+			"a":     {ObjectMeta: owned("web")},
+			"old":   {ObjectMeta: owned("web")},
+			"other": {ObjectMeta: owned("other")},
+		},
+	}
+	cfg.KubeClient = client
+
+	manifests := []string{
+		"kind: ConfigMap\nmetadata:\n  name: old\n---\nkind: ConfigMap\nmetadata:\n  name: other\n---\nkind: ConfigMap\nmetadata:\n  name: gone\n",
+		"kind: ConfigMap\nmetadata:\n  name: a\n---\nkind: ConfigMap\nmetadata:\n  name: b\n",
+	}
+	for i, status := range []release.Status{release.StatusSuperseded, release.StatusDeployed} {
+		rls := namedReleaseStub("web", status)
+		rls.Namespace = "ns-a"
+		rls.Version = i + 1
+		rls.Manifest = manifests[i]
+		if err := cfg.Releases.Create(rls); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cfg, client
+}
+
+func TestReconcile(t *testing.T) {
+	is := assert.New(t)
+	cfg, client := reconcileFixture(t)
+
+	result, err := NewReconcile(cfg).Run("web")
+	is.NoError(err)
+	is.Equal([]string{"ConfigMap/a", "ConfigMap/b"}, result.Updated)
+	is.Empty(result.Pruned)
+	is.Len(client.original, 2)
+	is.Len(client.target, 2)
+
+	rel, err := cfg.Releases.Deployed("web")
+	is.NoError(err)
+	is.Equal(3, rel.Version)
+	is.Equal("Reconciled revision 2", rel.Info.Description)
+	previous, err := cfg.Releases.Get("web", 2)
+	is.NoError(err)
+	is.Equal(release.StatusSuperseded, previous.Info.Status)
+}
+
+func TestReconcileForceServerSide(t *testing.T) {
+	cfg, client := reconcileFixture(t)
+
+	reconcile := NewReconcile(cfg)
+	reconcile.Force = true
+	reconcile.ServerSideApply = true
+	_, err := reconcile.Run("web")
+	assert.Equal(t, errForceServerSide, err)
+	assert.Empty(t, client.original)
+}
+
+func TestReconcilePrune(t *testing.T) {
+	is := assert.New(t)
+	cfg, client := reconcileFixture(t)
+
+	reconcile := NewReconcile(cfg)
+	reconcile.Prune = true
+	result, err := reconcile.Run("web")
+	is.NoError(err)
+	// Objects of other releases and objects that no longer exist are left alone
+	is.Equal([]string{"ConfigMap/old"}, result.Pruned)
+	is.Len(client.original, 3)
+}
+
+func TestReconcileDryRun(t *testing.T) {
+	is := assert.New(t)
+	cfg, client := reconcileFixture(t)
+
+	reconcile := NewReconcile(cfg)
+	reconcile.Prune = true
+	reconcile.DryRun = true
+	result, err := reconcile.Run("web")
+	is.NoError(err)
+	is.Equal([]string{"ConfigMap/a"}, result.Updated)
+	is.Equal([]string{"ConfigMap/b"}, result.Created)
+	is.Equal([]string{"ConfigMap/old"}, result.Pruned)
+	is.Nil(client.target)
+
+	last, err := cfg.Releases.Last("web")
+	is.NoError(err)
+	is.Equal(2, last.Version)
+}
+
+func TestReconcileFailure(t *testing.T) {
+	is := assert.New(t)
+	cfg, client := reconcileFixture(t)
+	client.updateErr = errors.New("update failed")
+
+	_, err := NewReconcile(cfg).Run("web")
+	is.EqualError(err, "update failed")
+
+	rel, err := cfg.Releases.Get("web", 3)
+	is.NoError(err)
+	is.Equal(release.StatusFailed, rel.Info.Status)
+	deployed, err := cfg.Releases.Deployed("web")
+	is.NoError(err)
+	is.Equal(2, deployed.Version)
+}
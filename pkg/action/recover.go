@@ -0,0 +1,161 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+// DefaultRecoverStaleAfter is how long a pending release can go without a
+// heartbeat before Recover considers its operation abandoned.
+const DefaultRecoverStaleAfter = 5 * time.Minute
+
+// heartbeatInterval is how often a running operation records a heartbeat for
+// its pending release.
+var heartbeatInterval = 30 * time.Second
+
+// Recover is the action for recovering a release whose last revision was left
+// in a pending state, e.g. because helm was killed during an upgrade.
+//
+// It provides the implementation of 'helm release unlock'.
+type Recover struct {
+	cfg *Configuration
+
+	// StaleAfter is how long the pending revision must have gone without a
+	// heartbeat before it is recovered.
+	StaleAfter time.Duration
+	// Force recovers the release even if its operation still appears to be alive.
+	Force bool
+	// Rollback rolls back to the last successful revision once the pending
+	// revision has been marked as failed.
+	Rollback bool
+	// Wait, WaitForJobs and Timeout are passed on to the rollback.
+	Wait        bool
+	WaitForJobs bool
+	Timeout     time.Duration
+}
+
+// NewRecover creates a new Recover object with the given configuration.
+func NewRecover(cfg *Configuration) *Recover {
+	return &Recover{
+		cfg:        cfg,
+		StaleAfter: DefaultRecoverStaleAfter,
+	}
+}
+
+// Run marks the pending revision of the named release as failed and, if
+// Rollback is set, rolls back to the last successful revision.
+//
+// It returns the revision that was marked as failed.
+func (r *Recover) Run(name string) (*release.Release, error) {
+	if err := r.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return nil, errors.Errorf("release name is invalid: %s", name)
+	}
+
+	unlock, err := r.cfg.lockRelease(context.Background(), name, r.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	rel, err := r.cfg.Releases.Last(name)
+	if err != nil {
+		return nil, err
+	}
+	if !rel.Info.Status.IsPending() {
+		return nil, errors.Errorf("release %s is not pending: revision %d is %s", name, rel.Version, rel.Info.Status)
+	}
+
+	last, err := r.cfg.Releases.LastHeartbeat(rel.Name, rel.Version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to determine the last heartbeat of release %s", name)
+	}
+	if time.Since(last) < r.StaleAfter && !r.Force {
+		return nil, errors.Errorf("release %s is %s and recorded a heartbeat within the last %s; its operation may still be running", name, rel.Info.Status, r.StaleAfter)
+	}
+
+	r.cfg.Log("marking revision %d of %s as failed", rel.Version, name)
+	status := rel.Info.Status
+	rel.SetStatus(release.StatusFailed, fmt.Sprintf("Recovered from stale %s state", status))
+	if err := r.cfg.Releases.Update(rel); err != nil {
+		return nil, err
+	}
+
+	if !r.Rollback {
+		return rel, nil
+	}
+
+	// Like an atomic upgrade, roll back to the last revision that was
+	// successfully deployed.
+	hist, err := r.cfg.Releases.History(name)
+	if err != nil {
+		return rel, err
+	}
+	successful := releaseutil.FilterFunc(func(h *release.Release) bool {
+		return h.Info.Status == release.StatusSuperseded || h.Info.Status == release.StatusDeployed
+	}).Filter(hist)
+	if len(successful) == 0 {
+		return rel, errors.Errorf("release %s has been unlocked, but has no successful revision to roll back to", name)
+	}
+	releaseutil.Reverse(successful, releaseutil.SortByRevision)
+
+	rollback := NewRollback(r.cfg)
+	rollback.lockHeld = true
+	rollback.Version = successful[0].Version
+	rollback.Wait = r.Wait
+	rollback.WaitForJobs = r.WaitForJobs
+	rollback.Timeout = r.Timeout
+	if err := rollback.Run(name); err != nil {
+		return rel, errors.Wrapf(err, "release %s has been unlocked, but rolling back to revision %d failed", name, rollback.Version)
+	}
+	return rel, nil
+}
+
+// startHeartbeat records a heartbeat for the pending release every
+// heartbeatInterval until the returned function is called, so that Recover
+// can tell the release of a live operation from an abandoned one.
+func (c *Configuration) startHeartbeat(rel *release.Release) func() {
+	name, version := rel.Name, rel.Version
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := c.Releases.Heartbeat(name, version); err != nil {
+					c.Log("warning: failed to record heartbeat of release %s: %s", name, err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
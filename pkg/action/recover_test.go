@@ -0,0 +1,127 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func recoverAction(t *testing.T, statuses ...release.Status) *Recover {
+	config := actionConfigFixture(t)
+	for i, status := range statuses {
+		rel := namedReleaseStub("stuck", status)
+		rel.Version = i + 1
+		require.NoError(t, config.Releases.Create(rel))
+	}
+	return NewRecover(config)
+}
+
+func TestRecover(t *testing.T) {
+	is := assert.New(t)
+
+	client := recoverAction(t, release.StatusDeployed, release.StatusPendingUpgrade)
+	client.StaleAfter = 0
+
+	res, err := client.Run("stuck")
+	is.NoError(err)
+	is.Equal(2, res.Version)
+	is.Equal(release.StatusFailed, res.Info.Status)
+	is.Equal("Recovered from stale pending-upgrade state", res.Info.Description)
+
+	stored, err := client.cfg.Releases.Get("stuck", 2)
+	is.NoError(err)
+	is.Equal(release.StatusFailed, stored.Info.Status)
+}
+
+func TestRecoverLiveOperation(t *testing.T) {
+	is := assert.New(t)
+
+	client := recoverAction(t, release.StatusDeployed, release.StatusPendingUpgrade)
+	client.StaleAfter = time.Hour
+
+	_, err := client.Run("stuck")
+	is.Error(err)
+	is.Contains(err.Error(), "may still be running")
+
+	stored, err := client.cfg.Releases.Get("stuck", 2)
+	is.NoError(err)
+	is.Equal(release.StatusPendingUpgrade, stored.Info.Status)
+
+	client.Force = true
+	res, err := client.Run("stuck")
+	is.NoError(err)
+	is.Equal(release.StatusFailed, res.Info.Status)
+}
+
+func TestRecoverNotPending(t *testing.T) {
+	client := recoverAction(t, release.StatusDeployed)
+
+	_, err := client.Run("stuck")
+	assert.EqualError(t, err, "release stuck is not pending: revision 1 is deployed")
+}
+
+func TestRecoverRollback(t *testing.T) {
+	is := assert.New(t)
+
+	client := recoverAction(t, release.StatusDeployed, release.StatusPendingUpgrade)
+	client.StaleAfter = 0
+	client.Rollback = true
+
+	_, err := client.Run("stuck")
+	is.NoError(err)
+
+	last, err := client.cfg.Releases.Last("stuck")
+	is.NoError(err)
+	is.Equal(3, last.Version)
+	is.Equal(release.StatusDeployed, last.Info.Status)
+	is.Equal("Rollback to 1", last.Info.Description)
+}
+
+func TestRecoverRollbackWithoutSuccessfulRevision(t *testing.T) {
+	client := recoverAction(t, release.StatusPendingInstall)
+	client.StaleAfter = 0
+	client.Rollback = true
+
+	res, err := client.Run("stuck")
+	assert.Error(t, err)
+	assert.Equal(t, release.StatusFailed, res.Info.Status)
+}
+
+func TestStartHeartbeat(t *testing.T) {
+	defer func(d time.Duration) { heartbeatInterval = d }(heartbeatInterval)
+	heartbeatInterval = 10 * time.Millisecond
+
+	client := recoverAction(t, release.StatusPendingInstall)
+	before, err := client.cfg.Releases.LastHeartbeat("stuck", 1)
+	require.NoError(t, err)
+
+	rel, err := client.cfg.Releases.Get("stuck", 1)
+	require.NoError(t, err)
+	stop := client.cfg.startHeartbeat(rel)
+	defer stop()
+
+	assert.Eventually(t, func() bool {
+		last, err := client.cfg.Releases.LastHeartbeat("stuck", 1)
+		return err == nil && last.After(before)
+	}, time.Second, 10*time.Millisecond)
+}
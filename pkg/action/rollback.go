@@ -18,6 +18,7 @@ package action
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -45,6 +46,19 @@ type Rollback struct {
 	Force         bool // will (if true) force resource upgrade through uninstall/recreate if needed
 	CleanupOnFail bool
 	MaxHistory    int // MaxHistory limits the maximum number of revisions saved per release
+	// ServerSideApply sends resources with server-side apply instead of
+	// client-side three-way merge patches. Combined with DryRun, the changes
+	// are validated by the API server with a server-side dry run.
+	ServerSideApply bool
+	// ForceConflicts takes ownership of fields managed by other field
+	// managers. It only applies when ServerSideApply is set, which cannot be
+	// combined with Force: replacing the resources would bypass server-side
+	// apply, so ForceConflicts stands for Force instead.
+	ForceConflicts bool
+
+	// lockHeld is set when the caller already holds the lock of the release,
+	// e.g. when an atomic upgrade is rolled back.
+	lockHeld bool
 }
 
 // NewRollback creates a new Rollback object with the given configuration.
@@ -56,10 +70,31 @@ func NewRollback(cfg *Configuration) *Rollback {
 
 // Run executes 'helm rollback' against the given release.
 func (r *Rollback) Run(name string) error {
+	return r.RunWithContext(context.Background(), name)
+}
+
+// RunWithContext executes the rollback like Run, but aborts it when ctx is
+// done. An aborted rollback is marked as failed.
+func (r *Rollback) RunWithContext(ctx context.Context, name string) error {
 	if err := r.cfg.KubeClient.IsReachable(); err != nil {
 		return err
 	}
 
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return errors.Errorf("release name is invalid: %s", name)
+	}
+	if r.Force && r.ServerSideApply {
+		return errForceServerSide
+	}
+
+	if !r.DryRun && !r.lockHeld {
+		unlock, err := r.cfg.lockRelease(ctx, name, r.Timeout)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
 	r.cfg.Releases.MaxHistory = r.MaxHistory
 
 	r.cfg.Log("preparing rollback of %s", name)
@@ -68,15 +103,20 @@ func (r *Rollback) Run(name string) error {
 		return err
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	if !r.DryRun {
 		r.cfg.Log("creating rolled back release for %s", name)
 		if err := r.cfg.Releases.Create(targetRelease); err != nil {
 			return err
 		}
+		defer r.cfg.startHeartbeat(targetRelease)()
 	}
 
 	r.cfg.Log("performing rollback of %s", name)
-	if _, err := r.performRollback(currentRelease, targetRelease); err != nil {
+	if _, err := r.performRollback(ctx, currentRelease, targetRelease); err != nil {
 		return err
 	}
 
@@ -119,10 +159,11 @@ func (r *Rollback) prepareRollback(name string) (*release.Release, *release.Rele
 
 	// Store a new release object with previous release's configuration
 	targetRelease := &release.Release{
-		Name:      name,
-		Namespace: currentRelease.Namespace,
-		Chart:     previousRelease.Chart,
-		Config:    previousRelease.Config,
+		Name:            name,
+		Namespace:       currentRelease.Namespace,
+		Chart:           previousRelease.Chart,
+		Config:          previousRelease.Config,
+		SensitiveValues: previousRelease.SensitiveValues,
 		Info: &release.Info{
 			FirstDeployed: currentRelease.Info.FirstDeployed,
 			LastDeployed:  helmtime.Now(),
@@ -140,8 +181,8 @@ func (r *Rollback) prepareRollback(name string) (*release.Release, *release.Rele
 	return currentRelease, targetRelease, nil
 }
 
-func (r *Rollback) performRollback(currentRelease, targetRelease *release.Release) (*release.Release, error) {
-	if r.DryRun {
+func (r *Rollback) performRollback(ctx context.Context, currentRelease, targetRelease *release.Release) (*release.Release, error) {
+	if r.DryRun && !r.ServerSideApply {
 		r.cfg.Log("dry run for %s", targetRelease.Name)
 		return targetRelease, nil
 	}
@@ -155,16 +196,24 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 		return targetRelease, errors.Wrap(err, "unable to build kubernetes objects from new release manifest")
 	}
 
+	if r.DryRun {
+		r.cfg.Log("server-side dry run for %s", targetRelease.Name)
+		if _, err := r.cfg.updateResources(current, target, r.Force, applyOptions(true, r.ForceConflicts, true)); err != nil {
+			return targetRelease, errors.Wrap(err, "server-side dry run failed")
+		}
+		return targetRelease, nil
+	}
+
 	// pre-rollback hooks
 	if !r.DisableHooks {
-		if err := r.cfg.execHook(targetRelease, release.HookPreRollback, r.Timeout); err != nil {
-			return targetRelease, err
+		if err := r.cfg.execHook(ctx, targetRelease, release.HookPreRollback, r.Timeout, 1); err != nil {
+			return r.failRelease(currentRelease, targetRelease, err)
 		}
 	} else {
 		r.cfg.Log("rollback hooks disabled for %s", targetRelease.Name)
 	}
 
-	results, err := r.cfg.KubeClient.Update(current, target, r.Force)
+	results, err := r.cfg.updateResources(current, target, r.Force, applyOptions(r.ServerSideApply, r.ForceConflicts, false))
 
 	if err != nil {
 		msg := fmt.Sprintf("Rollback %q failed: %s", targetRelease.Name, err)
@@ -199,28 +248,20 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 		}
 	}
 
+	if err := ctx.Err(); err != nil {
+		return r.failRelease(currentRelease, targetRelease, err)
+	}
+
 	if r.Wait {
-		if r.WaitForJobs {
-			if err := r.cfg.KubeClient.WaitWithJobs(target, r.Timeout); err != nil {
-				targetRelease.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", targetRelease.Name, err.Error()))
-				r.cfg.recordRelease(currentRelease)
-				r.cfg.recordRelease(targetRelease)
-				return targetRelease, errors.Wrapf(err, "release %s failed", targetRelease.Name)
-			}
-		} else {
-			if err := r.cfg.KubeClient.Wait(target, r.Timeout); err != nil {
-				targetRelease.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", targetRelease.Name, err.Error()))
-				r.cfg.recordRelease(currentRelease)
-				r.cfg.recordRelease(targetRelease)
-				return targetRelease, errors.Wrapf(err, "release %s failed", targetRelease.Name)
-			}
+		if err := r.cfg.waitForResources(ctx, target, r.Timeout, r.WaitForJobs); err != nil {
+			return r.failRelease(currentRelease, targetRelease, err)
 		}
 	}
 
 	// post-rollback hooks
 	if !r.DisableHooks {
-		if err := r.cfg.execHook(targetRelease, release.HookPostRollback, r.Timeout); err != nil {
-			return targetRelease, err
+		if err := r.cfg.execHook(ctx, targetRelease, release.HookPostRollback, r.Timeout, 1); err != nil {
+			return r.failRelease(currentRelease, targetRelease, err)
 		}
 	}
 
@@ -239,3 +280,12 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 
 	return targetRelease, nil
 }
+
+// failRelease marks the rollback release as failed, so that it is not left
+// pending when hooks or the wait fail, or the rollback is aborted.
+func (r *Rollback) failRelease(currentRelease, targetRelease *release.Release, err error) (*release.Release, error) {
+	targetRelease.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", targetRelease.Name, err.Error()))
+	r.cfg.recordRelease(currentRelease)
+	r.cfg.recordRelease(targetRelease)
+	return targetRelease, errors.Wrapf(err, "release %s failed", targetRelease.Name)
+}
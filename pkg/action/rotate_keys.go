@@ -0,0 +1,145 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// RotateKeys is the action for re-encrypting stored releases with the current
+// key of the storage, e.g. after a new key has been added to the key file.
+//
+// It provides the implementation of 'helm release rotate-keys'.
+type RotateKeys struct {
+	cfg *Configuration
+}
+
+// NewRotateKeys creates a new RotateKeys object with the given configuration.
+func NewRotateKeys(cfg *Configuration) *RotateKeys {
+	return &RotateKeys{
+		cfg: cfg,
+	}
+}
+
+// KeyID returns the ID of the key the releases are encrypted with.
+func (r *RotateKeys) KeyID() (string, error) {
+	e, err := r.encrypted()
+	if err != nil {
+		return "", err
+	}
+	return e.CurrentKeyID(), nil
+}
+
+// Run re-encrypts every revision of the named releases, or of all the
+// releases in the storage if no name is given, and returns the revisions.
+// Revisions that were stored before encryption was enabled are encrypted.
+//
+// The revisions are read from the storage under the encryption, so that none
+// is left behind unnoticed: if a revision cannot be decrypted or updated, the
+// other revisions are still re-encrypted, and the error reports how many were
+// not.
+func (r *RotateKeys) Run(names ...string) ([]*release.Release, error) {
+	e, err := r.encrypted()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(names) == 0 {
+		all, err := e.Driver.List(func(*release.Release) bool { return true })
+		if err != nil {
+			return nil, err
+		}
+		seen := map[string]bool{}
+		for _, rls := range all {
+			if !seen[rls.Name] {
+				seen[rls.Name] = true
+				names = append(names, rls.Name)
+			}
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		if err := chartutil.ValidateReleaseName(name); err != nil {
+			return nil, errors.Errorf("release name is invalid: %s", name)
+		}
+	}
+
+	var rotated []*release.Release
+	var failures []string
+	for _, name := range names {
+		revisions, errs, err := r.rotate(e, name)
+		rotated = append(rotated, revisions...)
+		if err != nil {
+			return rotated, err
+		}
+		for _, err := range errs {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return rotated, errors.Errorf("%d revisions were not re-encrypted:\n%s", len(failures), strings.Join(failures, "\n"))
+	}
+	return rotated, nil
+}
+
+// rotate re-encrypts the revisions of the named release while holding its
+// lock, so that no revision is written with the old key meanwhile. It returns
+// the revisions that were re-encrypted and an error for each that was not.
+func (r *RotateKeys) rotate(e *driver.Encrypted, name string) ([]*release.Release, []error, error) {
+	unlock, err := r.cfg.lockRelease(context.Background(), name, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	records, err := e.Driver.Query(map[string]string{"name": name, "owner": "helm"})
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to read the history of release %q", name)
+	}
+	var rotated []*release.Release
+	var errs []error
+	for _, rec := range records {
+		rls, err := e.Decrypt(rec)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to decrypt release %q revision %d", rec.Name, rec.Version))
+			continue
+		}
+		r.cfg.Log("re-encrypting release %s revision %d", rls.Name, rls.Version)
+		if err := r.cfg.Releases.Update(rls); err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to re-encrypt release %q revision %d", rls.Name, rls.Version))
+			continue
+		}
+		rotated = append(rotated, rls)
+	}
+	return rotated, errs, nil
+}
+
+func (r *RotateKeys) encrypted() (*driver.Encrypted, error) {
+	e, ok := r.cfg.Releases.Driver.(*driver.Encrypted)
+	if !ok {
+		return nil, errors.New("release storage is not encrypted: set HELM_DRIVER_ENCRYPTION")
+	}
+	return e, nil
+}
@@ -0,0 +1,118 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func localKeys(t *testing.T, keys ...byte) *driver.LocalKeys {
+	var raw [][]byte
+	for _, k := range keys {
+		raw = append(raw, bytes.Repeat([]byte{k}, 32))
+	}
+	lk, err := driver.NewLocalKeys(raw...)
+	require.NoError(t, err)
+	return lk
+}
+
+func TestRotateKeys(t *testing.T) {
+	is := assert.New(t)
+	config := actionConfigFixture(t)
+	mem := config.Releases.Driver
+
+	// Releases stored before encryption was enabled, and with an old key
+	require.NoError(t, config.Releases.Create(namedReleaseStub("angry-panda", release.StatusSuperseded)))
+	config.Releases.Driver = driver.NewEncrypted(mem, localKeys(t, 1))
+	rel := namedReleaseStub("angry-panda", release.StatusDeployed)
+	rel.Version = 2
+	require.NoError(t, config.Releases.Create(rel))
+	require.NoError(t, config.Releases.Create(namedReleaseStub("happy-bunny", release.StatusDeployed)))
+
+	config.Releases.Driver = driver.NewEncrypted(mem, localKeys(t, 2, 1))
+	client := NewRotateKeys(config)
+	keyID, err := client.KeyID()
+	is.NoError(err)
+	is.Equal(localKeys(t, 2).KeyID(), keyID)
+
+	rotated, err := client.Run()
+	is.NoError(err)
+	is.Len(rotated, 3)
+
+	// All revisions are encrypted with the new key only
+	config.Releases.Driver = mem
+	all, err := config.Releases.ListReleases()
+	is.NoError(err)
+	for _, rls := range all {
+		is.True(strings.HasPrefix(rls.Manifest, "helm.sh/encrypted-release.v1:"), "%s revision %d is not encrypted", rls.Name, rls.Version)
+	}
+	config.Releases.Driver = driver.NewEncrypted(mem, localKeys(t, 2))
+	all, err = config.Releases.ListReleases()
+	is.NoError(err)
+	is.Len(all, 3)
+	for _, rls := range all {
+		is.Equal(rel.Manifest, rls.Manifest)
+	}
+}
+
+func TestRotateKeysNamedRelease(t *testing.T) {
+	is := assert.New(t)
+	config := actionConfigFixture(t)
+	require.NoError(t, config.Releases.Create(namedReleaseStub("angry-panda", release.StatusDeployed)))
+	require.NoError(t, config.Releases.Create(namedReleaseStub("happy-bunny", release.StatusDeployed)))
+	config.Releases.Driver = driver.NewEncrypted(config.Releases.Driver, localKeys(t, 1))
+
+	rotated, err := NewRotateKeys(config).Run("happy-bunny")
+	is.NoError(err)
+	is.Len(rotated, 1)
+	is.Equal("happy-bunny", rotated[0].Name)
+}
+
+func TestRotateKeysNotEncrypted(t *testing.T) {
+	_, err := NewRotateKeys(actionConfigFixture(t)).Run()
+	assert.EqualError(t, err, "release storage is not encrypted: set HELM_DRIVER_ENCRYPTION")
+}
+
+func TestRotateKeysUndecryptable(t *testing.T) {
+	is := assert.New(t)
+	config := actionConfigFixture(t)
+	mem := config.Releases.Driver
+
+	// One revision is encrypted with a key that is no longer in the key file
+	config.Releases.Driver = driver.NewEncrypted(mem, localKeys(t, 3))
+	require.NoError(t, config.Releases.Create(namedReleaseStub("angry-panda", release.StatusSuperseded)))
+	config.Releases.Driver = driver.NewEncrypted(mem, localKeys(t, 1))
+	rel := namedReleaseStub("angry-panda", release.StatusDeployed)
+	rel.Version = 2
+	require.NoError(t, config.Releases.Create(rel))
+	require.NoError(t, config.Releases.Create(namedReleaseStub("happy-bunny", release.StatusDeployed)))
+
+	config.Releases.Driver = driver.NewEncrypted(mem, localKeys(t, 2, 1))
+	rotated, err := NewRotateKeys(config).Run()
+	is.Error(err)
+	is.Contains(err.Error(), "1 revisions were not re-encrypted")
+	is.Contains(err.Error(), `failed to decrypt release "angry-panda" revision 1`)
+	is.Len(rotated, 2)
+}
@@ -0,0 +1,235 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// StorageMigrate is the action for copying the stored releases to another
+// storage, e.g. from the ConfigMaps driver to the SQL driver.
+//
+// It provides the implementation of 'helm storage migrate'.
+type StorageMigrate struct {
+	cfg *Configuration
+
+	// Target is the storage the releases are copied to.
+	Target *storage.Storage
+	// DryRun reports what would be copied without writing anything.
+	DryRun bool
+	// DeleteSource deletes the revisions from the storage of the
+	// configuration once they have been copied and verified.
+	DeleteSource bool
+}
+
+// MigratedRevision is the outcome of the migration or import of a release
+// revision.
+type MigratedRevision struct {
+	Name      string
+	Namespace string
+	Version   int
+	// Digest is the SHA-256 digest of the revision, which is the same in both
+	// storages.
+	Digest string
+	// Existing is true if the revision was already in the target storage.
+	Existing bool
+}
+
+// NewStorageMigrate creates a new StorageMigrate object with the given
+// configuration, whose storage holds the releases to migrate.
+func NewStorageMigrate(cfg *Configuration) *StorageMigrate {
+	return &StorageMigrate{
+		cfg: cfg,
+	}
+}
+
+// Run copies every revision of every release of the storage to the target
+// storage, and verifies that the target storage returns the same revisions.
+// Revisions that are already in the target storage are left as they are if
+// they are identical, and fail the migration otherwise.
+func (m *StorageMigrate) Run() ([]*MigratedRevision, error) {
+	if m.Target == nil {
+		return nil, errors.New("no target storage")
+	}
+	if m.Target == m.cfg.Releases || m.Target.Driver == m.cfg.Releases.Driver {
+		return nil, errors.New("the target storage is the source storage")
+	}
+
+	all, err := m.cfg.Releases.ListReleases()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, rls := range all {
+		if !seen[rls.Name] {
+			seen[rls.Name] = true
+			names = append(names, rls.Name)
+		}
+	}
+	sort.Strings(names)
+
+	var migrated []*MigratedRevision
+	for _, name := range names {
+		revisions, err := m.migrate(name)
+		migrated = append(migrated, revisions...)
+		if err != nil {
+			return migrated, err
+		}
+	}
+	return migrated, nil
+}
+
+// migrate copies the revisions of the named release while holding its lock
+// in both storages, so that no revision is added meanwhile. If both storages
+// share their Locker, e.g. the Leases of the same namespace, the lock is only
+// acquired once.
+func (m *StorageMigrate) migrate(name string) ([]*MigratedRevision, error) {
+	if !m.DryRun {
+		unlock, err := m.cfg.lockRelease(context.Background(), name, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+		if m.Target.Locker != m.cfg.Releases.Locker {
+			ctx, cancel := context.WithTimeout(context.Background(), defaultLockTimeout)
+			defer cancel()
+			unlockTarget, err := m.Target.Lock(ctx, name)
+			if err != nil {
+				return nil, errors.Wrap(err, "target storage")
+			}
+			defer unlockTarget()
+		}
+	}
+
+	history, err := m.cfg.Releases.History(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the history of release %q", name)
+	}
+	releaseutil.SortByRevision(history)
+
+	var migrated []*MigratedRevision
+	for _, rls := range history {
+		rev, err := copyRevision(m.Target, rls, m.DryRun, m.cfg.Log)
+		if err != nil {
+			return migrated, err
+		}
+		migrated = append(migrated, rev)
+	}
+
+	if m.DryRun {
+		return migrated, nil
+	}
+	if err := m.verify(name, migrated); err != nil {
+		return migrated, err
+	}
+	if m.DeleteSource {
+		for _, rev := range migrated {
+			m.cfg.Log("deleting release %s revision %d from the source storage", rev.Name, rev.Version)
+			if _, err := m.cfg.Releases.Delete(rev.Name, rev.Version); err != nil {
+				return migrated, errors.Wrapf(err, "failed to delete release %q revision %d from the source storage", rev.Name, rev.Version)
+			}
+		}
+	}
+	return migrated, nil
+}
+
+// verify checks that the target storage returns the migrated revisions, both
+// by name and version and through the labels the history is queried with.
+func (m *StorageMigrate) verify(name string, migrated []*MigratedRevision) error {
+	history, err := m.Target.History(name)
+	if err != nil {
+		return errors.Wrapf(err, "verification failed: failed to read the history of release %q from the target storage", name)
+	}
+	queried := map[int]bool{}
+	for _, rls := range history {
+		queried[rls.Version] = true
+	}
+
+	for _, rev := range migrated {
+		if !queried[rev.Version] {
+			return errors.Errorf("verification failed: release %q revision %d is missing from the history in the target storage", rev.Name, rev.Version)
+		}
+		rls, err := m.Target.Get(rev.Name, rev.Version)
+		if err != nil {
+			return errors.Wrapf(err, "verification failed: failed to read release %q revision %d from the target storage", rev.Name, rev.Version)
+		}
+		digest, err := releaseDigest(rls)
+		if err != nil {
+			return err
+		}
+		if digest != rev.Digest {
+			return errors.Errorf("verification failed: release %q revision %d has digest %s in the target storage, expected %s", rev.Name, rev.Version, digest, rev.Digest)
+		}
+	}
+	return nil
+}
+
+// copyRevision creates the release revision in the target storage. If the
+// revision already is in the target storage, it is left as it is if it is
+// identical, and an error is returned otherwise.
+func copyRevision(target *storage.Storage, rls *release.Release, dryRun bool, log DebugLog) (*MigratedRevision, error) {
+	digest, err := releaseDigest(rls)
+	if err != nil {
+		return nil, err
+	}
+	rev := &MigratedRevision{Name: rls.Name, Namespace: rls.Namespace, Version: rls.Version, Digest: digest}
+
+	existing, err := target.Get(rls.Name, rls.Version)
+	switch {
+	case err == nil:
+		existingDigest, err := releaseDigest(existing)
+		if err != nil {
+			return nil, err
+		}
+		if existingDigest != digest {
+			return nil, errors.Errorf("release %q revision %d already exists in the target storage with different content", rls.Name, rls.Version)
+		}
+		rev.Existing = true
+	case errors.Is(err, driver.ErrReleaseNotFound):
+		if !dryRun {
+			log("copying release %s revision %d", rls.Name, rls.Version)
+			if err := target.Create(rls); err != nil {
+				return nil, errors.Wrapf(err, "failed to copy release %q revision %d", rls.Name, rls.Version)
+			}
+		}
+	default:
+		return nil, errors.Wrapf(err, "failed to read release %q revision %d from the target storage", rls.Name, rls.Version)
+	}
+	return rev, nil
+}
+
+// releaseDigest returns the SHA-256 digest of the serialized release.
+func releaseDigest(rls *release.Release) (string, error) {
+	b, err := json.Marshal(rls)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to serialize release %q revision %d", rls.Name, rls.Version)
+	}
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,181 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func storageMigrateAction(t *testing.T) *StorageMigrate {
+	config := actionConfigFixture(t)
+	for i, status := range []release.Status{release.StatusSuperseded, release.StatusDeployed} {
+		rel := namedReleaseStub("angry-panda", status)
+		rel.Version = i + 1
+		require.NoError(t, config.Releases.Create(rel))
+	}
+	require.NoError(t, config.Releases.Create(namedReleaseStub("happy-bunny", release.StatusDeployed)))
+
+	client := NewStorageMigrate(config)
+	client.Target = storage.Init(driver.NewMemory())
+	return client
+}
+
+func TestStorageMigrate(t *testing.T) {
+	is := assert.New(t)
+	client := storageMigrateAction(t)
+
+	migrated, err := client.Run()
+	is.NoError(err)
+	is.Len(migrated, 3)
+	is.Equal("angry-panda", migrated[0].Name)
+	is.Equal(1, migrated[0].Version)
+	is.Equal("happy-bunny", migrated[2].Name)
+	for _, rev := range migrated {
+		is.False(rev.Existing)
+		is.Contains(rev.Digest, "sha256:")
+	}
+
+	deployed, err := client.Target.Deployed("angry-panda")
+	is.NoError(err)
+	is.Equal(2, deployed.Version)
+	history, err := client.Target.History("angry-panda")
+	is.NoError(err)
+	is.Len(history, 2)
+
+	// The source is left as it is, and migrating again is a no-op
+	source, err := client.cfg.Releases.ListReleases()
+	is.NoError(err)
+	is.Len(source, 3)
+	migrated, err = client.Run()
+	is.NoError(err)
+	is.Len(migrated, 3)
+	for _, rev := range migrated {
+		is.True(rev.Existing)
+	}
+}
+
+func TestStorageMigrateDryRun(t *testing.T) {
+	is := assert.New(t)
+	client := storageMigrateAction(t)
+	client.DryRun = true
+
+	migrated, err := client.Run()
+	is.NoError(err)
+	is.Len(migrated, 3)
+
+	target, err := client.Target.ListReleases()
+	is.NoError(err)
+	is.Empty(target)
+}
+
+func TestStorageMigrateDeleteSource(t *testing.T) {
+	is := assert.New(t)
+	client := storageMigrateAction(t)
+	client.DeleteSource = true
+
+	_, err := client.Run()
+	is.NoError(err)
+
+	source, err := client.cfg.Releases.ListReleases()
+	is.NoError(err)
+	is.Empty(source)
+	target, err := client.Target.ListReleases()
+	is.NoError(err)
+	is.Len(target, 3)
+}
+
+func TestStorageMigrateConflict(t *testing.T) {
+	is := assert.New(t)
+	client := storageMigrateAction(t)
+	client.DeleteSource = true
+
+	other := namedReleaseStub("happy-bunny", release.StatusFailed)
+	require.NoError(t, client.Target.Create(other))
+
+	migrated, err := client.Run()
+	is.EqualError(err, `release "happy-bunny" revision 1 already exists in the target storage with different content`)
+	is.Len(migrated, 2)
+
+	// The releases that were not migrated are not deleted
+	source, err := client.cfg.Releases.ListReleases()
+	is.NoError(err)
+	is.Len(source, 1)
+}
+
+func TestStorageMigrateNoTarget(t *testing.T) {
+	_, err := NewStorageMigrate(actionConfigFixture(t)).Run()
+	assert.EqualError(t, err, "no target storage")
+}
+
+func TestStorageMigrateSameStorage(t *testing.T) {
+	client := storageMigrateAction(t)
+	client.DeleteSource = true
+	client.Target = storage.Init(client.cfg.Releases.Driver)
+
+	_, err := client.Run()
+	assert.EqualError(t, err, "the target storage is the source storage")
+
+	source, err := client.cfg.Releases.ListReleases()
+	assert.NoError(t, err)
+	assert.Len(t, source, 3)
+}
+
+func TestStorageMigrateConfigMapsToSecrets(t *testing.T) {
+	is := assert.New(t)
+	clientset := fake.NewSimpleClientset()
+	leases := driver.NewLeases(clientset.CoordinationV1().Leases("default"))
+	leases.RetryPeriod = 10 * time.Millisecond
+
+	config := actionConfigFixture(t)
+	config.Releases = storage.Init(driver.NewConfigMaps(clientset.CoreV1().ConfigMaps("default")))
+	config.Releases.Locker = leases
+	require.NoError(t, config.Releases.Create(namedReleaseStub("angry-panda", release.StatusDeployed)))
+
+	// Both storages lock with the Leases of the same namespace.
+	client := NewStorageMigrate(config)
+	client.Target = storage.Init(driver.NewSecrets(clientset.CoreV1().Secrets("default")))
+	client.Target.Locker = leases
+	client.DeleteSource = true
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Run()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		is.NoError(err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("the migration is waiting for the lock it holds")
+	}
+
+	target, err := client.Target.ListReleases()
+	is.NoError(err)
+	is.Len(target, 1)
+	source, err := config.Releases.ListReleases()
+	is.NoError(err)
+	is.Empty(source)
+}
@@ -0,0 +1,515 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+// Transfer is the action for moving resources from a release to another
+// release, or for moving a whole release to another name or namespace.
+//
+// It provides the implementation of 'helm release transfer'.
+type Transfer struct {
+	cfg *Configuration
+
+	// Target is the storage of the namespace of the target release. It
+	// defaults to the storage of the configuration.
+	Target *storage.Storage
+	// TargetNamespace is the namespace of the target release. It defaults to
+	// the namespace of the source release. Target must be set along with it,
+	// as the storage drivers store releases per namespace.
+	TargetNamespace string
+	// Resources selects the resources of the deployed revision to move, as
+	// KIND/NAME. If empty, the whole history of the release is moved, which
+	// renames the release.
+	Resources []string
+	// DryRun reports what would be moved without changing anything.
+	DryRun bool
+}
+
+// TransferResult is the outcome of a transfer.
+type TransferResult struct {
+	// Resources are the moved resources, as KIND/NAME.
+	Resources []string
+	// Missing are the moved resources that do not exist in the cluster.
+	Missing []string
+	// Revisions are the revisions stored by the transfer, or that would be
+	// stored in a dry run.
+	Revisions []*release.Release
+}
+
+// NewTransfer creates a new Transfer object with the given configuration.
+func NewTransfer(cfg *Configuration) *Transfer {
+	return &Transfer{
+		cfg: cfg,
+	}
+}
+
+// Run moves the selected resources of the named release to the target
+// release, or the whole release if no resources are selected.
+//
+// The ownership metadata of the live resources is rewritten to name the
+// target release. Both releases are locked meanwhile, and if any step fails
+// the stored revisions and the ownership metadata are restored.
+func (t *Transfer) Run(name, target string) (*TransferResult, error) {
+	if err := t.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return nil, errors.Errorf("transfer: Release name is invalid: %s", name)
+	}
+	if err := chartutil.ValidateReleaseName(target); err != nil {
+		return nil, errors.Errorf("transfer: Release name is invalid: %s", target)
+	}
+	if t.Target == nil {
+		if t.TargetNamespace != "" {
+			return nil, errors.Errorf("no target storage for namespace %q", t.TargetNamespace)
+		}
+		t.Target = t.cfg.Releases
+	}
+
+	if !t.DryRun {
+		unlock, err := t.cfg.lockRelease(context.Background(), name, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+		ctx, cancel := context.WithTimeout(context.Background(), defaultLockTimeout)
+		defer cancel()
+		unlockTarget, err := t.Target.Lock(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		defer unlockTarget()
+	}
+
+	if len(t.Resources) == 0 {
+		return t.moveRelease(name, target)
+	}
+	return t.moveResources(name, target)
+}
+
+// moveRelease copies every revision of the release to the target release,
+// takes over the live resources of its deployed revision, and deletes the
+// revisions of the release.
+func (t *Transfer) moveRelease(name, target string) (*TransferResult, error) {
+	history, err := t.cfg.Releases.History(name)
+	if err != nil || len(history) == 0 {
+		return nil, errors.Errorf("release: %q not found", name)
+	}
+	releaseutil.SortByRevision(history)
+	namespace := t.targetNamespace(history[0])
+	if name == target && namespace == history[0].Namespace {
+		return nil, errors.Errorf("release %q already is in namespace %q", name, namespace)
+	}
+	if err := t.requireNoHistory(target); err != nil {
+		return nil, err
+	}
+
+	var manifest string
+	if deployed, err := t.cfg.Releases.Deployed(name); err == nil {
+		manifest = deployed.Manifest
+	}
+	if namespace != history[0].Namespace {
+		if err := t.requireExplicitNamespaces(splitManifestDocs(manifest)); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &TransferResult{Resources: manifestResources(splitManifestDocs(manifest))}
+	for _, rls := range history {
+		moved := *rls
+		moved.Name = target
+		moved.Namespace = namespace
+		result.Revisions = append(result.Revisions, &moved)
+	}
+
+	var undo undoStack
+	for _, rls := range result.Revisions {
+		if t.DryRun {
+			break
+		}
+		t.cfg.Log("storing release %s revision %d in namespace %s", rls.Name, rls.Version, rls.Namespace)
+		if err := t.Target.Create(rls); err != nil {
+			undo.run(t.cfg.Log)
+			return result, errors.Wrapf(err, "failed to store release %q revision %d", rls.Name, rls.Version)
+		}
+		version := rls.Version
+		undo.push(func() error {
+			_, err := t.Target.Delete(target, version)
+			return err
+		})
+	}
+
+	if err := t.adopt(result, manifest, history[0].Name, history[0].Namespace, target, namespace, &undo); err != nil {
+		return result, err
+	}
+	if t.DryRun {
+		return result, nil
+	}
+
+	for _, rls := range history {
+		t.cfg.Log("deleting release %s revision %d", rls.Name, rls.Version)
+		if _, err := t.cfg.Releases.Delete(rls.Name, rls.Version); err != nil {
+			undo.run(t.cfg.Log)
+			return result, errors.Wrapf(err, "failed to delete release %q revision %d", name, rls.Version)
+		}
+		deleted := rls
+		undo.push(func() error {
+			return t.cfg.Releases.Create(deleted)
+		})
+	}
+	return result, nil
+}
+
+// moveResources removes the selected resources from the manifest of the
+// deployed revision of the release, adds them to the manifest of the target
+// release, and stores a new revision of both releases.
+func (t *Transfer) moveResources(name, target string) (*TransferResult, error) {
+	source, err := t.cfg.Releases.Deployed(name)
+	if err != nil {
+		return nil, err
+	}
+	namespace := t.targetNamespace(source)
+	if name == target && namespace == source.Namespace {
+		return nil, errors.New("the source and the target release are the same")
+	}
+
+	kept, moved, err := selectManifestDocs(splitManifestDocs(source.Manifest), t.Resources)
+	if err != nil {
+		return nil, errors.Wrapf(err, "release %q", name)
+	}
+	if namespace != source.Namespace {
+		if err := t.requireExplicitNamespaces(moved); err != nil {
+			return nil, err
+		}
+	}
+	result := &TransferResult{Resources: manifestResources(moved)}
+
+	// The target release is created from the chart of the source release if
+	// it does not exist yet, so that it can be upgraded with its own chart.
+	var previous *release.Release
+	targetRelease := &release.Release{
+		Name:            target,
+		Namespace:       namespace,
+		Chart:           source.Chart,
+		Config:          source.Config,
+		SensitiveValues: source.SensitiveValues,
+		Version:         1,
+		Info:            &release.Info{FirstDeployed: t.cfg.Now()},
+	}
+	history, err := t.Target.History(target)
+	if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, err
+	}
+	if len(history) == 0 {
+		targetRelease.Manifest = joinManifestDocs(moved)
+	} else {
+		if previous, err = t.Target.Deployed(target); err != nil {
+			return nil, err
+		}
+		releaseutil.Reverse(history, releaseutil.SortByRevision)
+		targetRelease = newTransferRevision(previous, history[0].Version+1, t.cfg.Now())
+		targetRelease.Manifest = joinManifestDocs(append(splitManifestDocs(previous.Manifest), moved...))
+	}
+	targetRelease.Info.LastDeployed = t.cfg.Now()
+	targetRelease.Info.Status = release.StatusDeployed
+	targetRelease.Info.Description = fmt.Sprintf("Transferred %d resources from release %q", len(result.Resources), name)
+
+	last, err := t.cfg.Releases.Last(name)
+	if err != nil {
+		return nil, err
+	}
+	sourceRelease := newTransferRevision(source, last.Version+1, t.cfg.Now())
+	sourceRelease.Manifest = joinManifestDocs(kept)
+	sourceRelease.Info.Description = fmt.Sprintf("Transferred %d resources to release %q", len(result.Resources), target)
+	result.Revisions = []*release.Release{sourceRelease, targetRelease}
+
+	var undo undoStack
+	if !t.DryRun {
+		if err := t.store(t.cfg.Releases, source, sourceRelease, &undo); err != nil {
+			undo.run(t.cfg.Log)
+			return result, err
+		}
+		if err := t.store(t.Target, previous, targetRelease, &undo); err != nil {
+			undo.run(t.cfg.Log)
+			return result, err
+		}
+	}
+
+	return result, t.adopt(result, joinManifestDocs(moved), name, source.Namespace, target, namespace, &undo)
+}
+
+// store creates the revision in the storage and supersedes the previous
+// revision, if any, recording how to revert both in undo.
+func (t *Transfer) store(s *storage.Storage, previous, rls *release.Release, undo *undoStack) error {
+	t.cfg.Log("storing release %s revision %d", rls.Name, rls.Version)
+	if err := s.Create(rls); err != nil {
+		return errors.Wrapf(err, "failed to store release %q revision %d", rls.Name, rls.Version)
+	}
+	undo.push(func() error {
+		_, err := s.Delete(rls.Name, rls.Version)
+		return err
+	})
+	if previous == nil {
+		return nil
+	}
+
+	superseded := *previous
+	info := *previous.Info
+	info.Status = release.StatusSuperseded
+	superseded.Info = &info
+	if err := s.Update(&superseded); err != nil {
+		return errors.Wrapf(err, "failed to supersede release %q revision %d", previous.Name, previous.Version)
+	}
+	undo.push(func() error {
+		return s.Update(previous)
+	})
+	return nil
+}
+
+// adopt rewrites the ownership metadata of the live resources of the manifest
+// to name the target release, recording how to give them back to the source
+// release in undo. If that fails, the resources already rewritten are given
+// back and undo is run.
+func (t *Transfer) adopt(result *TransferResult, manifest, name, namespace, target, targetNamespace string, undo *undoStack) error {
+	if strings.TrimSpace(manifest) == "" {
+		return nil
+	}
+	resources, err := t.cfg.KubeClient.Build(bytes.NewBufferString(manifest), false)
+	if err != nil {
+		undo.run(t.cfg.Log)
+		return errors.Wrap(err, "unable to build kubernetes objects from the manifest")
+	}
+	adopted, missing, err := adoptResources(resources, target, targetNamespace, true, t.DryRun)
+	for _, info := range missing {
+		result.Missing = append(result.Missing, resourceString(info))
+	}
+	if err != nil {
+		if _, _, revertErr := adoptResources(adopted, name, namespace, true, false); revertErr != nil {
+			t.cfg.Log("failed to restore the ownership metadata: %s", revertErr)
+		}
+		undo.run(t.cfg.Log)
+		return err
+	}
+	if !t.DryRun {
+		undo.push(func() error {
+			_, _, err := adoptResources(adopted, name, namespace, true, false)
+			return err
+		})
+	}
+	return nil
+}
+
+// requireExplicitNamespaces returns an error if a namespaced resource of the
+// documents does not set metadata.namespace. Such a resource lives in the
+// namespace of the release, so moving the release to another namespace would
+// leave it behind, and the next upgrade would create it anew.
+func (t *Transfer) requireExplicitNamespaces(docs []manifestDoc) error {
+	implicit := map[string]bool{}
+	for _, doc := range docs {
+		if doc.kind != "" && doc.namespace == "" {
+			implicit[strings.ToLower(doc.resource())] = true
+		}
+	}
+	if len(implicit) == 0 {
+		return nil
+	}
+	resources, err := t.cfg.KubeClient.Build(bytes.NewBufferString(joinManifestDocs(docs)), false)
+	if err != nil {
+		return errors.Wrap(err, "unable to build kubernetes objects from the manifest")
+	}
+	var names []string
+	for _, info := range resources {
+		resource := info.Mapping.GroupVersionKind.Kind + "/" + info.Name
+		if info.Namespaced() && implicit[strings.ToLower(resource)] {
+			names = append(names, resource)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		return errors.Errorf("cannot move to another namespace: %s do not set metadata.namespace and would stay in the namespace of the release", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+func (t *Transfer) targetNamespace(rls *release.Release) string {
+	if t.TargetNamespace != "" {
+		return t.TargetNamespace
+	}
+	return rls.Namespace
+}
+
+// requireNoHistory returns an error if the target storage has revisions of
+// the named release.
+func (t *Transfer) requireNoHistory(name string) error {
+	history, err := t.Target.History(name)
+	if err != nil && !errors.Is(err, driver.ErrReleaseNotFound) {
+		return err
+	}
+	if len(history) > 0 {
+		return errors.Errorf("release %q already exists", name)
+	}
+	return nil
+}
+
+// newTransferRevision returns the next revision of the deployed release rls.
+func newTransferRevision(rls *release.Release, version int, now helmtime.Time) *release.Release {
+	next := *rls
+	next.Version = version
+	info := *rls.Info
+	info.LastDeployed = now
+	info.Status = release.StatusDeployed
+	next.Info = &info
+	return &next
+}
+
+// undoStack holds the functions that revert the steps of a transfer, which
+// are run in reverse order.
+type undoStack []func() error
+
+func (u *undoStack) push(fn func() error) {
+	*u = append(*u, fn)
+}
+
+func (u *undoStack) run(log DebugLog) {
+	for i := len(*u) - 1; i >= 0; i-- {
+		if err := (*u)[i](); err != nil {
+			log("failed to revert the transfer: %s", err)
+		}
+	}
+	*u = nil
+}
+
+// manifestDoc is a document of a release manifest.
+type manifestDoc struct {
+	content   string
+	kind      string
+	name      string
+	namespace string
+}
+
+func (d manifestDoc) resource() string {
+	return d.kind + "/" + d.name
+}
+
+// splitManifestDocs splits a release manifest into its documents.
+func splitManifestDocs(manifest string) []manifestDoc {
+	split := releaseutil.SplitManifests(manifest)
+	keys := make([]string, 0, len(split))
+	for k := range split {
+		keys = append(keys, k)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	var docs []manifestDoc
+	for _, k := range keys {
+		doc := manifestDoc{content: split[k]}
+		var head struct {
+			Kind     string `json:"kind"`
+			Metadata *struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(doc.content), &head); err == nil && head.Metadata != nil {
+			doc.kind = head.Kind
+			doc.name = head.Metadata.Name
+			doc.namespace = head.Metadata.Namespace
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// joinManifestDocs joins documents the way rendered manifests are written.
+func joinManifestDocs(docs []manifestDoc) string {
+	var b strings.Builder
+	for _, doc := range docs {
+		fmt.Fprintf(&b, "---\n%s\n", doc.content)
+	}
+	return b.String()
+}
+
+// selectManifestDocs splits the documents into those that are not selected
+// and those that are. Every selector must match a document.
+func selectManifestDocs(docs []manifestDoc, selectors []string) (kept, selected []manifestDoc, err error) {
+	for _, sel := range selectors {
+		if !strings.Contains(sel, "/") {
+			return nil, nil, errors.Errorf("invalid resource %q: must be KIND/NAME", sel)
+		}
+		found := false
+		for _, doc := range docs {
+			if doc.matches(sel) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, nil, errors.Errorf("resource %s not found in the manifest", sel)
+		}
+	}
+	for _, doc := range docs {
+		if matchesAny(doc, selectors) {
+			selected = append(selected, doc)
+		} else {
+			kept = append(kept, doc)
+		}
+	}
+	return kept, selected, nil
+}
+
+// matches reports whether the document is the resource KIND/NAME. Kinds are
+// matched case-insensitively.
+func (d manifestDoc) matches(selector string) bool {
+	i := strings.Index(selector, "/")
+	return i > 0 && d.kind != "" && strings.EqualFold(selector[:i], d.kind) && selector[i+1:] == d.name
+}
+
+func matchesAny(doc manifestDoc, selectors []string) bool {
+	for _, sel := range selectors {
+		if doc.matches(sel) {
+			return true
+		}
+	}
+	return false
+}
+
+func manifestResources(docs []manifestDoc) []string {
+	var resources []string
+	for _, doc := range docs {
+		if doc.kind != "" {
+			resources = append(resources, doc.resource())
+		}
+	}
+	return resources
+}
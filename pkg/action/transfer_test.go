@@ -0,0 +1,265 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+const transferManifest = `---
+# Source: hello/templates/a.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+---
+# Source: hello/templates/b.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+`
+
+func transferFixture(t *testing.T, names ...string) (*Configuration, map[string]string) {
+	t.Helper()
+	cfg := actionConfigFixture(t)
+	owned := metav1.ObjectMeta{
+		Labels:      map[string]string{appManagedByLabel: appManagedByHelm},
+		Annotations: map[string]string{helmReleaseNameAnnotation: "source", helmReleaseNamespaceAnnotation: "ns-a"},
+	}
+	live := map[string]*corev1.ConfigMap{"a": {ObjectMeta: owned}, "b": {ObjectMeta: owned}}
+	patches := map[string]string{}
+	client := &liveKubeClient{PrintingKubeClient: kubefake.PrintingKubeClient{Out: ioutil.Discard}}
+	for _, name := range names {
+		client.resources.Append(newLiveConfigMapResource(name, live, patches))
+	}
+	cfg.KubeClient = client
+
+	for i, status := range []release.Status{release.StatusSuperseded, release.StatusDeployed} {
+		rls := namedReleaseStub("source", status)
+		rls.Namespace = "ns-a"
+		rls.Version = i + 1
+		rls.Manifest = transferManifest
+		if err := cfg.Releases.Create(rls); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return cfg, patches
+}
+
+func TestTransferRelease(t *testing.T) {
+	is := assert.New(t)
+	cfg, patches := transferFixture(t, "a", "b")
+
+	result, err := NewTransfer(cfg).Run("source", "target")
+	is.NoError(err)
+	is.Equal([]string{"ConfigMap/a", "ConfigMap/b"}, result.Resources)
+	is.Len(result.Revisions, 2)
+
+	history, err := cfg.Releases.History("target")
+	is.NoError(err)
+	is.Len(history, 2)
+	for _, rls := range history {
+		is.Equal("target", rls.Name)
+		is.Equal("ns-a", rls.Namespace)
+	}
+	history, _ = cfg.Releases.History("source")
+	is.Empty(history)
+	is.Contains(patches["a"], `"meta.helm.sh/release-name":"target"`)
+	is.Contains(patches["b"], `"meta.helm.sh/release-name":"target"`)
+}
+
+func TestTransferReleaseToNamespace(t *testing.T) {
+	is := assert.New(t)
+	cfg, patches := transferFixture(t, "a")
+	target := storage.Init(driver.NewMemory())
+
+	transfer := NewTransfer(cfg)
+	transfer.TargetNamespace = "ns-b"
+	_, err := transfer.Run("source", "source")
+	is.EqualError(err, `no target storage for namespace "ns-b"`)
+
+	// The config maps would be left behind in ns-a
+	transfer.Target = target
+	_, err = transfer.Run("source", "source")
+	is.EqualError(err, "cannot move to another namespace: ConfigMap/a do not set metadata.namespace and would stay in the namespace of the release")
+	history, _ := target.History("source")
+	is.Empty(history)
+
+	deployed, err := cfg.Releases.Deployed("source")
+	is.NoError(err)
+	deployed.Manifest = strings.ReplaceAll(transferManifest, "metadata:\n", "metadata:\n  namespace: ns-a\n")
+	is.NoError(cfg.Releases.Update(deployed))
+	_, err = transfer.Run("source", "source")
+	is.NoError(err)
+	history, err = target.History("source")
+	is.NoError(err)
+	is.Len(history, 2)
+	is.Equal("ns-b", history[0].Namespace)
+	is.Contains(patches["a"], `"meta.helm.sh/release-namespace":"ns-b"`)
+}
+
+// failingDeleteDriver fails to delete the release with the given key.
+type failingDeleteDriver struct {
+	driver.Driver
+	key string
+}
+
+func (d *failingDeleteDriver) Delete(key string) (*release.Release, error) {
+	if key == d.key {
+		return nil, errors.New("delete failed")
+	}
+	return d.Driver.Delete(key)
+}
+
+func TestTransferReleaseRevert(t *testing.T) {
+	is := assert.New(t)
+	cfg, patches := transferFixture(t, "a", "b")
+	cfg.Releases.Driver = &failingDeleteDriver{Driver: cfg.Releases.Driver, key: "sh.helm.release.v1.source.v2"}
+
+	_, err := NewTransfer(cfg).Run("source", "target")
+	is.EqualError(err, `failed to delete release "source" revision 2: delete failed`)
+
+	history, err := cfg.Releases.History("source")
+	is.NoError(err)
+	is.Len(history, 2)
+	history, _ = cfg.Releases.History("target")
+	is.Empty(history)
+	is.Contains(patches["a"], `"meta.helm.sh/release-name":"source"`)
+	is.Contains(patches["b"], `"meta.helm.sh/release-name":"source"`)
+}
+
+func TestTransferReleaseExists(t *testing.T) {
+	cfg, _ := transferFixture(t)
+	rls := namedReleaseStub("target", release.StatusDeployed)
+	rls.Namespace = "ns-a"
+	if err := cfg.Releases.Create(rls); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := NewTransfer(cfg).Run("source", "target")
+	assert.EqualError(t, err, `release "target" already exists`)
+}
+
+func TestTransferResources(t *testing.T) {
+	is := assert.New(t)
+	cfg, patches := transferFixture(t, "b")
+
+	transfer := NewTransfer(cfg)
+	transfer.Resources = []string{"configmap/b"}
+	result, err := transfer.Run("source", "target")
+	is.NoError(err)
+	is.Equal([]string{"ConfigMap/b"}, result.Resources)
+
+	source, err := cfg.Releases.Get("source", 3)
+	is.NoError(err)
+	is.Equal(release.StatusDeployed, source.Info.Status)
+	is.Contains(source.Manifest, "name: a")
+	is.NotContains(source.Manifest, "name: b")
+	previous, err := cfg.Releases.Get("source", 2)
+	is.NoError(err)
+	is.Equal(release.StatusSuperseded, previous.Info.Status)
+
+	target, err := cfg.Releases.Get("target", 1)
+	is.NoError(err)
+	is.Equal(release.StatusDeployed, target.Info.Status)
+	is.Equal("ns-a", target.Namespace)
+	is.Equal("---\n# Source: hello/templates/b.yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n", target.Manifest)
+	is.Contains(patches["b"], `"meta.helm.sh/release-name":"target"`)
+
+	// Moving to an existing release adds to its manifest
+	transfer.Resources = []string{"ConfigMap/a"}
+	_, err = transfer.Run("source", "target")
+	is.NoError(err)
+	target, err = cfg.Releases.Get("target", 2)
+	is.NoError(err)
+	is.Contains(target.Manifest, "name: a")
+	is.Contains(target.Manifest, "name: b")
+	previous, err = cfg.Releases.Get("target", 1)
+	is.NoError(err)
+	is.Equal(release.StatusSuperseded, previous.Info.Status)
+	source, err = cfg.Releases.Deployed("source")
+	is.NoError(err)
+	is.Equal(4, source.Version)
+	is.NotContains(source.Manifest, "kind: ConfigMap")
+}
+
+func TestTransferResourcesNotFound(t *testing.T) {
+	cfg, _ := transferFixture(t)
+
+	transfer := NewTransfer(cfg)
+	transfer.Resources = []string{"Secret/b"}
+	_, err := transfer.Run("source", "target")
+	assert.EqualError(t, err, `release "source": resource Secret/b not found in the manifest`)
+
+	transfer.Resources = []string{"b"}
+	_, err = transfer.Run("source", "target")
+	assert.EqualError(t, err, `release "source": invalid resource "b": must be KIND/NAME`)
+}
+
+func TestTransferResourcesDryRun(t *testing.T) {
+	is := assert.New(t)
+	cfg, patches := transferFixture(t, "b")
+
+	transfer := NewTransfer(cfg)
+	transfer.Resources = []string{"ConfigMap/b"}
+	transfer.DryRun = true
+	result, err := transfer.Run("source", "target")
+	is.NoError(err)
+	is.Len(result.Revisions, 2)
+	is.Empty(patches)
+	history, _ := cfg.Releases.History("target")
+	is.Empty(history)
+	last, err := cfg.Releases.Last("source")
+	is.NoError(err)
+	is.Equal(2, last.Version)
+}
+
+func TestTransferResourcesRevert(t *testing.T) {
+	is := assert.New(t)
+	cfg, _ := transferFixture(t)
+	cfg.KubeClient = &kubefake.FailingKubeClient{
+		PrintingKubeClient: kubefake.PrintingKubeClient{Out: ioutil.Discard},
+		BuildError:         errors.New("build failed"),
+	}
+
+	transfer := NewTransfer(cfg)
+	transfer.Resources = []string{"ConfigMap/b"}
+	_, err := transfer.Run("source", "target")
+	is.Error(err)
+
+	source, err := cfg.Releases.Deployed("source")
+	is.NoError(err)
+	is.Equal(2, source.Version)
+	is.Contains(source.Manifest, "name: b")
+	_, err = cfg.Releases.Get("source", 3)
+	is.Error(err)
+	_, err = cfg.Releases.Get("target", 1)
+	is.Error(err)
+}
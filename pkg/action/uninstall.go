@@ -17,6 +17,7 @@ limitations under the License.
 package action
 
 import (
+	"context"
 	"strings"
 	"time"
 
@@ -39,6 +40,10 @@ type Uninstall struct {
 	KeepHistory  bool
 	Timeout      time.Duration
 	Description  string
+
+	// lockHeld is set when the caller already holds the lock of the release,
+	// e.g. when an atomic install is rolled back.
+	lockHeld bool
 }
 
 // NewUninstall creates a new Uninstall object with the given configuration.
@@ -67,6 +72,14 @@ func (u *Uninstall) Run(name string) (*release.UninstallReleaseResponse, error)
 		return nil, errors.Errorf("uninstall: Release name is invalid: %s", name)
 	}
 
+	if !u.lockHeld {
+		unlock, err := u.cfg.lockRelease(context.Background(), name, u.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
 	rels, err := u.cfg.Releases.History(name)
 	if err != nil {
 		return nil, errors.Wrapf(err, "uninstall: Release not loaded: %s", name)
@@ -97,7 +110,7 @@ func (u *Uninstall) Run(name string) (*release.UninstallReleaseResponse, error)
 	res := &release.UninstallReleaseResponse{Release: rel}
 
 	if !u.DisableHooks {
-		if err := u.cfg.execHook(rel, release.HookPreDelete, u.Timeout); err != nil {
+		if err := u.cfg.execHook(context.Background(), rel, release.HookPreDelete, u.Timeout, 1); err != nil {
 			return res, err
 		}
 	} else {
@@ -118,7 +131,7 @@ func (u *Uninstall) Run(name string) (*release.UninstallReleaseResponse, error)
 	res.Info = kept
 
 	if !u.DisableHooks {
-		if err := u.cfg.execHook(rel, release.HookPostDelete, u.Timeout); err != nil {
+		if err := u.cfg.execHook(context.Background(), rel, release.HookPostDelete, u.Timeout, 1); err != nil {
 			errs = append(errs, err)
 		}
 	}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -96,8 +97,28 @@ type Upgrade struct {
 	// If this is non-nil, then after templates are rendered, they will be sent to the
 	// post renderer before sending to the Kubernetes API server.
 	PostRenderer postrender.PostRenderer
+	// ValueSources names the values file or flag of each user-supplied
+	// value in schema validation errors.
+	ValueSources chartutil.ValueSources
+	// SensitiveValues are the JSON pointers of the values that came from
+	// encrypted values files. They are stored with the release, together with
+	// those of the current release whose values are reused.
+	SensitiveValues []string
 	// DisableOpenAPIValidation controls whether OpenAPI validation is enforced.
 	DisableOpenAPIValidation bool
+	// ServerSideApply sends resources with server-side apply instead of
+	// client-side three-way merge patches. Combined with DryRun, the changes
+	// are validated by the API server with a server-side dry run.
+	ServerSideApply bool
+	// ForceConflicts takes ownership of fields managed by other field
+	// managers. It only applies when ServerSideApply is set, which cannot be
+	// combined with Force: replacing the resources would bypass server-side
+	// apply, so ForceConflicts stands for Force instead.
+	ForceConflicts bool
+	// HookParallelism is the maximum number of hooks of the same weight that
+	// run at the same time. Hooks of different weights always run in order.
+	// Zero or one runs the hooks one at a time.
+	HookParallelism int
 }
 
 // NewUpgrade creates a new Upgrade object with the given configuration.
@@ -109,6 +130,13 @@ func NewUpgrade(cfg *Configuration) *Upgrade {
 
 // Run executes the upgrade on the given release.
 func (u *Upgrade) Run(name string, chart *chart.Chart, vals map[string]interface{}) (*release.Release, error) {
+	return u.RunWithContext(context.Background(), name, chart, vals)
+}
+
+// RunWithContext executes the upgrade like Run, but aborts it when ctx is
+// done. An aborted upgrade is marked as failed and, if Atomic is set, rolled
+// back to the last successful release.
+func (u *Upgrade) RunWithContext(ctx context.Context, name string, chart *chart.Chart, vals map[string]interface{}) (*release.Release, error) {
 	if err := u.cfg.KubeClient.IsReachable(); err != nil {
 		return nil, err
 	}
@@ -120,6 +148,18 @@ func (u *Upgrade) Run(name string, chart *chart.Chart, vals map[string]interface
 	if err := chartutil.ValidateReleaseName(name); err != nil {
 		return nil, errors.Errorf("release name is invalid: %s", name)
 	}
+	if u.Force && u.ServerSideApply {
+		return nil, errForceServerSide
+	}
+
+	if !u.DryRun {
+		unlock, err := u.cfg.lockRelease(ctx, name, u.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
 	u.cfg.Log("preparing upgrade for %s", name)
 	currentRelease, upgradedRelease, err := u.prepareUpgrade(name, chart, vals)
 	if err != nil {
@@ -129,7 +169,7 @@ func (u *Upgrade) Run(name string, chart *chart.Chart, vals map[string]interface
 	u.cfg.Releases.MaxHistory = u.MaxHistory
 
 	u.cfg.Log("performing update for %s", name)
-	res, err := u.performUpgrade(currentRelease, upgradedRelease)
+	res, err := u.performUpgrade(ctx, currentRelease, upgradedRelease)
 	if err != nil {
 		return res, err
 	}
@@ -183,6 +223,7 @@ func (u *Upgrade) prepareUpgrade(name string, chart *chart.Chart, vals map[strin
 	}
 
 	// determine if values will be reused
+	sensitive := u.sensitiveValues(currentRelease, vals)
 	vals, err = u.reuseValues(chart, currentRelease, vals)
 	if err != nil {
 		return nil, nil, err
@@ -197,10 +238,11 @@ func (u *Upgrade) prepareUpgrade(name string, chart *chart.Chart, vals map[strin
 	revision := lastRelease.Version + 1
 
 	options := chartutil.ReleaseOptions{
-		Name:      name,
-		Namespace: currentRelease.Namespace,
-		Revision:  revision,
-		IsUpgrade: true,
+		Name:         name,
+		Namespace:    currentRelease.Namespace,
+		Revision:     revision,
+		IsUpgrade:    true,
+		ValueSources: u.ValueSources,
 	}
 
 	caps, err := u.cfg.getCapabilities()
@@ -229,9 +271,10 @@ func (u *Upgrade) prepareUpgrade(name string, chart *chart.Chart, vals map[strin
 			Status:        release.StatusPendingUpgrade,
 			Description:   "Preparing upgrade", // This should be overwritten later.
 		},
-		Version:  revision,
-		Manifest: manifestDoc.String(),
-		Hooks:    hooks,
+		Version:         revision,
+		Manifest:        manifestDoc.String(),
+		Hooks:           hooks,
+		SensitiveValues: sensitive,
 	}
 
 	if len(notesTxt) > 0 {
@@ -241,7 +284,7 @@ func (u *Upgrade) prepareUpgrade(name string, chart *chart.Chart, vals map[strin
 	return currentRelease, upgradedRelease, err
 }
 
-func (u *Upgrade) performUpgrade(originalRelease, upgradedRelease *release.Release) (*release.Release, error) {
+func (u *Upgrade) performUpgrade(ctx context.Context, originalRelease, upgradedRelease *release.Release) (*release.Release, error) {
 	current, err := u.cfg.KubeClient.Build(bytes.NewBufferString(originalRelease.Manifest), false)
 	if err != nil {
 		// Checking for removed Kubernetes API error so can provide a more informative error message to the user
@@ -292,6 +335,11 @@ func (u *Upgrade) performUpgrade(originalRelease, upgradedRelease *release.Relea
 
 	if u.DryRun {
 		u.cfg.Log("dry run for %s", upgradedRelease.Name)
+		if u.ServerSideApply {
+			if _, err := u.cfg.updateResources(current, target, u.Force, applyOptions(true, u.ForceConflicts, true)); err != nil {
+				return upgradedRelease, errors.Wrap(err, "server-side dry run failed")
+			}
+		}
 		if len(u.Description) > 0 {
 			upgradedRelease.Info.Description = u.Description
 		} else {
@@ -300,21 +348,27 @@ func (u *Upgrade) performUpgrade(originalRelease, upgradedRelease *release.Relea
 		return upgradedRelease, nil
 	}
 
+	// Nothing has been stored yet, so there is nothing to clean up.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	u.cfg.Log("creating upgraded release for %s", upgradedRelease.Name)
 	if err := u.cfg.Releases.Create(upgradedRelease); err != nil {
 		return nil, err
 	}
+	defer u.cfg.startHeartbeat(upgradedRelease)()
 
 	// pre-upgrade hooks
 	if !u.DisableHooks {
-		if err := u.cfg.execHook(upgradedRelease, release.HookPreUpgrade, u.Timeout); err != nil {
+		if err := u.cfg.execHook(ctx, upgradedRelease, release.HookPreUpgrade, u.Timeout, u.HookParallelism); err != nil {
 			return u.failRelease(upgradedRelease, kube.ResourceList{}, fmt.Errorf("pre-upgrade hooks failed: %s", err))
 		}
 	} else {
 		u.cfg.Log("upgrade hooks disabled for %s", upgradedRelease.Name)
 	}
 
-	results, err := u.cfg.KubeClient.Update(current, target, u.Force)
+	results, err := u.cfg.updateResources(current, target, u.Force, applyOptions(u.ServerSideApply, u.ForceConflicts, false))
 	if err != nil {
 		u.cfg.recordRelease(originalRelease)
 		return u.failRelease(upgradedRelease, results.Created, err)
@@ -330,23 +384,21 @@ func (u *Upgrade) performUpgrade(originalRelease, upgradedRelease *release.Relea
 		}
 	}
 
+	if err := ctx.Err(); err != nil {
+		u.cfg.recordRelease(originalRelease)
+		return u.failRelease(upgradedRelease, results.Created, err)
+	}
+
 	if u.Wait {
-		if u.WaitForJobs {
-			if err := u.cfg.KubeClient.WaitWithJobs(target, u.Timeout); err != nil {
-				u.cfg.recordRelease(originalRelease)
-				return u.failRelease(upgradedRelease, results.Created, err)
-			}
-		} else {
-			if err := u.cfg.KubeClient.Wait(target, u.Timeout); err != nil {
-				u.cfg.recordRelease(originalRelease)
-				return u.failRelease(upgradedRelease, results.Created, err)
-			}
+		if err := u.cfg.waitForResources(ctx, target, u.Timeout, u.WaitForJobs); err != nil {
+			u.cfg.recordRelease(originalRelease)
+			return u.failRelease(upgradedRelease, results.Created, err)
 		}
 	}
 
 	// post-upgrade hooks
 	if !u.DisableHooks {
-		if err := u.cfg.execHook(upgradedRelease, release.HookPostUpgrade, u.Timeout); err != nil {
+		if err := u.cfg.execHook(ctx, upgradedRelease, release.HookPostUpgrade, u.Timeout, u.HookParallelism); err != nil {
 			return u.failRelease(upgradedRelease, results.Created, fmt.Errorf("post-upgrade hooks failed: %s", err))
 		}
 	}
@@ -407,12 +459,15 @@ func (u *Upgrade) failRelease(rel *release.Release, created kube.ResourceList, e
 		releaseutil.Reverse(filteredHistory, releaseutil.SortByRevision)
 
 		rollin := NewRollback(u.cfg)
+		rollin.lockHeld = true
 		rollin.Version = filteredHistory[0].Version
 		rollin.Wait = true
 		rollin.WaitForJobs = u.WaitForJobs
 		rollin.DisableHooks = u.DisableHooks
 		rollin.Recreate = u.Recreate
 		rollin.Force = u.Force
+		rollin.ServerSideApply = u.ServerSideApply
+		rollin.ForceConflicts = u.ForceConflicts
 		rollin.Timeout = u.Timeout
 		if rollErr := rollin.Run(rel.Name); rollErr != nil {
 			return rel, errors.Wrapf(rollErr, "an error occurred while rolling back the release. original upgrade error: %s", err)
@@ -462,6 +517,23 @@ func (u *Upgrade) reuseValues(chart *chart.Chart, current *release.Release, newV
 	return newVals, nil
 }
 
+// sensitiveValues returns the sensitive values of the upgraded release: those
+// of u.SensitiveValues, plus those of the current release whose values are
+// reused and not overridden by newVals.
+func (u *Upgrade) sensitiveValues(current *release.Release, newVals map[string]interface{}) []string {
+	if u.ResetValues || (!u.ReuseValues && len(newVals) > 0) {
+		return u.SensitiveValues
+	}
+	sensitive := append([]string{}, u.SensitiveValues...)
+	for _, ptr := range current.SensitiveValues {
+		if !chartutil.HasValue(newVals, ptr) {
+			sensitive = append(sensitive, ptr)
+		}
+	}
+	sort.Strings(sensitive)
+	return sensitive
+}
+
 func validateManifest(c kube.Interface, manifest []byte, openAPIValidation bool) error {
 	_, err := c.Build(bytes.NewReader(manifest), openAPIValidation)
 	return err
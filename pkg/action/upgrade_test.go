@@ -17,6 +17,7 @@ limitations under the License.
 package action
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -38,6 +39,18 @@ func upgradeAction(t *testing.T) *Upgrade {
 	return upAction
 }
 
+func TestUpgradeRelease_ForceServerSide(t *testing.T) {
+	upAction := upgradeAction(t)
+	rel := releaseStub()
+	rel.Info.Status = release.StatusDeployed
+	require.NoError(t, upAction.cfg.Releases.Create(rel))
+
+	upAction.Force = true
+	upAction.ServerSideApply = true
+	_, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	assert.Equal(t, errForceServerSide, err)
+}
+
 func TestUpgradeRelease_Wait(t *testing.T) {
 	is := assert.New(t)
 	req := require.New(t)
@@ -159,6 +172,54 @@ func TestUpgradeRelease_Atomic(t *testing.T) {
 	})
 }
 
+func TestUpgradeRelease_Cancelled(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	t.Run("release is marked failed", func(t *testing.T) {
+		upAction := upgradeAction(t)
+		rel := releaseStub()
+		rel.Name = "interrupted"
+		rel.Info.Status = release.StatusDeployed
+		upAction.cfg.Releases.Create(rel)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		upAction.cfg.KubeClient = &cancellingKubeClient{upAction.cfg.KubeClient.(*kubefake.FailingKubeClient), cancel}
+		upAction.Wait = true
+
+		res, err := upAction.RunWithContext(ctx, rel.Name, buildChart(), map[string]interface{}{})
+		req.Error(err)
+		is.Contains(err.Error(), context.Canceled.Error())
+
+		updatedRes, err := upAction.cfg.Releases.Get(res.Name, 2)
+		req.NoError(err)
+		is.Equal(release.StatusFailed, updatedRes.Info.Status)
+	})
+
+	t.Run("atomic rollback succeeds", func(t *testing.T) {
+		upAction := upgradeAction(t)
+		rel := releaseStub()
+		rel.Name = "interrupted"
+		rel.Info.Status = release.StatusDeployed
+		upAction.cfg.Releases.Create(rel)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		upAction.cfg.KubeClient = &cancellingKubeClient{upAction.cfg.KubeClient.(*kubefake.FailingKubeClient), cancel}
+		upAction.Atomic = true
+
+		res, err := upAction.RunWithContext(ctx, rel.Name, buildChart(), map[string]interface{}{})
+		req.Error(err)
+		is.Contains(err.Error(), "atomic")
+
+		// The rollback runs after the cancel, so it creates a deployed revision.
+		rolledBack, err := upAction.cfg.Releases.Get(res.Name, 3)
+		req.NoError(err)
+		is.Equal(release.StatusDeployed, rolledBack.Info.Status)
+	})
+}
+
 func TestUpgradeRelease_ReuseValues(t *testing.T) {
 	is := assert.New(t)
 
@@ -277,6 +338,50 @@ func TestUpgradeRelease_ReuseValues(t *testing.T) {
 	})
 }
 
+func TestUpgradeRelease_SensitiveValues(t *testing.T) {
+	is := assert.New(t)
+
+	for _, tt := range []struct {
+		name        string
+		reuseValues bool
+		newValues   map[string]interface{}
+		expected    []string
+	}{
+		{
+			name:        "reused values keep their sensitivity",
+			reuseValues: true,
+			newValues:   map[string]interface{}{"db": map[string]interface{}{"user": "admin"}, "token": "abc"},
+			expected:    []string{"/db/password", "/token"},
+		},
+		{
+			name:        "overridden values are no longer sensitive",
+			reuseValues: true,
+			newValues:   map[string]interface{}{"db": map[string]interface{}{"password": "plain"}},
+			expected:    []string{"/token"},
+		},
+		{
+			name:      "new values replace the sensitive values",
+			newValues: map[string]interface{}{"name": "value"},
+			expected:  []string{"/token"},
+		},
+	} {
+		upAction := upgradeAction(t)
+
+		rel := releaseStub()
+		rel.Name = "nuketown"
+		rel.Info.Status = release.StatusDeployed
+		rel.Config = map[string]interface{}{"db": map[string]interface{}{"password": "s3cr3t"}}
+		rel.SensitiveValues = []string{"/db/password"}
+		is.NoError(upAction.cfg.Releases.Create(rel))
+
+		upAction.ReuseValues = tt.reuseValues
+		upAction.SensitiveValues = []string{"/token"}
+		res, err := upAction.Run(rel.Name, buildChart(), tt.newValues)
+		is.NoError(err, tt.name)
+		is.Equal(tt.expected, res.SensitiveValues, tt.name)
+	}
+}
+
 func TestUpgradeRelease_Pending(t *testing.T) {
 	req := require.New(t)
 
@@ -296,3 +401,53 @@ func TestUpgradeRelease_Pending(t *testing.T) {
 	_, err := upAction.Run(rel.Name, buildChart(), vals)
 	req.Contains(err.Error(), "progress", err)
 }
+
+func TestUpgradeRelease_ServerSideDryRun(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction := upgradeAction(t)
+	rel := releaseStub()
+	rel.Name = "admission-denied"
+	rel.Info.Status = release.StatusDeployed
+	upAction.cfg.Releases.Create(rel)
+
+	failer := upAction.cfg.KubeClient.(*kubefake.FailingKubeClient)
+	failer.UpdateError = fmt.Errorf("admission webhook denied the request")
+	upAction.DryRun = true
+
+	// A client-side dry run never contacts the API server.
+	_, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	req.NoError(err)
+
+	upAction.ServerSideApply = true
+	_, err = upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	req.Error(err)
+	is.Contains(err.Error(), "server-side dry run failed")
+	is.Contains(err.Error(), "admission webhook denied the request")
+
+	// Nothing was recorded for the dry run.
+	last, err := upAction.cfg.Releases.Last(rel.Name)
+	req.NoError(err)
+	is.Equal(rel.Version, last.Version)
+}
+
+func TestUpgradeRelease_ServerSideApply(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction := upgradeAction(t)
+	rel := releaseStub()
+	rel.Name = "applied"
+	rel.Info.Status = release.StatusDeployed
+	upAction.cfg.Releases.Create(rel)
+
+	failer := upAction.cfg.KubeClient.(*kubefake.FailingKubeClient)
+	failer.UpdateError = fmt.Errorf("conflict with \"kubectl\"")
+	upAction.ServerSideApply = true
+
+	res, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	req.Error(err)
+	is.Equal(release.StatusFailed, res.Info.Status)
+	is.Contains(res.Info.Description, "conflict with")
+}
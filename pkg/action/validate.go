@@ -17,12 +17,14 @@ limitations under the License.
 package action
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/pkg/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/cli-runtime/pkg/resource"
 
 	"helm.sh/helm/v3/pkg/kube"
@@ -66,6 +68,82 @@ func existingResourceConflict(resources kube.ResourceList, releaseName, releaseN
 	return requireUpdate, err
 }
 
+// adoptResources sets the ownership metadata of the release on the live
+// objects of the resources that lack it. Resources that do not exist are
+// returned as missing. Resources owned by another release are not adopted
+// unless force is set.
+func adoptResources(resources kube.ResourceList, releaseName, releaseNamespace string, force, dryRun bool) (adopted, missing kube.ResourceList, err error) {
+	patch, err := ownershipPatch(releaseName, releaseNamespace)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+
+		helper := resource.NewHelper(info.Client, info.Mapping)
+		existing, err := helper.Get(info.Namespace, info.Name)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				missing.Append(info)
+				return nil
+			}
+			return errors.Wrap(err, "could not get information about the resource")
+		}
+
+		err = checkOwnership(existing, releaseName, releaseNamespace)
+		if err == nil {
+			return nil
+		}
+		if !force && ownedByOtherRelease(existing, releaseName, releaseNamespace) {
+			return fmt.Errorf("%s cannot be adopted: %s", resourceString(info), err)
+		}
+		if !dryRun {
+			if _, err := helper.Patch(info.Namespace, info.Name, types.MergePatchType, patch, nil); err != nil {
+				return errors.Wrapf(err, "failed to set the ownership metadata of %s", resourceString(info))
+			}
+		}
+		adopted.Append(info)
+		return nil
+	})
+
+	return adopted, missing, err
+}
+
+// ownershipPatch returns the merge patch that sets the ownership metadata of
+// the release.
+func ownershipPatch(releaseName, releaseNamespace string) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]string{
+				appManagedByLabel: appManagedByHelm,
+			},
+			"annotations": map[string]string{
+				helmReleaseNameAnnotation:      releaseName,
+				helmReleaseNamespaceAnnotation: releaseNamespace,
+			},
+		},
+	})
+}
+
+// ownedByOtherRelease reports whether the ownership annotations of obj name
+// another release.
+func ownedByOtherRelease(obj runtime.Object, releaseName, releaseNamespace string) bool {
+	annos, err := accessor.Annotations(obj)
+	if err != nil {
+		return false
+	}
+	if name, ok := annos[helmReleaseNameAnnotation]; ok && name != releaseName {
+		return true
+	}
+	if ns, ok := annos[helmReleaseNamespaceAnnotation]; ok && ns != releaseNamespace {
+		return true
+	}
+	return false
+}
+
 func checkOwnership(obj runtime.Object, releaseName, releaseNamespace string) error {
 	lbls, err := accessor.Labels(obj)
 	if err != nil {
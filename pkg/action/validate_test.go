@@ -17,17 +17,27 @@ limitations under the License.
 package action
 
 import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path"
 	"testing"
 
 	"helm.sh/helm/v3/pkg/kube"
 
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 
+	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/stretchr/testify/assert"
 	"k8s.io/apimachinery/pkg/api/meta"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest/fake"
 )
 
 func newDeploymentResource(name, namespace string) *resource.Info {
@@ -121,3 +131,98 @@ func TestSetMetadataVisitor(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), `Deployment "baz" in namespace "" cannot be owned`)
 }
+
+// newLiveConfigMapResource returns a ConfigMap resource whose client serves
+// the live objects, and records the patches it receives.
+func newLiveConfigMapResource(name string, live map[string]*corev1.ConfigMap, patches map[string]string) *resource.Info {
+	codec := scheme.Codecs.LegacyCodec(corev1.SchemeGroupVersion)
+	respond := func(code int, obj runtime.Object) (*http.Response, error) {
+		header := http.Header{}
+		header.Set("Content-Type", runtime.ContentTypeJSON)
+		body := ioutil.NopCloser(bytes.NewReader([]byte(runtime.EncodeOrDie(codec, obj))))
+		return &http.Response{StatusCode: code, Header: header, Body: body}, nil
+	}
+	client := &fake.RESTClient{
+		GroupVersion:         corev1.SchemeGroupVersion,
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			name := path.Base(req.URL.Path)
+			obj, ok := live[name]
+			if !ok {
+				return respond(http.StatusNotFound, &v1.Status{Status: v1.StatusFailure, Reason: v1.StatusReasonNotFound, Code: http.StatusNotFound})
+			}
+			if req.Method == http.MethodPatch {
+				body, _ := ioutil.ReadAll(req.Body)
+				patches[name] = string(body)
+				current, _ := json.Marshal(obj)
+				patched, err := jsonpatch.MergePatch(current, body)
+				if err != nil {
+					return nil, err
+				}
+				obj = &corev1.ConfigMap{}
+				if err := json.Unmarshal(patched, obj); err != nil {
+					return nil, err
+				}
+				live[name] = obj
+			}
+			return respond(http.StatusOK, obj)
+		}),
+	}
+	return &resource.Info{
+		Name:      name,
+		Namespace: "ns-a",
+		Client:    client,
+		Mapping: &meta.RESTMapping{
+			Resource:         schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+			GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+			Scope:            meta.RESTScopeNamespace,
+		},
+		Object: &corev1.ConfigMap{ObjectMeta: v1.ObjectMeta{Name: name, Namespace: "ns-a"}},
+	}
+}
+
+func TestAdoptResources(t *testing.T) {
+	owned := func(release string) v1.ObjectMeta {
+		return v1.ObjectMeta{
+			Labels:      map[string]string{appManagedByLabel: appManagedByHelm},
+			Annotations: map[string]string{helmReleaseNameAnnotation: release, helmReleaseNamespaceAnnotation: "ns-a"},
+		}
+	}
+	live := map[string]*corev1.ConfigMap{
+		"owned":   {ObjectMeta: owned("rel-a")},
+		"unowned": {},
+		"other":   {ObjectMeta: owned("rel-b")},
+	}
+	patches := map[string]string{}
+	resources := func(names ...string) kube.ResourceList {
+		var list kube.ResourceList
+		for _, name := range names {
+			list.Append(newLiveConfigMapResource(name, live, patches))
+		}
+		return list
+	}
+
+	adopted, missing, err := adoptResources(resources("owned", "unowned", "missing"), "rel-a", "ns-a", false, false)
+	assert.NoError(t, err)
+	assert.Len(t, adopted, 1)
+	assert.Equal(t, "unowned", adopted[0].Name)
+	assert.Len(t, missing, 1)
+	assert.Equal(t, "missing", missing[0].Name)
+	assert.JSONEq(t, `{"metadata":{"labels":{"app.kubernetes.io/managed-by":"Helm"},"annotations":{"meta.helm.sh/release-name":"rel-a","meta.helm.sh/release-namespace":"ns-a"}}}`, patches["unowned"])
+	assert.NotContains(t, patches, "owned")
+
+	// Resources of another release are only adopted with force
+	_, _, err = adoptResources(resources("other"), "rel-a", "ns-a", false, false)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `ConfigMap "other" in namespace "ns-a" cannot be adopted`)
+	assert.NotContains(t, patches, "other")
+
+	adopted, _, err = adoptResources(resources("other"), "rel-a", "ns-a", true, true)
+	assert.NoError(t, err)
+	assert.Len(t, adopted, 1)
+	assert.NotContains(t, patches, "other", "a dry run must not patch")
+
+	_, _, err = adoptResources(resources("other"), "rel-a", "ns-a", true, false)
+	assert.NoError(t, err)
+	assert.Contains(t, patches, "other")
+}
@@ -0,0 +1,72 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"time"
+
+	"helm.sh/helm/v3/pkg/kube"
+)
+
+// waitForResources waits for the resources to be ready. When the KubeClient
+// implements kube.InterfaceWithContext the wait is aborted as soon as ctx is
+// done, otherwise ctx is only checked before waiting.
+func (c *Configuration) waitForResources(ctx context.Context, resources kube.ResourceList, timeout time.Duration, waitForJobs bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if kc, ok := c.KubeClient.(kube.InterfaceWithContext); ok {
+		ctx = c.progressContext(ctx)
+		if waitForJobs {
+			return kc.WaitWithJobsWithContext(ctx, resources, timeout)
+		}
+		return kc.WaitWithContext(ctx, resources, timeout)
+	}
+	if waitForJobs {
+		return c.KubeClient.WaitWithJobs(resources, timeout)
+	}
+	return c.KubeClient.Wait(resources, timeout)
+}
+
+// watchUntilReady watches the resources until they are ready. Like
+// waitForResources, it honors ctx when the KubeClient supports it.
+func (c *Configuration) watchUntilReady(ctx context.Context, resources kube.ResourceList, timeout time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if kc, ok := c.KubeClient.(kube.InterfaceWithContext); ok {
+		return kc.WatchUntilReadyWithContext(c.progressContext(ctx), resources, timeout)
+	}
+	return c.KubeClient.WatchUntilReady(resources, timeout)
+}
+
+// progressContext returns ctx carrying the Progress callback, unless ctx
+// already carries one, e.g. the one of a hook.
+func (c *Configuration) progressContext(ctx context.Context) context.Context {
+	if c.Progress == nil || kube.ProgressFromContext(ctx) != nil {
+		return ctx
+	}
+	return kube.WithProgress(ctx, c.Progress)
+}
+
+// reportProgress emits a progress event, if a Progress callback is set.
+func (c *Configuration) reportProgress(e kube.ProgressEvent) {
+	if c.Progress != nil {
+		c.Progress(e)
+	}
+}
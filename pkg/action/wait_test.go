@@ -0,0 +1,54 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"helm.sh/helm/v3/pkg/kube"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+)
+
+// progressKubeClient reports a progress event for every wait.
+type progressKubeClient struct {
+	*kubefake.PrintingKubeClient
+}
+
+func (c *progressKubeClient) WaitWithContext(ctx context.Context, _ kube.ResourceList, _ time.Duration) error {
+	if progress := kube.ProgressFromContext(ctx); progress != nil {
+		progress(kube.ProgressEvent{Kind: "Deployment", Name: "foo", Phase: kube.ProgressReady})
+	}
+	return nil
+}
+
+func TestWaitForResourcesProgress(t *testing.T) {
+	is := assert.New(t)
+	config := actionConfigFixture(t)
+	config.KubeClient = &progressKubeClient{&kubefake.PrintingKubeClient{}}
+
+	// without a Progress callback, no ProgressFunc is passed on
+	is.NoError(config.waitForResources(context.Background(), nil, time.Minute, false))
+
+	var events []kube.ProgressEvent
+	config.Progress = func(e kube.ProgressEvent) { events = append(events, e) }
+	is.NoError(config.waitForResources(context.Background(), nil, time.Minute, false))
+	is.Equal([]kube.ProgressEvent{{Kind: "Deployment", Name: "foo", Phase: kube.ProgressReady}}, events)
+}
@@ -18,9 +18,11 @@ package chartutil
 
 import (
 	"log"
+	"strings"
 
 	"github.com/mitchellh/copystructure"
 	"github.com/pkg/errors"
+	"github.com/santhosh-tekuri/jsonschema/v5"
 
 	"helm.sh/helm/v3/pkg/chart"
 )
@@ -34,7 +36,15 @@ import (
 //	- Scalar values and arrays are replaced, maps are merged
 //	- A chart has access to all of the variables for it, as well as all of
 //		the values destined for its dependencies.
+//	- Defaults declared in a chart's values.schema.json fill in whatever is
+//		still missing after the chart's values.yaml has been applied, except
+//		for the values that were set to null.
 func CoalesceValues(chrt *chart.Chart, vals map[string]interface{}) (Values, error) {
+	return coalesceWithOrigins(chrt, vals, nil)
+}
+
+// coalesceWithOrigins is CoalesceValues, recording the origin of the values in o.
+func coalesceWithOrigins(chrt *chart.Chart, vals map[string]interface{}, o *valueOrigins) (Values, error) {
 	v, err := copystructure.Copy(vals)
 	if err != nil {
 		return vals, err
@@ -45,19 +55,53 @@ func CoalesceValues(chrt *chart.Chart, vals map[string]interface{}) (Values, err
 	if valsCopy == nil {
 		valsCopy = make(map[string]interface{})
 	}
-	return coalesce(chrt, valsCopy)
+	o.setUser(valsCopy)
+	return coalesce(chrt, valsCopy, &coalescer{schemas: compileSchemas(chrt), origins: o}, nil)
+}
+
+// coalescer holds the state shared by the steps of a single CoalesceValues call.
+type coalescer struct {
+	schemas map[*chart.Chart]*jsonschema.Schema
+	origins *valueOrigins
 }
 
 // coalesce coalesces the dest values and the chart values, giving priority to the dest values.
+// nulled holds the keys of dest that were set to null by a parent chart or
+// the user, which are not given their schema defaults.
 //
 // This is a helper function for CoalesceValues.
-func coalesce(ch *chart.Chart, dest map[string]interface{}) (map[string]interface{}, error) {
-	coalesceValues(ch, dest)
-	return coalesceDeps(ch, dest)
+func coalesce(ch *chart.Chart, dest map[string]interface{}, co *coalescer, nulled map[string]interface{}) (map[string]interface{}, error) {
+	// The null values are removed by coalesceValues, so they are recorded
+	// first.
+	nulled = nulledKeys(dest, nulled)
+	coalesceValues(ch, dest, co.origins)
+	applySchemaDefaults(co.schemas[ch], dest, nulled, co.origins, schemaOrigin(ch))
+	return coalesceDeps(ch, dest, co, nulled)
+}
+
+// nulledKeys returns the keys of vals whose value is null, as a tree of
+// tables whose leaves are true, merged with the tree inherited.
+func nulledKeys(vals, inherited map[string]interface{}) map[string]interface{} {
+	nulled := make(map[string]interface{}, len(inherited))
+	for key, val := range inherited {
+		nulled[key] = val
+	}
+	for key, val := range vals {
+		switch val := val.(type) {
+		case nil:
+			nulled[key] = true
+		case map[string]interface{}:
+			sub, _ := nulled[key].(map[string]interface{})
+			if n := nulledKeys(val, sub); len(n) > 0 {
+				nulled[key] = n
+			}
+		}
+	}
+	return nulled
 }
 
 // coalesceDeps coalesces the dependencies of the given chart.
-func coalesceDeps(chrt *chart.Chart, dest map[string]interface{}) (map[string]interface{}, error) {
+func coalesceDeps(chrt *chart.Chart, dest map[string]interface{}, co *coalescer, nulled map[string]interface{}) (map[string]interface{}, error) {
 	for _, subchart := range chrt.Dependencies() {
 		if c, ok := dest[subchart.Name()]; !ok {
 			// If dest doesn't already have the key, create it.
@@ -69,11 +113,12 @@ func coalesceDeps(chrt *chart.Chart, dest map[string]interface{}) (map[string]in
 			dvmap := dv.(map[string]interface{})
 
 			// Get globals out of dest and merge them into dvmap.
-			coalesceGlobals(dvmap, dest)
+			coalesceGlobals(dvmap, dest, co.origins)
 
 			// Now coalesce the rest of the values.
 			var err error
-			dest[subchart.Name()], err = coalesce(subchart, dvmap)
+			subNulled, _ := nulled[subchart.Name()].(map[string]interface{})
+			dest[subchart.Name()], err = coalesce(subchart, dvmap, co, subNulled)
 			if err != nil {
 				return dest, err
 			}
@@ -85,11 +130,12 @@ func coalesceDeps(chrt *chart.Chart, dest map[string]interface{}) (map[string]in
 // coalesceGlobals copies the globals out of src and merges them into dest.
 //
 // For convenience, returns dest.
-func coalesceGlobals(dest, src map[string]interface{}) {
+func coalesceGlobals(dest, src map[string]interface{}, o *valueOrigins) {
 	var dg, sg map[string]interface{}
 
 	if destglob, ok := dest[GlobalKey]; !ok {
 		dg = make(map[string]interface{})
+		o.set(dest, GlobalKey, strings.TrimPrefix(o.get(src, GlobalKey, "")+propagatedGlobal, ", "))
 	} else if dg, ok = destglob.(map[string]interface{}); !ok {
 		log.Printf("warning: skipping globals because destination %s is not a table.", GlobalKey)
 		return
@@ -106,20 +152,28 @@ func coalesceGlobals(dest, src map[string]interface{}) {
 	// reverses that decision. It may somehow be possible to introduce a loop
 	// here, but I haven't found a way. So for the time being, let's allow
 	// tables in globals.
+	globalOrigin := o.get(src, GlobalKey, "")
 	for key, val := range sg {
+		origin := o.get(sg, key, globalOrigin)
+		if origin != "" && !strings.HasSuffix(origin, propagatedGlobal) {
+			origin += propagatedGlobal
+		}
 		if istable(val) {
 			vv := copyMap(val.(map[string]interface{}))
+			o.copy(val.(map[string]interface{}), vv)
 			if destv, ok := dg[key]; !ok {
 				// Here there is no merge. We're just adding.
 				dg[key] = vv
+				o.set(dg, key, origin)
 			} else {
 				if destvmap, ok := destv.(map[string]interface{}); !ok {
 					log.Printf("Conflict: cannot merge map onto non-map for %q. Skipping.", key)
 				} else {
 					// Basically, we reverse order of coalesce here to merge
 					// top-down.
-					CoalesceTables(vv, destvmap)
+					coalesceTables(vv, destvmap, o, o.get(dg, key, o.get(dest, GlobalKey, "")))
 					dg[key] = vv
+					o.set(dg, key, origin)
 					continue
 				}
 			}
@@ -130,6 +184,7 @@ func coalesceGlobals(dest, src map[string]interface{}) {
 		}
 		// TODO: Do we need to do any additional checking on the value?
 		dg[key] = val
+		o.set(dg, key, origin)
 	}
 	dest[GlobalKey] = dg
 }
@@ -145,7 +200,8 @@ func copyMap(src map[string]interface{}) map[string]interface{} {
 // coalesceValues builds up a values map for a particular chart.
 //
 // Values in v will override the values in the chart.
-func coalesceValues(c *chart.Chart, v map[string]interface{}) {
+func coalesceValues(c *chart.Chart, v map[string]interface{}, o *valueOrigins) {
+	chartOrigin := defaultOrigin(c)
 	for key, val := range c.Values {
 		if value, ok := v[key]; ok {
 			if value == nil {
@@ -166,11 +222,12 @@ func coalesceValues(c *chart.Chart, v map[string]interface{}) {
 				}
 				// Because v has higher precedence than nv, dest values override src
 				// values.
-				CoalesceTables(dest, src)
+				coalesceTables(dest, src, o, o.get(c.Values, key, chartOrigin))
 			}
 		} else {
 			// If the key is not in v, copy it from nv.
 			v[key] = val
+			o.set(v, key, o.get(c.Values, key, chartOrigin))
 		}
 	}
 }
@@ -179,6 +236,12 @@ func coalesceValues(c *chart.Chart, v map[string]interface{}) {
 //
 // dest is considered authoritative.
 func CoalesceTables(dst, src map[string]interface{}) map[string]interface{} {
+	return coalesceTables(dst, src, nil, "")
+}
+
+// coalesceTables is CoalesceTables, recording the origin of the values copied
+// from src in o. origin is the origin of src itself.
+func coalesceTables(dst, src map[string]interface{}, o *valueOrigins, origin string) map[string]interface{} {
 	// When --reuse-values is set but there are no modifications yet, return new values
 	if src == nil {
 		return dst
@@ -193,9 +256,10 @@ func CoalesceTables(dst, src map[string]interface{}) map[string]interface{} {
 			delete(dst, key)
 		} else if !ok {
 			dst[key] = val
+			o.set(dst, key, o.get(src, key, origin))
 		} else if istable(val) {
 			if istable(dv) {
-				CoalesceTables(dv.(map[string]interface{}), val.(map[string]interface{}))
+				coalesceTables(dv.(map[string]interface{}), val.(map[string]interface{}), o, o.get(src, key, origin))
 			} else {
 				log.Printf("warning: cannot overwrite table with non table for %s (%v)", key, val)
 			}
@@ -179,6 +179,86 @@ func TestCoalesceValues(t *testing.T) {
 	is.Equal(valsCopy, vals)
 }
 
+func TestCoalesceValuesSchemaDefaults(t *testing.T) {
+	is := assert.New(t)
+
+	sub := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "sub"},
+		Values:   map[string]interface{}{"replicas": 2},
+		Schema: []byte(`{
+  "$schema": "https://json-schema.org/draft/2019-09/schema",
+  "properties": {
+    "replicas": {"type": "integer", "default": 1},
+    "service": {"$ref": "#/$defs/service"}
+  },
+  "$defs": {
+    "service": {
+      "type": "object",
+      "properties": {
+        "port": {"type": "integer", "default": 80},
+        "type": {"type": "string", "default": "ClusterIP"}
+      }
+    }
+  }
+}`),
+	}
+	c := withDeps(&chart.Chart{
+		Metadata: &chart.Metadata{Name: "top"},
+		Values:   map[string]interface{}{"name": "from-values"},
+		Schema: []byte(`{
+  "properties": {
+    "name": {"type": "string", "default": "from-schema"},
+    "ratio": {"type": "number", "default": 0.5},
+    "tags": {"type": "array", "default": ["a"]},
+    "sub": {"properties": {"service": {"properties": {"port": {"default": 8080}}}}}
+  }
+}`),
+	}, sub)
+
+	v, err := CoalesceValues(c, map[string]interface{}{
+		"sub": map[string]interface{}{"service": map[string]interface{}{"type": "NodePort"}},
+	})
+	is.NoError(err)
+
+	is.Equal("from-values", v["name"], "values.yaml takes precedence over the schema")
+	is.Equal(0.5, v["ratio"])
+	is.Equal([]interface{}{"a"}, v["tags"])
+
+	subv := v["sub"].(map[string]interface{})
+	is.Equal(2, subv["replicas"], "subchart values.yaml takes precedence over its schema")
+	is.Equal(map[string]interface{}{
+		"port": float64(8080),
+		"type": "NodePort",
+	}, subv["service"], "parent schema defaults and user values take precedence over the subchart schema")
+	// Integral defaults have the type of the numbers of values files
+	fromFile, err := ReadValues([]byte("port: 8080"))
+	is.NoError(err)
+	is.IsType(fromFile["port"], subv["service"].(map[string]interface{})["port"])
+
+	// Values set to null are not given their schema defaults back.
+	v, err = CoalesceValues(c, map[string]interface{}{
+		"name":  nil,
+		"ratio": nil,
+		"sub": map[string]interface{}{
+			"replicas": nil,
+			"service":  map[string]interface{}{"port": nil},
+		},
+	})
+	is.NoError(err)
+	is.NotContains(v, "name")
+	is.Nil(v["ratio"])
+	is.Equal([]interface{}{"a"}, v["tags"])
+	subv = v["sub"].(map[string]interface{})
+	is.NotContains(subv, "replicas")
+	is.Equal(map[string]interface{}{"port": nil, "type": "ClusterIP"}, subv["service"])
+
+	// An invalid schema leaves the values alone.
+	c.Schema = []byte(`{"properties": {"name": {"type": 42}}}`)
+	v, err = CoalesceValues(c, map[string]interface{}{})
+	is.NoError(err)
+	is.NotContains(v, "ratio")
+}
+
 func TestCoalesceTables(t *testing.T) {
 	dst := map[string]interface{}{
 		"name": "Ishmael",
@@ -27,7 +27,7 @@ func ProcessDependencies(c *chart.Chart, v Values) error {
 	if err := processDependencyEnabled(c, v, ""); err != nil {
 		return err
 	}
-	return processDependencyImportValues(c)
+	return processDependencyImportValues(c, nil)
 }
 
 // processDependencyConditions disables charts based on condition path value in values
@@ -217,12 +217,14 @@ func set(path []string, data map[string]interface{}) map[string]interface{} {
 }
 
 // processImportValues merges values from child to parent based on the chart's dependencies' ImportValues field.
-func processImportValues(c *chart.Chart) error {
+//
+// The origin of the merged values is recorded in o.
+func processImportValues(c *chart.Chart, o *valueOrigins) error {
 	if c.Metadata.Dependencies == nil {
 		return nil
 	}
 	// combine chart values and empty config to get Values
-	cvals, err := CoalesceValues(c, nil)
+	cvals, err := coalesceWithOrigins(c, nil, o)
 	if err != nil {
 		return err
 	}
@@ -248,7 +250,7 @@ func processImportValues(c *chart.Chart) error {
 					continue
 				}
 				// create value map from child to be merged into parent
-				b = CoalesceTables(cvals, pathToMap(parent, vv.AsMap()))
+				b = coalesceTables(cvals, pathToMap(parent, o.fresh(vv.AsMap())), o, importOrigin(r, child))
 			case string:
 				child := "exports." + iv
 				outiv = append(outiv, map[string]string{
@@ -260,7 +262,7 @@ func processImportValues(c *chart.Chart) error {
 					log.Printf("Warning: ImportValues missing table: %v", err)
 					continue
 				}
-				b = CoalesceTables(b, vm.AsMap())
+				b = coalesceTables(b, o.fresh(vm.AsMap()), o, importOrigin(r, child))
 			}
 		}
 		// set our formatted import values
@@ -268,18 +270,18 @@ func processImportValues(c *chart.Chart) error {
 	}
 
 	// set the new values
-	c.Values = CoalesceTables(b, cvals)
+	c.Values = coalesceTables(b, cvals, o, "")
 
 	return nil
 }
 
 // processDependencyImportValues imports specified chart values from child to parent.
-func processDependencyImportValues(c *chart.Chart) error {
+func processDependencyImportValues(c *chart.Chart, o *valueOrigins) error {
 	for _, d := range c.Dependencies() {
 		// recurse
-		if err := processDependencyImportValues(d); err != nil {
+		if err := processDependencyImportValues(d, o); err != nil {
 			return err
 		}
 	}
-	return processImportValues(c)
+	return processImportValues(c, o)
 }
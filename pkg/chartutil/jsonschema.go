@@ -18,70 +18,400 @@ package chartutil
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
-	"github.com/xeipuuv/gojsonschema"
+	"github.com/santhosh-tekuri/jsonschema/v5"
 	"sigs.k8s.io/yaml"
 
 	"helm.sh/helm/v3/pkg/chart"
 )
 
-// ValidateAgainstSchema checks that values does not violate the structure laid out in schema
-func ValidateAgainstSchema(chrt *chart.Chart, values map[string]interface{}) error {
+// schemaURLScheme is the scheme of the URLs schemas are compiled under. The
+// root chart lives at chart:///, and each dependency under charts/NAME/ of
+// its parent, so a "$ref" can point at any JSON file in the chart or its
+// dependencies using a relative path.
+const schemaURLScheme = "chart"
+
+// SchemaError describes a single value that does not meet the schema of a chart.
+type SchemaError struct {
+	// Chart is the name of the chart whose schema was violated.
+	Chart string
+	// Path is the JSON pointer of the offending value, relative to the values
+	// passed in for the top-level chart. The empty string is the root.
+	Path string
+	// Message describes the violation.
+	Message string
+	// Source is the values file or flag that supplied the value. It is empty
+	// when the value came from the chart itself or is missing.
+	Source string
+}
+
+func (e SchemaError) Error() string {
+	p := e.Path
+	if p == "" {
+		p = "(root)"
+	}
+	if e.Source == "" {
+		return fmt.Sprintf("%s: %s", p, e.Message)
+	}
+	return fmt.Sprintf("%s: %s (from %s)", p, e.Message, e.Source)
+}
+
+// SchemaValidationError is returned when values do not meet the schema of a
+// chart or one of its dependencies.
+type SchemaValidationError struct {
+	Errors []SchemaError
+}
+
+func (e *SchemaValidationError) Error() string {
 	var sb strings.Builder
-	if chrt.Schema != nil {
-		err := ValidateAgainstSingleSchema(values, chrt.Schema)
-		if err != nil {
-			sb.WriteString(fmt.Sprintf("%s:\n", chrt.Name()))
-			sb.WriteString(err.Error())
+	for i, se := range e.Errors {
+		if se.Chart != "" && (i == 0 || e.Errors[i-1].Chart != se.Chart) {
+			fmt.Fprintf(&sb, "%s:\n", se.Chart)
 		}
+		fmt.Fprintf(&sb, "- %s\n", se.Error())
+	}
+	return sb.String()
+}
+
+// setSources fills in the source of each error from sources.
+func (e *SchemaValidationError) setSources(sources ValueSources) {
+	for i := range e.Errors {
+		e.Errors[i].Source = sources.Lookup(e.Errors[i].Path)
 	}
+}
+
+// ValueSources maps the JSON pointer of a user-supplied value to the values
+// file or flag it came from.
+type ValueSources map[string]string
 
-	// For each dependency, recursively call this function with the coalesced values
-	for _, subchart := range chrt.Dependencies() {
-		subchartValues := values[subchart.Name()].(map[string]interface{})
-		if err := ValidateAgainstSchema(subchart, subchartValues); err != nil {
-			sb.WriteString(err.Error())
+// Record marks every leaf of values as supplied by source, replacing the
+// source of any value recorded before.
+func (s ValueSources) Record(values map[string]interface{}, source string) {
+	s.record("", values, source)
+}
+
+func (s ValueSources) record(ptr string, v interface{}, source string) {
+	if m, ok := v.(map[string]interface{}); ok && len(m) > 0 {
+		for k, v := range m {
+			s.record(ptr+"/"+escapePointerToken(k), v, source)
 		}
+		return
 	}
+	for p := range s {
+		if strings.HasPrefix(p, ptr+"/") {
+			delete(s, p)
+		}
+	}
+	s[ptr] = source
+}
 
-	if sb.Len() > 0 {
-		return errors.New(sb.String())
+// Lookup returns the source of the value at ptr, or of the closest parent
+// that was supplied as a whole. It returns the empty string if the value was
+// not supplied by the user.
+func (s ValueSources) Lookup(ptr string) string {
+	for {
+		if src, ok := s[ptr]; ok {
+			return src
+		}
+		i := strings.LastIndex(ptr, "/")
+		if i < 0 {
+			return ""
+		}
+		ptr = ptr[:i]
 	}
+}
 
+func escapePointerToken(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// ValidateAgainstSchema checks that values does not violate the structure laid out in schema
+//
+// Schemas may use any JSON Schema draft from draft-04 to 2020-12; schemas that
+// do not declare one with "$schema" are treated as draft-07. References may
+// point to other JSON files in the chart and its dependencies. The returned
+// error, if any, is a *SchemaValidationError.
+func ValidateAgainstSchema(chrt *chart.Chart, values map[string]interface{}) error {
+	var errs []SchemaError
+	compiler := newSchemaCompiler(chrt)
+	err := walkSchemas(chrt, values, func(ch *chart.Chart, dir, ptr string, vals map[string]interface{}) error {
+		if ch.Schema == nil {
+			return nil
+		}
+		schema, err := compileSchema(compiler, dir)
+		if err != nil {
+			return errors.Wrapf(err, "%s", ch.Name())
+		}
+		chartErrs, err := validate(schema, vals, ptr)
+		if err != nil {
+			return err
+		}
+		for i := range chartErrs {
+			chartErrs[i].Chart = ch.Name()
+		}
+		errs = append(errs, chartErrs...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return &SchemaValidationError{Errors: errs}
+	}
 	return nil
 }
 
 // ValidateAgainstSingleSchema checks that values does not violate the structure laid out in this schema
+//
+// Since there is no chart, the schema cannot reference other files. The
+// returned error, if any, is a *SchemaValidationError.
 func ValidateAgainstSingleSchema(values Values, schemaJSON []byte) error {
-	valuesData, err := yaml.Marshal(values)
+	schema, err := compileSchema(newSchemaCompiler(&chart.Chart{Schema: schemaJSON}), "")
 	if err != nil {
 		return err
 	}
-	valuesJSON, err := yaml.YAMLToJSON(valuesData)
+	errs, err := validate(schema, values, "")
 	if err != nil {
 		return err
 	}
+	if len(errs) > 0 {
+		return &SchemaValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// walkSchemas calls fn for chrt and each of its dependencies, depth first,
+// with the directory of the chart relative to chrt, the JSON pointer of its
+// values and the values themselves.
+func walkSchemas(chrt *chart.Chart, values map[string]interface{}, fn func(ch *chart.Chart, dir, ptr string, vals map[string]interface{}) error) error {
+	var walk func(ch *chart.Chart, dir, ptr string, vals map[string]interface{}) error
+	walk = func(ch *chart.Chart, dir, ptr string, vals map[string]interface{}) error {
+		if err := fn(ch, dir, ptr, vals); err != nil {
+			return err
+		}
+		for _, subchart := range ch.Dependencies() {
+			subchartValues, _ := vals[subchart.Name()].(map[string]interface{})
+			if err := walk(subchart, dir+"charts/"+subchart.Name()+"/", ptr+"/"+escapePointerToken(subchart.Name()), subchartValues); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(chrt, "", "", values)
+}
+
+// newSchemaCompiler returns a compiler that loads schemas from chrt and its
+// dependencies, and nowhere else.
+func newSchemaCompiler(chrt *chart.Chart) *jsonschema.Compiler {
+	compiler := jsonschema.NewCompiler()
+	compiler.Draft = jsonschema.Draft7
+	compiler.ExtractAnnotations = true
+	compiler.LoadURL = func(s string) (io.ReadCloser, error) {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		if u.Scheme != schemaURLScheme {
+			return nil, errors.Errorf("cannot load %s: schemas may only reference files in the chart and its dependencies", s)
+		}
+		data, ok := schemaFile(chrt, strings.TrimPrefix(path.Clean(u.Path), "/"))
+		if !ok {
+			return nil, errors.Errorf("cannot load %s: no such file in the chart", s)
+		}
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	return compiler
+}
+
+// schemaFile returns the contents of the file at name, resolving charts/NAME/
+// prefixes to the dependencies of chrt.
+func schemaFile(chrt *chart.Chart, name string) ([]byte, bool) {
+	for strings.HasPrefix(name, "charts/") {
+		parts := strings.SplitN(strings.TrimPrefix(name, "charts/"), "/", 2)
+		if len(parts) < 2 {
+			break
+		}
+		var next *chart.Chart
+		for _, dep := range chrt.Dependencies() {
+			if dep.Name() == parts[0] {
+				next = dep
+				break
+			}
+		}
+		if next == nil {
+			break
+		}
+		chrt, name = next, parts[1]
+	}
+	if name == SchemafileName {
+		return chrt.Schema, chrt.Schema != nil
+	}
+	for _, f := range chrt.Files {
+		if f.Name == name {
+			return f.Data, true
+		}
+	}
+	return nil, false
+}
+
+// compileSchema compiles the values.schema.json of the chart at dir.
+func compileSchema(compiler *jsonschema.Compiler, dir string) (*jsonschema.Schema, error) {
+	schema, err := compiler.Compile(schemaURLScheme + ":///" + dir + SchemafileName)
+	return schema, errors.Wrap(err, "invalid values schema")
+}
+
+// validate checks values against schema, returning one SchemaError for each
+// violation. ptr is the JSON pointer of values, which prefixes the path of
+// every error.
+func validate(schema *jsonschema.Schema, values map[string]interface{}, ptr string) ([]SchemaError, error) {
+	valuesData, err := yaml.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	valuesJSON, err := yaml.YAMLToJSON(valuesData)
+	if err != nil {
+		return nil, err
+	}
 	if bytes.Equal(valuesJSON, []byte("null")) {
 		valuesJSON = []byte("{}")
 	}
-	schemaLoader := gojsonschema.NewBytesLoader(schemaJSON)
-	valuesLoader := gojsonschema.NewBytesLoader(valuesJSON)
+	decoder := json.NewDecoder(bytes.NewReader(valuesJSON))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
 
-	result, err := gojsonschema.Validate(schemaLoader, valuesLoader)
-	if err != nil {
-		return err
+	err = schema.Validate(doc)
+	if err == nil {
+		return nil, nil
+	}
+	verr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return nil, err
 	}
 
-	if !result.Valid() {
-		var sb strings.Builder
-		for _, desc := range result.Errors() {
-			sb.WriteString(fmt.Sprintf("- %s\n", desc))
+	var errs []SchemaError
+	seen := map[SchemaError]bool{}
+	var collect func(*jsonschema.ValidationError)
+	collect = func(ve *jsonschema.ValidationError) {
+		if len(ve.Causes) > 0 {
+			for _, cause := range ve.Causes {
+				collect(cause)
+			}
+			return
+		}
+		// The library percent-encodes each token of the instance location.
+		loc, err := url.PathUnescape(ve.InstanceLocation)
+		if err != nil {
+			loc = ve.InstanceLocation
+		}
+		se := SchemaError{Path: ptr + loc, Message: ve.Message}
+		if !seen[se] {
+			seen[se] = true
+			errs = append(errs, se)
 		}
-		return errors.New(sb.String())
 	}
+	collect(verr)
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Path < errs[j].Path })
+	return errs, nil
+}
 
+// compileSchemas compiles the schemas of chrt and its dependencies for
+// defaulting. Charts whose schema does not compile are left out; the error
+// is reported when the values are validated.
+func compileSchemas(chrt *chart.Chart) map[*chart.Chart]*jsonschema.Schema {
+	schemas := map[*chart.Chart]*jsonschema.Schema{}
+	compiler := newSchemaCompiler(chrt)
+	walkSchemas(chrt, nil, func(ch *chart.Chart, dir, _ string, _ map[string]interface{}) error {
+		if ch.Schema == nil {
+			return nil
+		}
+		if schema, err := compileSchema(compiler, dir); err == nil {
+			schemas[ch] = schema
+		}
+		return nil
+	})
+	return schemas
+}
+
+// applySchemaDefaults sets every property missing from dest that has a
+// default in schema, unless it is marked true in nulled, the tree of the keys
+// set to null. Objects missing from dest are created when any of their
+// properties has a default. The values set are recorded in o with origin.
+func applySchemaDefaults(schema *jsonschema.Schema, dest, nulled map[string]interface{}, o *valueOrigins, origin string) {
+	if schema == nil {
+		return
+	}
+	applySchemaDefaults(schema.Ref, dest, nulled, o, origin)
+	for _, s := range schema.AllOf {
+		applySchemaDefaults(s, dest, nulled, o, origin)
+	}
+	for name, prop := range schema.Properties {
+		if nulled[name] == true {
+			// A value set to null removes the default along with it.
+			continue
+		}
+		subNulled, _ := nulled[name].(map[string]interface{})
+		if value, ok := dest[name]; ok {
+			if m, ok := value.(map[string]interface{}); ok {
+				applySchemaDefaults(prop, m, subNulled, o, origin)
+			}
+			continue
+		}
+		if d := defaultOf(prop); d != nil {
+			dest[name] = fromJSON(d)
+			o.set(dest, name, origin)
+			continue
+		}
+		nested := map[string]interface{}{}
+		applySchemaDefaults(prop, nested, subNulled, nil, "")
+		if len(nested) > 0 {
+			dest[name] = nested
+			o.set(dest, name, origin)
+		}
+	}
+}
+
+// defaultOf returns the default of schema, following references.
+func defaultOf(schema *jsonschema.Schema) interface{} {
+	for s := schema; s != nil; s = s.Ref {
+		if s.Default != nil {
+			return s.Default
+		}
+	}
 	return nil
 }
+
+// fromJSON converts a value decoded from a schema to the types used for
+// values read from values files, where every number is a float64. The result
+// shares nothing with v.
+func fromJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, v := range v {
+			m[k] = fromJSON(v)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, v := range v {
+			l[i] = fromJSON(v)
+		}
+		return l
+	}
+	return v
+}
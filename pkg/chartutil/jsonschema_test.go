@@ -18,8 +18,12 @@ package chartutil
 
 import (
 	"io/ioutil"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/pkg/errors"
+
 	"helm.sh/helm/v3/pkg/chart"
 )
 
@@ -55,8 +59,8 @@ func TestValidateAgainstSingleSchemaNegative(t *testing.T) {
 		errString = err.Error()
 	}
 
-	expectedErrString := `- (root): employmentInfo is required
-- age: Must be greater than or equal to 0
+	expectedErrString := `- (root): missing properties: 'employmentInfo'
+- /age: must be >= 0 but found -5
 `
 	if errString != expectedErrString {
 		t.Errorf("Error string :\n`%s`\ndoes not match expected\n`%s`", errString, expectedErrString)
@@ -135,9 +139,113 @@ func TestValidateAgainstSchemaNegative(t *testing.T) {
 	}
 
 	expectedErrString := `subchart:
-- (root): age is required
+- /subchart: missing properties: 'age'
 `
 	if errString != expectedErrString {
 		t.Errorf("Error string :\n`%s`\ndoes not match expected\n`%s`", errString, expectedErrString)
 	}
 }
+
+func TestValidateAgainstSchemaReferences(t *testing.T) {
+	subchart := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "subchart"},
+		Schema: []byte(`{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {
+    "port": {"$ref": "../../schemas/common.json#/$defs/port"},
+    "tags": {"prefixItems": [{"type": "string"}], "items": false}
+  }
+}`),
+	}
+	chrt := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "chrt"},
+		Schema: []byte(`{
+  "type": "object",
+  "properties": {
+    "port": {"$ref": "schemas/common.json#/$defs/port"},
+    "subchart": {"$ref": "charts/subchart/values.schema.json"}
+  }
+}`),
+		Files: []*chart.File{{
+			Name: "schemas/common.json",
+			Data: []byte(`{"$defs": {"port": {"type": "integer", "minimum": 1, "maximum": 65535}}}`),
+		}},
+	}
+	chrt.AddDependency(subchart)
+
+	vals := map[string]interface{}{
+		"port": 8080,
+		"subchart": map[string]interface{}{
+			"port": 443,
+			"tags": []interface{}{"a"},
+		},
+	}
+	if err := ValidateAgainstSchema(chrt, vals); err != nil {
+		t.Fatalf("Error validating Values against Schema: %s", err)
+	}
+
+	vals["subchart"] = map[string]interface{}{
+		"port": 0,
+		"tags": []interface{}{"a", "b"},
+	}
+	err := ValidateAgainstSchema(chrt, vals)
+	var verr *SchemaValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a *SchemaValidationError, got %v", err)
+	}
+	var paths []string
+	for _, se := range verr.Errors {
+		paths = append(paths, se.Chart+" "+se.Path)
+	}
+	expected := []string{
+		"chrt /subchart/port",
+		"chrt /subchart/tags/1",
+		"subchart /subchart/port",
+		"subchart /subchart/tags/1",
+	}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("expected errors at %v, got %v", expected, paths)
+	}
+}
+
+func TestValidateAgainstSchemaExternalReference(t *testing.T) {
+	chrt := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "chrt"},
+		Schema:   []byte(`{"properties": {"port": {"$ref": "file:///etc/schema.json"}}}`),
+	}
+	err := ValidateAgainstSchema(chrt, map[string]interface{}{})
+	if err == nil || !strings.Contains(err.Error(), "schemas may only reference files in the chart") {
+		t.Errorf("expected the reference to be refused, got %v", err)
+	}
+}
+
+func TestValueSources(t *testing.T) {
+	sources := ValueSources{}
+	sources.Record(map[string]interface{}{
+		"image": map[string]interface{}{"repository": "nginx", "tag": "1.0"},
+		"a/b":   true,
+	}, "values.yaml")
+	sources.Record(map[string]interface{}{
+		"image": map[string]interface{}{"tag": "2.0"},
+	}, "--set")
+
+	for ptr, expected := range map[string]string{
+		"/image/repository": "values.yaml",
+		"/image/tag":        "--set",
+		"/a~1b":             "values.yaml",
+		"/a~1b/c":           "values.yaml",
+		"/image":            "",
+		"/missing":          "",
+	} {
+		if got := sources.Lookup(ptr); got != expected {
+			t.Errorf("expected the source of %q to be %q, got %q", ptr, expected, got)
+		}
+	}
+
+	// A value replacing a table takes over the source of everything below it.
+	sources.Record(map[string]interface{}{"image": "nginx:3.0"}, "override.yaml")
+	if got := sources.Lookup("/image/repository"); got != "override.yaml" {
+		t.Errorf("expected the source of /image/repository to be %q, got %q", "override.yaml", got)
+	}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/mitchellh/copystructure"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// propagatedGlobal is appended to the origin of a global copied into a subchart.
+const propagatedGlobal = ", propagated as a global"
+
+// userOrigin is the origin of user-supplied values whose source is unknown.
+const userOrigin = "user-supplied values"
+
+// ValueOrigins maps the JSON pointer of each value in a set of coalesced
+// values to a description of where it came from: the values file or flag
+// that supplied it, the chart or subchart default, a global propagated from a
+// parent chart, a value imported from a subchart, or a schema default.
+//
+// Tables are described by the origins of the values they hold; only their
+// leaves, including empty tables and lists, have an origin.
+type ValueOrigins map[string]string
+
+// ExplainValues computes the values of a chart like ProcessDependencies
+// followed by CoalesceValues do, and reports the origin of each of them.
+//
+// sources names the file or flag of each user-supplied value; values missing
+// from it are described as user-supplied. Like ProcessDependencies, this
+// modifies chrt.
+func ExplainValues(chrt *chart.Chart, vals map[string]interface{}, sources ValueSources) (Values, ValueOrigins, error) {
+	o := &valueOrigins{sources: sources, tables: map[uintptr]*tableOrigins{}}
+	if err := processDependencyEnabled(chrt, vals, ""); err != nil {
+		return nil, nil, err
+	}
+	if err := processDependencyImportValues(chrt, o); err != nil {
+		return nil, nil, err
+	}
+	v, err := coalesceWithOrigins(chrt, vals, o)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	origins := ValueOrigins{}
+	var walk func(m map[string]interface{}, ptr, inherited string)
+	walk = func(m map[string]interface{}, ptr, inherited string) {
+		for key, val := range m {
+			p := ptr + "/" + escapePointerToken(key)
+			origin := o.get(m, key, inherited)
+			if t, ok := val.(map[string]interface{}); ok && len(t) > 0 {
+				walk(t, p, origin)
+				continue
+			}
+			origins[p] = origin
+		}
+	}
+	walk(v, "", "")
+	return v, origins, nil
+}
+
+// valueOrigins records the origin of values while they are coalesced.
+//
+// Origins are attached to the table holding a value, so they follow the
+// value wherever the table is shared. A value without an origin of its own
+// has the origin of the table holding it. A nil *valueOrigins records nothing.
+type valueOrigins struct {
+	sources ValueSources
+	tables  map[uintptr]*tableOrigins
+}
+
+// tableOrigins holds the origins of the values of a table. It keeps a
+// reference to the table so that its address is not reused while origins
+// are recorded.
+type tableOrigins struct {
+	table   map[string]interface{}
+	origins map[string]string
+}
+
+func tableID(m map[string]interface{}) uintptr {
+	return reflect.ValueOf(m).Pointer()
+}
+
+// set records the origin of m[key].
+func (o *valueOrigins) set(m map[string]interface{}, key, origin string) {
+	if o == nil || origin == "" {
+		return
+	}
+	id := tableID(m)
+	if o.tables[id] == nil {
+		o.tables[id] = &tableOrigins{table: m, origins: map[string]string{}}
+	}
+	o.tables[id].origins[key] = origin
+}
+
+// get returns the origin of m[key], or inherited if it has none.
+func (o *valueOrigins) get(m map[string]interface{}, key, inherited string) string {
+	if o == nil {
+		return inherited
+	}
+	if t, ok := o.tables[tableID(m)]; ok {
+		if origin, ok := t.origins[key]; ok {
+			return origin
+		}
+	}
+	return inherited
+}
+
+// copy gives the values of to the origins of the same keys in from.
+func (o *valueOrigins) copy(from, to map[string]interface{}) {
+	if o == nil {
+		return
+	}
+	if t, ok := o.tables[tableID(from)]; ok {
+		for key, origin := range t.origins {
+			o.set(to, key, origin)
+		}
+	}
+}
+
+// fresh returns a deep copy of m without origins, so that the origin it is
+// given when merged applies to all of it. It returns m itself when nothing is
+// recorded.
+func (o *valueOrigins) fresh(m map[string]interface{}) map[string]interface{} {
+	if o == nil {
+		return m
+	}
+	c, err := copystructure.Copy(m)
+	if err != nil {
+		return m
+	}
+	return c.(map[string]interface{})
+}
+
+// setUser records the origin of user-supplied values.
+func (o *valueOrigins) setUser(vals map[string]interface{}) {
+	if o == nil {
+		return
+	}
+	var walk func(m map[string]interface{}, ptr string)
+	walk = func(m map[string]interface{}, ptr string) {
+		for key, val := range m {
+			p := ptr + "/" + escapePointerToken(key)
+			origin := o.sources.Lookup(p)
+			if origin == "" {
+				origin = userOrigin
+			}
+			o.set(m, key, origin)
+			if t, ok := val.(map[string]interface{}); ok {
+				walk(t, p)
+			}
+		}
+	}
+	walk(vals, "")
+}
+
+// defaultOrigin is the origin of the values in the values.yaml of c.
+func defaultOrigin(c *chart.Chart) string {
+	if c.IsRoot() {
+		return fmt.Sprintf("chart default (%s)", c.Name())
+	}
+	return fmt.Sprintf("subchart default (%s)", c.Name())
+}
+
+// schemaOrigin is the origin of the defaults in the values schema of c.
+func schemaOrigin(c *chart.Chart) string {
+	return fmt.Sprintf("schema default (%s)", c.Name())
+}
+
+// importOrigin is the origin of the values imported from the child table of dependency r.
+func importOrigin(r *chart.Dependency, child string) string {
+	return fmt.Sprintf("imported from subchart %s (%s)", r.Name, child)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"reflect"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+func TestExplainValues(t *testing.T) {
+	sub := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "sub"},
+		Values: map[string]interface{}{
+			"port":   80,
+			"image":  map[string]interface{}{"tag": "1.0"},
+			"global": map[string]interface{}{"region": "sub-region"},
+			"exports": map[string]interface{}{
+				"data": map[string]interface{}{"exported": "yes"},
+			},
+		},
+		Schema: []byte(`{"properties": {"replicas": {"default": 2}}}`),
+	}
+	parent := &chart.Chart{
+		Metadata: &chart.Metadata{
+			Name: "parent",
+			Dependencies: []*chart.Dependency{
+				{Name: "sub", ImportValues: []interface{}{"data"}},
+			},
+		},
+		Values: map[string]interface{}{
+			"name":   "parent",
+			"global": map[string]interface{}{"env": "prod"},
+			"sub":    map[string]interface{}{"port": 8080},
+		},
+	}
+	parent.AddDependency(sub)
+
+	vals := map[string]interface{}{
+		"name":  "override",
+		"sub":   map[string]interface{}{"image": map[string]interface{}{"tag": "2.0"}},
+		"extra": map[string]interface{}{"enabled": true},
+	}
+	sources := ValueSources{"/name": "values-prod.yaml", "/sub/image/tag": "--set"}
+
+	v, origins, err := ExplainValues(parent, vals, sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v["name"] != "override" {
+		t.Errorf("unexpected values %v", v)
+	}
+
+	expected := ValueOrigins{
+		"/name":                      "values-prod.yaml",
+		"/extra/enabled":             userOrigin,
+		"/global/env":                "chart default (parent)",
+		"/exported":                  "imported from subchart sub (exports.data)",
+		"/sub/port":                  "chart default (parent)",
+		"/sub/image/tag":             "--set",
+		"/sub/replicas":              "schema default (sub)",
+		"/sub/global/env":            "chart default (parent), propagated as a global",
+		"/sub/global/region":         "subchart default (sub)",
+		"/sub/exports/data/exported": "subchart default (sub)",
+	}
+	if !reflect.DeepEqual(origins, expected) {
+		for k, v := range origins {
+			if expected[k] != v {
+				t.Errorf("%s: expected %q, got %q", k, expected[k], v)
+			}
+		}
+		for k := range expected {
+			if _, ok := origins[k]; !ok {
+				t.Errorf("%s: missing", k)
+			}
+		}
+	}
+}
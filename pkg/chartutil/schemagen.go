@@ -0,0 +1,192 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/pkg/errors"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// GenerateSchema infers a JSON schema from data, the contents of a
+// values.yaml file.
+//
+// Types are taken from the values, and descriptions, enums, types and
+// required markers from the comments documenting them (see ValueDoc). If
+// existing holds a schema, the result is merged with it; keywords in the
+// existing schema win over inferred ones, so hand-written constraints are
+// kept.
+func GenerateSchema(data, existing []byte) ([]byte, error) {
+	root, err := parseValuesNode(data)
+	if err != nil {
+		return nil, err
+	}
+	schema := map[string]interface{}{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"type":    "object",
+	}
+	if root != nil {
+		s, err := schemaForNode(root, nil)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range s {
+			schema[k] = v
+		}
+	}
+
+	if len(existing) > 0 {
+		var current map[string]interface{}
+		if err := json.Unmarshal(existing, &current); err != nil {
+			return nil, errors.Wrap(err, "cannot parse the existing schema")
+		}
+		schema = mergeSchemas(schema, current)
+	}
+
+	out, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// schemaForNode infers the schema of a YAML node at path.
+func schemaForNode(n *yamlv3.Node, path []string) (map[string]interface{}, error) {
+	n = resolveAlias(n)
+	schema := map[string]interface{}{}
+	if t := nodeType(n); t != "" {
+		schema["type"] = t
+	}
+
+	switch n.Kind {
+	case yamlv3.MappingNode:
+		props := map[string]interface{}{}
+		var required []string
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			p := append(append([]string{}, path...), key.Value)
+			comment, err := parseValueComment(key, value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid comment for %s", dottedKey(p))
+			}
+			prop, err := schemaForNode(value, p)
+			if err != nil {
+				return nil, err
+			}
+			if comment.description != "" {
+				prop["description"] = comment.description
+			}
+			if comment.enum != nil {
+				prop["enum"] = comment.enum
+			}
+			if comment.types != nil {
+				prop["type"] = comment.types
+			}
+			if comment.required {
+				required = append(required, key.Value)
+			}
+			props[key.Value] = prop
+		}
+		if len(props) > 0 {
+			schema["properties"] = props
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+	case yamlv3.SequenceNode:
+		if len(n.Content) == 0 {
+			break
+		}
+		// Items get a schema only if they all have the same type; tables are
+		// described by the first one.
+		t := nodeType(n.Content[0])
+		for _, item := range n.Content[1:] {
+			if nodeType(item) != t {
+				return schema, nil
+			}
+		}
+		items, err := schemaForNode(n.Content[0], path)
+		if err != nil {
+			return nil, err
+		}
+		schema["items"] = items
+	}
+	return schema, nil
+}
+
+// mergeSchemas merges existing into generated. Keywords of existing replace
+// those of generated, except that properties are merged one by one and the
+// required lists are combined.
+func mergeSchemas(generated, existing map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(generated))
+	for k, v := range generated {
+		out[k] = v
+	}
+	for k, v := range existing {
+		switch k {
+		case "properties", "items":
+			g, gok := out[k].(map[string]interface{})
+			e, eok := v.(map[string]interface{})
+			if gok && eok {
+				if k == "items" {
+					out[k] = mergeSchemas(g, e)
+					continue
+				}
+				props := make(map[string]interface{}, len(g))
+				for name, p := range g {
+					props[name] = p
+				}
+				for name, p := range e {
+					gp, gok := props[name].(map[string]interface{})
+					ep, eok := p.(map[string]interface{})
+					if gok && eok {
+						props[name] = mergeSchemas(gp, ep)
+					} else {
+						props[name] = p
+					}
+				}
+				out[k] = props
+				continue
+			}
+		case "required":
+			if e, ok := v.([]interface{}); ok {
+				seen := map[string]bool{}
+				var required []string
+				for _, r := range e {
+					if s, ok := r.(string); ok && !seen[s] {
+						seen[s] = true
+						required = append(required, s)
+					}
+				}
+				g, _ := out[k].([]string)
+				for _, s := range g {
+					if !seen[s] {
+						seen[s] = true
+						required = append(required, s)
+					}
+				}
+				sort.Strings(required)
+				out[k] = required
+				continue
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,83 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGenerateSchema(t *testing.T) {
+	existing := `{
+  "properties": {
+    "replicas": {"minimum": 1},
+    "image": {"properties": {"tag": {"type": "string", "pattern": "^v"}}, "required": ["tag"]},
+    "extra": {"type": "boolean"}
+  }
+}`
+	out, err := GenerateSchema([]byte(documentedValues+"ports:\n  - 80\n  - 443\nmixed: [1, a]\n"), []byte(existing))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal(out, &schema); err != nil {
+		t.Fatalf("invalid schema %s: %s", out, err)
+	}
+
+	var expected map[string]interface{}
+	if err := json.Unmarshal([]byte(`{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "replicas": {"type": "integer", "description": "Number of replicas.", "minimum": 1},
+    "image": {
+      "type": "object",
+      "properties": {
+        "repository": {"type": "string", "description": "Image repository."},
+        "tag": {"type": "string", "description": "Image tag.", "pattern": "^v"}
+      },
+      "required": ["repository", "tag"]
+    },
+    "serviceType": {"type": "string", "description": "Type of the service.", "enum": ["ClusterIP", "NodePort"]},
+    "dotted.key": {"type": ["string", "null"]},
+    "labels": {"type": "object"},
+    "ports": {"type": "array", "items": {"type": "integer"}},
+    "mixed": {"type": "array"},
+    "extra": {"type": "boolean"}
+  }
+}`), &expected); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(schema, expected) {
+		t.Errorf("unexpected schema:\n%s", out)
+	}
+}
+
+func TestGenerateSchemaValidatesValues(t *testing.T) {
+	schema, err := GenerateSchema([]byte(documentedValues), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := ReadValues([]byte(documentedValues))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateAgainstSingleSchema(values, schema); err != nil {
+		t.Errorf("expected the values to meet the generated schema: %s", err)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import "strings"
+
+// RedactedValue is shown in place of a sensitive value.
+const RedactedValue = "<redacted>"
+
+// RedactValues returns vals with each value at one of the JSON pointers in
+// sensitive replaced by RedactedValue. The tables on the way to a redacted
+// value are copied, so vals itself is not modified.
+func RedactValues(vals map[string]interface{}, sensitive []string) map[string]interface{} {
+	for _, ptr := range sensitive {
+		vals = redact(vals, pointerTokens(ptr))
+	}
+	return vals
+}
+
+func redact(table map[string]interface{}, tokens []string) map[string]interface{} {
+	if len(tokens) == 0 {
+		return table
+	}
+	v, ok := table[tokens[0]]
+	if !ok {
+		return table
+	}
+	if len(tokens) > 1 {
+		sub, ok := v.(map[string]interface{})
+		if !ok {
+			return table
+		}
+		v = redact(sub, tokens[1:])
+	} else {
+		v = RedactedValue
+	}
+
+	out := make(map[string]interface{}, len(table))
+	for k, tv := range table {
+		out[k] = tv
+	}
+	out[tokens[0]] = v
+	return out
+}
+
+// HasValue reports whether vals has a value at the JSON pointer ptr.
+func HasValue(vals map[string]interface{}, ptr string) bool {
+	var v interface{} = vals
+	for _, token := range pointerTokens(ptr) {
+		table, ok := v.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if v, ok = table[token]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// pointerTokens splits a JSON pointer into its unescaped reference tokens.
+func pointerTokens(ptr string) []string {
+	if ptr == "" {
+		return nil
+	}
+	tokens := strings.Split(strings.TrimPrefix(ptr, "/"), "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens
+}
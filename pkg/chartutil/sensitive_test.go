@@ -0,0 +1,67 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRedactValues(t *testing.T) {
+	vals := map[string]interface{}{
+		"db": map[string]interface{}{
+			"password": "s3cr3t",
+			"host":     "db.example.com",
+		},
+		"a/b":  "slash",
+		"name": "web",
+	}
+	redacted := RedactValues(vals, []string{"/db/password", "/a~1b", "/missing/key", "/name/nested"})
+
+	expected := map[string]interface{}{
+		"db": map[string]interface{}{
+			"password": RedactedValue,
+			"host":     "db.example.com",
+		},
+		"a/b":  RedactedValue,
+		"name": "web",
+	}
+	if !reflect.DeepEqual(redacted, expected) {
+		t.Errorf("Expected %v, got %v", expected, redacted)
+	}
+	if vals["db"].(map[string]interface{})["password"] != "s3cr3t" || vals["a/b"] != "slash" {
+		t.Errorf("Expected the original values to be unchanged, got %v", vals)
+	}
+}
+
+func TestHasValue(t *testing.T) {
+	vals := map[string]interface{}{
+		"db":  map[string]interface{}{"password": nil},
+		"a/b": "slash",
+	}
+	for ptr, expect := range map[string]bool{
+		"/db/password": true,
+		"/db":          true,
+		"/a~1b":        true,
+		"/db/host":     false,
+		"/a~1b/c":      false,
+	} {
+		if HasValue(vals, ptr) != expect {
+			t.Errorf("HasValue(%q): expected %t", ptr, expect)
+		}
+	}
+}
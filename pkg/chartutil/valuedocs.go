@@ -0,0 +1,295 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	yamlv3 "gopkg.in/yaml.v3"
+	"sigs.k8s.io/yaml"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// ValueDoc documents a single value of a chart.
+//
+// Values are documented with comments in values.yaml. The comment lines above
+// a key, or the comment at the end of its line, describe it. Lines starting
+// with an annotation give more information about the value:
+//
+//	# @enum: [ClusterIP, NodePort, LoadBalancer]
+//	# @type: [string, "null"]
+//	# @required
+//
+// Comment lines that look like values commented out, such as "# key: value"
+// or "# - item", are left out of the description.
+type ValueDoc struct {
+	// Key is the key of the value in the dotted form used by --set.
+	Key string
+	// Path holds the keys leading to the value.
+	Path []string
+	// Type is the JSON Schema type of the value. It is empty for null values
+	// without a @type annotation.
+	Type string
+	// Default is the value in values.yaml.
+	Default interface{}
+	// Description is the text of the comment documenting the value.
+	Description string
+	// Enum lists the allowed values, if any.
+	Enum []interface{}
+	// Required is set if the value was marked with @required.
+	Required bool
+}
+
+// commentedValue matches comment lines holding values commented out.
+var commentedValue = regexp.MustCompile(`^(- |[^\s:]+:(\s|$))`)
+
+// valueComment holds what a comment says about a value.
+type valueComment struct {
+	description string
+	enum        []interface{}
+	types       interface{}
+	required    bool
+}
+
+// DocumentValues returns the documentation of every value of a chart, in the
+// order they appear in its values.yaml. Tables are documented as a whole only
+// when they are empty or commented; otherwise each of their values is. Values
+// without a comment take their description from the chart's schema.
+func DocumentValues(chrt *chart.Chart) ([]ValueDoc, error) {
+	var data []byte
+	for _, f := range chrt.Raw {
+		if f.Name == ValuesfileName {
+			data = f.Data
+			break
+		}
+	}
+	docs, err := ParseValueDocs(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(chrt.Schema) == 0 {
+		return docs, nil
+	}
+
+	var schema map[string]interface{}
+	if err := yaml.Unmarshal(chrt.Schema, &schema); err != nil {
+		return nil, errors.Wrap(err, "cannot parse the values schema")
+	}
+	for i := range docs {
+		s := schemaProperty(schema, docs[i].Path)
+		if s == nil {
+			continue
+		}
+		if docs[i].Description == "" {
+			docs[i].Description, _ = s["description"].(string)
+		}
+		if docs[i].Enum == nil {
+			docs[i].Enum, _ = s["enum"].([]interface{})
+		}
+		if t, ok := s["type"].(string); ok && docs[i].Type == "" {
+			docs[i].Type = t
+		}
+	}
+	return docs, nil
+}
+
+// schemaProperty returns the schema of the property at path, following
+// "properties" only.
+func schemaProperty(schema map[string]interface{}, path []string) map[string]interface{} {
+	for _, key := range path {
+		props, ok := schema["properties"].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if schema, ok = props[key].(map[string]interface{}); !ok {
+			return nil
+		}
+	}
+	return schema
+}
+
+// ParseValueDocs documents the values in data, the contents of a values.yaml
+// file. Defaults are read with ReadValues, so they have the types Helm uses
+// when rendering.
+func ParseValueDocs(data []byte) ([]ValueDoc, error) {
+	vals, err := ReadValues(data)
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseValuesNode(data)
+	if err != nil || root == nil {
+		return nil, err
+	}
+
+	var docs []ValueDoc
+	var walk func(n *yamlv3.Node, path []string) error
+	walk = func(n *yamlv3.Node, path []string) error {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], resolveAlias(n.Content[i+1])
+			p := append(append([]string{}, path...), key.Value)
+			comment, err := parseValueComment(key, n.Content[i+1])
+			if err != nil {
+				return errors.Wrapf(err, "invalid comment for %s", dottedKey(p))
+			}
+			if value.Kind == yamlv3.MappingNode && len(value.Content) > 0 {
+				if comment.description != "" || comment.enum != nil || comment.types != nil || comment.required {
+					docs = append(docs, newValueDoc(p, value, vals, comment))
+				}
+				if err := walk(value, p); err != nil {
+					return err
+				}
+				continue
+			}
+			docs = append(docs, newValueDoc(p, value, vals, comment))
+		}
+		return nil
+	}
+	if err := walk(root, nil); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+func newValueDoc(path []string, n *yamlv3.Node, vals Values, comment valueComment) ValueDoc {
+	doc := ValueDoc{
+		Key:         dottedKey(path),
+		Path:        path,
+		Type:        nodeType(n),
+		Description: comment.description,
+		Enum:        comment.enum,
+		Required:    comment.required,
+	}
+	var v interface{} = map[string]interface{}(vals)
+	for _, key := range path {
+		m, _ := v.(map[string]interface{})
+		v = m[key]
+	}
+	doc.Default = v
+	switch t := comment.types.(type) {
+	case string:
+		doc.Type = t
+	case []interface{}:
+		var types []string
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		doc.Type = strings.Join(types, ", ")
+	}
+	return doc
+}
+
+// parseValuesNode parses data into the mapping node holding the values. It
+// returns nil for an empty document.
+func parseValuesNode(data []byte) (*yamlv3.Node, error) {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &doc); err != nil {
+		return nil, errors.Wrap(err, "cannot parse values")
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := resolveAlias(doc.Content[0])
+	if root.Kind != yamlv3.MappingNode {
+		return nil, errors.New("cannot parse values: not a table")
+	}
+	return root, nil
+}
+
+func resolveAlias(n *yamlv3.Node) *yamlv3.Node {
+	for n.Kind == yamlv3.AliasNode {
+		n = n.Alias
+	}
+	return n
+}
+
+// nodeType returns the JSON Schema type of a YAML node.
+func nodeType(n *yamlv3.Node) string {
+	n = resolveAlias(n)
+	switch n.Kind {
+	case yamlv3.MappingNode:
+		return "object"
+	case yamlv3.SequenceNode:
+		return "array"
+	}
+	switch n.ShortTag() {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	case "!!null":
+		return ""
+	}
+	return "string"
+}
+
+// parseValueComment reads the comment documenting the value of key.
+func parseValueComment(key, value *yamlv3.Node) (valueComment, error) {
+	var c valueComment
+	text := key.HeadComment
+	if text == "" {
+		text = key.LineComment
+	}
+	if text == "" {
+		text = value.LineComment
+	}
+
+	var description []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+		line = strings.TrimSpace(strings.TrimPrefix(line, "--"))
+		if !strings.HasPrefix(line, "@") {
+			if line != "" && !commentedValue.MatchString(line) {
+				description = append(description, line)
+			}
+			continue
+		}
+		name, arg := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			name, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+		switch name {
+		case "@required":
+			c.required = true
+		case "@enum":
+			if err := yaml.Unmarshal([]byte(arg), &c.enum); err != nil {
+				return c, errors.Wrap(err, "invalid @enum")
+			}
+		case "@type":
+			if err := yaml.Unmarshal([]byte(arg), &c.types); err != nil {
+				return c, errors.Wrap(err, "invalid @type")
+			}
+		}
+	}
+	c.description = strings.Join(description, " ")
+	return c, nil
+}
+
+// dottedKey joins path in the form used by --set, escaping dots in keys.
+func dottedKey(path []string) string {
+	keys := make([]string, len(path))
+	for i, k := range path {
+		keys[i] = strings.ReplaceAll(k, ".", `\.`)
+	}
+	return strings.Join(keys, ".")
+}
@@ -0,0 +1,95 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+const documentedValues = `# Number of replicas.
+replicas: 1
+
+image:
+  # Image repository.
+  # @required
+  repository: nginx
+  tag: "1.19" # Image tag.
+  # pullPolicy: Always
+
+# Type of the service.
+# @enum: [ClusterIP, NodePort]
+serviceType: ClusterIP
+
+# @type: [string, "null"]
+dotted.key: ~
+
+labels: {}
+`
+
+func TestParseValueDocs(t *testing.T) {
+	docs, err := ParseValueDocs([]byte(documentedValues))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []ValueDoc{
+		{Key: "replicas", Path: []string{"replicas"}, Type: "integer", Default: float64(1), Description: "Number of replicas."},
+		{Key: "image.repository", Path: []string{"image", "repository"}, Type: "string", Default: "nginx", Description: "Image repository.", Required: true},
+		{Key: "image.tag", Path: []string{"image", "tag"}, Type: "string", Default: "1.19", Description: "Image tag."},
+		{Key: "serviceType", Path: []string{"serviceType"}, Type: "string", Default: "ClusterIP", Description: "Type of the service.", Enum: []interface{}{"ClusterIP", "NodePort"}},
+		{Key: `dotted\.key`, Path: []string{"dotted.key"}, Type: "string, null"},
+		{Key: "labels", Path: []string{"labels"}, Type: "object", Default: map[string]interface{}{}},
+	}
+	if !reflect.DeepEqual(docs, expected) {
+		t.Errorf("expected\n%#v\ngot\n%#v", expected, docs)
+	}
+}
+
+func TestParseValueDocsInvalidAnnotation(t *testing.T) {
+	_, err := ParseValueDocs([]byte("image:\n  # @enum: [a\n  tag: a\n"))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if expected := "invalid comment for image.tag: invalid @enum"; !strings.HasPrefix(err.Error(), expected) {
+		t.Errorf("expected an error starting with %q, got %q", expected, err)
+	}
+}
+
+func TestDocumentValues(t *testing.T) {
+	chrt := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "chrt"},
+		Raw:      []*chart.File{{Name: ValuesfileName, Data: []byte("port: 80\nname: web # The name.\n")}},
+		Schema:   []byte(`{"properties": {"port": {"description": "The port.", "enum": [80, 443]}, "name": {"description": "Ignored."}}}`),
+	}
+	docs, err := DocumentValues(chrt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 values, got %d", len(docs))
+	}
+	if docs[0].Description != "The port." || !reflect.DeepEqual(docs[0].Enum, []interface{}{float64(80), float64(443)}) {
+		t.Errorf("expected the schema to document port, got %#v", docs[0])
+	}
+	if docs[1].Description != "The name." {
+		t.Errorf("expected the comment to take precedence over the schema, got %q", docs[1].Description)
+	}
+}
@@ -129,6 +129,9 @@ type ReleaseOptions struct {
 	Revision  int
 	IsUpgrade bool
 	IsInstall bool
+	// ValueSources, if set, names the values file or flag of each
+	// user-supplied value in schema validation errors.
+	ValueSources ValueSources
 }
 
 // ToRenderValues composes the struct from the data coming from the Releases, Charts and Values files
@@ -157,8 +160,11 @@ func ToRenderValues(chrt *chart.Chart, chrtVals map[string]interface{}, options
 	}
 
 	if err := ValidateAgainstSchema(chrt, vals); err != nil {
-		errFmt := "values don't meet the specifications of the schema(s) in the following chart(s):\n%s"
-		return top, fmt.Errorf(errFmt, err.Error())
+		if verr, ok := err.(*SchemaValidationError); ok {
+			verr.setSources(options.ValueSources)
+		}
+		errFmt := "values don't meet the specifications of the schema(s) in the following chart(s):\n%w"
+		return top, fmt.Errorf(errFmt, err)
 	}
 
 	top["Values"] = vals
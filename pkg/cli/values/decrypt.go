@@ -0,0 +1,300 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package values
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	agearmor "filippo.io/age/armor"
+	"github.com/pkg/errors"
+	sopsaes "go.mozilla.org/sops/v3/aes"
+	"go.mozilla.org/sops/v3/keyservice"
+	sopsyaml "go.mozilla.org/sops/v3/stores/yaml"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"google.golang.org/grpc"
+	yamlv3 "gopkg.in/yaml.v3"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/provenance"
+)
+
+// sensitive marks the values read from an encrypted values file in the
+// sensitivity recorded by MergeValuesWithSources.
+const sensitive = "sensitive"
+
+const pgpArmorHeader = "-----BEGIN PGP MESSAGE-----"
+
+// ageMagic starts the header of an age file.
+const ageMagic = "age-encryption.org/v1\n"
+
+// sensitivePaths returns the JSON pointers of the values marked as sensitive
+// in sensitivity, in order.
+func sensitivePaths(sensitivity chartutil.ValueSources) []string {
+	var paths []string
+	for ptr, mark := range sensitivity {
+		if mark == sensitive {
+			paths = append(paths, ptr)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// decrypt returns the plain text of the values file read from filePath, and
+// whether it was encrypted.
+//
+// OpenPGP messages, age files, and SOPS files whose data key is encrypted
+// with PGP or age are decrypted with the secret keys in opts.Keyring and the
+// age identities. When opts.DecryptCommand is set, it decrypts all encrypted
+// files instead. The plain text is only ever kept in memory.
+func (opts *Options) decrypt(filePath string, data []byte) ([]byte, bool, error) {
+	isSOPS := isSOPSFile(data)
+	if !isSOPS && !isPGPMessage(data) && !isAgeFile(data) {
+		return data, false, nil
+	}
+
+	if opts.DecryptCommand != "" {
+		out, err := runDecryptCommand(opts.DecryptCommand, filePath, data)
+		return out, true, err
+	}
+	keys, err := opts.decryptionKeys()
+	if err != nil {
+		return nil, true, err
+	}
+	if keys.keyring == nil && keys.identities == nil {
+		return nil, true, errors.Errorf("%s is encrypted, but neither a keyring, an age identity nor a decrypt command was given", filePath)
+	}
+
+	var out []byte
+	switch {
+	case isSOPS:
+		out, err = decryptSOPS(data, keys)
+	case isAgeFile(data):
+		if keys.identities == nil {
+			return nil, true, errors.Errorf("%s is encrypted with age, which requires an age identity", filePath)
+		}
+		out, err = decryptAge(data, keys.identities)
+	default:
+		if keys.keyring == nil {
+			return nil, true, errors.Errorf("%s is encrypted with PGP, which requires a keyring", filePath)
+		}
+		out, err = decryptPGP(data, keys.keyring)
+	}
+	if err != nil {
+		return nil, true, errors.Wrapf(err, "failed to decrypt %s", filePath)
+	}
+	return out, true, nil
+}
+
+// decryptionKeys holds the secret keys that decrypt values files.
+type decryptionKeys struct {
+	keyring    openpgp.EntityList
+	identities []age.Identity
+}
+
+// decryptionKeys loads the PGP keyring and the age identities of opts, if
+// they are given.
+func (opts *Options) decryptionKeys() (*decryptionKeys, error) {
+	keys := &decryptionKeys{}
+	if opts.Keyring != "" {
+		signatory, err := provenance.NewFromKeyring(opts.Keyring, "")
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load keyring %s", opts.Keyring)
+		}
+		keys.keyring = signatory.KeyRing
+	}
+
+	identityFile := opts.AgeIdentity
+	if identityFile == "" {
+		identityFile = os.Getenv("SOPS_AGE_KEY_FILE")
+	}
+	if identityFile != "" {
+		f, err := os.Open(identityFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load age identity")
+		}
+		defer f.Close()
+		if keys.identities, err = age.ParseIdentities(f); err != nil {
+			return nil, errors.Wrapf(err, "failed to load age identity %s", identityFile)
+		}
+	}
+	return keys, nil
+}
+
+// isPGPMessage reports whether data is an armored OpenPGP message, or a
+// binary one that starts with a public-key encrypted session key packet.
+func isPGPMessage(data []byte) bool {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(pgpArmorHeader)) {
+		return true
+	}
+	// The old and new packet formats of tag 1. Neither is a valid first byte
+	// of a UTF-8 encoded values file.
+	return len(data) > 0 && (data[0]&0xfc == 0x84 || data[0] == 0xc1)
+}
+
+// isAgeFile reports whether data is an armored or binary age file.
+func isAgeFile(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(ageMagic)) ||
+		bytes.HasPrefix(bytes.TrimSpace(data), []byte(agearmor.Header))
+}
+
+// isSOPSFile reports whether data is a YAML file encrypted by SOPS, which has
+// a top-level "sops" mapping.
+func isSOPSFile(data []byte) bool {
+	var doc yamlv3.Node
+	if err := yamlv3.Unmarshal(data, &doc); err != nil || len(doc.Content) == 0 {
+		return false
+	}
+	root := doc.Content[0]
+	if root.Kind != yamlv3.MappingNode {
+		return false
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "sops" && root.Content[i+1].Kind == yamlv3.MappingNode {
+			return true
+		}
+	}
+	return false
+}
+
+// runDecryptCommand runs command, the path to an executable followed by its
+// arguments, with the encrypted file on stdin, and returns its output. The
+// HELM_VALUES_FILE environment variable names the file being decrypted.
+func runDecryptCommand(command, filePath string, data []byte) ([]byte, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.Errorf("the command to decrypt %s is blank", filePath)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "HELM_VALUES_FILE="+filePath)
+	cmd.Stdin = bytes.NewReader(data)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "error while running command %s to decrypt %s. error output:\n%s", args[0], filePath, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
+
+// decryptPGP decrypts an armored or binary OpenPGP message with a key from
+// keyring.
+func decryptPGP(data []byte, keyring openpgp.EntityList) ([]byte, error) {
+	var r io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(pgpArmorHeader)) {
+		block, err := armor.Decode(bytes.NewReader(bytes.TrimSpace(data)))
+		if err != nil {
+			return nil, err
+		}
+		r = block.Body
+	}
+	md, err := openpgp.ReadMessage(r, keyring, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Reading the whole body also checks the integrity of the message.
+	return ioutil.ReadAll(md.UnverifiedBody)
+}
+
+// decryptAge decrypts an armored or binary age file with one of identities.
+func decryptAge(data []byte, identities []age.Identity) ([]byte, error) {
+	var r io.Reader = bytes.NewReader(data)
+	if !bytes.HasPrefix(data, []byte(ageMagic)) {
+		r = agearmor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+	}
+	plain, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(plain)
+}
+
+// decryptSOPS decrypts a SOPS file and returns its values as YAML, without the
+// SOPS metadata. It does what the decrypt package of SOPS does, except that
+// the data key is decrypted with keys rather than with the keys SOPS finds in
+// the environment.
+func decryptSOPS(data []byte, keys *decryptionKeys) ([]byte, error) {
+	store := &sopsyaml.Store{}
+	tree, err := store.LoadEncryptedFile(data)
+	if err != nil {
+		return nil, err
+	}
+	key, err := tree.Metadata.GetDataKeyWithKeyServices([]keyservice.KeyServiceClient{&sopsKeyService{keys: keys}})
+	if err != nil {
+		return nil, errors.Wrap(err, "the data key is not encrypted with any of the given keys")
+	}
+
+	cipher := sopsaes.NewCipher()
+	mac, err := tree.Decrypt(key, cipher)
+	if err != nil {
+		return nil, err
+	}
+	fileMAC, err := cipher.Decrypt(tree.Metadata.MessageAuthenticationCode, key, tree.Metadata.LastModified.Format(time.RFC3339))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt the message authentication code")
+	}
+	if fileMAC != mac {
+		return nil, errors.New("the message authentication code does not match; the file may have been tampered with")
+	}
+	return store.EmitPlainFile(tree.Branches)
+}
+
+// sopsKeyService is a SOPS key service that decrypts data keys encrypted with
+// PGP or age using the given secret keys.
+type sopsKeyService struct {
+	keys *decryptionKeys
+}
+
+// Encrypt is not supported, as values files are only decrypted.
+func (s *sopsKeyService) Encrypt(context.Context, *keyservice.EncryptRequest, ...grpc.CallOption) (*keyservice.EncryptResponse, error) {
+	return nil, errors.New("encryption is not supported")
+}
+
+// Decrypt decrypts the data key of a PGP or age master key.
+func (s *sopsKeyService) Decrypt(_ context.Context, req *keyservice.DecryptRequest, _ ...grpc.CallOption) (*keyservice.DecryptResponse, error) {
+	var plain []byte
+	var err error
+	switch req.GetKey().GetKeyType().(type) {
+	case *keyservice.Key_PgpKey:
+		if s.keys.keyring == nil {
+			return nil, errors.New("no keyring was given")
+		}
+		plain, err = decryptPGP(req.GetCiphertext(), s.keys.keyring)
+	case *keyservice.Key_AgeKey:
+		if s.keys.identities == nil {
+			return nil, errors.New("no age identity was given")
+		}
+		plain, err = decryptAge(req.GetCiphertext(), s.keys.identities)
+	default:
+		return nil, errors.New("only PGP and age keys are supported")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &keyservice.DecryptResponse{Plaintext: plain}, nil
+}
@@ -0,0 +1,242 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package values
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/getter"
+)
+
+const (
+	testKeyring     = "testdata/helm-test-key.secret"
+	testAgeIdentity = "testdata/age-key.txt"
+)
+
+func TestMergeValuesDecryptPGP(t *testing.T) {
+	opts := &Options{
+		ValueFiles: []string{"testdata/secrets.yaml.asc"},
+		Values:     []string{"database.port=5433"},
+		Keyring:    testKeyring,
+	}
+	vals, _, sensitive, err := opts.MergeValuesWithSources(getter.Providers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"database": map[string]interface{}{
+			"password": "s3cr3t",
+			"port":     int64(5433),
+		},
+	}
+	if !reflect.DeepEqual(vals, expected) {
+		t.Errorf("Expected %v, got %v", expected, vals)
+	}
+	if !reflect.DeepEqual(sensitive, []string{"/database/password"}) {
+		t.Errorf("Expected only /database/password to be sensitive, got %v", sensitive)
+	}
+}
+
+func TestMergeValuesDecryptSOPS(t *testing.T) {
+	opts := &Options{
+		ValueFiles: []string{"testdata/secrets.sops.yaml"},
+		Keyring:    testKeyring,
+	}
+	vals, sources, sensitive, err := opts.MergeValuesWithSources(getter.Providers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"image": map[string]interface{}{"tag": "v1.2.3"},
+		"database": map[string]interface{}{
+			"password": "s3cr3t",
+			"port":     float64(5432),
+			"ratio":    0.5,
+			"enabled":  true,
+		},
+		"hosts":            []interface{}{"a.example.com", "b.example.com"},
+		"name_unencrypted": "web",
+	}
+	if !reflect.DeepEqual(vals, expected) {
+		t.Errorf("Expected %v, got %v", expected, vals)
+	}
+	if sources["/database/password"] != "testdata/secrets.sops.yaml" {
+		t.Errorf("Unexpected source %q", sources["/database/password"])
+	}
+	if len(sensitive) != len(sources) {
+		t.Errorf("Expected all the values of the SOPS file to be sensitive, got %v", sensitive)
+	}
+}
+
+func TestMergeValuesDecryptAge(t *testing.T) {
+	opts := &Options{
+		ValueFiles:  []string{"testdata/secrets.yaml.age"},
+		AgeIdentity: testAgeIdentity,
+	}
+	vals, _, sensitive, err := opts.MergeValuesWithSources(getter.Providers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"database": map[string]interface{}{
+			"password": "s3cr3t",
+			"port":     float64(5432),
+		},
+	}
+	if !reflect.DeepEqual(vals, expected) {
+		t.Errorf("Expected %v, got %v", expected, vals)
+	}
+	if !reflect.DeepEqual(sensitive, []string{"/database/password", "/database/port"}) {
+		t.Errorf("Expected the values of the age file to be sensitive, got %v", sensitive)
+	}
+}
+
+func TestMergeValuesDecryptSOPSAge(t *testing.T) {
+	// The identity is found through SOPS_AGE_KEY_FILE, like SOPS does.
+	defer os.Setenv("SOPS_AGE_KEY_FILE", os.Getenv("SOPS_AGE_KEY_FILE"))
+	os.Setenv("SOPS_AGE_KEY_FILE", testAgeIdentity)
+
+	opts := &Options{ValueFiles: []string{"testdata/secrets.age.sops.yaml"}}
+	vals, err := opts.MergeValues(getter.Providers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vals["database"].(map[string]interface{})["password"] != "s3cr3t" {
+		t.Errorf("Expected the decrypted password, got %v", vals)
+	}
+}
+
+func TestMergeValuesDecryptErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   *Options
+		expect string
+	}{
+		{
+			name:   "tampered SOPS file",
+			opts:   &Options{ValueFiles: []string{"testdata/tampered.sops.yaml"}, Keyring: testKeyring},
+			expect: "message authentication code does not match",
+		},
+		{
+			name:   "no keyring",
+			opts:   &Options{ValueFiles: []string{"testdata/secrets.sops.yaml"}},
+			expect: "neither a keyring, an age identity nor a decrypt command was given",
+		},
+		{
+			name:   "wrong keyring",
+			opts:   &Options{ValueFiles: []string{"testdata/secrets.yaml.asc"}, Keyring: "../../provenance/testdata/helm-test-key.pub"},
+			expect: "failed to decrypt testdata/secrets.yaml.asc",
+		},
+		{
+			name:   "age file without an age identity",
+			opts:   &Options{ValueFiles: []string{"testdata/secrets.yaml.age"}, Keyring: testKeyring},
+			expect: "requires an age identity",
+		},
+		{
+			name:   "SOPS file without a matching key",
+			opts:   &Options{ValueFiles: []string{"testdata/secrets.age.sops.yaml"}, Keyring: testKeyring},
+			expect: "the data key is not encrypted with any of the given keys",
+		},
+		{
+			name:   "PGP file without a keyring",
+			opts:   &Options{ValueFiles: []string{"testdata/secrets.yaml.asc"}, AgeIdentity: testAgeIdentity},
+			expect: "requires a keyring",
+		},
+		{
+			name:   "invalid age identity",
+			opts:   &Options{ValueFiles: []string{"testdata/secrets.yaml.age"}, AgeIdentity: testKeyring},
+			expect: "failed to load age identity",
+		},
+		{
+			name:   "blank decrypt command",
+			opts:   &Options{ValueFiles: []string{"testdata/secrets.sops.yaml"}, DecryptCommand: " \t"},
+			expect: "the command to decrypt testdata/secrets.sops.yaml is blank",
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := tt.opts.MergeValues(getter.Providers{})
+		if err == nil || !strings.Contains(err.Error(), tt.expect) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.expect, err)
+		}
+	}
+}
+
+func TestMergeValuesDecryptCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("TODO: refactor this test to work on windows")
+	}
+
+	// The SOPS file is passed through unchanged, so only its unencrypted
+	// value is checked.
+	opts := &Options{
+		ValueFiles:     []string{"testdata/secrets.sops.yaml"},
+		DecryptCommand: "cat -",
+	}
+	vals, _, sensitive, err := opts.MergeValuesWithSources(getter.Providers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vals["name_unencrypted"] != "web" {
+		t.Errorf("Expected the output of the decrypt command, got %v", vals)
+	}
+	if len(sensitive) == 0 {
+		t.Error("Expected the values of the decrypt command to be sensitive")
+	}
+
+	opts.DecryptCommand = "false"
+	if _, err := opts.MergeValues(getter.Providers{}); err == nil {
+		t.Error("Expected error from a failing decrypt command")
+	}
+}
+
+func TestMergeValuesSensitiveOverridden(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helm-values")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// A plain values file whose name looks like a marker is not sensitive
+	plain := filepath.Join(dir, "decrypted values.yaml")
+	if err := ioutil.WriteFile(plain, []byte("database:\n  host: db\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &Options{
+		ValueFiles:  []string{"testdata/secrets.yaml.age", plain},
+		Values:      []string{"database.port=5433"},
+		AgeIdentity: testAgeIdentity,
+	}
+	_, sources, sensitive, err := opts.MergeValuesWithSources(getter.Providers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sensitive, []string{"/database/password"}) {
+		t.Errorf("Expected only /database/password to be sensitive, got %v", sensitive)
+	}
+	if sources["/database/password"] != "testdata/secrets.yaml.age" {
+		t.Errorf("Unexpected source %q", sources["/database/password"])
+	}
+}
@@ -17,58 +17,121 @@ limitations under the License.
 package values
 
 import (
+	"bytes"
 	"io/ioutil"
 	"net/url"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
 	"sigs.k8s.io/yaml"
 
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/getter"
 	"helm.sh/helm/v3/pkg/strvals"
 )
 
+// envScheme is the scheme of values sources that read environment variables,
+// such as env://MYAPP_.
+const envScheme = "env"
+
 type Options struct {
 	ValueFiles   []string
 	StringValues []string
 	Values       []string
 	FileValues   []string
+	JSONValues   []string
+
+	// Keyring is the path to a PGP keyring with the secret keys that decrypt
+	// encrypted values files.
+	Keyring string
+	// AgeIdentity is the path to a file of age secret keys, as written by
+	// age-keygen, that decrypt encrypted values files. If it is empty, the
+	// SOPS_AGE_KEY_FILE environment variable names the file.
+	AgeIdentity string
+	// DecryptCommand, if set, is the path to an executable, followed by its
+	// arguments, that decrypts encrypted values files. It is given the file on
+	// stdin and writes the decrypted file to stdout.
+	DecryptCommand string
 }
 
 // MergeValues merges values from files specified via -f/--values and directly
-// via --set, --set-string, or --set-file, marshaling them to YAML
+// via --set, --set-string, --set-json, or --set-file, marshaling them to YAML.
+//
+// Besides local files and stdin ("-"), a values file may be any URL that one
+// of the providers p supports, such as https://, oci:// URLs, the protocols of
+// downloader plugins and exec:// URLs with getter.ValuesProviders, or an
+// env://PREFIX URL that reads the environment variables starting with PREFIX.
+// See readValuesFile.
+//
+// Values files that are encrypted are decrypted in memory, and their values
+// are reported as sensitive by MergeValuesWithSources. See decrypt.
 func (opts *Options) MergeValues(p getter.Providers) (map[string]interface{}, error) {
+	base, _, _, err := opts.MergeValuesWithSources(p)
+	return base, err
+}
+
+// MergeValuesWithSources is like MergeValues, but also reports the file or
+// flag that supplied each value, and the JSON pointers, in order, of the
+// values that are sensitive because they were read from an encrypted values
+// file and not overridden since.
+func (opts *Options) MergeValuesWithSources(p getter.Providers) (map[string]interface{}, chartutil.ValueSources, []string, error) {
 	base := map[string]interface{}{}
+	sources := chartutil.ValueSources{}
+	// sensitivity is recorded like the sources, so that a value that is
+	// overridden takes the sensitivity of its new source.
+	sensitivity := chartutil.ValueSources{}
 
 	// User specified a values files via -f/--values
 	for _, filePath := range opts.ValueFiles {
 		currentMap := map[string]interface{}{}
 
-		bytes, err := readFile(filePath, p)
+		bytes, err := readValuesFile(filePath, p)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		bytes, encrypted, err := opts.decrypt(filePath, bytes)
 		if err != nil {
-			return nil, err
+			return nil, nil, nil, err
 		}
 
 		if err := yaml.Unmarshal(bytes, &currentMap); err != nil {
-			return nil, errors.Wrapf(err, "failed to parse %s", filePath)
+			return nil, nil, nil, errors.Wrapf(err, "failed to parse %s", filePath)
 		}
 		// Merge with the previous map
 		base = mergeMaps(base, currentMap)
+		sources.Record(currentMap, filePath)
+		if encrypted {
+			sensitivity.Record(currentMap, sensitive)
+		} else {
+			sensitivity.Record(currentMap, "")
+		}
+	}
+
+	// User specified a value via --set-json
+	for _, value := range opts.JSONValues {
+		if err := strvals.ParseJSON(value, base); err != nil {
+			return nil, nil, nil, errors.Wrap(err, "failed parsing --set-json data")
+		}
+		recordFlag(sources, sensitivity, value, "--set-json", strvals.ParseJSON)
 	}
 
 	// User specified a value via --set
 	for _, value := range opts.Values {
 		if err := strvals.ParseInto(value, base); err != nil {
-			return nil, errors.Wrap(err, "failed parsing --set data")
+			return nil, nil, nil, errors.Wrap(err, "failed parsing --set data")
 		}
+		recordFlag(sources, sensitivity, value, "--set", strvals.ParseInto)
 	}
 
 	// User specified a value via --set-string
 	for _, value := range opts.StringValues {
 		if err := strvals.ParseIntoString(value, base); err != nil {
-			return nil, errors.Wrap(err, "failed parsing --set-string data")
+			return nil, nil, nil, errors.Wrap(err, "failed parsing --set-string data")
 		}
+		recordFlag(sources, sensitivity, value, "--set-string", strvals.ParseIntoString)
 	}
 
 	// User specified a value via --set-file
@@ -78,11 +141,25 @@ func (opts *Options) MergeValues(p getter.Providers) (map[string]interface{}, er
 			return string(bytes), err
 		}
 		if err := strvals.ParseIntoFile(value, base, reader); err != nil {
-			return nil, errors.Wrap(err, "failed parsing --set-file data")
+			return nil, nil, nil, errors.Wrap(err, "failed parsing --set-file data")
 		}
+		recordFlag(sources, sensitivity, value, "--set-file", func(s string, dest map[string]interface{}) error {
+			return strvals.ParseIntoFile(s, dest, func([]rune) (interface{}, error) { return "", nil })
+		})
 	}
 
-	return base, nil
+	return base, sources, sensitivePaths(sensitivity), nil
+}
+
+// recordFlag records the values set by a single occurrence of flag in sources,
+// as not sensitive. parse is run again on its own map so that only the keys of
+// this value are recorded.
+func recordFlag(sources, sensitivity chartutil.ValueSources, value, flag string, parse func(string, map[string]interface{}) error) {
+	set := map[string]interface{}{}
+	if parse(value, set) == nil {
+		sources.Record(set, flag)
+		sensitivity.Record(set, "")
+	}
 }
 
 func mergeMaps(a, b map[string]interface{}) map[string]interface{} {
@@ -109,13 +186,102 @@ func readFile(filePath string, p getter.Providers) ([]byte, error) {
 	if strings.TrimSpace(filePath) == "-" {
 		return ioutil.ReadAll(os.Stdin)
 	}
-	u, _ := url.Parse(filePath)
+	u, err := url.Parse(filePath)
+	if err != nil {
+		return ioutil.ReadFile(filePath)
+	}
 
-	// FIXME: maybe someone handle other protocols like ftp.
 	g, err := p.ByScheme(u.Scheme)
 	if err != nil {
+		// A Windows path such as C:\values.yaml also parses with a scheme,
+		// so only report the scheme when it is followed by "://".
+		if strings.Contains(filePath, "://") {
+			return nil, errors.Errorf("unable to read %s: scheme %q not supported", filePath, u.Scheme)
+		}
 		return ioutil.ReadFile(filePath)
 	}
 	data, err := g.Get(filePath, getter.WithURL(filePath))
-	return data.Bytes(), err
+	if err != nil {
+		return nil, err
+	}
+	return data.Bytes(), nil
+}
+
+// readValuesFile loads the values file at filePath. Unlike readFile, it reads
+// env:// sources, and when the source is a chart archive, as it is for
+// oci:// references, it returns the file named by the URL fragment, or
+// values.yaml if there is none.
+func readValuesFile(filePath string, p getter.Providers) ([]byte, error) {
+	if strings.HasPrefix(filePath, envScheme+"://") {
+		return readEnv(strings.TrimPrefix(filePath, envScheme+"://"))
+	}
+
+	name := chartutil.ValuesfileName
+	if i := strings.Index(filePath, "#"); i >= 0 && strings.Contains(filePath, "://") {
+		filePath, name = filePath[:i], filePath[i+1:]
+	}
+	data, err := readFile(filePath, p)
+	if err != nil || !isArchive(data) {
+		return data, err
+	}
+
+	ch, err := loader.LoadArchive(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load chart archive %s", filePath)
+	}
+	for _, f := range ch.Raw {
+		if f.Name == name {
+			return f.Data, nil
+		}
+	}
+	return nil, errors.Errorf("chart archive %s has no file %s", filePath, name)
+}
+
+// isArchive reports whether data is gzip compressed, as chart archives are.
+func isArchive(data []byte) bool {
+	return len(data) > 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+// readEnv returns the environment variables starting with prefix as a values
+// file. The rest of each variable name is its key, with "__" separating nested
+// keys, so MYAPP_image__tag=v1 sets image.tag when prefix is MYAPP_. Values
+// are typed the same way as --set values.
+func readEnv(prefix string) ([]byte, error) {
+	if prefix == "" {
+		return nil, errors.New("env:// values sources require a prefix, such as env://MYAPP_")
+	}
+	var env []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, prefix) {
+			env = append(env, strings.TrimPrefix(kv, prefix))
+		}
+	}
+	sort.Strings(env)
+
+	vals := map[string]interface{}{}
+	for _, kv := range env {
+		i := strings.Index(kv, "=")
+		if i <= 0 {
+			continue
+		}
+		keys := strings.Split(kv[:i], "__")
+		for j, key := range keys {
+			keys[j] = escapeSetKey(key)
+		}
+		// Escape the value so that commas and a leading "{" are kept as is.
+		value := strings.NewReplacer("\\", "\\\\", ",", "\\,").Replace(kv[i+1:])
+		if strings.HasPrefix(value, "{") {
+			value = "\\" + value
+		}
+		line := strings.Join(keys, ".") + "=" + value
+		if err := strvals.ParseInto(line, vals); err != nil {
+			return nil, errors.Wrapf(err, "failed parsing environment variable %s%s", prefix, kv[:i])
+		}
+	}
+	return yaml.Marshal(vals)
+}
+
+// escapeSetKey escapes the characters that have a meaning in --set keys.
+func escapeSetKey(key string) string {
+	return strings.NewReplacer("\\", "\\\\", ".", "\\.", "[", "\\[", "=", "\\=", ",", "\\,").Replace(key)
 }
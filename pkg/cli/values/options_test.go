@@ -17,8 +17,17 @@ limitations under the License.
 package values
 
 import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/getter"
 )
 
 func TestMergeValues(t *testing.T) {
@@ -75,3 +84,147 @@ func TestMergeValues(t *testing.T) {
 		t.Errorf("Expected a map with different keys to merge properly with another map. Expected: %v, got %v", expectedMap, testMap)
 	}
 }
+
+func TestMergeValuesWithSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helm-values")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "values.yaml")
+	if err := ioutil.WriteFile(file, []byte("image:\n  repository: nginx\n  tag: \"1.0\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &Options{
+		ValueFiles:   []string{file},
+		JSONValues:   []string{`resources={"limits":{"cpu":"100m"}}`},
+		Values:       []string{"image.tag=2.0,replicas=3"},
+		StringValues: []string{"name=web"},
+	}
+	_, sources, _, err := opts.MergeValuesWithSources(getter.Providers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := chartutil.ValueSources{
+		"/image/repository":     file,
+		"/image/tag":            "--set",
+		"/replicas":             "--set",
+		"/name":                 "--set-string",
+		"/resources/limits/cpu": "--set-json",
+	}
+	if !reflect.DeepEqual(sources, expected) {
+		t.Errorf("Expected sources %v, got %v", expected, sources)
+	}
+}
+
+type testGetter struct {
+	data []byte
+}
+
+func (g *testGetter) Get(href string, _ ...getter.Option) (*bytes.Buffer, error) {
+	return bytes.NewBuffer(g.data), nil
+}
+
+func testProvider(data []byte) getter.Providers {
+	return getter.Providers{{
+		Schemes: []string{"test"},
+		New: func(...getter.Option) (getter.Getter, error) {
+			return &testGetter{data: data}, nil
+		},
+	}}
+}
+
+func TestMergeValuesFromProvider(t *testing.T) {
+	opts := &Options{ValueFiles: []string{"test://values.yaml"}}
+	vals, sources, _, err := opts.MergeValuesWithSources(testProvider([]byte("name: remote\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vals["name"] != "remote" || sources["/name"] != "test://values.yaml" {
+		t.Errorf("Expected name from test://values.yaml, got %v from %v", vals, sources)
+	}
+
+	opts = &Options{ValueFiles: []string{"nosuchscheme://values.yaml"}}
+	if _, err := opts.MergeValues(testProvider(nil)); err == nil || !strings.Contains(err.Error(), `scheme "nosuchscheme" not supported`) {
+		t.Errorf("Expected unsupported scheme error, got %v", err)
+	}
+}
+
+func TestMergeValuesFromArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "helm-values")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ch := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "values", Version: "0.1.0"},
+		Raw:      []*chart.File{{Name: chartutil.ValuesfileName, Data: []byte("name: packaged\n")}},
+		Files:    []*chart.File{{Name: "values-prod.yaml", Data: []byte("name: prod\n")}},
+	}
+	archive, err := chartutil.Save(ch, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for ref, expect := range map[string]string{
+		"test://example.com/values:0.1.0":                  "packaged",
+		"test://example.com/values:0.1.0#values-prod.yaml": "prod",
+	} {
+		opts := &Options{ValueFiles: []string{ref}}
+		vals, err := opts.MergeValues(testProvider(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if vals["name"] != expect {
+			t.Errorf("%s: expected name %q, got %v", ref, expect, vals["name"])
+		}
+	}
+
+	opts := &Options{ValueFiles: []string{"test://example.com/values:0.1.0#missing.yaml"}}
+	if _, err := opts.MergeValues(testProvider(data)); err == nil {
+		t.Error("Expected error for a file missing from the archive")
+	}
+}
+
+func TestMergeValuesFromEnv(t *testing.T) {
+	env := map[string]string{
+		"HELMTEST_image__tag":  "v1,2",
+		"HELMTEST_replicas":    "3",
+		"HELMTEST_annotations": "{a}",
+		"HELMTESTING":          "ignored",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	opts := &Options{ValueFiles: []string{"env://HELMTEST_"}}
+	vals, sources, _, err := opts.MergeValuesWithSources(getter.Providers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"image":       map[string]interface{}{"tag": "v1,2"},
+		"replicas":    float64(3),
+		"annotations": "{a}",
+	}
+	if !reflect.DeepEqual(vals, expected) {
+		t.Errorf("Expected %v, got %v", expected, vals)
+	}
+	if sources["/image/tag"] != "env://HELMTEST_" {
+		t.Errorf("Expected /image/tag from env://HELMTEST_, got %q", sources["/image/tag"])
+	}
+
+	opts = &Options{ValueFiles: []string{"env://"}}
+	if _, err := opts.MergeValues(getter.Providers{}); err == nil {
+		t.Error("Expected error for env:// without a prefix")
+	}
+}
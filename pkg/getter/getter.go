@@ -175,3 +175,14 @@ func All(settings *cli.EnvSettings) Providers {
 	result = append(result, pluginDownloaders...)
 	return result
 }
+
+// ValuesProviders returns the getters of All and the exec:// getter, which
+// runs downloader plugins. It is meant for the values files given by the user
+// only, not for URLs found in charts or repository indexes.
+func ValuesProviders(settings *cli.EnvSettings) Providers {
+	execProvider := Provider{
+		Schemes: []string{"exec"},
+		New:     NewExecGetter(settings),
+	}
+	return append(All(settings), execProvider)
+}
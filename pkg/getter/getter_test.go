@@ -77,4 +77,20 @@ func TestByScheme(t *testing.T) {
 	if _, err := g.ByScheme("https"); err != nil {
 		t.Error(err)
 	}
+	if _, err := g.ByScheme("exec"); err == nil {
+		t.Error("Did not expect handler for exec")
+	}
+}
+
+func TestValuesProviders(t *testing.T) {
+	env := cli.New()
+	env.PluginsDirectory = pluginDir
+
+	g := ValuesProviders(env)
+	if _, err := g.ByScheme("exec"); err != nil {
+		t.Error(err)
+	}
+	if _, err := g.ByScheme("test"); err != nil {
+		t.Error(err)
+	}
 }
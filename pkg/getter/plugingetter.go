@@ -17,6 +17,7 @@ package getter
 
 import (
 	"bytes"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -71,6 +72,11 @@ func (p *pluginGetter) Get(href string, options ...Option) (*bytes.Buffer, error
 	argv := append(commands[1:], p.opts.certFile, p.opts.keyFile, p.opts.caFile, href)
 	prog := exec.Command(filepath.Join(p.base, commands[0]), argv...)
 	plugin.SetupPluginEnv(p.settings, p.name, p.base)
+	return runPlugin(prog, p.command)
+}
+
+// runPlugin runs a plugin command and returns what it wrote to stdout.
+func runPlugin(prog *exec.Cmd, command string) (*bytes.Buffer, error) {
 	prog.Env = os.Environ()
 	buf := bytes.NewBuffer(nil)
 	prog.Stdout = buf
@@ -78,7 +84,7 @@ func (p *pluginGetter) Get(href string, options ...Option) (*bytes.Buffer, error
 	if err := prog.Run(); err != nil {
 		if eerr, ok := err.(*exec.ExitError); ok {
 			os.Stderr.Write(eerr.Stderr)
-			return nil, errors.Errorf("plugin %q exited with error", p.command)
+			return nil, errors.Errorf("plugin %q exited with error", command)
 		}
 		return nil, err
 	}
@@ -100,3 +106,54 @@ func NewPluginGetter(command string, settings *cli.EnvSettings, name, base strin
 		return result, nil
 	}
 }
+
+// execGetter invokes the main command of the downloader plugin named by the
+// host of an exec:// URL, such as exec://my-plugin, and returns its output.
+// The full URL is passed to the plugin as its last argument.
+type execGetter struct {
+	settings *cli.EnvSettings
+	opts     options
+}
+
+// Get runs the plugin command named by href
+func (e *execGetter) Get(href string, options ...Option) (*bytes.Buffer, error) {
+	for _, opt := range options {
+		opt(&e.opts)
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return nil, err
+	}
+	plugins, err := plugin.FindPlugins(e.settings.PluginsDirectory)
+	if err != nil {
+		return nil, err
+	}
+	for _, plug := range plugins {
+		if plug.Metadata.Name != u.Host {
+			continue
+		}
+		if len(plug.Metadata.Downloaders) == 0 {
+			return nil, errors.Errorf("plugin %q is not a downloader plugin", u.Host)
+		}
+		// SetupPluginEnv must run first, as PrepareCommand expands
+		// environment variables such as $HELM_PLUGIN_DIR.
+		plugin.SetupPluginEnv(e.settings, plug.Metadata.Name, plug.Dir)
+		main, argv, err := plug.PrepareCommand([]string{href})
+		if err != nil {
+			return nil, errors.Wrapf(err, "plugin %q", u.Host)
+		}
+		return runPlugin(exec.Command(main, argv...), u.Host)
+	}
+	return nil, errors.Errorf("no plugin named %q is installed", u.Host)
+}
+
+// NewExecGetter constructs a getter that runs installed downloader plugins
+func NewExecGetter(settings *cli.EnvSettings) Constructor {
+	return func(options ...Option) (Getter, error) {
+		result := &execGetter{settings: settings}
+		for _, opt := range options {
+			opt(&result.opts)
+		}
+		return result, nil
+	}
+}
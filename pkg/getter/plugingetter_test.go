@@ -16,6 +16,9 @@ limitations under the License.
 package getter
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
@@ -74,6 +77,45 @@ func TestPluginGetter(t *testing.T) {
 	}
 }
 
+func TestExecGetter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("TODO: refactor this test to work on windows")
+	}
+
+	env := cli.New()
+	env.PluginsDirectory = pluginDir
+	g, err := NewExecGetter(env)()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := g.Get("exec://testgetter/values")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(data.String(), "HELM_PLUGIN_NAME=testgetter") {
+		t.Errorf("Expected plugin environment in output, got %q", data.String())
+	}
+
+	if _, err := g.Get("exec://nosuchthing"); err == nil {
+		t.Error("Expected error for a plugin that is not installed")
+	}
+
+	// Only downloader plugins are run
+	dir := filepath.Join(t.TempDir(), "command")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	metadata := "name: command\nversion: 0.1.0\ncommand: echo ran\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "plugin.yaml"), []byte(metadata), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env.PluginsDirectory = filepath.Dir(dir)
+	if _, err := g.Get("exec://command"); err == nil || !strings.Contains(err.Error(), "not a downloader plugin") {
+		t.Errorf("Expected error for a plugin that is not a downloader, got %v", err)
+	}
+}
+
 func TestPluginSubCommands(t *testing.T) {
 	if runtime.GOOS == "windows" {
 		t.Skip("TODO: refactor this test to work on windows")
@@ -55,6 +55,34 @@ var ErrNoObjectsVisited = errors.New("no objects visited")
 
 var metadataAccessor = meta.NewAccessor()
 
+// DefaultFieldManager is the field manager used for server-side apply when
+// ApplyOptions.FieldManager is empty.
+const DefaultFieldManager = "helm"
+
+// ApplyOptions controls how resources are sent to the API server on create
+// and update.
+type ApplyOptions struct {
+	// ServerSideApply sends resources as server-side apply patches instead of
+	// creating them and updating them with client-side three-way merge patches.
+	ServerSideApply bool
+	// FieldManager is the name recorded as the owner of the applied fields.
+	// Server-side apply uses DefaultFieldManager if it is empty.
+	FieldManager string
+	// ForceConflicts takes ownership of fields that are managed by another
+	// field manager instead of failing. It only applies to server-side apply.
+	ForceConflicts bool
+	// DryRun sends every request with server-side dry run, so that validation
+	// and admission webhooks run but nothing is persisted.
+	DryRun bool
+}
+
+func (o ApplyOptions) fieldManager() string {
+	if o.FieldManager != "" {
+		return o.FieldManager
+	}
+	return DefaultFieldManager
+}
+
 // Client represents a client capable of communicating with the Kubernetes API.
 type Client struct {
 	Factory Factory
@@ -119,8 +147,16 @@ func (c *Client) IsReachable() error {
 
 // Create creates Kubernetes resources specified in the resource list.
 func (c *Client) Create(resources ResourceList) (*Result, error) {
+	return c.CreateWithOptions(resources, ApplyOptions{})
+}
+
+// CreateWithOptions creates Kubernetes resources specified in the resource
+// list, using server-side apply and server-side dry run as configured in opts.
+func (c *Client) CreateWithOptions(resources ResourceList, opts ApplyOptions) (*Result, error) {
 	c.Log("creating %d resource(s)", len(resources))
-	if err := perform(resources, createResource); err != nil {
+	if err := perform(resources, func(info *resource.Info) error {
+		return createResource(info, opts)
+	}); err != nil {
 		return nil, err
 	}
 	return &Result{Created: resources}, nil
@@ -128,30 +164,39 @@ func (c *Client) Create(resources ResourceList) (*Result, error) {
 
 // Wait up to the given timeout for the specified resources to be ready
 func (c *Client) Wait(resources ResourceList, timeout time.Duration) error {
-	cs, err := c.getKubeClient()
-	if err != nil {
-		return err
-	}
-	w := waiter{
-		c:       cs,
-		log:     c.Log,
-		timeout: timeout,
-	}
-	return w.waitForResources(resources, false)
+	return c.WaitWithContext(context.Background(), resources, timeout)
+}
+
+// WaitWithContext waits up to the given timeout for the specified resources
+// to be ready, or until ctx is done.
+func (c *Client) WaitWithContext(ctx context.Context, resources ResourceList, timeout time.Duration) error {
+	return c.wait(ctx, resources, timeout, false)
 }
 
 // WaitWithJobs wait up to the given timeout for the specified resources to be ready, including jobs.
 func (c *Client) WaitWithJobs(resources ResourceList, timeout time.Duration) error {
+	return c.WaitWithJobsWithContext(context.Background(), resources, timeout)
+}
+
+// WaitWithJobsWithContext waits up to the given timeout for the specified
+// resources to be ready, including jobs, or until ctx is done.
+func (c *Client) WaitWithJobsWithContext(ctx context.Context, resources ResourceList, timeout time.Duration) error {
+	return c.wait(ctx, resources, timeout, true)
+}
+
+func (c *Client) wait(ctx context.Context, resources ResourceList, timeout time.Duration, waitForJobs bool) error {
 	cs, err := c.getKubeClient()
 	if err != nil {
 		return err
 	}
 	w := waiter{
-		c:       cs,
-		log:     c.Log,
-		timeout: timeout,
+		c:        cs,
+		log:      c.Log,
+		timeout:  timeout,
+		progress: ProgressFromContext(ctx),
+		crds:     cs.Discovery().RESTClient(),
 	}
-	return w.waitForResources(resources, true)
+	return w.waitForResources(ctx, resources, waitForJobs)
 }
 
 func (c *Client) namespace() string {
@@ -195,6 +240,14 @@ func (c *Client) Build(reader io.Reader, validate bool) (ResourceList, error) {
 // resource updates, creations, and deletions that were attempted. These can be
 // used for cleanup or other logging purposes.
 func (c *Client) Update(original, target ResourceList, force bool) (*Result, error) {
+	return c.UpdateWithOptions(original, target, force, ApplyOptions{})
+}
+
+// UpdateWithOptions behaves like Update. With opts.ServerSideApply, existing
+// resources are sent as server-side apply requests instead of client-side
+// three-way merge patches. With opts.DryRun, every request is sent with
+// server-side dry run and no resource is deleted.
+func (c *Client) UpdateWithOptions(original, target ResourceList, force bool, opts ApplyOptions) (*Result, error) {
 	updateErrors := []string{}
 	res := &Result{}
 
@@ -214,7 +267,7 @@ func (c *Client) Update(original, target ResourceList, force bool) (*Result, err
 			res.Created = append(res.Created, info)
 
 			// Since the resource does not exist, create it.
-			if err := createResource(info); err != nil {
+			if err := createResource(info, opts); err != nil {
 				return errors.Wrap(err, "failed to create resource")
 			}
 
@@ -229,7 +282,7 @@ func (c *Client) Update(original, target ResourceList, force bool) (*Result, err
 			return errors.Errorf("no %s with the name %q found", kind, info.Name)
 		}
 
-		if err := updateResource(c, info, originalInfo.Object, force); err != nil {
+		if err := updateResource(c, info, originalInfo.Object, force, opts); err != nil {
 			c.Log("error updating the resource %q:\n\t %v", info.Name, err)
 			updateErrors = append(updateErrors, err.Error())
 		}
@@ -247,6 +300,10 @@ func (c *Client) Update(original, target ResourceList, force bool) (*Result, err
 	}
 
 	for _, info := range original.Difference(target) {
+		if opts.DryRun {
+			c.Log("Skipping delete of %q in %s: dry run", info.Name, info.Namespace)
+			continue
+		}
 		c.Log("Deleting %q in %s...", info.Name, info.Namespace)
 
 		if err := info.Get(); err != nil {
@@ -314,9 +371,9 @@ func (c *Client) skipIfNotFound(err error) error {
 	return err
 }
 
-func (c *Client) watchTimeout(t time.Duration) func(*resource.Info) error {
+func (c *Client) watchTimeout(ctx context.Context, t time.Duration) func(*resource.Info) error {
 	return func(info *resource.Info) error {
-		return c.watchUntilReady(t, info)
+		return c.watchUntilReady(ctx, t, info)
 	}
 }
 
@@ -335,9 +392,15 @@ func (c *Client) watchTimeout(t time.Duration) func(*resource.Info) error {
 //
 // Handling for other kinds will be added as necessary.
 func (c *Client) WatchUntilReady(resources ResourceList, timeout time.Duration) error {
+	return c.WatchUntilReadyWithContext(context.Background(), resources, timeout)
+}
+
+// WatchUntilReadyWithContext behaves like WatchUntilReady, but stops watching
+// when ctx is done.
+func (c *Client) WatchUntilReadyWithContext(ctx context.Context, resources ResourceList, timeout time.Duration) error {
 	// For jobs, there's also the option to do poll c.Jobs(namespace).Get():
 	// https://github.com/adamreese/kubernetes/blob/master/test/e2e/job.go#L291-L300
-	return perform(resources, c.watchTimeout(timeout))
+	return perform(resources, c.watchTimeout(ctx, timeout))
 }
 
 func perform(infos ResourceList, fn func(*resource.Info) error) error {
@@ -374,8 +437,29 @@ func batchPerform(infos ResourceList, fn func(*resource.Info) error, errs chan<-
 	}
 }
 
-func createResource(info *resource.Info) error {
-	obj, err := resource.NewHelper(info.Client, info.Mapping).Create(info.Namespace, true, info.Object)
+func createResource(info *resource.Info, opts ApplyOptions) error {
+	if opts.ServerSideApply {
+		return applyResource(info, opts)
+	}
+	helper := resource.NewHelper(info.Client, info.Mapping).DryRun(opts.DryRun).WithFieldManager(opts.FieldManager)
+	obj, err := helper.Create(info.Namespace, true, info.Object)
+	if err != nil {
+		return err
+	}
+	return info.Refresh(obj, true)
+}
+
+// applyResource sends the object with a server-side apply patch, which
+// creates it if it does not exist.
+func applyResource(info *resource.Info, opts ApplyOptions) error {
+	data, err := json.Marshal(info.Object)
+	if err != nil {
+		return errors.Wrap(err, "serializing target configuration")
+	}
+	helper := resource.NewHelper(info.Client, info.Mapping).DryRun(opts.DryRun).WithFieldManager(opts.fieldManager())
+	obj, err := helper.Patch(info.Namespace, info.Name, types.ApplyPatchType, data, &metav1.PatchOptions{
+		Force: &opts.ForceConflicts,
+	})
 	if err != nil {
 		return err
 	}
@@ -439,14 +523,16 @@ func createPatch(target *resource.Info, current runtime.Object) ([]byte, types.P
 	return patch, types.StrategicMergePatchType, err
 }
 
-func updateResource(c *Client, target *resource.Info, currentObj runtime.Object, force bool) error {
+func updateResource(c *Client, target *resource.Info, currentObj runtime.Object, force bool, opts ApplyOptions) error {
 	var (
 		obj    runtime.Object
-		helper = resource.NewHelper(target.Client, target.Mapping)
+		helper = resource.NewHelper(target.Client, target.Mapping).DryRun(opts.DryRun).WithFieldManager(opts.FieldManager)
 		kind   = target.Mapping.GroupVersionKind.Kind
 	)
 
 	// if --force is applied, attempt to replace the existing resource with the new object.
+	// Callers reject force together with server-side apply, whose counterpart is
+	// opts.ForceConflicts.
 	if force {
 		var err error
 		obj, err = helper.Replace(target.Namespace, target.Name, true, target.Object)
@@ -454,6 +540,12 @@ func updateResource(c *Client, target *resource.Info, currentObj runtime.Object,
 			return errors.Wrap(err, "failed to replace object")
 		}
 		c.Log("Replaced %q with kind %s for kind %s", target.Name, currentObj.GetObjectKind().GroupVersionKind().Kind, kind)
+	} else if opts.ServerSideApply {
+		if err := applyResource(target, opts); err != nil {
+			return errors.Wrapf(err, "cannot apply %q with kind %s", target.Name, kind)
+		}
+		c.Log("Applied %q with kind %s", target.Name, kind)
+		return nil
 	} else {
 		patch, patchType, err := createPatch(target, currentObj)
 		if err != nil {
@@ -480,7 +572,7 @@ func updateResource(c *Client, target *resource.Info, currentObj runtime.Object,
 	return nil
 }
 
-func (c *Client) watchUntilReady(timeout time.Duration, info *resource.Info) error {
+func (c *Client) watchUntilReady(ctx context.Context, timeout time.Duration, info *resource.Info) error {
 	kind := info.Mapping.GroupVersionKind.Kind
 	switch kind {
 	case "Job", "Pod":
@@ -504,7 +596,8 @@ func (c *Client) watchUntilReady(timeout time.Duration, info *resource.Info) err
 	// In the future, we might want to add some special logic for types
 	// like Ingress, Volume, etc.
 
-	ctx, cancel := watchtools.ContextWithOptionalTimeout(context.Background(), timeout)
+	progress := ProgressFromContext(ctx)
+	ctx, cancel := watchtools.ContextWithOptionalTimeout(ctx, timeout)
 	defer cancel()
 	_, err = watchtools.UntilWithSync(ctx, lw, &unstructured.Unstructured{}, nil, func(e watch.Event) (bool, error) {
 		// Make sure the incoming object is versioned as we use unstructured
@@ -517,13 +610,20 @@ func (c *Client) watchUntilReady(timeout time.Duration, info *resource.Info) err
 			// the status go into a good state. For other types, like ReplicaSet
 			// we don't really do anything to support these as hooks.
 			c.Log("Add/Modify event for %s: %v", info.Name, e.Type)
+			var (
+				done bool
+				err  error
+			)
 			switch kind {
 			case "Job":
-				return c.waitForJob(obj, info.Name)
+				done, err = c.waitForJob(obj, info.Name)
 			case "Pod":
-				return c.waitForPodSuccess(obj, info.Name)
+				done, err = c.waitForPodSuccess(obj, info.Name)
 			}
-			return true, nil
+			if progress != nil {
+				progress(watchProgressEvent(info, obj, done, err))
+			}
+			return done, err
 		case watch.Deleted:
 			c.Log("Deleted event for %s", info.Name)
 			return true, nil
@@ -27,6 +27,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest/fake"
@@ -214,6 +215,97 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
+func TestUpdateServerSideApply(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		listA := newPodList("starfish", "squid")
+		listB := newPodList("starfish", "dolphin")
+
+		var actions []string
+
+		c := newTestClient(t)
+		c.Factory.(*cmdtesting.TestFactory).UnstructuredClient = &fake.RESTClient{
+			NegotiatedSerializer: unstructuredSerializer,
+			Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+				p, m := req.URL.Path, req.Method
+				actions = append(actions, p+":"+m)
+				t.Logf("got request %s %s?%s", p, m, req.URL.RawQuery)
+				switch {
+				case m == "GET" && p == "/namespaces/default/pods/starfish":
+					return newResponse(200, &listA.Items[0])
+				case m == "GET" && p == "/namespaces/default/pods/dolphin":
+					return newResponse(404, notFoundBody())
+				case m == "PATCH":
+					if ct := req.Header.Get("Content-Type"); ct != string(types.ApplyPatchType) {
+						t.Errorf("expected content type %s, got %s", types.ApplyPatchType, ct)
+					}
+					q := req.URL.Query()
+					if q.Get("fieldManager") != "helm" {
+						t.Errorf("expected field manager helm, got %q", q.Get("fieldManager"))
+					}
+					if q.Get("force") != "true" {
+						t.Errorf("expected force=true, got %q", q.Get("force"))
+					}
+					if got := q.Get("dryRun") == metav1.DryRunAll; got != dryRun {
+						t.Errorf("expected dry run %t, got query %q", dryRun, req.URL.RawQuery)
+					}
+					return newResponse(200, &listB.Items[0])
+				case m == "GET" && p == "/namespaces/default/pods/squid":
+					return newResponse(200, &listA.Items[1])
+				case m == "DELETE" && p == "/namespaces/default/pods/squid":
+					return newResponse(200, &listA.Items[1])
+				default:
+					t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+					return nil, nil
+				}
+			}),
+		}
+		first, err := c.Build(objBody(&listA), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		second, err := c.Build(objBody(&listB), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		result, err := c.UpdateWithOptions(first, second, false, ApplyOptions{
+			ServerSideApply: true,
+			ForceConflicts:  true,
+			DryRun:          dryRun,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Created) != 1 {
+			t.Errorf("expected 1 resource created, got %d", len(result.Created))
+		}
+		if len(result.Updated) != 1 {
+			t.Errorf("expected 1 resource updated, got %d", len(result.Updated))
+		}
+
+		expectedActions := []string{
+			"/namespaces/default/pods/starfish:GET",
+			"/namespaces/default/pods/starfish:PATCH",
+			"/namespaces/default/pods/dolphin:GET",
+			"/namespaces/default/pods/dolphin:PATCH",
+		}
+		if !dryRun {
+			expectedActions = append(expectedActions,
+				"/namespaces/default/pods/squid:GET",
+				"/namespaces/default/pods/squid:DELETE",
+			)
+		}
+		if len(expectedActions) != len(actions) {
+			t.Fatalf("unexpected requests, expected %v, got %v", expectedActions, actions)
+		}
+		for k, v := range expectedActions {
+			if actions[k] != v {
+				t.Errorf("expected %s request got %s", v, actions[k])
+			}
+		}
+	}
+}
+
 func TestBuild(t *testing.T) {
 	tests := []struct {
 		name      string
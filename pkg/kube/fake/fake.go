@@ -18,6 +18,7 @@ limitations under the License.
 package fake
 
 import (
+	"context"
 	"io"
 	"time"
 
@@ -50,6 +51,14 @@ func (f *FailingKubeClient) Create(resources kube.ResourceList) (*kube.Result, e
 	return f.PrintingKubeClient.Create(resources)
 }
 
+// CreateWithOptions returns the configured error if set or prints
+func (f *FailingKubeClient) CreateWithOptions(resources kube.ResourceList, opts kube.ApplyOptions) (*kube.Result, error) {
+	if f.CreateError != nil {
+		return nil, f.CreateError
+	}
+	return f.PrintingKubeClient.CreateWithOptions(resources, opts)
+}
+
 // Wait returns the configured error if set or prints
 func (f *FailingKubeClient) Wait(resources kube.ResourceList, d time.Duration) error {
 	if f.WaitError != nil {
@@ -66,6 +75,22 @@ func (f *FailingKubeClient) WaitWithJobs(resources kube.ResourceList, d time.Dur
 	return f.PrintingKubeClient.Wait(resources, d)
 }
 
+// WaitWithContext returns the configured error if set or prints
+func (f *FailingKubeClient) WaitWithContext(ctx context.Context, resources kube.ResourceList, d time.Duration) error {
+	if f.WaitError != nil {
+		return f.WaitError
+	}
+	return f.PrintingKubeClient.WaitWithContext(ctx, resources, d)
+}
+
+// WaitWithJobsWithContext returns the configured error if set or prints
+func (f *FailingKubeClient) WaitWithJobsWithContext(ctx context.Context, resources kube.ResourceList, d time.Duration) error {
+	if f.WaitError != nil {
+		return f.WaitError
+	}
+	return f.PrintingKubeClient.WaitWithJobsWithContext(ctx, resources, d)
+}
+
 // Delete returns the configured error if set or prints
 func (f *FailingKubeClient) Delete(resources kube.ResourceList) (*kube.Result, []error) {
 	if f.DeleteError != nil {
@@ -82,6 +107,14 @@ func (f *FailingKubeClient) WatchUntilReady(resources kube.ResourceList, d time.
 	return f.PrintingKubeClient.WatchUntilReady(resources, d)
 }
 
+// WatchUntilReadyWithContext returns the configured error if set or prints
+func (f *FailingKubeClient) WatchUntilReadyWithContext(ctx context.Context, resources kube.ResourceList, d time.Duration) error {
+	if f.WatchUntilReadyError != nil {
+		return f.WatchUntilReadyError
+	}
+	return f.PrintingKubeClient.WatchUntilReadyWithContext(ctx, resources, d)
+}
+
 // Update returns the configured error if set or prints
 func (f *FailingKubeClient) Update(r, modified kube.ResourceList, ignoreMe bool) (*kube.Result, error) {
 	if f.UpdateError != nil {
@@ -90,6 +123,14 @@ func (f *FailingKubeClient) Update(r, modified kube.ResourceList, ignoreMe bool)
 	return f.PrintingKubeClient.Update(r, modified, ignoreMe)
 }
 
+// UpdateWithOptions returns the configured error if set or prints
+func (f *FailingKubeClient) UpdateWithOptions(r, modified kube.ResourceList, ignoreMe bool, opts kube.ApplyOptions) (*kube.Result, error) {
+	if f.UpdateError != nil {
+		return &kube.Result{}, f.UpdateError
+	}
+	return f.PrintingKubeClient.UpdateWithOptions(r, modified, ignoreMe, opts)
+}
+
 // Build returns the configured error if set or prints
 func (f *FailingKubeClient) Build(r io.Reader, _ bool) (kube.ResourceList, error) {
 	if f.BuildError != nil {
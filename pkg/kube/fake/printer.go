@@ -17,6 +17,7 @@ limitations under the License.
 package fake
 
 import (
+	"context"
 	"io"
 	"strings"
 	"time"
@@ -47,6 +48,11 @@ func (p *PrintingKubeClient) Create(resources kube.ResourceList) (*kube.Result,
 	return &kube.Result{Created: resources}, nil
 }
 
+// CreateWithOptions prints the values of what would be created with a real KubeClient.
+func (p *PrintingKubeClient) CreateWithOptions(resources kube.ResourceList, _ kube.ApplyOptions) (*kube.Result, error) {
+	return p.Create(resources)
+}
+
 func (p *PrintingKubeClient) Wait(resources kube.ResourceList, _ time.Duration) error {
 	_, err := io.Copy(p.Out, bufferize(resources))
 	return err
@@ -57,6 +63,22 @@ func (p *PrintingKubeClient) WaitWithJobs(resources kube.ResourceList, _ time.Du
 	return err
 }
 
+// WaitWithContext returns the context error if ctx is done, or prints.
+func (p *PrintingKubeClient) WaitWithContext(ctx context.Context, resources kube.ResourceList, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return p.Wait(resources, d)
+}
+
+// WaitWithJobsWithContext returns the context error if ctx is done, or prints.
+func (p *PrintingKubeClient) WaitWithJobsWithContext(ctx context.Context, resources kube.ResourceList, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return p.WaitWithJobs(resources, d)
+}
+
 // Delete implements KubeClient delete.
 //
 // It only prints out the content to be deleted.
@@ -74,6 +96,14 @@ func (p *PrintingKubeClient) WatchUntilReady(resources kube.ResourceList, _ time
 	return err
 }
 
+// WatchUntilReadyWithContext returns the context error if ctx is done, or prints.
+func (p *PrintingKubeClient) WatchUntilReadyWithContext(ctx context.Context, resources kube.ResourceList, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return p.WatchUntilReady(resources, d)
+}
+
 // Update implements KubeClient Update.
 func (p *PrintingKubeClient) Update(_, modified kube.ResourceList, _ bool) (*kube.Result, error) {
 	_, err := io.Copy(p.Out, bufferize(modified))
@@ -86,6 +116,11 @@ func (p *PrintingKubeClient) Update(_, modified kube.ResourceList, _ bool) (*kub
 	return &kube.Result{Updated: modified}, nil
 }
 
+// UpdateWithOptions implements KubeClient UpdateWithOptions.
+func (p *PrintingKubeClient) UpdateWithOptions(original, modified kube.ResourceList, force bool, _ kube.ApplyOptions) (*kube.Result, error) {
+	return p.Update(original, modified, force)
+}
+
 // Build implements KubeClient Build.
 func (p *PrintingKubeClient) Build(_ io.Reader, _ bool) (kube.ResourceList, error) {
 	return []*resource.Info{}, nil
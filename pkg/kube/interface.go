@@ -17,6 +17,7 @@ limitations under the License.
 package kube
 
 import (
+	"context"
 	"io"
 	"time"
 
@@ -65,4 +66,32 @@ type Interface interface {
 	IsReachable() error
 }
 
+// InterfaceApply is introduced to avoid breaking backwards compatibility for Interface implementers.
+//
+// TODO Helm 4: Remove InterfaceApply and integrate its method(s) into the Interface.
+type InterfaceApply interface {
+	// CreateWithOptions creates one or more resources as configured by opts.
+	CreateWithOptions(resources ResourceList, opts ApplyOptions) (*Result, error)
+
+	// UpdateWithOptions updates one or more resources or creates the resource
+	// if it doesn't exist, as configured by opts.
+	UpdateWithOptions(original, target ResourceList, force bool, opts ApplyOptions) (*Result, error)
+}
+
+// InterfaceWithContext is introduced to avoid breaking backwards compatibility for Interface implementers.
+//
+// Its methods behave like their counterparts in Interface, but return as soon
+// as the given context is done.
+//
+// TODO Helm 4: Remove InterfaceWithContext and make the Interface methods take a context.
+type InterfaceWithContext interface {
+	WaitWithContext(ctx context.Context, resources ResourceList, timeout time.Duration) error
+
+	WaitWithJobsWithContext(ctx context.Context, resources ResourceList, timeout time.Duration) error
+
+	WatchUntilReadyWithContext(ctx context.Context, resources ResourceList, timeout time.Duration) error
+}
+
 var _ Interface = (*Client)(nil)
+var _ InterfaceApply = (*Client)(nil)
+var _ InterfaceWithContext = (*Client)(nil)
@@ -0,0 +1,146 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"context"
+	"fmt"
+
+	batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// ProgressPhase is the state of a resource in a ProgressEvent.
+type ProgressPhase string
+
+const (
+	// ProgressPending means the resource is not ready yet.
+	ProgressPending ProgressPhase = "Pending"
+	// ProgressReady means the resource is ready, or a hook has completed.
+	ProgressReady ProgressPhase = "Ready"
+	// ProgressFailed means the resource has failed and will not become ready.
+	ProgressFailed ProgressPhase = "Failed"
+)
+
+// ProgressEvent describes the state of a resource while Helm waits for it.
+//
+// Events are emitted every time a resource is checked, so consumers should
+// key them by Kind, Namespace and Name and keep the latest one.
+type ProgressEvent struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	// Hook is the hook event the resource was created for, if any.
+	Hook    string        `json:"hook,omitempty"`
+	Phase   ProgressPhase `json:"phase"`
+	Message string        `json:"message,omitempty"`
+}
+
+// ProgressFunc receives progress events.
+type ProgressFunc func(ProgressEvent)
+
+type progressKey struct{}
+
+// WithProgress returns a copy of ctx that carries fn. The waits of
+// InterfaceWithContext report the progress of their resources to the
+// ProgressFunc of their context.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ProgressFromContext returns the ProgressFunc of ctx, or nil if there is
+// none. Implementations of InterfaceWithContext use it to report progress.
+func ProgressFromContext(ctx context.Context) ProgressFunc {
+	fn, _ := ctx.Value(progressKey{}).(ProgressFunc)
+	return fn
+}
+
+// watchProgressEvent returns the progress event of a Job or Pod that is
+// watched until it has completed.
+func watchProgressEvent(info *resource.Info, obj runtime.Object, done bool, err error) ProgressEvent {
+	e := ProgressEvent{
+		Kind:      info.Mapping.GroupVersionKind.Kind,
+		Namespace: info.Namespace,
+		Name:      info.Name,
+		Phase:     ProgressPending,
+	}
+	switch {
+	case err != nil:
+		e.Phase, e.Message = ProgressFailed, err.Error()
+	case done:
+		e.Phase = ProgressReady
+	default:
+		switch o := obj.(type) {
+		case *batch.Job:
+			e.Message = fmt.Sprintf("jobs active: %d, jobs failed: %d, jobs succeeded: %d", o.Status.Active, o.Status.Failed, o.Status.Succeeded)
+		case *v1.Pod:
+			e.Message = string(o.Status.Phase)
+			if problem := podProblem(o); problem != "" {
+				e.Message += ": " + problem
+			}
+		}
+	}
+	return e
+}
+
+// podProblem returns a description of the first container of the pod that
+// is failing to start or keeps crashing, e.g. because its image cannot be
+// pulled. It returns an empty string if there is none.
+func podProblem(pod *v1.Pod) string {
+	statuses := append(append([]v1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, s := range statuses {
+		if w := s.State.Waiting; w != nil && w.Reason != "" && w.Reason != "ContainerCreating" && w.Reason != "PodInitializing" {
+			return describeProblem(pod.Name, s.Name, w.Reason, w.Message)
+		}
+		if t := s.State.Terminated; t != nil && t.ExitCode != 0 {
+			return describeProblem(pod.Name, s.Name, t.Reason, t.Message)
+		}
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodScheduled && c.Status == v1.ConditionFalse && c.Reason != "" {
+			return describeProblem(pod.Name, "", c.Reason, c.Message)
+		}
+	}
+	return ""
+}
+
+func describeProblem(pod, container, reason, message string) string {
+	s := "pod " + pod
+	if container != "" {
+		s += " container " + container
+	}
+	s += ": " + reason
+	if message != "" {
+		s += ": " + message
+	}
+	return s
+}
+
+// jobFailure returns the reason of the Failed condition of the job, if any.
+func jobFailure(job *batch.Job) string {
+	for _, c := range job.Status.Conditions {
+		if c.Type == batch.JobFailed && c.Status == v1.ConditionTrue {
+			if c.Message != "" {
+				return fmt.Sprintf("%s: %s", c.Reason, c.Message)
+			}
+			return c.Reason
+		}
+	}
+	return ""
+}
@@ -0,0 +1,185 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestProgressFromContext(t *testing.T) {
+	if ProgressFromContext(context.Background()) != nil {
+		t.Fatal("expected no ProgressFunc in an empty context")
+	}
+
+	var got []ProgressEvent
+	ctx := WithProgress(context.Background(), func(e ProgressEvent) { got = append(got, e) })
+	ProgressFromContext(ctx)(ProgressEvent{Name: "foo"})
+	if len(got) != 1 || got[0].Name != "foo" {
+		t.Errorf("expected the event to be delivered, got %v", got)
+	}
+}
+
+func TestPodProblem(t *testing.T) {
+	tests := []struct {
+		name   string
+		status corev1.PodStatus
+		want   string
+	}{
+		{
+			name: "running",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "app",
+				State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+			}}},
+		},
+		{
+			name: "creating",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "app",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}},
+			}}},
+		},
+		{
+			name: "image pull",
+			status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "app",
+				State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff", Message: "Back-off pulling image \"nginx:nope\""}},
+			}}},
+			want: `pod foo container app: ImagePullBackOff: Back-off pulling image "nginx:nope"`,
+		},
+		{
+			name: "failed init container",
+			status: corev1.PodStatus{InitContainerStatuses: []corev1.ContainerStatus{{
+				Name:  "init",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: 1, Reason: "Error"}},
+			}}},
+			want: "pod foo container init: Error",
+		},
+		{
+			name: "unschedulable",
+			status: corev1.PodStatus{Conditions: []corev1.PodCondition{{
+				Type:    corev1.PodScheduled,
+				Status:  corev1.ConditionFalse,
+				Reason:  "Unschedulable",
+				Message: "0/3 nodes are available",
+			}}},
+			want: "pod foo: Unschedulable: 0/3 nodes are available",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "foo"}, Status: tt.status}
+			if got := podProblem(pod); got != tt.want {
+				t.Errorf("podProblem() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatchProgressEvent(t *testing.T) {
+	info := &resource.Info{
+		Name:      "foo",
+		Namespace: defaultNamespace,
+		Mapping:   &meta.RESTMapping{GroupVersionKind: batchv1.SchemeGroupVersion.WithKind("Job")},
+	}
+	job := &batchv1.Job{Status: batchv1.JobStatus{Active: 1}}
+
+	e := watchProgressEvent(info, job, false, nil)
+	if e.Kind != "Job" || e.Name != "foo" || e.Phase != ProgressPending {
+		t.Errorf("unexpected event %+v", e)
+	}
+	if e.Message != "jobs active: 1, jobs failed: 0, jobs succeeded: 0" {
+		t.Errorf("unexpected message %q", e.Message)
+	}
+	if e := watchProgressEvent(info, job, true, nil); e.Phase != ProgressReady {
+		t.Errorf("expected phase %s, got %s", ProgressReady, e.Phase)
+	}
+	if e := watchProgressEvent(info, job, true, errors.New("job failed: BackoffLimitExceeded")); e.Phase != ProgressFailed || e.Message != "job failed: BackoffLimitExceeded" {
+		t.Errorf("unexpected event %+v", e)
+	}
+}
+
+func Test_waiter_report(t *testing.T) {
+	pod := newPodWithCondition("foo", corev1.ConditionFalse)
+	pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:  "app",
+		State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+	}}
+
+	var got []ProgressEvent
+	w := &waiter{
+		c:        fake.NewSimpleClientset(pod),
+		log:      nopLogger,
+		progress: func(e ProgressEvent) { got = append(got, e) },
+	}
+	info := &resource.Info{
+		Name:      "foo",
+		Namespace: defaultNamespace,
+		Object:    pod,
+		Mapping:   &meta.RESTMapping{GroupVersionKind: corev1.SchemeGroupVersion.WithKind("Pod")},
+	}
+
+	ready, err := w.isReady(context.Background(), info, nil, false)
+	if ready || err != nil {
+		t.Fatalf("expected pod not to be ready, got %v, %v", ready, err)
+	}
+	w.report(context.Background(), info, ready, err)
+
+	if len(got) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(got))
+	}
+	e := got[0]
+	if e.Kind != "Pod" || e.Name != "foo" || e.Phase != ProgressPending {
+		t.Errorf("unexpected event %+v", e)
+	}
+	if !strings.HasPrefix(e.Message, "Pod is not ready: default/foo") || !strings.Contains(e.Message, "CrashLoopBackOff") {
+		t.Errorf("unexpected message %q", e.Message)
+	}
+}
+
+func Test_waiter_reportFailedJob(t *testing.T) {
+	job := newJob("foo", 1, 1, 0, 2)
+
+	var got []ProgressEvent
+	w := &waiter{
+		c:        fake.NewSimpleClientset(job),
+		log:      nopLogger,
+		progress: func(e ProgressEvent) { got = append(got, e) },
+	}
+	info := &resource.Info{
+		Name:      "foo",
+		Namespace: defaultNamespace,
+		Object:    job,
+		Mapping:   &meta.RESTMapping{GroupVersionKind: batchv1.SchemeGroupVersion.WithKind("Job")},
+	}
+
+	ready, err := w.isReady(context.Background(), info, nil, true)
+	w.report(context.Background(), info, ready, err)
+	if len(got) != 1 || got[0].Phase != ProgressFailed {
+		t.Errorf("expected a failed event, got %+v", got)
+	}
+}
@@ -0,0 +1,277 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// ReadinessAnno is the annotation name for a readiness rule.
+//
+// A readiness rule consists of one or more lines, each comparing a JSONPath
+// expression evaluated against the live object with an expected value:
+//
+//	helm.sh/readiness: |
+//	  {.status.phase}=Healthy
+//	  {.status.conditions[?(@.type=="Degraded")].status}!=True
+//
+// The object is ready when every line holds; the rule replaces the built-in
+// readiness checks of its kind. Set on a CustomResourceDefinition,
+// the rule applies to all objects of that kind, unless an object declares its
+// own rule. This includes CRDs installed from the crds/ directory of a chart or
+// by other means, which are looked up in the cluster.
+const ReadinessAnno = "helm.sh/readiness"
+
+// readinessCondition is one line of a readiness rule.
+type readinessCondition struct {
+	expr   string
+	path   *jsonpath.JSONPath
+	value  string
+	negate bool
+}
+
+// parseReadinessRule parses the value of the readiness annotation.
+func parseReadinessRule(rule string) ([]readinessCondition, error) {
+	var conds []readinessCondition
+	for _, line := range strings.Split(rule, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		// The expression may itself contain '=' in filters, so split after
+		// its closing brace.
+		end := strings.LastIndex(line, "}")
+		if !strings.HasPrefix(line, "{") || end < 0 {
+			return nil, errors.Errorf("invalid readiness rule %q: expected {<jsonpath>}=<value>", line)
+		}
+		cond := readinessCondition{expr: line[:end+1]}
+		switch op := strings.TrimSpace(line[end+1:]); {
+		case strings.HasPrefix(op, "!="):
+			cond.negate = true
+			cond.value = strings.TrimSpace(op[2:])
+		case strings.HasPrefix(op, "=="):
+			cond.value = strings.TrimSpace(op[2:])
+		case strings.HasPrefix(op, "="):
+			cond.value = strings.TrimSpace(op[1:])
+		default:
+			return nil, errors.Errorf("invalid readiness rule %q: expected {<jsonpath>}=<value>", line)
+		}
+		cond.path = jsonpath.New(ReadinessAnno).AllowMissingKeys(true)
+		if err := cond.path.Parse(cond.expr); err != nil {
+			return nil, errors.Wrapf(err, "invalid readiness rule %q", line)
+		}
+		conds = append(conds, cond)
+	}
+	if len(conds) == 0 {
+		return nil, errors.New("readiness rule is empty")
+	}
+	return conds, nil
+}
+
+// evaluate reports whether obj satisfies the condition. If it does not, the
+// actual value is returned.
+func (c readinessCondition) evaluate(obj map[string]interface{}) (bool, string, error) {
+	var buf bytes.Buffer
+	if err := c.path.Execute(&buf, obj); err != nil {
+		return false, "", errors.Wrapf(err, "failed to evaluate readiness rule %s", c.expr)
+	}
+	actual := strings.TrimSpace(buf.String())
+	return (actual == c.value) != c.negate, actual, nil
+}
+
+// readinessRules returns the parsed readiness rules of the resources, keyed by
+// resource. Rules declared on CustomResourceDefinitions in the list apply to
+// all resources of their kind.
+func readinessRules(resources ResourceList) (map[*resource.Info][]readinessCondition, error) {
+	kindRules := map[schema.GroupKind]string{}
+	for _, info := range resources {
+		rule := readinessAnnotation(info.Object)
+		if rule == "" || !isCRD(info) {
+			continue
+		}
+		u, err := toUnstructured(info.Object)
+		if err != nil {
+			return nil, err
+		}
+		group, _, _ := unstructured.NestedString(u, "spec", "group")
+		kind, _, _ := unstructured.NestedString(u, "spec", "names", "kind")
+		kindRules[schema.GroupKind{Group: group, Kind: kind}] = rule
+	}
+
+	rules := map[*resource.Info][]readinessCondition{}
+	for _, info := range resources {
+		rule := readinessAnnotation(info.Object)
+		if rule == "" || isCRD(info) {
+			rule = kindRules[groupVersionKind(info).GroupKind()]
+		}
+		if rule == "" {
+			continue
+		}
+		conds, err := parseReadinessRule(rule)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s %q", groupVersionKind(info).Kind, info.Name)
+		}
+		rules[info] = conds
+	}
+	return rules, nil
+}
+
+// crdsPath is the API path of the CustomResourceDefinitions in the cluster.
+const crdsPath = "/apis/apiextensions.k8s.io/v1/customresourcedefinitions"
+
+// clusterReadinessRules adds to rules the rules declared on the
+// CustomResourceDefinitions in the cluster for the custom resources that have
+// none yet. Each CRD is fetched once.
+func (w *waiter) clusterReadinessRules(ctx context.Context, resources ResourceList, rules map[*resource.Info][]readinessCondition) error {
+	if w.crds == nil {
+		return nil
+	}
+	kindRules := map[schema.GroupResource]string{}
+	for _, info := range resources {
+		if _, ok := rules[info]; ok || info.Mapping == nil || isCRD(info) {
+			continue
+		}
+		// Built-in API groups have no dot, and no CRD to look up
+		gr := info.Mapping.Resource.GroupResource()
+		if !strings.Contains(gr.Group, ".") {
+			continue
+		}
+		rule, ok := kindRules[gr]
+		if !ok {
+			var err error
+			if rule, err = w.crdReadinessRule(ctx, gr); err != nil {
+				return err
+			}
+			kindRules[gr] = rule
+		}
+		if rule == "" {
+			continue
+		}
+		conds, err := parseReadinessRule(rule)
+		if err != nil {
+			return errors.Wrapf(err, "CustomResourceDefinition %q", gr.String())
+		}
+		rules[info] = conds
+	}
+	return nil
+}
+
+// crdReadinessRule returns the readiness rule of the CustomResourceDefinition
+// of the resource, or an empty string if there is none or it cannot be read.
+func (w *waiter) crdReadinessRule(ctx context.Context, gr schema.GroupResource) (string, error) {
+	body, err := w.crds.Get().AbsPath(crdsPath, gr.String()).Do(ctx).Raw()
+	switch {
+	case apierrors.IsNotFound(err):
+		return "", nil
+	case apierrors.IsForbidden(err):
+		w.log("cannot read the readiness rule of CustomResourceDefinition %q: %s", gr.String(), err)
+		return "", nil
+	case err != nil:
+		return "", errors.Wrapf(err, "failed to get CustomResourceDefinition %q", gr.String())
+	}
+	var crd metav1.PartialObjectMetadata
+	if err := json.Unmarshal(body, &crd); err != nil {
+		return "", errors.Wrapf(err, "failed to decode CustomResourceDefinition %q", gr.String())
+	}
+	return crd.Annotations[ReadinessAnno], nil
+}
+
+// objectReady evaluates the readiness of an arbitrary object from the
+// conventions most controllers follow for their status:
+//
+//   - status.observedGeneration must have caught up with metadata.generation
+//   - the Stalled and Reconciling conditions must not be True
+//   - the Ready condition, or if absent the Available condition, must be True
+//
+// An object without a status is ready, as its kind has nothing to wait for.
+// If the object is not ready, the reason is returned.
+func objectReady(obj map[string]interface{}) (bool, string) {
+	generation, _, _ := unstructured.NestedInt64(obj, "metadata", "generation")
+	observed, found, _ := unstructured.NestedInt64(obj, "status", "observedGeneration")
+	if found && observed < generation {
+		return false, fmt.Sprintf("observed generation %d is behind generation %d", observed, generation)
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(obj, "status", "conditions")
+	status := map[string]string{}
+	messages := map[string]string{}
+	for _, c := range conditions {
+		cond, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		t, _, _ := unstructured.NestedString(cond, "type")
+		status[t], _, _ = unstructured.NestedString(cond, "status")
+		messages[t], _, _ = unstructured.NestedString(cond, "message")
+	}
+
+	for _, t := range []string{"Stalled", "Reconciling"} {
+		if status[t] == "True" {
+			return false, fmt.Sprintf("%s: %s", t, messages[t])
+		}
+	}
+	for _, t := range []string{"Ready", "Available"} {
+		if s, ok := status[t]; ok {
+			if s != "True" {
+				return false, fmt.Sprintf("%s is %s: %s", t, s, messages[t])
+			}
+			return true, ""
+		}
+	}
+	return true, ""
+}
+
+func readinessAnnotation(obj runtime.Object) string {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	return accessor.GetAnnotations()[ReadinessAnno]
+}
+
+func groupVersionKind(info *resource.Info) schema.GroupVersionKind {
+	if info.Mapping != nil {
+		return info.Mapping.GroupVersionKind
+	}
+	return info.Object.GetObjectKind().GroupVersionKind()
+}
+
+func isCRD(info *resource.Info) bool {
+	gvk := groupVersionKind(info)
+	return gvk.Group == "apiextensions.k8s.io" && gvk.Kind == "CustomResourceDefinition"
+}
+
+func toUnstructured(obj runtime.Object) (map[string]interface{}, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u.Object, nil
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+}
@@ -0,0 +1,353 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/fake"
+	restfake "k8s.io/client-go/rest/fake"
+)
+
+var rolloutGVK = schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}
+
+func newUnstructuredInfo(gvk schema.GroupVersionKind, name string, obj map[string]interface{}) *resource.Info {
+	u := &unstructured.Unstructured{Object: obj}
+	u.SetGroupVersionKind(gvk)
+	u.SetName(name)
+	u.SetNamespace(defaultNamespace)
+	return &resource.Info{
+		Name:      name,
+		Namespace: defaultNamespace,
+		Object:    u,
+		Mapping: &meta.RESTMapping{
+			GroupVersionKind: gvk,
+			Resource:         gvk.GroupVersion().WithResource("rollouts"),
+			Scope:            meta.RESTScopeNamespace,
+		},
+	}
+}
+
+func TestParseReadinessRule(t *testing.T) {
+	conds, err := parseReadinessRule(`
+{.status.phase}=Healthy
+{.status.conditions[?(@.type=="Degraded")].status} != True
+`)
+	if err != nil {
+		t.Fatalf("failed to parse readiness rule: %v", err)
+	}
+	if len(conds) != 2 {
+		t.Fatalf("expected 2 conditions, got %d", len(conds))
+	}
+	if conds[0].expr != "{.status.phase}" || conds[0].value != "Healthy" || conds[0].negate {
+		t.Errorf("unexpected first condition %+v", conds[0])
+	}
+	if conds[1].expr != `{.status.conditions[?(@.type=="Degraded")].status}` || conds[1].value != "True" || !conds[1].negate {
+		t.Errorf("unexpected second condition %+v", conds[1])
+	}
+
+	for _, rule := range []string{"", ".status.phase=Healthy", "{.status.phase}", "{.status.phase}>1", "{.status[}=x"} {
+		if _, err := parseReadinessRule(rule); err == nil {
+			t.Errorf("expected an error parsing readiness rule %q", rule)
+		}
+	}
+}
+
+func TestReadinessConditionEvaluate(t *testing.T) {
+	conds, err := parseReadinessRule("{.status.phase}=Healthy\n{.status.conditions[?(@.type==\"Degraded\")].status}!=True")
+	if err != nil {
+		t.Fatalf("failed to parse readiness rule: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		status map[string]interface{}
+		want   []bool
+	}{
+		{
+			name:   "healthy",
+			status: map[string]interface{}{"phase": "Healthy"},
+			want:   []bool{true, true},
+		},
+		{
+			name: "degraded",
+			status: map[string]interface{}{
+				"phase":      "Progressing",
+				"conditions": []interface{}{map[string]interface{}{"type": "Degraded", "status": "True"}},
+			},
+			want: []bool{false, false},
+		},
+		{
+			name: "missing status",
+			want: []bool{false, true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := map[string]interface{}{}
+			if tt.status != nil {
+				obj["status"] = tt.status
+			}
+			for i, cond := range conds {
+				got, _, err := cond.evaluate(obj)
+				if err != nil {
+					t.Fatalf("failed to evaluate %s: %v", cond.expr, err)
+				}
+				if got != tt.want[i] {
+					t.Errorf("%s = %v, want %v", cond.expr, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestObjectReady(t *testing.T) {
+	condition := func(t, status string) interface{} {
+		return map[string]interface{}{"type": t, "status": status}
+	}
+	tests := []struct {
+		name string
+		obj  map[string]interface{}
+		want bool
+	}{
+		{
+			name: "no status",
+			obj:  map[string]interface{}{},
+			want: true,
+		},
+		{
+			name: "stale observed generation",
+			obj: map[string]interface{}{
+				"metadata": map[string]interface{}{"generation": int64(2)},
+				"status":   map[string]interface{}{"observedGeneration": int64(1), "conditions": []interface{}{condition("Ready", "True")}},
+			},
+			want: false,
+		},
+		{
+			name: "ready",
+			obj: map[string]interface{}{
+				"metadata": map[string]interface{}{"generation": int64(2)},
+				"status":   map[string]interface{}{"observedGeneration": int64(2), "conditions": []interface{}{condition("Ready", "True")}},
+			},
+			want: true,
+		},
+		{
+			name: "not ready",
+			obj:  map[string]interface{}{"status": map[string]interface{}{"conditions": []interface{}{condition("Ready", "False")}}},
+			want: false,
+		},
+		{
+			name: "ready takes precedence over available",
+			obj:  map[string]interface{}{"status": map[string]interface{}{"conditions": []interface{}{condition("Available", "True"), condition("Ready", "Unknown")}}},
+			want: false,
+		},
+		{
+			name: "available",
+			obj:  map[string]interface{}{"status": map[string]interface{}{"conditions": []interface{}{condition("Available", "True")}}},
+			want: true,
+		},
+		{
+			name: "reconciling",
+			obj:  map[string]interface{}{"status": map[string]interface{}{"conditions": []interface{}{condition("Ready", "True"), condition("Reconciling", "True")}}},
+			want: false,
+		},
+		{
+			name: "stalled",
+			obj:  map[string]interface{}{"status": map[string]interface{}{"conditions": []interface{}{condition("Stalled", "True")}}},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, reason := objectReady(tt.obj); got != tt.want {
+				t.Errorf("objectReady() = %v (%s), want %v", got, reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadinessRules(t *testing.T) {
+	crdGVK := schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}
+	crd := newUnstructuredInfo(crdGVK, "rollouts.argoproj.io", map[string]interface{}{
+		"spec": map[string]interface{}{
+			"group": "argoproj.io",
+			"names": map[string]interface{}{"kind": "Rollout"},
+		},
+	})
+	crd.Object.(*unstructured.Unstructured).SetAnnotations(map[string]string{ReadinessAnno: "{.status.phase}=Healthy"})
+
+	inherited := newUnstructuredInfo(rolloutGVK, "inherited", map[string]interface{}{})
+	own := newUnstructuredInfo(rolloutGVK, "own", map[string]interface{}{})
+	own.Object.(*unstructured.Unstructured).SetAnnotations(map[string]string{ReadinessAnno: "{.status.phase}=Paused"})
+	other := newUnstructuredInfo(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, "other", map[string]interface{}{})
+
+	rules, err := readinessRules(ResourceList{crd, inherited, own, other})
+	if err != nil {
+		t.Fatalf("failed to collect readiness rules: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("expected rules for 2 resources, got %d", len(rules))
+	}
+	if got := rules[inherited][0].value; got != "Healthy" {
+		t.Errorf("expected the rule of the CRD to apply, got %q", got)
+	}
+	if got := rules[own][0].value; got != "Paused" {
+		t.Errorf("expected the rule of the object to apply, got %q", got)
+	}
+
+	other.Object.(*unstructured.Unstructured).SetAnnotations(map[string]string{ReadinessAnno: "phase=Healthy"})
+	if _, err := readinessRules(ResourceList{other}); err == nil {
+		t.Error("expected an error for an invalid readiness rule")
+	}
+}
+
+func Test_waiter_clusterReadinessRules(t *testing.T) {
+	var requested []string
+	crds := &restfake.RESTClient{
+		NegotiatedSerializer: unstructuredSerializer,
+		Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			requested = append(requested, req.URL.Path)
+			header := http.Header{}
+			header.Set("Content-Type", runtime.ContentTypeJSON)
+			if req.URL.Path != crdsPath+"/rollouts.argoproj.io" {
+				body := `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`
+				return &http.Response{StatusCode: http.StatusNotFound, Header: header, Body: ioutil.NopCloser(bytes.NewBufferString(body))}, nil
+			}
+			body := `{"apiVersion":"apiextensions.k8s.io/v1","kind":"CustomResourceDefinition","metadata":{"name":"rollouts.argoproj.io","annotations":{"helm.sh/readiness":"{.status.phase}=Healthy"}}}`
+			return &http.Response{StatusCode: http.StatusOK, Header: header, Body: ioutil.NopCloser(bytes.NewBufferString(body))}, nil
+		}),
+	}
+	w := &waiter{
+		c:    fake.NewSimpleClientset(),
+		log:  nopLogger,
+		crds: crds,
+	}
+
+	first := newUnstructuredInfo(rolloutGVK, "first", map[string]interface{}{})
+	second := newUnstructuredInfo(rolloutGVK, "second", map[string]interface{}{})
+	own := newUnstructuredInfo(rolloutGVK, "own", map[string]interface{}{})
+	own.Object.(*unstructured.Unstructured).SetAnnotations(map[string]string{ReadinessAnno: "{.status.phase}=Paused"})
+	other := newUnstructuredInfo(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, "other", map[string]interface{}{})
+	builtin := newUnstructuredInfo(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, "builtin", map[string]interface{}{})
+	resources := ResourceList{first, second, own, other, builtin}
+
+	rules, err := readinessRules(resources)
+	if err != nil {
+		t.Fatalf("failed to collect readiness rules: %v", err)
+	}
+	if err := w.clusterReadinessRules(context.Background(), resources, rules); err != nil {
+		t.Fatalf("failed to look up readiness rules: %v", err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("expected rules for 3 resources, got %d", len(rules))
+	}
+	for _, info := range []*resource.Info{first, second} {
+		if got := rules[info][0].value; got != "Healthy" {
+			t.Errorf("expected the rule of the CRD in the cluster to apply to %s, got %q", info.Name, got)
+		}
+	}
+	if got := rules[own][0].value; got != "Paused" {
+		t.Errorf("expected the rule of the object to apply, got %q", got)
+	}
+	want := []string{crdsPath + "/rollouts.argoproj.io", crdsPath + "/rollouts.example.com"}
+	if !reflect.DeepEqual(requested, want) {
+		t.Errorf("expected the CRDs to be fetched once each, got %v", requested)
+	}
+}
+
+func Test_waiter_customResourceReady(t *testing.T) {
+	w := &waiter{
+		c:   fake.NewSimpleClientset(),
+		log: nopLogger,
+	}
+
+	tests := []struct {
+		name       string
+		annotation string
+		live       map[string]interface{}
+		ready      bool
+	}{
+		{
+			name:  "ready by conditions",
+			live:  map[string]interface{}{"status": map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": "True"}}}},
+			ready: true,
+		},
+		{
+			name: "not ready by conditions",
+			live: map[string]interface{}{"status": map[string]interface{}{"conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": "False"}}}},
+		},
+		{
+			name:       "ready by rule",
+			annotation: "{.status.phase}=Healthy",
+			live:       map[string]interface{}{"status": map[string]interface{}{"phase": "Healthy"}},
+			ready:      true,
+		},
+		{
+			name:       "not ready by rule",
+			annotation: "{.status.phase}=Healthy",
+			live:       map[string]interface{}{"status": map[string]interface{}{"phase": "Progressing", "conditions": []interface{}{map[string]interface{}{"type": "Ready", "status": "True"}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := newUnstructuredInfo(rolloutGVK, "foo", map[string]interface{}{})
+			if tt.annotation != "" {
+				info.Object.(*unstructured.Unstructured).SetAnnotations(map[string]string{ReadinessAnno: tt.annotation})
+			}
+			live := newUnstructuredInfo(rolloutGVK, "foo", tt.live).Object
+			info.Client = &restfake.RESTClient{
+				NegotiatedSerializer: unstructuredSerializer,
+				Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+					body, err := live.(*unstructured.Unstructured).MarshalJSON()
+					if err != nil {
+						return nil, err
+					}
+					header := http.Header{}
+					header.Set("Content-Type", runtime.ContentTypeJSON)
+					return &http.Response{StatusCode: http.StatusOK, Header: header, Body: ioutil.NopCloser(bytes.NewReader(body))}, nil
+				}),
+			}
+
+			rules, err := readinessRules(ResourceList{info})
+			if err != nil {
+				t.Fatalf("failed to collect readiness rules: %v", err)
+			}
+			var ready bool
+			if conds, ok := rules[info]; ok {
+				ready, err = w.ruleReady(info, conds)
+			} else {
+				ready, err = w.unstructuredReady(info)
+			}
+			if err != nil {
+				t.Fatalf("failed to evaluate readiness: %v", err)
+			}
+			if ready != tt.ready {
+				t.Errorf("ready = %v, want %v", ready, tt.ready)
+			}
+		})
+	}
+}
@@ -31,12 +31,16 @@ import (
 	apiextv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	watchtools "k8s.io/client-go/tools/watch"
 
 	deploymentutil "helm.sh/helm/v3/internal/third_party/k8s.io/kubernetes/deployment/util"
 )
@@ -45,115 +49,262 @@ type waiter struct {
 	c       kubernetes.Interface
 	timeout time.Duration
 	log     func(string, ...interface{})
+	// progress, if set, receives an event for every resource checked.
+	progress ProgressFunc
+	// crds, if set, is used to look up the readiness rules declared on the
+	// CustomResourceDefinitions in the cluster.
+	crds rest.Interface
+
+	// reason and failed describe the resource last found not to be ready.
+	reason string
+	failed bool
 }
 
 // waitForResources polls to get the current status of all pods, PVCs, Services and
-// Jobs(optional) until all are ready or a timeout is reached
-func (w *waiter) waitForResources(created ResourceList, waitForJobsEnabled bool) error {
+// Jobs(optional) until all are ready, a timeout is reached or ctx is done
+func (w *waiter) waitForResources(ctx context.Context, created ResourceList, waitForJobsEnabled bool) error {
 	w.log("beginning wait for %d resources with timeout of %v", len(created), w.timeout)
 
-	return wait.Poll(2*time.Second, w.timeout, func() (bool, error) {
+	rules, err := readinessRules(created)
+	if err != nil {
+		return err
+	}
+	if err := w.clusterReadinessRules(ctx, created, rules); err != nil {
+		return err
+	}
+
+	// A timeout of 0 waits until ctx is done.
+	pollCtx, cancel := watchtools.ContextWithOptionalTimeout(ctx, w.timeout)
+	defer cancel()
+
+	err = wait.PollUntil(2*time.Second, func() (bool, error) {
 		for _, v := range created {
-			var (
-				// This defaults to true, otherwise we get to a point where
-				// things will always return false unless one of the objects
-				// that manages pods has been hit
-				ok  = true
-				err error
-			)
-			switch value := AsVersioned(v).(type) {
-			case *corev1.Pod:
-				pod, err := w.c.CoreV1().Pods(v.Namespace).Get(context.Background(), v.Name, metav1.GetOptions{})
-				if err != nil || !w.isPodReady(pod) {
-					return false, err
-				}
-			case *batchv1.Job:
-				if waitForJobsEnabled {
-					job, err := w.c.BatchV1().Jobs(v.Namespace).Get(context.Background(), v.Name, metav1.GetOptions{})
-					if err != nil || !w.jobReady(job) {
-						return false, err
-					}
-				}
-			case *appsv1.Deployment, *appsv1beta1.Deployment, *appsv1beta2.Deployment, *extensionsv1beta1.Deployment:
-				currentDeployment, err := w.c.AppsV1().Deployments(v.Namespace).Get(context.Background(), v.Name, metav1.GetOptions{})
-				if err != nil {
-					return false, err
-				}
-				// If paused deployment will never be ready
-				if currentDeployment.Spec.Paused {
-					continue
-				}
-				// Find RS associated with deployment
-				newReplicaSet, err := deploymentutil.GetNewReplicaSet(currentDeployment, w.c.AppsV1())
-				if err != nil || newReplicaSet == nil {
-					return false, err
-				}
-				if !w.deploymentReady(newReplicaSet, currentDeployment) {
-					return false, nil
-				}
-			case *corev1.PersistentVolumeClaim:
-				claim, err := w.c.CoreV1().PersistentVolumeClaims(v.Namespace).Get(context.Background(), v.Name, metav1.GetOptions{})
-				if err != nil {
-					return false, err
-				}
-				if !w.volumeReady(claim) {
-					return false, nil
-				}
-			case *corev1.Service:
-				svc, err := w.c.CoreV1().Services(v.Namespace).Get(context.Background(), v.Name, metav1.GetOptions{})
-				if err != nil {
-					return false, err
-				}
-				if !w.serviceReady(svc) {
-					return false, nil
-				}
-			case *extensionsv1beta1.DaemonSet, *appsv1.DaemonSet, *appsv1beta2.DaemonSet:
-				ds, err := w.c.AppsV1().DaemonSets(v.Namespace).Get(context.Background(), v.Name, metav1.GetOptions{})
-				if err != nil {
-					return false, err
-				}
-				if !w.daemonSetReady(ds) {
-					return false, nil
-				}
-			case *apiextv1beta1.CustomResourceDefinition:
-				if err := v.Get(); err != nil {
-					return false, err
-				}
-				crd := &apiextv1beta1.CustomResourceDefinition{}
-				if err := scheme.Scheme.Convert(v.Object, crd, nil); err != nil {
-					return false, err
-				}
-				if !w.crdBetaReady(*crd) {
-					return false, nil
-				}
-			case *apiextv1.CustomResourceDefinition:
-				if err := v.Get(); err != nil {
-					return false, err
-				}
-				crd := &apiextv1.CustomResourceDefinition{}
-				if err := scheme.Scheme.Convert(v.Object, crd, nil); err != nil {
-					return false, err
-				}
-				if !w.crdReady(*crd) {
-					return false, nil
-				}
-			case *appsv1.StatefulSet, *appsv1beta1.StatefulSet, *appsv1beta2.StatefulSet:
-				sts, err := w.c.AppsV1().StatefulSets(v.Namespace).Get(context.Background(), v.Name, metav1.GetOptions{})
-				if err != nil {
-					return false, err
-				}
-				if !w.statefulSetReady(sts) {
-					return false, nil
-				}
-			case *corev1.ReplicationController, *extensionsv1beta1.ReplicaSet, *appsv1beta2.ReplicaSet, *appsv1.ReplicaSet:
-				ok, err = w.podsReadyForObject(v.Namespace, value)
-			}
-			if !ok || err != nil {
+			ready, err := w.isReady(pollCtx, v, rules[v], waitForJobsEnabled)
+			w.report(pollCtx, v, ready, err)
+			if !ready || err != nil {
 				return false, err
 			}
 		}
 		return true, nil
-	})
+	}, pollCtx.Done())
+	if err == wait.ErrWaitTimeout && ctx.Err() != nil {
+		// The caller gave up before the timeout was reached.
+		return ctx.Err()
+	}
+	return err
+}
+
+// isReady checks whether the resource is ready, according to its readiness
+// rule if it has one. If it is not, the reason is left in w.reason.
+func (w *waiter) isReady(ctx context.Context, v *resource.Info, conds []readinessCondition, waitForJobsEnabled bool) (bool, error) {
+	w.reason, w.failed = "", false
+	if conds != nil {
+		return w.ruleReady(v, conds)
+	}
+
+	var (
+		// This defaults to true, otherwise we get to a point where
+		// things will always return false unless one of the objects
+		// that manages pods has been hit
+		ok  = true
+		err error
+	)
+	switch value := AsVersioned(v).(type) {
+	case *corev1.Pod:
+		pod, err := w.c.CoreV1().Pods(v.Namespace).Get(ctx, v.Name, metav1.GetOptions{})
+		if err != nil || !w.isPodReady(pod) {
+			return false, err
+		}
+	case *batchv1.Job:
+		if waitForJobsEnabled {
+			job, err := w.c.BatchV1().Jobs(v.Namespace).Get(ctx, v.Name, metav1.GetOptions{})
+			if err != nil || !w.jobReady(job) {
+				return false, err
+			}
+		}
+	case *appsv1.Deployment, *appsv1beta1.Deployment, *appsv1beta2.Deployment, *extensionsv1beta1.Deployment:
+		currentDeployment, err := w.c.AppsV1().Deployments(v.Namespace).Get(ctx, v.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		// If paused deployment will never be ready
+		if currentDeployment.Spec.Paused {
+			return true, nil
+		}
+		// Find RS associated with deployment
+		newReplicaSet, err := deploymentutil.GetNewReplicaSet(currentDeployment, w.c.AppsV1())
+		if err != nil || newReplicaSet == nil {
+			return false, err
+		}
+		if !w.deploymentReady(newReplicaSet, currentDeployment) {
+			return false, nil
+		}
+	case *corev1.PersistentVolumeClaim:
+		claim, err := w.c.CoreV1().PersistentVolumeClaims(v.Namespace).Get(ctx, v.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if !w.volumeReady(claim) {
+			return false, nil
+		}
+	case *corev1.Service:
+		svc, err := w.c.CoreV1().Services(v.Namespace).Get(ctx, v.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if !w.serviceReady(svc) {
+			return false, nil
+		}
+	case *extensionsv1beta1.DaemonSet, *appsv1.DaemonSet, *appsv1beta2.DaemonSet:
+		ds, err := w.c.AppsV1().DaemonSets(v.Namespace).Get(ctx, v.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if !w.daemonSetReady(ds) {
+			return false, nil
+		}
+	case *apiextv1beta1.CustomResourceDefinition:
+		if err := v.Get(); err != nil {
+			return false, err
+		}
+		crd := &apiextv1beta1.CustomResourceDefinition{}
+		if err := scheme.Scheme.Convert(v.Object, crd, nil); err != nil {
+			return false, err
+		}
+		if !w.crdBetaReady(*crd) {
+			return false, nil
+		}
+	case *apiextv1.CustomResourceDefinition:
+		if err := v.Get(); err != nil {
+			return false, err
+		}
+		crd := &apiextv1.CustomResourceDefinition{}
+		if err := scheme.Scheme.Convert(v.Object, crd, nil); err != nil {
+			return false, err
+		}
+		if !w.crdReady(*crd) {
+			return false, nil
+		}
+	case *appsv1.StatefulSet, *appsv1beta1.StatefulSet, *appsv1beta2.StatefulSet:
+		sts, err := w.c.AppsV1().StatefulSets(v.Namespace).Get(ctx, v.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if !w.statefulSetReady(sts) {
+			return false, nil
+		}
+	case *corev1.ReplicationController, *extensionsv1beta1.ReplicaSet, *appsv1beta2.ReplicaSet, *appsv1.ReplicaSet:
+		ok, err = w.podsReadyForObject(v.Namespace, value)
+	case *unstructured.Unstructured:
+		// Kinds unknown to Helm, e.g. custom resources
+		ok, err = w.unstructuredReady(v)
+	}
+	return ok, err
+}
+
+// notReady logs why a resource is not ready and records it for the progress
+// event of the resource.
+func (w *waiter) notReady(format string, args ...interface{}) {
+	w.log(format, args...)
+	w.reason = fmt.Sprintf(format, args...)
+}
+
+// report emits the progress event of a resource that has been checked.
+func (w *waiter) report(ctx context.Context, v *resource.Info, ready bool, err error) {
+	if w.progress == nil {
+		return
+	}
+	e := ProgressEvent{
+		Kind:      groupVersionKind(v).Kind,
+		Namespace: v.Namespace,
+		Name:      v.Name,
+		Phase:     ProgressPending,
+		Message:   w.reason,
+	}
+	switch {
+	case err != nil:
+		e.Phase, e.Message = ProgressFailed, err.Error()
+	case ready:
+		e.Phase, e.Message = ProgressReady, ""
+	default:
+		if w.failed {
+			e.Phase = ProgressFailed
+		}
+		if problem := w.podProblem(ctx, v); problem != "" {
+			if e.Message != "" {
+				e.Message += ": "
+			}
+			e.Message += problem
+		}
+	}
+	w.progress(e)
+}
+
+// podProblem looks for a problem with the pods of a resource that is not
+// ready, such as a crashing container or an image that cannot be pulled.
+func (w *waiter) podProblem(ctx context.Context, v *resource.Info) string {
+	var pods []corev1.Pod
+	switch obj := AsVersioned(v).(type) {
+	case *corev1.Pod:
+		pod, err := w.c.CoreV1().Pods(v.Namespace).Get(ctx, v.Name, metav1.GetOptions{})
+		if err != nil {
+			return ""
+		}
+		pods = []corev1.Pod{*pod}
+	case *unstructured.Unstructured:
+		return ""
+	default:
+		var err error
+		if pods, err = w.podsforObject(v.Namespace, obj); err != nil {
+			return ""
+		}
+	}
+	for i := range pods {
+		if problem := podProblem(&pods[i]); problem != "" {
+			return problem
+		}
+	}
+	return ""
+}
+
+// ruleReady evaluates the readiness rule declared for the resource against
+// its live state.
+func (w *waiter) ruleReady(v *resource.Info, conds []readinessCondition) (bool, error) {
+	if err := v.Get(); err != nil {
+		return false, err
+	}
+	obj, err := toUnstructured(v.Object)
+	if err != nil {
+		return false, err
+	}
+	for _, cond := range conds {
+		ok, actual, err := cond.evaluate(obj)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			w.notReady("Resource is not ready: %s/%s: %s is %q", v.Namespace, v.Name, cond.expr, actual)
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// unstructuredReady evaluates the readiness of a resource of a kind Helm has
+// no built-in knowledge of from the conventional fields of its status.
+func (w *waiter) unstructuredReady(v *resource.Info) (bool, error) {
+	if err := v.Get(); err != nil {
+		return false, err
+	}
+	obj, err := toUnstructured(v.Object)
+	if err != nil {
+		return false, err
+	}
+	if ok, reason := objectReady(obj); !ok {
+		w.notReady("Resource is not ready: %s/%s: %s", v.Namespace, v.Name, reason)
+		return false, nil
+	}
+	return true, nil
 }
 
 func (w *waiter) podsReadyForObject(namespace string, obj runtime.Object) (bool, error) {
@@ -185,17 +336,18 @@ func (w *waiter) isPodReady(pod *corev1.Pod) bool {
 			return true
 		}
 	}
-	w.log("Pod is not ready: %s/%s", pod.GetNamespace(), pod.GetName())
+	w.notReady("Pod is not ready: %s/%s", pod.GetNamespace(), pod.GetName())
 	return false
 }
 
 func (w *waiter) jobReady(job *batchv1.Job) bool {
 	if job.Status.Failed > *job.Spec.BackoffLimit {
-		w.log("Job is failed: %s/%s", job.GetNamespace(), job.GetName())
+		w.notReady("Job is failed: %s/%s", job.GetNamespace(), job.GetName())
+		w.failed = true
 		return false
 	}
 	if job.Status.Succeeded < *job.Spec.Completions {
-		w.log("Job is not completed: %s/%s", job.GetNamespace(), job.GetName())
+		w.notReady("Job is not completed: %s/%s", job.GetNamespace(), job.GetName())
 		return false
 	}
 	return true
@@ -209,7 +361,7 @@ func (w *waiter) serviceReady(s *corev1.Service) bool {
 
 	// Ensure that the service cluster IP is not empty
 	if s.Spec.ClusterIP == "" {
-		w.log("Service does not have cluster IP address: %s/%s", s.GetNamespace(), s.GetName())
+		w.notReady("Service does not have cluster IP address: %s/%s", s.GetNamespace(), s.GetName())
 		return false
 	}
 
@@ -222,7 +374,7 @@ func (w *waiter) serviceReady(s *corev1.Service) bool {
 		}
 
 		if s.Status.LoadBalancer.Ingress == nil {
-			w.log("Service does not have load balancer ingress IP address: %s/%s", s.GetNamespace(), s.GetName())
+			w.notReady("Service does not have load balancer ingress IP address: %s/%s", s.GetNamespace(), s.GetName())
 			return false
 		}
 	}
@@ -232,7 +384,7 @@ func (w *waiter) serviceReady(s *corev1.Service) bool {
 
 func (w *waiter) volumeReady(v *corev1.PersistentVolumeClaim) bool {
 	if v.Status.Phase != corev1.ClaimBound {
-		w.log("PersistentVolumeClaim is not bound: %s/%s", v.GetNamespace(), v.GetName())
+		w.notReady("PersistentVolumeClaim is not bound: %s/%s", v.GetNamespace(), v.GetName())
 		return false
 	}
 	return true
@@ -241,7 +393,7 @@ func (w *waiter) volumeReady(v *corev1.PersistentVolumeClaim) bool {
 func (w *waiter) deploymentReady(rs *appsv1.ReplicaSet, dep *appsv1.Deployment) bool {
 	expectedReady := *dep.Spec.Replicas - deploymentutil.MaxUnavailable(*dep)
 	if !(rs.Status.ReadyReplicas >= expectedReady) {
-		w.log("Deployment is not ready: %s/%s. %d out of %d expected pods are ready", dep.Namespace, dep.Name, rs.Status.ReadyReplicas, expectedReady)
+		w.notReady("Deployment is not ready: %s/%s. %d out of %d expected pods are ready", dep.Namespace, dep.Name, rs.Status.ReadyReplicas, expectedReady)
 		return false
 	}
 	return true
@@ -255,7 +407,7 @@ func (w *waiter) daemonSetReady(ds *appsv1.DaemonSet) bool {
 
 	// Make sure all the updated pods have been scheduled
 	if ds.Status.UpdatedNumberScheduled != ds.Status.DesiredNumberScheduled {
-		w.log("DaemonSet is not ready: %s/%s. %d out of %d expected pods have been scheduled", ds.Namespace, ds.Name, ds.Status.UpdatedNumberScheduled, ds.Status.DesiredNumberScheduled)
+		w.notReady("DaemonSet is not ready: %s/%s. %d out of %d expected pods have been scheduled", ds.Namespace, ds.Name, ds.Status.UpdatedNumberScheduled, ds.Status.DesiredNumberScheduled)
 		return false
 	}
 	maxUnavailable, err := intstr.GetValueFromIntOrPercent(ds.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable, int(ds.Status.DesiredNumberScheduled), true)
@@ -268,7 +420,7 @@ func (w *waiter) daemonSetReady(ds *appsv1.DaemonSet) bool {
 
 	expectedReady := int(ds.Status.DesiredNumberScheduled) - maxUnavailable
 	if !(int(ds.Status.NumberReady) >= expectedReady) {
-		w.log("DaemonSet is not ready: %s/%s. %d out of %d expected pods are ready", ds.Namespace, ds.Name, ds.Status.NumberReady, expectedReady)
+		w.notReady("DaemonSet is not ready: %s/%s. %d out of %d expected pods are ready", ds.Namespace, ds.Name, ds.Status.NumberReady, expectedReady)
 		return false
 	}
 	return true
@@ -345,12 +497,12 @@ func (w *waiter) statefulSetReady(sts *appsv1.StatefulSet) bool {
 
 	// Make sure all the updated pods have been scheduled
 	if int(sts.Status.UpdatedReplicas) != expectedReplicas {
-		w.log("StatefulSet is not ready: %s/%s. %d out of %d expected pods have been scheduled", sts.Namespace, sts.Name, sts.Status.UpdatedReplicas, expectedReplicas)
+		w.notReady("StatefulSet is not ready: %s/%s. %d out of %d expected pods have been scheduled", sts.Namespace, sts.Name, sts.Status.UpdatedReplicas, expectedReplicas)
 		return false
 	}
 
 	if int(sts.Status.ReadyReplicas) != replicas {
-		w.log("StatefulSet is not ready: %s/%s. %d out of %d expected pods are ready", sts.Namespace, sts.Name, sts.Status.ReadyReplicas, replicas)
+		w.notReady("StatefulSet is not ready: %s/%s. %d out of %d expected pods are ready", sts.Namespace, sts.Name, sts.Status.ReadyReplicas, replicas)
 		return false
 	}
 	return true
@@ -19,6 +19,7 @@ package kube // import "helm.sh/helm/v3/pkg/kube"
 import (
 	"context"
 	"testing"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
@@ -26,6 +27,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/kubernetes/fake"
 )
 
@@ -242,6 +244,43 @@ func Test_waiter_podsReadyForObject(t *testing.T) {
 	}
 }
 
+func Test_waiter_waitForResourcesCancelled(t *testing.T) {
+	w := &waiter{
+		c:       fake.NewSimpleClientset(),
+		log:     nopLogger,
+		timeout: time.Minute,
+	}
+	pod := newPodWithCondition("foo", corev1.ConditionFalse)
+	if _, err := w.c.CoreV1().Pods(defaultNamespace).Create(context.TODO(), pod, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create Pod error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	resources := ResourceList{&resource.Info{Name: "foo", Namespace: defaultNamespace, Object: pod}}
+	if err := w.waitForResources(ctx, resources, false); err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func Test_waiter_waitForResourcesNoTimeout(t *testing.T) {
+	w := &waiter{
+		c:   fake.NewSimpleClientset(),
+		log: nopLogger,
+	}
+	pod := newPodWithCondition("foo", corev1.ConditionTrue)
+	if _, err := w.c.CoreV1().Pods(defaultNamespace).Create(context.TODO(), pod, metav1.CreateOptions{}); err != nil {
+		t.Fatalf("Failed to create Pod error: %v", err)
+	}
+
+	// A timeout of 0 waits without a deadline rather than timing out at once.
+	resources := ResourceList{&resource.Info{Name: "foo", Namespace: defaultNamespace, Object: pod}}
+	if err := w.waitForResources(context.Background(), resources, false); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+}
+
 func Test_waiter_jobReady(t *testing.T) {
 	type args struct {
 		job *batchv1.Job
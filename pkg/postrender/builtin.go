@@ -0,0 +1,256 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postrender
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+)
+
+// objectFunc modifies a rendered object. It returns the modified object, or
+// nil if the object is unchanged.
+type objectFunc func(obj map[string]interface{}) (map[string]interface{}, error)
+
+// objectRenderer is a PostRenderer that applies fn to every object of the
+// rendered manifests. Documents of unchanged objects are kept as they are and
+// the comments leading each document, such as the "# Source:" line of its
+// template, are preserved.
+type objectRenderer struct {
+	fn objectFunc
+}
+
+func (r objectRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	out := &bytes.Buffer{}
+	for _, doc := range splitDocuments(renderedManifests.String()) {
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal([]byte(doc.body), &obj); err != nil {
+			return nil, errors.Wrapf(err, "unable to parse %s", doc.source())
+		}
+		if len(obj) > 0 {
+			modified, err := r.fn(obj)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to post-render %s", doc.source())
+			}
+			if modified != nil {
+				b, err := yaml.Marshal(modified)
+				if err != nil {
+					return nil, err
+				}
+				doc.body = string(b)
+			}
+		}
+		out.WriteString("---\n")
+		out.WriteString(doc.header)
+		out.WriteString(doc.body)
+		if doc.body != "" && !strings.HasSuffix(doc.body, "\n") {
+			out.WriteString("\n")
+		}
+	}
+	return out, nil
+}
+
+// document is a YAML document of a manifest stream.
+type document struct {
+	// header holds the comments and blank lines before the content
+	header string
+	body   string
+}
+
+// source returns the template the document was rendered from, as recorded in
+// its "# Source:" comment.
+func (d document) source() string {
+	for _, line := range strings.Split(d.header, "\n") {
+		if strings.HasPrefix(line, "# Source: ") {
+			return strings.TrimPrefix(line, "# Source: ")
+		}
+	}
+	return "manifest"
+}
+
+// splitDocuments splits a manifest stream at its "---" separators. Empty
+// documents are dropped.
+func splitDocuments(manifests string) []document {
+	var (
+		docs  []document
+		lines []string
+	)
+	flush := func() {
+		var d document
+		i := 0
+		for ; i < len(lines); i++ {
+			if l := strings.TrimSpace(lines[i]); l != "" && !strings.HasPrefix(l, "#") {
+				break
+			}
+		}
+		if i > 0 {
+			d.header = strings.Join(lines[:i], "\n") + "\n"
+		}
+		if i < len(lines) {
+			d.body = strings.Join(lines[i:], "\n") + "\n"
+		}
+		if strings.TrimSpace(d.header+d.body) != "" {
+			docs = append(docs, d)
+		}
+		lines = nil
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(manifests, "\n"), "\n") {
+		if strings.TrimRight(line, " \t") == "---" {
+			flush()
+			continue
+		}
+		lines = append(lines, line)
+	}
+	flush()
+	return docs
+}
+
+// NewLabels returns a PostRenderer that adds the labels to every rendered
+// resource, replacing labels of the same name. Only the metadata of the
+// resources is changed: selectors and pod templates are left alone, as
+// changing them breaks upgrades of existing resources.
+func NewLabels(labels map[string]string) PostRenderer {
+	return objectRenderer{func(obj map[string]interface{}) (map[string]interface{}, error) {
+		return setMetadata(obj, "labels", labels)
+	}}
+}
+
+// NewAnnotations returns a PostRenderer that adds the annotations to every
+// rendered resource, replacing annotations of the same name.
+func NewAnnotations(annotations map[string]string) PostRenderer {
+	return objectRenderer{func(obj map[string]interface{}) (map[string]interface{}, error) {
+		return setMetadata(obj, "annotations", annotations)
+	}}
+}
+
+// setMetadata sets the entries of a string map in the metadata of obj.
+func setMetadata(obj map[string]interface{}, field string, values map[string]string) (map[string]interface{}, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	metadata, ok := obj["metadata"].(map[string]interface{})
+	if !ok {
+		if obj["metadata"] != nil {
+			return nil, errors.New("metadata is not an object")
+		}
+		metadata = map[string]interface{}{}
+		obj["metadata"] = metadata
+	}
+	m, ok := metadata[field].(map[string]interface{})
+	if !ok {
+		if metadata[field] != nil {
+			return nil, errors.Errorf("metadata.%s is not an object", field)
+		}
+		m = map[string]interface{}{}
+		metadata[field] = m
+	}
+	for k, v := range values {
+		m[k] = v
+	}
+	return obj, nil
+}
+
+// containerFields are the fields of a pod spec that list containers.
+var containerFields = []string{"containers", "initContainers", "ephemeralContainers"}
+
+// NewImageRegistry returns a PostRenderer that rewrites the images of the
+// containers of every rendered resource. rewrites maps a registry, or a
+// registry followed by a repository prefix, to its replacement, e.g.
+// "docker.io" to "registry.example.com/mirror". Images that do not name a
+// registry are on docker.io. The longest matching prefix is used.
+func NewImageRegistry(rewrites map[string]string) PostRenderer {
+	targets := map[string]string{}
+	prefixes := make([]string, 0, len(rewrites))
+	for from, to := range rewrites {
+		from = strings.TrimSuffix(from, "/")
+		targets[from] = strings.TrimSuffix(to, "/")
+		prefixes = append(prefixes, from)
+	}
+	// Longest first, so the most specific prefix matches
+	sort.Slice(prefixes, func(i, j int) bool { return len(prefixes[i]) > len(prefixes[j]) })
+
+	rewrite := func(image string) (string, bool) {
+		name := normalizeImage(image)
+		for _, p := range prefixes {
+			if !strings.HasPrefix(name, p) {
+				continue
+			}
+			// Match whole path components, or a whole repository followed by
+			// its tag or digest
+			rest := name[len(p):]
+			if strings.HasPrefix(rest, "/") || (strings.Contains(p, "/") && (rest == "" || rest[0] == ':' || rest[0] == '@')) {
+				return targets[p] + rest, true
+			}
+		}
+		return image, false
+	}
+
+	return objectRenderer{func(obj map[string]interface{}) (map[string]interface{}, error) {
+		if rewriteImages(obj, rewrite) {
+			return obj, nil
+		}
+		return nil, nil
+	}}
+}
+
+// rewriteImages rewrites the images of the containers found anywhere in v,
+// and reports whether any was changed.
+func rewriteImages(v interface{}, rewrite func(string) (string, bool)) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, field := range containerFields {
+			containers, _ := v[field].([]interface{})
+			for _, c := range containers {
+				container, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				if image, ok := container["image"].(string); ok {
+					if image, ok = rewrite(image); ok {
+						container["image"] = image
+						changed = true
+					}
+				}
+			}
+		}
+		for _, child := range v {
+			changed = rewriteImages(child, rewrite) || changed
+		}
+	case []interface{}:
+		for _, child := range v {
+			changed = rewriteImages(child, rewrite) || changed
+		}
+	}
+	return changed
+}
+
+// normalizeImage returns the image reference with its registry, which is
+// docker.io if the reference does not name one.
+func normalizeImage(image string) string {
+	i := strings.IndexRune(image, '/')
+	if i < 0 {
+		return "docker.io/library/" + image
+	}
+	if domain := image[:i]; !strings.ContainsAny(domain, ".:") && domain != "localhost" {
+		return "docker.io/" + image
+	}
+	return image
+}
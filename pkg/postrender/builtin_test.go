@@ -0,0 +1,209 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postrender
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifests = `---
+# Source: chart/templates/configmap.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  key: value
+---
+# Source: chart/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  replicas: 1
+  template:
+    spec:
+      initContainers:
+        - name: init
+          image: busybox
+      containers:
+        - name: web
+          image: docker.io/bitnami/nginx:1.19
+        - name: sidecar
+          image: quay.io/prometheus/node-exporter@sha256:abc
+`
+
+func TestSplitDocuments(t *testing.T) {
+	is := assert.New(t)
+
+	docs := splitDocuments("a: 1\n---\n# Source: b.yaml\n\nb: 2\n---\n---\n# only a comment\n")
+	is.Equal([]document{
+		{body: "a: 1\n"},
+		{header: "# Source: b.yaml\n\n", body: "b: 2\n"},
+		{header: "# only a comment\n"},
+	}, docs)
+	is.Equal("b.yaml", docs[1].source())
+	is.Equal("manifest", docs[0].source())
+}
+
+func TestObjectRendererKeepsUnchanged(t *testing.T) {
+	is := assert.New(t)
+
+	out, err := objectRenderer{func(map[string]interface{}) (map[string]interface{}, error) {
+		return nil, nil
+	}}.Run(bytes.NewBufferString(testManifests))
+	is.NoError(err)
+	is.Equal(testManifests, out.String())
+
+	_, err = objectRenderer{func(map[string]interface{}) (map[string]interface{}, error) {
+		return nil, errors.New("boom")
+	}}.Run(bytes.NewBufferString(testManifests))
+	if is.Error(err) {
+		is.Equal("unable to post-render chart/templates/configmap.yaml: boom", err.Error())
+	}
+}
+
+func TestLabelsAndAnnotations(t *testing.T) {
+	is := assert.New(t)
+
+	out, err := NewChain(
+		NewLabels(map[string]string{"team": "payments", "app": "shop"}),
+		NewAnnotations(map[string]string{"owner": "alice"}),
+	).Run(bytes.NewBufferString(testManifests))
+	require.NoError(t, err)
+
+	expected := `---
+# Source: chart/templates/configmap.yaml
+apiVersion: v1
+data:
+  key: value
+kind: ConfigMap
+metadata:
+  annotations:
+    owner: alice
+  labels:
+    app: shop
+    team: payments
+  name: config
+---
+# Source: chart/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    owner: alice
+  labels:
+    app: shop
+    team: payments
+  name: web
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - image: docker.io/bitnami/nginx:1.19
+        name: web
+      - image: quay.io/prometheus/node-exporter@sha256:abc
+        name: sidecar
+      initContainers:
+      - image: busybox
+        name: init
+`
+	is.Equal(expected, out.String())
+
+	_, err = NewLabels(map[string]string{"a": "b"}).Run(bytes.NewBufferString("metadata: []\n"))
+	is.Error(err)
+}
+
+func TestImageRegistry(t *testing.T) {
+	is := assert.New(t)
+
+	out, err := NewImageRegistry(map[string]string{
+		"docker.io":                 "mirror.example.com/hub",
+		"quay.io/prometheus/":       "mirror.example.com/prometheus/",
+		"docker.io/library/busybox": "mirror.example.com/tools/busybox",
+	}).Run(bytes.NewBufferString(testManifests))
+	require.NoError(t, err)
+
+	s := out.String()
+	is.Contains(s, "image: mirror.example.com/tools/busybox\n")
+	is.Contains(s, "image: mirror.example.com/hub/bitnami/nginx:1.19\n")
+	is.Contains(s, "image: mirror.example.com/prometheus/node-exporter@sha256:abc\n")
+	// the config map has no images, so it is not rewritten
+	is.True(strings.HasPrefix(s, testManifests[:strings.Index(testManifests, "---\n# Source: chart/templates/deployment.yaml")]))
+}
+
+func TestNormalizeImage(t *testing.T) {
+	for image, expected := range map[string]string{
+		"nginx":                     "docker.io/library/nginx",
+		"nginx:1.19":                "docker.io/library/nginx:1.19",
+		"bitnami/nginx":             "docker.io/bitnami/nginx",
+		"docker.io/bitnami/nginx":   "docker.io/bitnami/nginx",
+		"localhost/app":             "localhost/app",
+		"registry:5000/app":         "registry:5000/app",
+		"gcr.io/project/app@sha256": "gcr.io/project/app@sha256",
+	} {
+		assert.Equal(t, expected, normalizeImage(image), image)
+	}
+}
+
+func TestImageRegistryMatchesWholeComponents(t *testing.T) {
+	is := assert.New(t)
+	r := NewImageRegistry(map[string]string{
+		"gcr.io/project/app": "mirror.example.com/app",
+	})
+
+	for image, expected := range map[string]string{
+		"gcr.io/project/app:1.0":     "mirror.example.com/app:1.0",
+		"gcr.io/project/app":         "mirror.example.com/app",
+		"gcr.io/project/app/worker":  "mirror.example.com/app/worker",
+		"gcr.io/project/application": "gcr.io/project/application",
+	} {
+		out, err := r.Run(bytes.NewBufferString("kind: Pod\nspec:\n  containers:\n  - image: " + image + "\n"))
+		is.NoError(err)
+		is.Contains(out.String(), "image: "+expected+"\n", image)
+	}
+}
+
+type failingRenderer struct{}
+
+func (failingRenderer) Run(*bytes.Buffer) (*bytes.Buffer, error) {
+	return nil, errors.New("failed")
+}
+
+func TestChain(t *testing.T) {
+	is := assert.New(t)
+
+	out, err := NewChain(
+		NewLabels(map[string]string{"a": "1"}),
+		NewLabels(map[string]string{"a": "2"}),
+	).Run(bytes.NewBufferString("kind: ConfigMap\n"))
+	is.NoError(err)
+	is.Equal("---\nkind: ConfigMap\nmetadata:\n  labels:\n    a: \"2\"\n", out.String())
+
+	_, err = NewChain(failingRenderer{}, NewLabels(map[string]string{"a": "1"})).Run(bytes.NewBufferString(""))
+	is.EqualError(err, "failed")
+}
@@ -27,6 +27,7 @@ import (
 
 type execRender struct {
 	binaryPath string
+	args       []string
 }
 
 // NewExec returns a PostRenderer implementation that calls the provided binary.
@@ -34,16 +35,23 @@ type execRender struct {
 // contain any separators, it will search in $PATH, otherwise it will resolve
 // any relative paths to a fully qualified path
 func NewExec(binaryPath string) (PostRenderer, error) {
+	return NewExecWithArgs(binaryPath, nil)
+}
+
+// NewExecWithArgs returns a PostRenderer implementation that calls the
+// provided binary with the given arguments. The binary is looked up as in
+// NewExec.
+func NewExecWithArgs(binaryPath string, args []string) (PostRenderer, error) {
 	fullPath, err := getFullPath(binaryPath)
 	if err != nil {
 		return nil, err
 	}
-	return &execRender{fullPath}, nil
+	return &execRender{fullPath, args}, nil
 }
 
 // Run the configured binary for the post render
 func (p *execRender) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
-	cmd := exec.Command(p.binaryPath)
+	cmd := exec.Command(p.binaryPath, p.args...)
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return nil, err
@@ -0,0 +1,227 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postrender
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// PatchTarget selects the rendered resource a patch applies to. Empty fields
+// match any value.
+type PatchTarget struct {
+	Group     string `json:"group,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+func (t PatchTarget) String() string {
+	return fmt.Sprintf("%s %q", t.Kind, t.Name)
+}
+
+// matches reports whether obj is the target resource.
+func (t PatchTarget) matches(obj map[string]interface{}) bool {
+	apiVersion, _ := obj["apiVersion"].(string)
+	kind, _ := obj["kind"].(string)
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	namespace, _ := metadata["namespace"].(string)
+	gv, err := schema.ParseGroupVersion(apiVersion)
+	if err != nil {
+		return false
+	}
+	return kind == t.Kind && name == t.Name &&
+		(t.Group == "" || t.Group == gv.Group) &&
+		(t.Version == "" || t.Version == gv.Version) &&
+		(t.Namespace == "" || t.Namespace == namespace)
+}
+
+// patch modifies the target resource.
+type patch struct {
+	target PatchTarget
+	apply  func(obj map[string]interface{}) (map[string]interface{}, error)
+}
+
+// patchRenderer applies patches to the rendered resources. Every patch must
+// apply to at least one resource.
+type patchRenderer []patch
+
+func (p patchRenderer) Run(renderedManifests *bytes.Buffer) (*bytes.Buffer, error) {
+	applied := make([]bool, len(p))
+	out, err := objectRenderer{func(obj map[string]interface{}) (map[string]interface{}, error) {
+		var modified map[string]interface{}
+		for i, pt := range p {
+			if !pt.target.matches(obj) {
+				continue
+			}
+			patched, err := pt.apply(obj)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to patch %s", pt.target)
+			}
+			obj, modified = patched, patched
+			applied[i] = true
+		}
+		return modified, nil
+	}}.Run(renderedManifests)
+	if err != nil {
+		return nil, err
+	}
+	for i, ok := range applied {
+		if !ok {
+			return nil, errors.Errorf("patch target %s not found in the rendered resources", p[i].target)
+		}
+	}
+	return out, nil
+}
+
+// NewStrategicMergePatch returns a PostRenderer that applies the patches in
+// data, in the manner of the patchesStrategicMerge of kustomize. data holds
+// one or more YAML documents, each a partial resource that patches the
+// rendered resource with the same apiVersion, kind and name, and namespace if
+// it has one. Kinds built into Kubernetes are patched with the strategic
+// merge rules of their fields; other kinds, such as custom resources, are
+// patched as JSON merge patches (RFC 7386).
+func NewStrategicMergePatch(data []byte) (PostRenderer, error) {
+	var patches patchRenderer
+	for _, doc := range splitDocuments(string(data)) {
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal([]byte(doc.body), &obj); err != nil {
+			return nil, errors.Wrap(err, "unable to parse strategic merge patch")
+		}
+		if len(obj) == 0 {
+			continue
+		}
+		apiVersion, _ := obj["apiVersion"].(string)
+		kind, _ := obj["kind"].(string)
+		metadata, _ := obj["metadata"].(map[string]interface{})
+		name, _ := metadata["name"].(string)
+		namespace, _ := metadata["namespace"].(string)
+		gv, err := schema.ParseGroupVersion(apiVersion)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid strategic merge patch")
+		}
+		if apiVersion == "" || kind == "" || name == "" {
+			return nil, errors.New("invalid strategic merge patch: apiVersion, kind and metadata.name are required")
+		}
+		patches = append(patches, patch{
+			target: PatchTarget{Group: gv.Group, Version: gv.Version, Kind: kind, Name: name, Namespace: namespace},
+			apply:  strategicMerge(gv.WithKind(kind), obj),
+		})
+	}
+	if len(patches) == 0 {
+		return nil, errors.New("strategic merge patch is empty")
+	}
+	return patches, nil
+}
+
+func strategicMerge(gvk schema.GroupVersionKind, patch map[string]interface{}) func(map[string]interface{}) (map[string]interface{}, error) {
+	return func(obj map[string]interface{}) (map[string]interface{}, error) {
+		if dataStruct, err := scheme.Scheme.New(gvk); err == nil {
+			return strategicpatch.StrategicMergeMapPatch(obj, patch, dataStruct)
+		}
+		original, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		p, err := json.Marshal(patch)
+		if err != nil {
+			return nil, err
+		}
+		merged, err := jsonpatch.MergePatch(original, p)
+		if err != nil {
+			return nil, err
+		}
+		var result map[string]interface{}
+		if err := json.Unmarshal(merged, &result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+}
+
+// JSON6902Patch is a JSON patch (RFC 6902) of a rendered resource.
+type JSON6902Patch struct {
+	Target PatchTarget `json:"target"`
+	// Patch is the list of operations of the JSON patch
+	Patch []map[string]interface{} `json:"patch"`
+}
+
+// NewJSON6902Patch returns a PostRenderer that applies JSON patches (RFC 6902)
+// to the rendered resources. data is a YAML list of the patches and their
+// targets:
+//
+//	# replicas.yaml
+//	- target:
+//	    kind: Deployment
+//	    name: web
+//	  patch:
+//	    - op: replace
+//	      path: /spec/replicas
+//	      value: 3
+func NewJSON6902Patch(data []byte) (PostRenderer, error) {
+	var list []JSON6902Patch
+	if err := yaml.Unmarshal(data, &list); err != nil {
+		return nil, errors.Wrap(err, "unable to parse JSON patches")
+	}
+	var patches patchRenderer
+	for _, p := range list {
+		if p.Target.Kind == "" || p.Target.Name == "" {
+			return nil, errors.New("invalid JSON patch: target kind and name are required")
+		}
+		raw, err := json.Marshal(p.Patch)
+		if err != nil {
+			return nil, err
+		}
+		ops, err := jsonpatch.DecodePatch(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid JSON patch of %s", p.Target)
+		}
+		patches = append(patches, patch{target: p.Target, apply: jsonPatch(ops)})
+	}
+	if len(patches) == 0 {
+		return nil, errors.New("JSON patch is empty")
+	}
+	return patches, nil
+}
+
+func jsonPatch(ops jsonpatch.Patch) func(map[string]interface{}) (map[string]interface{}, error) {
+	return func(obj map[string]interface{}) (map[string]interface{}, error) {
+		original, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		patched, err := ops.Apply(original)
+		if err != nil {
+			return nil, err
+		}
+		var result map[string]interface{}
+		if err := json.Unmarshal(patched, &result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package postrender
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrategicMergePatch(t *testing.T) {
+	is := assert.New(t)
+
+	pr, err := NewStrategicMergePatch([]byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+        - name: web
+          resources:
+            limits:
+              memory: 128Mi
+`))
+	require.NoError(t, err)
+
+	out, err := pr.Run(bytes.NewBufferString(testManifests))
+	require.NoError(t, err)
+	s := out.String()
+	is.Contains(s, "  replicas: 3\n")
+	// containers are merged by name
+	is.Contains(s, `      containers:
+      - image: docker.io/bitnami/nginx:1.19
+        name: web
+        resources:
+          limits:
+            memory: 128Mi
+      - image: quay.io/prometheus/node-exporter@sha256:abc
+        name: sidecar
+`)
+	is.Contains(s, "# Source: chart/templates/deployment.yaml\n")
+}
+
+func TestStrategicMergePatchCustomResource(t *testing.T) {
+	is := assert.New(t)
+
+	pr, err := NewStrategicMergePatch([]byte(`apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: w
+spec:
+  size: large
+  color: null
+`))
+	require.NoError(t, err)
+
+	out, err := pr.Run(bytes.NewBufferString(`apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: w
+spec:
+  size: small
+  color: blue
+  items: [a]
+`))
+	require.NoError(t, err)
+	is.Equal(`---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: w
+spec:
+  items:
+  - a
+  size: large
+`, out.String())
+}
+
+func TestStrategicMergePatchTargets(t *testing.T) {
+	is := assert.New(t)
+
+	pr, err := NewStrategicMergePatch([]byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: missing
+data:
+  a: b
+`))
+	require.NoError(t, err)
+	_, err = pr.Run(bytes.NewBufferString(testManifests))
+	is.EqualError(err, `patch target ConfigMap "missing" not found in the rendered resources`)
+
+	// the namespace of a patch must match
+	pr, err = NewStrategicMergePatch([]byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: other
+`))
+	require.NoError(t, err)
+	_, err = pr.Run(bytes.NewBufferString(testManifests))
+	is.Error(err)
+
+	_, err = NewStrategicMergePatch([]byte("kind: ConfigMap\n"))
+	is.Error(err)
+	_, err = NewStrategicMergePatch([]byte("---\n"))
+	is.EqualError(err, "strategic merge patch is empty")
+}
+
+func TestJSON6902Patch(t *testing.T) {
+	is := assert.New(t)
+
+	pr, err := NewJSON6902Patch([]byte(`- target:
+    group: apps
+    kind: Deployment
+    name: web
+  patch:
+    - op: replace
+      path: /spec/replicas
+      value: 5
+    - op: add
+      path: /spec/template/spec/containers/0/args
+      value: ["--verbose"]
+- target:
+    kind: ConfigMap
+    name: config
+  patch:
+    - op: remove
+      path: /data/key
+`))
+	require.NoError(t, err)
+
+	out, err := pr.Run(bytes.NewBufferString(testManifests))
+	require.NoError(t, err)
+	s := out.String()
+	is.Contains(s, "  replicas: 5\n")
+	is.Contains(s, "      - args:\n        - --verbose\n")
+	is.NotContains(s, "key: value")
+}
+
+func TestJSON6902PatchErrors(t *testing.T) {
+	is := assert.New(t)
+
+	// the group of the target does not match
+	pr, err := NewJSON6902Patch([]byte(`- target: {group: batch, kind: Deployment, name: web}
+  patch: [{op: replace, path: /spec/replicas, value: 5}]
+`))
+	require.NoError(t, err)
+	_, err = pr.Run(bytes.NewBufferString(testManifests))
+	is.EqualError(err, `patch target Deployment "web" not found in the rendered resources`)
+
+	// the operation fails
+	pr, err = NewJSON6902Patch([]byte(`- target: {kind: Deployment, name: web}
+  patch: [{op: test, path: /spec/replicas, value: 2}]
+`))
+	require.NoError(t, err)
+	_, err = pr.Run(bytes.NewBufferString(testManifests))
+	is.Error(err)
+
+	_, err = NewJSON6902Patch([]byte(`- patch: [{op: remove, path: /data}]`))
+	is.Error(err)
+	_, err = NewJSON6902Patch([]byte(`[]`))
+	is.Error(err)
+}
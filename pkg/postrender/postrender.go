@@ -15,8 +15,9 @@ limitations under the License.
 */
 
 // Package postrender contains an interface that can be implemented for custom
-// post-renderers and an exec implementation that can be used for arbitrary
-// binaries and scripts
+// post-renderers, an exec implementation that can be used for arbitrary
+// binaries and scripts, built-in implementations for common modifications of
+// the rendered resources and a chain to run several post-renderers in turn
 package postrender
 
 import "bytes"
@@ -86,6 +86,22 @@ type HookExecution struct {
 	CompletedAt time.Time `json:"completed_at,omitempty"`
 	// Phase indicates whether the hook completed successfully
 	Phase HookPhase `json:"phase"`
+	// Logs holds the trailing container logs of the pods run by the hook.
+	Logs []HookLog `json:"logs,omitempty"`
+	// KubeEvents holds the Kubernetes events of the hook resources and their pods.
+	KubeEvents []string `json:"kube_events,omitempty"`
+}
+
+// A HookLog is the log of a container run by a hook.
+type HookLog struct {
+	// Pod is the name of the pod.
+	Pod string `json:"pod"`
+	// Container is the name of the container within the pod.
+	Container string `json:"container"`
+	// Log is the end of the log of the container.
+	Log string `json:"log,omitempty"`
+	// Truncated indicates that the beginning of the log was cut off.
+	Truncated bool `json:"truncated,omitempty"`
 }
 
 // A HookPhase indicates the state of a hook execution
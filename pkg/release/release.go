@@ -29,6 +29,9 @@ type Release struct {
 	// Config is the set of extra Values added to the chart.
 	// These values override the default values inside of the chart.
 	Config map[string]interface{} `json:"config,omitempty"`
+	// SensitiveValues are the JSON pointers of the values in Config that came
+	// from encrypted values files. They are redacted when values are shown.
+	SensitiveValues []string `json:"sensitive_values,omitempty"`
 	// Manifest is the string representation of the rendered template.
 	Manifest string `json:"manifest,omitempty"`
 	// Hooks are all of the hooks declared for this release.
@@ -27,6 +27,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kblabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/validation"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 
@@ -34,6 +35,7 @@ import (
 )
 
 var _ Driver = (*ConfigMaps)(nil)
+var _ Heartbeater = (*ConfigMaps)(nil)
 
 // ConfigMapsDriverName is the string name of the driver.
 const ConfigMapsDriverName = "ConfigMap"
@@ -43,6 +45,13 @@ const ConfigMapsDriverName = "ConfigMap"
 type ConfigMaps struct {
 	impl corev1.ConfigMapInterface
 	Log  func(string, ...interface{})
+	// Compression is the codec that compresses the releases. The default
+	// is CompressionGzip.
+	Compression Compression
+	// ChunkSize is the maximum size of the encoded release stored in a
+	// ConfigMap. Larger releases are split across several ConfigMaps. Zero
+	// means DefaultChunkSize.
+	ChunkSize int
 }
 
 // NewConfigMaps initializes a new ConfigMaps wrapping an implementation of
@@ -73,7 +82,7 @@ func (cfgmaps *ConfigMaps) Get(key string) (*rspb.Release, error) {
 		return nil, err
 	}
 	// found the configmap, decode the base64 data string
-	r, err := decodeRelease(obj.Data["release"])
+	r, err := cfgmaps.decode(obj)
 	if err != nil {
 		cfgmaps.Log("get: failed to decode data %q: %s", key, err)
 		return nil, err
@@ -99,8 +108,9 @@ func (cfgmaps *ConfigMaps) List(filter func(*rspb.Release) bool) ([]*rspb.Releas
 
 	// iterate over the configmaps object list
 	// and decode each release
-	for _, item := range list.Items {
-		rls, err := decodeRelease(item.Data["release"])
+	for i := range list.Items {
+		item := &list.Items[i]
+		rls, err := cfgmaps.decode(item)
 		if err != nil {
 			cfgmaps.Log("list: failed to decode release: %v: %s", item, err)
 			continue
@@ -125,6 +135,8 @@ func (cfgmaps *ConfigMaps) Query(labels map[string]string) ([]*rspb.Release, err
 		}
 		ls[k] = v
 	}
+	// Match the releases only, not the chunks of large releases
+	ls["owner"] = "helm"
 
 	opts := metav1.ListOptions{LabelSelector: ls.AsSelector().String()}
 
@@ -139,8 +151,8 @@ func (cfgmaps *ConfigMaps) Query(labels map[string]string) ([]*rspb.Release, err
 	}
 
 	var results []*rspb.Release
-	for _, item := range list.Items {
-		rls, err := decodeRelease(item.Data["release"])
+	for i := range list.Items {
+		rls, err := cfgmaps.decode(&list.Items[i])
 		if err != nil {
 			cfgmaps.Log("query: failed to decode release: %s", err)
 			continue
@@ -160,13 +172,19 @@ func (cfgmaps *ConfigMaps) Create(key string, rls *rspb.Release) error {
 	lbs.set("createdAt", strconv.Itoa(int(time.Now().Unix())))
 
 	// create a new configmap to hold the release
-	obj, err := newConfigMapsObject(key, rls, lbs)
+	obj, err := newConfigMapsObject(key, rls, lbs, cfgmaps.Compression)
 	if err != nil {
 		cfgmaps.Log("create: failed to encode release %q: %s", rls.Name, err)
 		return err
 	}
+	chunks, err := cfgmaps.createChunks(obj)
+	if err != nil {
+		cfgmaps.Log("create: failed to create chunks: %s", err)
+		return err
+	}
 	// push the configmap object out into the kubiverse
 	if _, err := cfgmaps.impl.Create(context.Background(), obj, metav1.CreateOptions{}); err != nil {
+		cfgmaps.deleteChunks(chunks)
 		if apierrors.IsAlreadyExists(err) {
 			return ErrReleaseExists
 		}
@@ -187,20 +205,51 @@ func (cfgmaps *ConfigMaps) Update(key string, rls *rspb.Release) error {
 	lbs.set("modifiedAt", strconv.Itoa(int(time.Now().Unix())))
 
 	// create a new configmap object to hold the release
-	obj, err := newConfigMapsObject(key, rls, lbs)
+	obj, err := newConfigMapsObject(key, rls, lbs, cfgmaps.Compression)
 	if err != nil {
 		cfgmaps.Log("update: failed to encode release %q: %s", rls.Name, err)
 		return err
 	}
+	chunks, err := cfgmaps.createChunks(obj)
+	if err != nil {
+		cfgmaps.Log("update: failed to create chunks: %s", err)
+		return err
+	}
 	// push the configmap object out into the kubiverse
 	_, err = cfgmaps.impl.Update(context.Background(), obj, metav1.UpdateOptions{})
 	if err != nil {
+		cfgmaps.deleteChunks(chunks)
 		cfgmaps.Log("update: failed to update: %s", err)
 		return err
 	}
+	// the chunks of the previous version of the object are no longer used
+	cfgmaps.pruneChunks(obj.Labels, obj.Labels["chunkId"])
 	return nil
 }
 
+// Heartbeat sets the "modifiedAt" label of the ConfigMap holding the release
+// named by key to the current time.
+func (cfgmaps *ConfigMaps) Heartbeat(key string) error {
+	_, err := cfgmaps.impl.Patch(context.Background(), key, types.MergePatchType, heartbeatPatch(), metav1.PatchOptions{})
+	if apierrors.IsNotFound(err) {
+		return ErrReleaseNotFound
+	}
+	return errors.Wrapf(err, "heartbeat: failed to patch %q", key)
+}
+
+// LastHeartbeat returns the latest of the "createdAt" and "modifiedAt" labels
+// of the ConfigMap holding the release named by key.
+func (cfgmaps *ConfigMaps) LastHeartbeat(key string) (time.Time, error) {
+	obj, err := cfgmaps.impl.Get(context.Background(), key, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return time.Time{}, ErrReleaseNotFound
+		}
+		return time.Time{}, errors.Wrapf(err, "heartbeat: failed to get %q", key)
+	}
+	return labels(obj.Labels).lastModified()
+}
+
 // Delete deletes the ConfigMap holding the release named by key.
 func (cfgmaps *ConfigMaps) Delete(key string) (rls *rspb.Release, err error) {
 	// fetch the release to check existence
@@ -211,12 +260,97 @@ func (cfgmaps *ConfigMaps) Delete(key string) (rls *rspb.Release, err error) {
 	if err = cfgmaps.impl.Delete(context.Background(), key, metav1.DeleteOptions{}); err != nil {
 		return rls, err
 	}
+	cfgmaps.pruneChunks(map[string]string{"name": rls.Name, "version": strconv.Itoa(rls.Version)}, "")
 	return rls, nil
 }
 
+// decode decodes the release held by the ConfigMap, reading its other chunks
+// if it has been split.
+func (cfgmaps *ConfigMaps) decode(obj *v1.ConfigMap) (*rspb.Release, error) {
+	for attempt := 0; ; attempt++ {
+		data, err := joinChunks(obj.Name, obj.Data["release"], obj.Labels, func(name string) (string, error) {
+			chunk, err := cfgmaps.impl.Get(context.Background(), name, metav1.GetOptions{})
+			if err != nil {
+				return "", err
+			}
+			return chunk.Data["chunk"], nil
+		})
+		if err == nil {
+			return decodeRelease(data)
+		}
+		if !apierrors.IsNotFound(errors.Cause(err)) || attempt == chunkRetries {
+			return nil, err
+		}
+		// The release was updated while its chunks were read, so read it again
+		if obj, err = cfgmaps.impl.Get(context.Background(), obj.Name, metav1.GetOptions{}); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// createChunks splits the release held by obj if it is larger than the chunk
+// size. obj keeps the first chunk, and the others are stored in new
+// ConfigMaps, which are returned.
+func (cfgmaps *ConfigMaps) createChunks(obj *v1.ConfigMap) ([]string, error) {
+	parts := splitChunks(obj.Data["release"], cfgmaps.ChunkSize)
+	if len(parts) == 1 {
+		return nil, nil
+	}
+	id, err := newChunkID()
+	if err != nil {
+		return nil, err
+	}
+	var created []string
+	for i, part := range parts[1:] {
+		chunk := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   chunkName(obj.Name, id, i+1),
+				Labels: chunkLabels(obj.Labels, id),
+			},
+			Data: map[string]string{"chunk": part},
+		}
+		if _, err := cfgmaps.impl.Create(context.Background(), chunk, metav1.CreateOptions{}); err != nil {
+			cfgmaps.deleteChunks(created)
+			return nil, err
+		}
+		created = append(created, chunk.Name)
+	}
+	obj.Data["release"] = parts[0]
+	setChunkLabels(obj.Labels, id, len(parts))
+	return created, nil
+}
+
+// deleteChunks deletes the named chunk ConfigMaps. Failures are logged, as
+// the chunks are not referenced by any release.
+func (cfgmaps *ConfigMaps) deleteChunks(names []string) {
+	for _, name := range names {
+		if err := cfgmaps.impl.Delete(context.Background(), name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			cfgmaps.Log("failed to delete chunk %q: %s", name, err)
+		}
+	}
+}
+
+// pruneChunks deletes the chunk ConfigMaps of the release with the given
+// labels, except those of the chunk ID keep.
+func (cfgmaps *ConfigMaps) pruneChunks(lbs map[string]string, keep string) {
+	sel := kblabels.Set{"owner": chunkOwner, "name": lbs["name"], "version": lbs["version"]}.AsSelector()
+	list, err := cfgmaps.impl.List(context.Background(), metav1.ListOptions{LabelSelector: sel.String()})
+	if err != nil {
+		cfgmaps.Log("failed to list the chunks of release %q: %s", lbs["name"], err)
+		return
+	}
+	var names []string
+	for _, item := range list.Items {
+		if item.Labels["chunkId"] != keep {
+			names = append(names, item.Name)
+		}
+	}
+	cfgmaps.deleteChunks(names)
+}
+
 // newConfigMapsObject constructs a kubernetes ConfigMap object
 // to store a release. Each configmap data entry is the base64
-// encoded gzipped string of a release.
+// encoded compressed string of a release.
 //
 // The following labels are used within each configmap:
 //
@@ -226,12 +360,14 @@ func (cfgmaps *ConfigMaps) Delete(key string) (rls *rspb.Release, err error) {
 //    "status"         - status of the release (see pkg/release/status.go for variants)
 //    "owner"          - owner of the configmap, currently "helm".
 //    "name"           - name of the release.
+//    "chunks"         - number of chunks of the release, if it is split. (set in Create and Update)
+//    "chunkId"        - identifier of the chunks of the release, if it is split. (set in Create and Update)
 //
-func newConfigMapsObject(key string, rls *rspb.Release, lbs labels) (*v1.ConfigMap, error) {
+func newConfigMapsObject(key string, rls *rspb.Release, lbs labels, compression Compression) (*v1.ConfigMap, error) {
 	const owner = "helm"
 
 	// encode the release
-	s, err := encodeRelease(rls)
+	s, err := encodeReleaseWith(rls, compression)
 	if err != nil {
 		return nil, err
 	}
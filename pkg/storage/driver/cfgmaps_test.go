@@ -16,8 +16,10 @@ package driver
 import (
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 
@@ -59,7 +61,7 @@ func TestUncompressedConfigMapGet(t *testing.T) {
 	rel := releaseStub(name, vers, namespace, rspb.StatusDeployed)
 
 	// Create a test fixture which contains an uncompressed release
-	cfgmap, err := newConfigMapsObject(key, rel, nil)
+	cfgmap, err := newConfigMapsObject(key, rel, nil, "")
 	if err != nil {
 		t.Fatalf("Failed to create configmap: %s", err)
 	}
@@ -239,3 +241,96 @@ func TestConfigMapDelete(t *testing.T) {
 		t.Errorf("Expected {%v}, got {%v}", ErrReleaseNotFound, err)
 	}
 }
+
+func TestConfigMapHeartbeat(t *testing.T) {
+	vers := 1
+	name := "smug-pigeon"
+	namespace := "default"
+	key := testKey(name, vers)
+	rel := releaseStub(name, vers, namespace, rspb.StatusPendingUpgrade)
+
+	cfgmaps := newTestFixtureCfgMaps(t, []*rspb.Release{rel}...)
+
+	before := time.Now().Add(-time.Second)
+	if err := cfgmaps.Heartbeat(key); err != nil {
+		t.Fatalf("Failed to record heartbeat: %s", err)
+	}
+
+	last, err := cfgmaps.LastHeartbeat(key)
+	if err != nil {
+		t.Fatalf("Failed to get last heartbeat: %s", err)
+	}
+	if last.Before(before) {
+		t.Errorf("Expected heartbeat after %s, got %s", before, last)
+	}
+
+	if err := cfgmaps.Heartbeat(testKey("missing", 1)); err != ErrReleaseNotFound {
+		t.Errorf("Expected %s, got %v", ErrReleaseNotFound, err)
+	}
+}
+
+func TestConfigMapChunks(t *testing.T) {
+	name := "smug-pigeon"
+	rel := releaseStub(name, 1, "default", rspb.StatusDeployed)
+	key := testKey(name, 1)
+
+	cfgmaps := newTestFixtureCfgMaps(t)
+	cfgmaps.ChunkSize = 64
+	cfgmaps.Compression = CompressionZstd
+	mock := cfgmaps.impl.(*MockConfigMapsInterface)
+
+	if err := cfgmaps.Create(key, rel); err != nil {
+		t.Fatalf("Failed to create release: %s", err)
+	}
+	var chunks []string
+	for name, obj := range mock.objects {
+		if obj.Labels["owner"] == chunkOwner {
+			chunks = append(chunks, name)
+		}
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("Expected the release to be split, got chunks %v", chunks)
+	}
+
+	got, err := cfgmaps.Get(key)
+	if err != nil {
+		t.Fatalf("Failed to get release: %s", err)
+	}
+	if !reflect.DeepEqual(rel, got) {
+		t.Errorf("Expected {%v}, got {%v}", rel, got)
+	}
+	all, err := cfgmaps.List(func(*rspb.Release) bool { return true })
+	if err != nil || len(all) != 1 {
+		t.Fatalf("Expected 1 release, got %d: %v", len(all), err)
+	}
+	var logged []string
+	cfgmaps.Log = func(format string, v ...interface{}) { logged = append(logged, fmt.Sprintf(format, v...)) }
+	query, err := cfgmaps.Query(map[string]string{"name": name})
+	if err != nil || len(query) != 1 {
+		t.Fatalf("Expected 1 release, got %d: %v", len(query), err)
+	}
+	if len(logged) != 0 {
+		t.Errorf("Expected the chunks not to be queried, got %v", logged)
+	}
+
+	previous := mock.objects[key].DeepCopy()
+	rel.Info.Description = "updated"
+	if err := cfgmaps.Update(key, rel); err != nil {
+		t.Fatalf("Failed to update release: %s", err)
+	}
+	for _, c := range chunks {
+		if _, ok := mock.objects[c]; ok {
+			t.Errorf("Expected chunk %q of the previous version to be deleted", c)
+		}
+	}
+	if got, err = cfgmaps.decode(previous); err != nil || got.Info.Description != "updated" {
+		t.Errorf("Expected the updated release, got %v: %v", got, err)
+	}
+
+	if _, err := cfgmaps.Delete(key); err != nil {
+		t.Fatalf("Failed to delete release: %s", err)
+	}
+	if len(mock.objects) != 0 {
+		t.Errorf("Expected all ConfigMaps to be deleted, got %d", len(mock.objects))
+	}
+}
@@ -0,0 +1,114 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v3/pkg/storage/driver"
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultChunkSize is the default maximum size of the encoded release stored
+// in a single Secret or ConfigMap. Kubernetes limits the data of these
+// objects to 1MiB; some room is left for their metadata.
+const DefaultChunkSize = 1<<20 - 64<<10
+
+// chunkOwner is the "owner" label of the objects holding the chunks of a
+// release after the first one. It differs from the owner of the objects
+// holding releases, so that chunks are never listed as releases.
+const chunkOwner = "helm-chunk"
+
+// chunkRetries is how many times the chunks of a release are read again when
+// a concurrent update of the release replaces them while they are read.
+const chunkRetries = 3
+
+// splitChunks splits the encoded release into chunks of at most size bytes.
+func splitChunks(data string, size int) []string {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	var chunks []string
+	for len(data) > size {
+		chunks = append(chunks, data[:size])
+		data = data[size:]
+	}
+	return append(chunks, data)
+}
+
+// newChunkID returns a random identifier for the chunks of one write of a
+// release. Every write stores its chunks in new objects, which are only
+// referenced once the object holding the release has been written, so readers
+// never mix the chunks of different writes.
+func newChunkID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// chunkName returns the name of the object holding the chunk at index i,
+// counting from zero, of a release stored under key.
+func chunkName(key, id string, i int) string {
+	return fmt.Sprintf("%s.%s.%d", key, id, i)
+}
+
+// setChunkLabels records on the labels of the object holding a release into
+// how many chunks it was split.
+func setChunkLabels(lbs map[string]string, id string, n int) {
+	lbs["chunks"] = strconv.Itoa(n)
+	lbs["chunkId"] = id
+}
+
+// chunkLabels returns the labels of the objects holding the chunks of the
+// release with the given labels.
+func chunkLabels(lbs map[string]string, id string) map[string]string {
+	return map[string]string{
+		"owner":   chunkOwner,
+		"name":    lbs["name"],
+		"version": lbs["version"],
+		"chunkId": id,
+	}
+}
+
+// joinChunks returns the encoded release stored under key, given the first
+// chunk and the labels of the object holding it. The other chunks are read
+// with get.
+func joinChunks(key, first string, lbs map[string]string, get func(name string) (string, error)) (string, error) {
+	val, ok := lbs["chunks"]
+	if !ok {
+		return first, nil
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 1 {
+		return "", errors.Errorf("invalid chunks label %q", val)
+	}
+	var b strings.Builder
+	b.WriteString(first)
+	for i := 1; i < n; i++ {
+		chunk, err := get(chunkName(key, lbs["chunkId"], i))
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get chunk %d of %d", i+1, n)
+		}
+		b.WriteString(chunk)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,105 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	rspb "helm.sh/helm/v3/pkg/release"
+)
+
+func TestSplitChunks(t *testing.T) {
+	for _, tt := range []struct {
+		data   string
+		size   int
+		chunks []string
+	}{
+		{"", 3, []string{""}},
+		{"abc", 3, []string{"abc"}},
+		{"abcdefg", 3, []string{"abc", "def", "g"}},
+		{"abcdef", 3, []string{"abc", "def"}},
+		{"abc", 0, []string{"abc"}},
+	} {
+		if got := splitChunks(tt.data, tt.size); !reflect.DeepEqual(tt.chunks, got) {
+			t.Errorf("splitChunks(%q, %d): expected %q, got %q", tt.data, tt.size, tt.chunks, got)
+		}
+	}
+}
+
+func TestJoinChunks(t *testing.T) {
+	stored := map[string]string{
+		"key.id.1": "def",
+		"key.id.2": "g",
+	}
+	get := func(name string) (string, error) {
+		if chunk, ok := stored[name]; ok {
+			return chunk, nil
+		}
+		return "", errors.Errorf("%s not found", name)
+	}
+
+	got, err := joinChunks("key", "abc", map[string]string{"chunks": "3", "chunkId": "id"}, get)
+	if err != nil || got != "abcdefg" {
+		t.Errorf("Expected %q, got %q: %v", "abcdefg", got, err)
+	}
+	// not split
+	got, err = joinChunks("key", "abc", map[string]string{}, get)
+	if err != nil || got != "abc" {
+		t.Errorf("Expected %q, got %q: %v", "abc", got, err)
+	}
+	if _, err := joinChunks("key", "abc", map[string]string{"chunks": "4", "chunkId": "id"}, get); err == nil || !strings.Contains(err.Error(), "chunk 4 of 4") {
+		t.Errorf("Expected an error for chunk 4 of 4, got %v", err)
+	}
+	if _, err := joinChunks("key", "abc", map[string]string{"chunks": "x"}, get); err == nil {
+		t.Error("Expected an error for an invalid chunks label")
+	}
+}
+
+func TestEncodeReleaseCompression(t *testing.T) {
+	rel := releaseStub("smug-pigeon", 1, "default", rspb.StatusDeployed)
+	for _, c := range []Compression{CompressionGzip, CompressionZstd} {
+		data, err := encodeReleaseWith(rel, c)
+		if err != nil {
+			t.Fatalf("%s: failed to encode: %s", c, err)
+		}
+		got, err := decodeRelease(data)
+		if err != nil {
+			t.Fatalf("%s: failed to decode: %s", c, err)
+		}
+		if !reflect.DeepEqual(rel, got) {
+			t.Errorf("%s: expected {%v}, got {%v}", c, rel, got)
+		}
+	}
+	if _, err := encodeReleaseWith(rel, "lzma"); err == nil {
+		t.Error("Expected an error for an unknown compression")
+	}
+}
+
+func TestParseCompression(t *testing.T) {
+	for s, expected := range map[string]Compression{"": CompressionGzip, "gzip": CompressionGzip, "zstd": CompressionZstd} {
+		if got, err := ParseCompression(s); err != nil || got != expected {
+			t.Errorf("ParseCompression(%q): expected %q, got %q: %v", s, expected, got, err)
+		}
+	}
+	if _, err := ParseCompression("lzma"); err == nil {
+		t.Error("Expected an error for an unknown compression")
+	}
+}
@@ -18,6 +18,7 @@ package driver // import "helm.sh/helm/v3/pkg/storage/driver"
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -92,6 +93,23 @@ type Queryor interface {
 	Query(labels map[string]string) ([]*rspb.Release, error)
 }
 
+// Heartbeater is the interface that wraps the Heartbeat and LastHeartbeat
+// methods. Drivers implement it to let a running operation signal that it
+// is still alive, so that its pending release is not mistaken for one that
+// was abandoned.
+//
+// Heartbeat records that the release named by key is still being worked on,
+// without rewriting the release.
+//
+// LastHeartbeat returns when the release named by key was last stored or
+// heartbeated.
+//
+// Both return ErrReleaseNotFound if the release does not exist.
+type Heartbeater interface {
+	Heartbeat(key string) error
+	LastHeartbeat(key string) (time.Time, error)
+}
+
 // Driver is the interface composed of Creator, Updator, Deletor, and Queryor
 // interfaces. It defines the behavior for storing, updating, deleted,
 // and retrieving Helm releases from some underlying storage mechanism,
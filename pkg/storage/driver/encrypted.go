@@ -0,0 +1,336 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v3/pkg/storage/driver"
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/chart"
+	rspb "helm.sh/helm/v3/pkg/release"
+)
+
+var _ Driver = (*Encrypted)(nil)
+var _ Heartbeater = (*Encrypted)(nil)
+
+// encryptedPrefix starts the manifest of an encrypted release record.
+const encryptedPrefix = "helm.sh/encrypted-release.v1:"
+
+// Encrypted is a Driver that encrypts the releases stored by another driver,
+// so that their manifests, values and notes are not readable by whoever can
+// read the storage.
+//
+// Each release is encrypted with its own random data key, which is in turn
+// encrypted, or wrapped, by a KeyProvider and stored with the release. The
+// record keeps the name, namespace, version, status and chart metadata of the
+// release in clear, so that releases can still be listed and queried.
+//
+// Records that are not encrypted, e.g. because they were stored before
+// encryption was enabled, are read as they are.
+type Encrypted struct {
+	Driver
+	keys KeyProvider
+	Log  func(string, ...interface{})
+
+	// dataKeys caches the unwrapped data keys by the ID of the key that
+	// wrapped them and their wrapped form, so that listing the releases
+	// unwraps each data key only once, however slow the KeyProvider.
+	mu       sync.Mutex
+	dataKeys map[string][]byte
+}
+
+// envelope is an encrypted release.
+type envelope struct {
+	// KeyID identifies the key that wrapped the data key
+	KeyID      string `json:"keyId"`
+	WrappedKey []byte `json:"wrappedKey"`
+	// Ciphertext is the release encrypted with AES-256-GCM with the data
+	// key, preceded by its nonce
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// NewEncrypted returns an Encrypted driver that stores the releases in d,
+// encrypted with data keys wrapped by keys.
+func NewEncrypted(d Driver, keys KeyProvider) *Encrypted {
+	return &Encrypted{
+		Driver: d,
+		keys:   keys,
+		Log:    func(_ string, _ ...interface{}) {},
+	}
+}
+
+// Get fetches the release named by key and decrypts it.
+func (e *Encrypted) Get(key string) (*rspb.Release, error) {
+	rls, err := e.Driver.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return e.decrypt(rls)
+}
+
+// List returns the decrypted releases such that filter(release) == true.
+// Releases that cannot be decrypted are skipped.
+func (e *Encrypted) List(filter func(*rspb.Release) bool) ([]*rspb.Release, error) {
+	list, err := e.Driver.List(func(*rspb.Release) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+	var results []*rspb.Release
+	for _, rls := range e.decryptAll("list", list) {
+		if filter(rls) {
+			results = append(results, rls)
+		}
+	}
+	return results, nil
+}
+
+// Query returns the decrypted releases that match the labels. Releases that
+// cannot be decrypted are skipped.
+func (e *Encrypted) Query(labels map[string]string) ([]*rspb.Release, error) {
+	list, err := e.Driver.Query(labels)
+	if err != nil {
+		return nil, err
+	}
+	return e.decryptAll("query", list), nil
+}
+
+// Create encrypts the release and stores it.
+func (e *Encrypted) Create(key string, rls *rspb.Release) error {
+	sealed, err := e.encrypt(rls)
+	if err != nil {
+		return errors.Wrapf(err, "create: failed to encrypt release %q", rls.Name)
+	}
+	return e.Driver.Create(key, sealed)
+}
+
+// Update encrypts the release and updates its record. The data key is always
+// wrapped with the current key of the KeyProvider, so updating a release
+// rotates its key.
+func (e *Encrypted) Update(key string, rls *rspb.Release) error {
+	sealed, err := e.encrypt(rls)
+	if err != nil {
+		return errors.Wrapf(err, "update: failed to encrypt release %q", rls.Name)
+	}
+	return e.Driver.Update(key, sealed)
+}
+
+// Delete deletes the release named by key and returns it decrypted.
+func (e *Encrypted) Delete(key string) (*rspb.Release, error) {
+	rls, err := e.Driver.Delete(key)
+	if err != nil {
+		return nil, err
+	}
+	return e.decrypt(rls)
+}
+
+// Heartbeat calls the Heartbeat method of the wrapped driver, if it
+// implements Heartbeater.
+func (e *Encrypted) Heartbeat(key string) error {
+	if hb, ok := e.Driver.(Heartbeater); ok {
+		return hb.Heartbeat(key)
+	}
+	return nil
+}
+
+// LastHeartbeat calls the LastHeartbeat method of the wrapped driver. If it
+// does not implement Heartbeater, the time the release was last deployed is
+// returned.
+func (e *Encrypted) LastHeartbeat(key string) (time.Time, error) {
+	if hb, ok := e.Driver.(Heartbeater); ok {
+		return hb.LastHeartbeat(key)
+	}
+	rls, err := e.Driver.Get(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return rls.Info.LastDeployed.Time, nil
+}
+
+// CurrentKeyID returns the ID of the key that wraps new data keys.
+func (e *Encrypted) CurrentKeyID() string {
+	return e.keys.KeyID()
+}
+
+// Decrypt returns the release of a record read from the wrapped driver. Unlike
+// List and Query, which skip the records that cannot be decrypted, it fails
+// for them. Records that are not encrypted are returned as they are.
+func (e *Encrypted) Decrypt(rls *rspb.Release) (*rspb.Release, error) {
+	return e.decrypt(rls)
+}
+
+func (e *Encrypted) decryptAll(op string, list []*rspb.Release) []*rspb.Release {
+	var results []*rspb.Release
+	for _, rls := range list {
+		opened, err := e.decrypt(rls)
+		if err != nil {
+			e.Log("%s: failed to decrypt release %q: %s", op, rls.Name, err)
+			continue
+		}
+		results = append(results, opened)
+	}
+	return results
+}
+
+// encrypt returns the record of an encrypted release.
+func (e *Encrypted) encrypt(rls *rspb.Release) (*rspb.Release, error) {
+	plaintext, err := json.Marshal(rls)
+	if err != nil {
+		return nil, err
+	}
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	ciphertext, err := seal(dataKey, plaintext, additionalData(rls))
+	if err != nil {
+		return nil, err
+	}
+	keyID := e.keys.KeyID()
+	wrapped, err := e.keys.WrapKey(dataKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to wrap the data key with key %s", keyID)
+	}
+	env, err := json.Marshal(envelope{KeyID: keyID, WrappedKey: wrapped, Ciphertext: ciphertext})
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := &rspb.Release{
+		Name:      rls.Name,
+		Namespace: rls.Namespace,
+		Version:   rls.Version,
+		Manifest:  encryptedPrefix + b64.EncodeToString(env),
+		Labels:    rls.Labels,
+	}
+	if rls.Info != nil {
+		info := *rls.Info
+		info.Notes = ""
+		sealed.Info = &info
+	}
+	if rls.Chart != nil {
+		sealed.Chart = &chart.Chart{Metadata: rls.Chart.Metadata}
+	}
+	return sealed, nil
+}
+
+// decrypt returns the release of an encrypted record. Records that are not
+// encrypted are returned as they are.
+func (e *Encrypted) decrypt(rls *rspb.Release) (*rspb.Release, error) {
+	if !strings.HasPrefix(rls.Manifest, encryptedPrefix) {
+		return rls, nil
+	}
+	env, err := parseEnvelope(rls.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := e.unwrapKey(env)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unwrap the data key with key %s", env.KeyID)
+	}
+	plaintext, err := open(dataKey, env.Ciphertext, additionalData(rls))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt release")
+	}
+	var opened rspb.Release
+	if err := json.Unmarshal(plaintext, &opened); err != nil {
+		return nil, err
+	}
+	// The labels are those of the storage object, not those of the release
+	opened.Labels = rls.Labels
+	return &opened, nil
+}
+
+// unwrapKey returns the data key of env, which is only unwrapped by the
+// KeyProvider the first time it is seen.
+func (e *Encrypted) unwrapKey(env *envelope) ([]byte, error) {
+	id := env.KeyID + "\x00" + string(env.WrappedKey)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if dataKey, ok := e.dataKeys[id]; ok {
+		return dataKey, nil
+	}
+	dataKey, err := e.keys.UnwrapKey(env.KeyID, env.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	if e.dataKeys == nil {
+		e.dataKeys = make(map[string][]byte)
+	}
+	e.dataKeys[id] = dataKey
+	return dataKey, nil
+}
+
+func parseEnvelope(manifest string) (*envelope, error) {
+	b, err := b64.DecodeString(strings.TrimPrefix(manifest, encryptedPrefix))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid encrypted release")
+	}
+	var env envelope
+	if err := json.Unmarshal(b, &env); err != nil {
+		return nil, errors.Wrap(err, "invalid encrypted release")
+	}
+	return &env, nil
+}
+
+// additionalData binds the ciphertext of a release to its namespace, name and
+// version, so that the encrypted record of a release cannot be passed off as
+// another, including the release of the same name in another namespace.
+func additionalData(rls *rspb.Release) []byte {
+	return []byte(fmt.Sprintf("%s/%s.v%d", rls.Namespace, rls.Name, rls.Version))
+}
+
+// seal encrypts plaintext with AES-256-GCM. The nonce precedes the result.
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts the result of seal.
+func open(key, ciphertext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+	nonce, ciphertext := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,487 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+
+	"helm.sh/helm/v3/pkg/chart"
+	rspb "helm.sh/helm/v3/pkg/release"
+)
+
+func testDataKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func testLocalKeys(t *testing.T, keys ...[]byte) *LocalKeys {
+	t.Helper()
+	lk, err := NewLocalKeys(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lk
+}
+
+func secretReleaseStub(name string, vers int) *rspb.Release {
+	rls := releaseStub(name, vers, "default", rspb.StatusDeployed)
+	rls.Info.Notes = "password: hunter2"
+	rls.Manifest = "kind: Secret\nstringData:\n  password: hunter2\n"
+	rls.Config = map[string]interface{}{"password": "hunter2"}
+	rls.Chart = &chart.Chart{
+		Metadata: &chart.Metadata{Name: "app", Version: "1.0.0"},
+		Values:   map[string]interface{}{"password": ""},
+	}
+	return rls
+}
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	mem := NewMemory()
+	e := NewEncrypted(mem, testLocalKeys(t, testDataKey(1)))
+
+	rls := secretReleaseStub("rls-a", 1)
+	key := testKey(rls.Name, rls.Version)
+	if err := e.Create(key, rls); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := mem.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(stored.Manifest, encryptedPrefix) {
+		t.Errorf("expected an encrypted manifest, got %q", stored.Manifest)
+	}
+	if stored.Config != nil || stored.Info.Notes != "" || stored.Chart.Values != nil {
+		t.Errorf("expected the values and notes to be encrypted, got %+v", stored)
+	}
+	if stored.Name != rls.Name || stored.Version != rls.Version || stored.Info.Status != rls.Info.Status || stored.Chart.Metadata.Name != "app" {
+		t.Errorf("expected the name, version, status and chart metadata in clear, got %+v", stored)
+	}
+
+	got, err := e.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Manifest != rls.Manifest || got.Info.Notes != rls.Info.Notes || !reflect.DeepEqual(got.Config, rls.Config) {
+		t.Errorf("expected %+v, got %+v", rls, got)
+	}
+
+	list, err := e.List(func(r *rspb.Release) bool { return r.Info.Status == rspb.StatusDeployed })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Manifest != rls.Manifest {
+		t.Errorf("expected the decrypted release from List, got %v", list)
+	}
+
+	query, err := e.Query(map[string]string{"name": "rls-a", "owner": "helm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(query) != 1 || query[0].Manifest != rls.Manifest {
+		t.Errorf("expected the decrypted release from Query, got %v", query)
+	}
+
+	deleted, err := e.Delete(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted.Manifest != rls.Manifest {
+		t.Errorf("expected the decrypted release from Delete, got %q", deleted.Manifest)
+	}
+	if _, err := mem.Get(key); err != ErrReleaseNotFound {
+		t.Errorf("expected the release to be deleted, got %v", err)
+	}
+}
+
+func TestEncryptedLegacyRelease(t *testing.T) {
+	mem := NewMemory()
+	rls := secretReleaseStub("rls-a", 1)
+	key := testKey(rls.Name, rls.Version)
+	if err := mem.Create(key, rls); err != nil {
+		t.Fatal(err)
+	}
+
+	e := NewEncrypted(mem, testLocalKeys(t, testDataKey(1)))
+	got, err := e.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Manifest != rls.Manifest {
+		t.Errorf("expected the unencrypted release as it is, got %q", got.Manifest)
+	}
+
+	// Updating the release encrypts it
+	if err := e.Update(key, got); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := mem.Get(key)
+	if !strings.HasPrefix(stored.Manifest, encryptedPrefix) {
+		t.Errorf("expected the updated release to be encrypted, got %q", stored.Manifest)
+	}
+}
+
+func TestEncryptedKeyRotation(t *testing.T) {
+	mem := NewMemory()
+	oldKey, newKey := testDataKey(1), testDataKey(2)
+	rls := secretReleaseStub("rls-a", 1)
+	key := testKey(rls.Name, rls.Version)
+
+	if err := NewEncrypted(mem, testLocalKeys(t, oldKey)).Create(key, rls); err != nil {
+		t.Fatal(err)
+	}
+
+	// The new key is added before the old one, which still decrypts
+	rotating := NewEncrypted(mem, testLocalKeys(t, newKey, oldKey))
+	got, err := rotating.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rotating.Update(key, got); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewEncrypted(mem, testLocalKeys(t, oldKey)).Get(key); err == nil {
+		t.Error("expected the old key to no longer decrypt the release")
+	}
+	got, err = NewEncrypted(mem, testLocalKeys(t, newKey)).Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Manifest != rls.Manifest {
+		t.Errorf("expected %q, got %q", rls.Manifest, got.Manifest)
+	}
+}
+
+func TestEncryptedWrongKey(t *testing.T) {
+	mem := NewMemory()
+	e := NewEncrypted(mem, testLocalKeys(t, testDataKey(1)))
+	for _, rls := range []*rspb.Release{secretReleaseStub("rls-a", 1), secretReleaseStub("rls-b", 1)} {
+		if err := e.Create(testKey(rls.Name, rls.Version), rls); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A release that is not encrypted is still read
+	if err := mem.Create(testKey("rls-c", 1), secretReleaseStub("rls-c", 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	wrong := NewEncrypted(mem, testLocalKeys(t, testDataKey(2)))
+	if _, err := wrong.Get(testKey("rls-a", 1)); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected an error about the missing key, got %v", err)
+	}
+	list, err := wrong.List(func(*rspb.Release) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "rls-c" {
+		t.Errorf("expected the releases that cannot be decrypted to be skipped, got %v", list)
+	}
+}
+
+func TestEncryptedTampering(t *testing.T) {
+	mem := NewMemory()
+	e := NewEncrypted(mem, testLocalKeys(t, testDataKey(1)))
+	if err := e.Create(testKey("rls-a", 1), secretReleaseStub("rls-a", 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The encrypted record of a release cannot be passed off as another
+	stored, _ := mem.Get(testKey("rls-a", 1))
+	forged := *stored
+	forged.Name = "rls-b"
+	if err := mem.Create(testKey("rls-b", 1), &forged); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Get(testKey("rls-b", 1)); err == nil {
+		t.Error("expected the forged release to fail to decrypt")
+	}
+
+	// Nor as the release of the same name in another namespace
+	forged = *stored
+	forged.Namespace = "other"
+	mem.SetNamespace("other")
+	if err := mem.Create(testKey("rls-a", 1), &forged); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Get(testKey("rls-a", 1)); err == nil {
+		t.Error("expected the release moved to another namespace to fail to decrypt")
+	}
+}
+
+// countingKeys counts the data keys unwrapped by a KeyProvider.
+type countingKeys struct {
+	KeyProvider
+	unwrapped int
+}
+
+func (k *countingKeys) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	k.unwrapped++
+	return k.KeyProvider.UnwrapKey(keyID, wrapped)
+}
+
+func TestEncryptedUnwrapCache(t *testing.T) {
+	keys := &countingKeys{KeyProvider: testLocalKeys(t, testDataKey(1))}
+	e := NewEncrypted(NewMemory(), keys)
+	for v := 1; v <= 3; v++ {
+		if err := e.Create(testKey("rls-a", v), secretReleaseStub("rls-a", v)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		list, err := e.List(func(*rspb.Release) bool { return true })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 3 {
+			t.Fatalf("expected 3 releases, got %d", len(list))
+		}
+		if _, err := e.Query(map[string]string{"name": "rls-a"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if keys.unwrapped != 3 {
+		t.Errorf("expected each data key to be unwrapped once, got %d unwraps", keys.unwrapped)
+	}
+}
+
+func TestEncryptedHeartbeat(t *testing.T) {
+	mem := NewMemory()
+	e := NewEncrypted(mem, testLocalKeys(t, testDataKey(1)))
+	key := testKey("rls-a", 1)
+	if err := e.Create(key, secretReleaseStub("rls-a", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Heartbeat(key); err != nil {
+		t.Fatal(err)
+	}
+	want, err := mem.LastHeartbeat(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := e.LastHeartbeat(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(want) {
+		t.Errorf("expected the heartbeat of the wrapped driver %s, got %s", want, got)
+	}
+}
+
+func TestLoadLocalKeys(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "keys")
+	content := "# current key\n" + b64.EncodeToString(testDataKey(2)) + "\n\n# old key\n" + b64.EncodeToString(testDataKey(1)) + "\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := NewKeyProvider("file:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.KeyID() != localKeyID(testDataKey(2)) {
+		t.Errorf("expected the first key to be current, got %s", keys.KeyID())
+	}
+	wrapped, err := testLocalKeys(t, testDataKey(1)).WrapKey([]byte("data key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrapped, err := keys.UnwrapKey(localKeyID(testDataKey(1)), wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(unwrapped) != "data key" {
+		t.Errorf("expected %q, got %q", "data key", unwrapped)
+	}
+
+	short := filepath.Join(dir, "short")
+	if err := ioutil.WriteFile(short, []byte(b64.EncodeToString([]byte("short"))), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadLocalKeys(short); err == nil || !strings.Contains(err.Error(), "expected 32") {
+		t.Errorf("expected an error about the key length, got %v", err)
+	}
+}
+
+func TestNewKeyProviderInvalid(t *testing.T) {
+	for _, spec := range []string{"keys", "file:", "vault:secret/helm", "exec: ", "exec:\t"} {
+		if _, err := NewKeyProvider(spec); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
+
+func TestPGPKeys(t *testing.T) {
+	entity, err := openpgp.NewEntity("helm", "", "helm@example.com", &packet.Config{RSABits: 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Prefer SHA-256 like the keys generated by gpg, instead of the default
+	// RIPEMD-160 that is not compiled in
+	for _, id := range entity.Identities {
+		id.SelfSignature.PreferredHash = []uint8{8}
+	}
+	var buf bytes.Buffer
+	if err := entity.SerializePrivate(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keyring.gpg")
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := NewKeyProvider("pgp:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(keys.KeyID(), "pgp:") {
+		t.Errorf("expected a pgp key ID, got %s", keys.KeyID())
+	}
+
+	mem := NewMemory()
+	e := NewEncrypted(mem, keys)
+	rls := secretReleaseStub("rls-a", 1)
+	if err := e.Create(testKey("rls-a", 1), rls); err != nil {
+		t.Fatal(err)
+	}
+	got, err := e.Get(testKey("rls-a", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Manifest != rls.Manifest {
+		t.Errorf("expected %q, got %q", rls.Manifest, got.Manifest)
+	}
+}
+
+func TestAgeKeys(t *testing.T) {
+	current, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "keys.txt")
+	content := fmt.Sprintf("# current key\n%s\n\n# old key\n%s\n", current, old)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := NewKeyProvider("age:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.KeyID() != "age:"+current.Recipient().String() {
+		t.Errorf("expected the first key to be current, got %s", keys.KeyID())
+	}
+
+	mem := NewMemory()
+	e := NewEncrypted(mem, keys)
+	rls := secretReleaseStub("rls-a", 1)
+	if err := e.Create(testKey("rls-a", 1), rls); err != nil {
+		t.Fatal(err)
+	}
+	got, err := e.Get(testKey("rls-a", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Manifest != rls.Manifest {
+		t.Errorf("expected %q, got %q", rls.Manifest, got.Manifest)
+	}
+
+	// Data keys wrapped by an older key are still unwrapped
+	var wrapped bytes.Buffer
+	w, err := age.Encrypt(&wrapped, old.Recipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("data key")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	unwrapped, err := keys.UnwrapKey("age:"+old.Recipient().String(), wrapped.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(unwrapped) != "data key" {
+		t.Errorf("expected %q, got %q", "data key", unwrapped)
+	}
+
+	if _, err := LoadAgeKeys(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error for a missing key file")
+	}
+}
+
+func TestExecKeys(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the key command is a shell script")
+	}
+	script := `#!/bin/sh
+case "$1" in
+key-id) echo test-key ;;
+wrap) base64 ;;
+unwrap) [ "$HELM_KEY_ID" = test-key ] || exit 1; base64 -d ;;
+*) echo "unknown command $1" >&2; exit 1 ;;
+esac
+`
+	path := filepath.Join(t.TempDir(), "kms.sh")
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := NewKeyProvider("exec:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.KeyID() != "exec:test-key" {
+		t.Errorf("expected key ID exec:test-key, got %s", keys.KeyID())
+	}
+
+	mem := NewMemory()
+	e := NewEncrypted(mem, keys)
+	rls := secretReleaseStub("rls-a", 1)
+	if err := e.Create(testKey("rls-a", 1), rls); err != nil {
+		t.Fatal(err)
+	}
+	got, err := e.Get(testKey("rls-a", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Manifest != rls.Manifest {
+		t.Errorf("expected %q, got %q", rls.Manifest, got.Manifest)
+	}
+
+	if _, err := NewExecKeys(path).run("rewrap", nil, nil); err == nil || !strings.Contains(err.Error(), "unknown command rewrap") {
+		t.Errorf("expected the error of the command, got %v", err)
+	}
+}
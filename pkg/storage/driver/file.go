@@ -0,0 +1,428 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v3/pkg/storage/driver"
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofrs/flock"
+	"github.com/pkg/errors"
+
+	rspb "helm.sh/helm/v3/pkg/release"
+)
+
+var _ Driver = (*File)(nil)
+var _ Heartbeater = (*File)(nil)
+
+// FileDriverName is the string name of the driver.
+const FileDriverName = "File"
+
+// fileLockRetryPeriod is how long Lock waits between attempts to acquire the
+// lock of a release held by another client.
+var fileLockRetryPeriod = 100 * time.Millisecond
+
+// File is a storage driver that stores each release revision in a file of a
+// local directory, for use without a cluster, e.g. by offline tooling and in
+// tests. The files are laid out as:
+//
+//	<dir>/<namespace>/<key>.json
+//
+// Files are replaced atomically, and writes are serialized with a lock file,
+// so several processes can share the directory.
+type File struct {
+	dir       string
+	namespace string
+
+	// Compression is the compression of the stored releases. It defaults
+	// to gzip.
+	Compression Compression
+	Log         func(string, ...interface{})
+}
+
+// fileRecord is the content of the file holding a release revision.
+type fileRecord struct {
+	// Labels are the labels the release is queried by, as in the
+	// Kubernetes storage objects.
+	Labels  map[string]string `json:"labels"`
+	Release string            `json:"release"`
+}
+
+// NewFile initializes a new File driver storing the releases in dir, which
+// is created if it does not exist.
+func NewFile(dir string) (*File, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrapf(err, "failed to create the release directory %s", dir)
+	}
+	return &File{
+		dir: dir,
+		Log: func(_ string, _ ...interface{}) {},
+	}, nil
+}
+
+// SetNamespace sets a specific namespace in which releases will be accessed.
+// An empty string indicates all namespaces (for the list operation).
+func (f *File) SetNamespace(ns string) {
+	f.namespace = ns
+}
+
+// Name returns the name of the driver.
+func (f *File) Name() string {
+	return FileDriverName
+}
+
+// Get fetches the release named by key. The corresponding release is returned
+// or error if not found.
+func (f *File) Get(key string) (*rspb.Release, error) {
+	path, err := f.path(f.currentNamespace(), key)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := readFileRecord(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get: failed to read %q", key)
+	}
+	if rec == nil {
+		return nil, ErrReleaseNotFound
+	}
+	rls, err := decodeRelease(rec.Release)
+	return rls, errors.Wrapf(err, "get: failed to decode data %q", key)
+}
+
+// List fetches all releases and returns the list releases such
+// that filter(release) == true.
+func (f *File) List(filter func(*rspb.Release) bool) ([]*rspb.Release, error) {
+	var results []*rspb.Release
+	err := f.walk(func(path string, rec *fileRecord) {
+		rls, err := decodeRelease(rec.Release)
+		if err != nil {
+			f.Log("list: failed to decode release %s: %s", path, err)
+			return
+		}
+		rls.Labels = rec.Labels
+		if filter(rls) {
+			results = append(results, rls)
+		}
+	})
+	return results, errors.Wrap(err, "list: failed to list")
+}
+
+// Query fetches all releases that match the provided map of labels.
+func (f *File) Query(keyvals map[string]string) ([]*rspb.Release, error) {
+	var lbs labels
+	lbs.init()
+	lbs.fromMap(keyvals)
+
+	var results []*rspb.Release
+	err := f.walk(func(path string, rec *fileRecord) {
+		if !labels(rec.Labels).match(lbs) {
+			return
+		}
+		rls, err := decodeRelease(rec.Release)
+		if err != nil {
+			f.Log("query: failed to decode release %s: %s", path, err)
+			return
+		}
+		results = append(results, rls)
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "query: failed to query with labels")
+	}
+	if len(results) == 0 {
+		return nil, ErrReleaseNotFound
+	}
+	return results, nil
+}
+
+// Create writes the file holding the release. If the file already exists,
+// ErrReleaseExists is returned.
+func (f *File) Create(key string, rls *rspb.Release) error {
+	path, err := f.path(releaseNamespace(rls), key)
+	if err != nil {
+		return err
+	}
+	unlock, err := f.lockWrites()
+	if err != nil {
+		return errors.Wrap(err, "create: failed to lock")
+	}
+	defer unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		return ErrReleaseExists
+	}
+	var lbs labels
+	lbs.init()
+	lbs.set("createdAt", strconv.Itoa(int(time.Now().Unix())))
+	if err := f.write(path, rls, lbs); err != nil {
+		return errors.Wrapf(err, "create: failed to write release %q", rls.Name)
+	}
+	return nil
+}
+
+// Update replaces the file holding the release. If the file does not exist,
+// ErrReleaseNotFound is returned.
+func (f *File) Update(key string, rls *rspb.Release) error {
+	path, err := f.path(releaseNamespace(rls), key)
+	if err != nil {
+		return err
+	}
+	unlock, err := f.lockWrites()
+	if err != nil {
+		return errors.Wrap(err, "update: failed to lock")
+	}
+	defer unlock()
+
+	old, err := readFileRecord(path)
+	if err != nil {
+		return errors.Wrapf(err, "update: failed to read %q", key)
+	}
+	if old == nil {
+		return ErrReleaseNotFound
+	}
+	var lbs labels
+	lbs.init()
+	if createdAt, ok := old.Labels["createdAt"]; ok {
+		lbs.set("createdAt", createdAt)
+	}
+	lbs.set("modifiedAt", strconv.Itoa(int(time.Now().Unix())))
+	if err := f.write(path, rls, lbs); err != nil {
+		return errors.Wrapf(err, "update: failed to write release %q", rls.Name)
+	}
+	return nil
+}
+
+// Delete deletes the file holding the release named by key.
+func (f *File) Delete(key string) (*rspb.Release, error) {
+	path, err := f.path(f.currentNamespace(), key)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := f.lockWrites()
+	if err != nil {
+		return nil, errors.Wrap(err, "delete: failed to lock")
+	}
+	defer unlock()
+
+	rls, err := f.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, errors.Wrapf(err, "delete: failed to delete %q", key)
+	}
+	return rls, nil
+}
+
+// Heartbeat sets the "modifiedAt" label of the release named by key to the
+// current time.
+func (f *File) Heartbeat(key string) error {
+	path, err := f.path(f.currentNamespace(), key)
+	if err != nil {
+		return err
+	}
+	unlock, err := f.lockWrites()
+	if err != nil {
+		return errors.Wrap(err, "heartbeat: failed to lock")
+	}
+	defer unlock()
+
+	rec, err := readFileRecord(path)
+	if err != nil {
+		return errors.Wrapf(err, "heartbeat: failed to read %q", key)
+	}
+	if rec == nil {
+		return ErrReleaseNotFound
+	}
+	rec.Labels["modifiedAt"] = strconv.Itoa(int(time.Now().Unix()))
+	return errors.Wrapf(writeFileRecord(path, rec), "heartbeat: failed to write %q", key)
+}
+
+// LastHeartbeat returns the latest of the "createdAt" and "modifiedAt" labels
+// of the release named by key.
+func (f *File) LastHeartbeat(key string) (time.Time, error) {
+	path, err := f.path(f.currentNamespace(), key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	rec, err := readFileRecord(path)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "heartbeat: failed to read %q", key)
+	}
+	if rec == nil {
+		return time.Time{}, ErrReleaseNotFound
+	}
+	return labels(rec.Labels).lastModified()
+}
+
+// Lock acquires a file lock for the named release in the namespace of the
+// driver. It implements storage.Locker.
+//
+// The lock is released by the operating system if the client dies while
+// holding it.
+func (f *File) Lock(ctx context.Context, name string) (func() error, error) {
+	if err := validFileName(name); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(f.dir, f.currentNamespace())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Wrap(err, "lock: failed to create the namespace directory")
+	}
+	lock := flock.New(filepath.Join(dir, name+".lock"))
+	locked, err := lock.TryLock()
+	if err == nil && !locked {
+		f.Log("release %q is locked by another client, waiting", name)
+		locked, err = lock.TryLockContext(ctx, fileLockRetryPeriod)
+	}
+	if err != nil || !locked {
+		return nil, errors.Errorf("release %q is locked by another client", name)
+	}
+	return lock.Unlock, nil
+}
+
+// lockWrites acquires the lock that serializes the writes to the directory.
+func (f *File) lockWrites() (func(), error) {
+	lock := flock.New(filepath.Join(f.dir, ".lock"))
+	if err := lock.Lock(); err != nil {
+		return nil, err
+	}
+	return func() { lock.Unlock() }, nil
+}
+
+func (f *File) write(path string, rls *rspb.Release, lbs labels) error {
+	data, err := encodeReleaseWith(rls, f.Compression)
+	if err != nil {
+		return err
+	}
+	lbs.set("name", rls.Name)
+	lbs.set("owner", "helm")
+	lbs.set("status", rls.Info.Status.String())
+	lbs.set("version", strconv.Itoa(rls.Version))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return writeFileRecord(path, &fileRecord{Labels: lbs.toMap(), Release: data})
+}
+
+// walk calls fn with the records of the namespace of the driver, or of all
+// the namespaces if it is not set, in the order of their paths.
+func (f *File) walk(fn func(path string, rec *fileRecord)) error {
+	pattern := filepath.Join(f.dir, "*", "*.json")
+	if f.namespace != "" {
+		pattern = filepath.Join(f.dir, f.namespace, "*.json")
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		rec, err := readFileRecord(path)
+		if err != nil {
+			f.Log("failed to read release %s: %s", path, err)
+			continue
+		}
+		if rec != nil {
+			fn(path, rec)
+		}
+	}
+	return nil
+}
+
+func (f *File) path(namespace, key string) (string, error) {
+	if err := validFileName(key); err != nil {
+		return "", err
+	}
+	if err := validFileName(namespace); err != nil {
+		return "", err
+	}
+	return filepath.Join(f.dir, namespace, key+".json"), nil
+}
+
+func (f *File) currentNamespace() string {
+	if f.namespace == "" {
+		return defaultNamespace
+	}
+	return f.namespace
+}
+
+func releaseNamespace(rls *rspb.Release) string {
+	if rls.Namespace == "" {
+		return defaultNamespace
+	}
+	return rls.Namespace
+}
+
+// validFileName rejects keys and namespaces that would escape the directory.
+func validFileName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+// readFileRecord reads the record of a release. It returns nil if the file
+// does not exist.
+func readFileRecord(path string) (*fileRecord, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rec fileRecord
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil, err
+	}
+	if rec.Labels == nil {
+		rec.Labels = map[string]string{}
+	}
+	return &rec, nil
+}
+
+// writeFileRecord replaces the record of a release atomically, by writing it
+// to a temporary file that is then renamed.
+func writeFileRecord(path string, rec *fileRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,301 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	rspb "helm.sh/helm/v3/pkg/release"
+)
+
+func newTestFixtureFile(t *testing.T, releases ...*rspb.Release) *File {
+	t.Helper()
+	f, err := NewFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rls := range releases {
+		if err := f.Create(testKey(rls.Name, rls.Version), rls); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return f
+}
+
+func TestFileName(t *testing.T) {
+	f := newTestFixtureFile(t)
+	if f.Name() != FileDriverName {
+		t.Errorf("Expected name to be %q, got %q", FileDriverName, f.Name())
+	}
+}
+
+func TestFileCreateGet(t *testing.T) {
+	rls := releaseStub("rls-a", 1, "default", rspb.StatusDeployed)
+	rls.Manifest = "kind: ConfigMap"
+	f := newTestFixtureFile(t, rls)
+
+	got, err := f.Get(testKey("rls-a", 1))
+	if err != nil {
+		t.Fatalf("Failed to get release: %s", err)
+	}
+	if !reflect.DeepEqual(rls, got) {
+		t.Errorf("Expected {%v}, got {%v}", rls, got)
+	}
+
+	if err := f.Create(testKey("rls-a", 1), rls); err != ErrReleaseExists {
+		t.Errorf("Expected %v, got %v", ErrReleaseExists, err)
+	}
+	if _, err := f.Get(testKey("rls-a", 2)); err != ErrReleaseNotFound {
+		t.Errorf("Expected %v, got %v", ErrReleaseNotFound, err)
+	}
+	if _, err := f.Get("../rls-a.v1"); err != ErrInvalidKey {
+		t.Errorf("Expected %v, got %v", ErrInvalidKey, err)
+	}
+
+	// A new driver on the same directory sees the release
+	other, err := NewFile(f.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Get(testKey("rls-a", 1)); err != nil {
+		t.Errorf("Expected the release to persist, got %v", err)
+	}
+}
+
+func TestFileNamespaces(t *testing.T) {
+	f := newTestFixtureFile(t,
+		releaseStub("rls-a", 1, "default", rspb.StatusDeployed),
+		releaseStub("rls-a", 1, "mynamespace", rspb.StatusDeployed),
+		releaseStub("rls-b", 1, "mynamespace", rspb.StatusDeployed),
+	)
+
+	f.SetNamespace("mynamespace")
+	if _, err := f.Get(testKey("rls-b", 1)); err != nil {
+		t.Errorf("Expected the release in the namespace, got %v", err)
+	}
+	ls, err := f.List(func(*rspb.Release) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ls) != 2 {
+		t.Errorf("Expected 2 releases in the namespace, got %d", len(ls))
+	}
+
+	f.SetNamespace("")
+	ls, err = f.List(func(*rspb.Release) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ls) != 3 {
+		t.Errorf("Expected 3 releases in all namespaces, got %d", len(ls))
+	}
+}
+
+func TestFileQuery(t *testing.T) {
+	f := newTestFixtureFile(t,
+		releaseStub("rls-a", 1, "default", rspb.StatusSuperseded),
+		releaseStub("rls-a", 2, "default", rspb.StatusDeployed),
+		releaseStub("rls-b", 1, "default", rspb.StatusDeployed),
+	)
+	f.SetNamespace("default")
+
+	rls, err := f.Query(map[string]string{"name": "rls-a", "owner": "helm"})
+	if err != nil {
+		t.Fatalf("Failed to query: %s", err)
+	}
+	if len(rls) != 2 {
+		t.Errorf("Expected 2 results, got %d", len(rls))
+	}
+
+	rls, err = f.Query(map[string]string{"status": "deployed"})
+	if err != nil {
+		t.Fatalf("Failed to query: %s", err)
+	}
+	if len(rls) != 2 {
+		t.Errorf("Expected 2 results, got %d", len(rls))
+	}
+
+	if _, err := f.Query(map[string]string{"name": "rls-c"}); err != ErrReleaseNotFound {
+		t.Errorf("Expected %v, got %v", ErrReleaseNotFound, err)
+	}
+}
+
+func TestFileUpdate(t *testing.T) {
+	f := newTestFixtureFile(t, releaseStub("rls-a", 1, "default", rspb.StatusDeployed))
+
+	rls := releaseStub("rls-a", 1, "default", rspb.StatusSuperseded)
+	if err := f.Update(testKey("rls-a", 1), rls); err != nil {
+		t.Fatalf("Failed to update release: %s", err)
+	}
+	got, err := f.Get(testKey("rls-a", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Info.Status != rspb.StatusSuperseded {
+		t.Errorf("Expected status %s, got %s", rspb.StatusSuperseded, got.Info.Status)
+	}
+	if _, err := f.Query(map[string]string{"name": "rls-a", "status": "superseded"}); err != nil {
+		t.Errorf("Expected the labels to be updated, got %v", err)
+	}
+
+	if err := f.Update(testKey("rls-b", 1), releaseStub("rls-b", 1, "default", rspb.StatusDeployed)); err != ErrReleaseNotFound {
+		t.Errorf("Expected %v, got %v", ErrReleaseNotFound, err)
+	}
+}
+
+func TestFileDelete(t *testing.T) {
+	rls := releaseStub("rls-a", 1, "default", rspb.StatusDeployed)
+	f := newTestFixtureFile(t, rls)
+
+	got, err := f.Delete(testKey("rls-a", 1))
+	if err != nil {
+		t.Fatalf("Failed to delete release: %s", err)
+	}
+	if !reflect.DeepEqual(rls, got) {
+		t.Errorf("Expected {%v}, got {%v}", rls, got)
+	}
+	if _, err := f.Get(testKey("rls-a", 1)); err != ErrReleaseNotFound {
+		t.Errorf("Expected %v, got %v", ErrReleaseNotFound, err)
+	}
+	if _, err := f.Delete(testKey("rls-a", 1)); err != ErrReleaseNotFound {
+		t.Errorf("Expected %v, got %v", ErrReleaseNotFound, err)
+	}
+
+	// No temporary or other files are left behind
+	files, err := ioutil.ReadDir(filepath.Join(f.dir, "default"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("Expected an empty namespace directory, got %d files", len(files))
+	}
+}
+
+func TestFileHeartbeat(t *testing.T) {
+	f := newTestFixtureFile(t, releaseStub("rls-a", 1, "default", rspb.StatusPendingUpgrade))
+	key := testKey("rls-a", 1)
+
+	created, err := f.LastHeartbeat(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(created) > time.Minute {
+		t.Errorf("Expected a recent heartbeat, got %s", created)
+	}
+	if err := f.Heartbeat(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Heartbeat(testKey("rls-b", 1)); err != ErrReleaseNotFound {
+		t.Errorf("Expected %v, got %v", ErrReleaseNotFound, err)
+	}
+}
+
+func TestFileConcurrentCreate(t *testing.T) {
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each client has its own driver, as separate processes would
+			f, err := NewFile(dir)
+			if err != nil {
+				errs <- err
+				return
+			}
+			errs <- f.Create(testKey("rls-a", 1), releaseStub("rls-a", 1, "default", rspb.StatusDeployed))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	created := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			created++
+		case ErrReleaseExists:
+		default:
+			t.Errorf("Unexpected error: %s", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("Expected the release to be created once, got %d", created)
+	}
+}
+
+func TestFileLock(t *testing.T) {
+	f := newTestFixtureFile(t)
+	unlock, err := f.Lock(context.Background(), "rls-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := NewFile(f.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*fileLockRetryPeriod)
+	defer cancel()
+	if _, err := other.Lock(ctx, "rls-a"); err == nil {
+		t.Fatal("Expected the lock to be held")
+	}
+	// Other releases are not locked
+	unlockB, err := other.Lock(context.Background(), "rls-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unlockB(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err = other.Lock(context.Background(), "rls-a")
+	if err != nil {
+		t.Fatalf("Expected the lock to be released, got %v", err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileCorruptRecord(t *testing.T) {
+	f := newTestFixtureFile(t, releaseStub("rls-a", 1, "default", rspb.StatusDeployed))
+	if err := ioutil.WriteFile(filepath.Join(f.dir, "default", testKey("rls-b", 1)+".json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	ls, err := f.List(func(*rspb.Release) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ls) != 1 {
+		t.Errorf("Expected the corrupt record to be skipped, got %d releases", len(ls))
+	}
+	if _, err := f.Get(testKey("rls-b", 1)); err == nil || err == ErrReleaseNotFound {
+		t.Errorf("Expected a read error, got %v", err)
+	}
+}
@@ -0,0 +1,333 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v3/pkg/storage/driver"
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"filippo.io/age"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/openpgp"
+)
+
+// KeyProvider wraps and unwraps the data keys of encrypted releases.
+//
+// A KeyProvider may be able to unwrap data keys wrapped by older keys, which
+// allows keys to be rotated: releases are re-encrypted with the current key
+// when they are updated.
+type KeyProvider interface {
+	// KeyID identifies the key that WrapKey currently uses.
+	KeyID() string
+	// WrapKey encrypts a data key.
+	WrapKey(dataKey []byte) ([]byte, error)
+	// UnwrapKey decrypts a data key wrapped by the key identified by keyID.
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// NewKeyProvider returns the KeyProvider described by spec, which is one of:
+//
+//	file:<path>      a file of base64 encoded 32-byte keys, see LoadLocalKeys
+//	pgp:<path>       an armored or binary PGP key ring, see LoadPGPKeys
+//	age:<path>       a file of age secret keys, see LoadAgeKeys
+//	exec:<command>   a command that wraps and unwraps keys, see ExecKeys
+func NewKeyProvider(spec string) (KeyProvider, error) {
+	i := strings.Index(spec, ":")
+	if i < 0 {
+		return nil, errors.Errorf("invalid key provider %q: expected file:<path>, pgp:<path>, age:<path> or exec:<command>", spec)
+	}
+	kind, arg := spec[:i], spec[i+1:]
+	if arg == "" {
+		return nil, errors.Errorf("invalid key provider %q: missing %s argument", spec, kind)
+	}
+	switch kind {
+	case "file":
+		return LoadLocalKeys(arg)
+	case "pgp":
+		return LoadPGPKeys(arg)
+	case "age":
+		return LoadAgeKeys(arg)
+	case "exec":
+		fields := strings.Fields(arg)
+		if len(fields) == 0 {
+			return nil, errors.Errorf("invalid key provider %q: the command is blank", spec)
+		}
+		return NewExecKeys(fields[0], fields[1:]...), nil
+	default:
+		return nil, errors.Errorf("unknown key provider %q: expected file:<path>, pgp:<path>, age:<path> or exec:<command>", kind)
+	}
+}
+
+// LocalKeys is a KeyProvider that wraps data keys with AES-256-GCM keys
+// held in memory. The first key wraps new data keys, and all the keys unwrap
+// them, so a key is rotated by adding a new key before it.
+type LocalKeys struct {
+	keys [][]byte
+}
+
+// NewLocalKeys returns LocalKeys with the keys, which must be 32 bytes long.
+func NewLocalKeys(keys ...[]byte) (*LocalKeys, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no keys")
+	}
+	for i, k := range keys {
+		if len(k) != 32 {
+			return nil, errors.Errorf("key %d is %d bytes long, expected 32", i+1, len(k))
+		}
+	}
+	return &LocalKeys{keys: keys}, nil
+}
+
+// LoadLocalKeys reads LocalKeys from a file with one base64 encoded key per
+// line. Empty lines and lines starting with '#' are ignored. A key can be
+// generated with:
+//
+//	head -c 32 /dev/urandom | base64
+func LoadLocalKeys(path string) (*LocalKeys, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, err := b64.DecodeString(line)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s:%d: invalid key", path, n)
+		}
+		keys = append(keys, k)
+	}
+	lk, err := NewLocalKeys(keys...)
+	return lk, errors.Wrapf(err, "invalid key file %s", path)
+}
+
+// KeyID returns the ID of the first key.
+func (k *LocalKeys) KeyID() string {
+	return localKeyID(k.keys[0])
+}
+
+// WrapKey encrypts the data key with the first key.
+func (k *LocalKeys) WrapKey(dataKey []byte) ([]byte, error) {
+	return seal(k.keys[0], dataKey, []byte(k.KeyID()))
+}
+
+// UnwrapKey decrypts the data key with the key identified by keyID.
+func (k *LocalKeys) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	for _, key := range k.keys {
+		if localKeyID(key) == keyID {
+			return open(key, wrapped, []byte(keyID))
+		}
+	}
+	return nil, errors.Errorf("key %s not found", keyID)
+}
+
+// localKeyID identifies a local key by a hash, which does not reveal it.
+func localKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return "local:" + hex.EncodeToString(sum[:8])
+}
+
+// PGPKeys is a KeyProvider that encrypts data keys to PGP keys. The first
+// entity of the key ring is the recipient of new data keys; the private keys
+// of the key ring decrypt them.
+type PGPKeys struct {
+	keyring openpgp.EntityList
+}
+
+// NewPGPKeys returns PGPKeys with the key ring.
+func NewPGPKeys(keyring openpgp.EntityList) (*PGPKeys, error) {
+	if len(keyring) == 0 {
+		return nil, errors.New("no keys")
+	}
+	return &PGPKeys{keyring: keyring}, nil
+}
+
+// LoadPGPKeys reads PGPKeys from an armored or binary key ring. Private keys
+// must not be protected by a passphrase.
+func LoadPGPKeys(path string) (*PGPKeys, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keyring, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+	if err != nil {
+		keyring, err = openpgp.ReadKeyRing(bytes.NewReader(b))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read key ring %s", path)
+		}
+	}
+	pk, err := NewPGPKeys(keyring)
+	return pk, errors.Wrapf(err, "invalid key ring %s", path)
+}
+
+// KeyID returns the fingerprint of the first entity.
+func (k *PGPKeys) KeyID() string {
+	return fmt.Sprintf("pgp:%X", k.keyring[0].PrimaryKey.Fingerprint)
+}
+
+// WrapKey encrypts the data key to the first entity.
+func (k *PGPKeys) WrapKey(dataKey []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := openpgp.Encrypt(&buf, k.keyring[:1], nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(dataKey); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnwrapKey decrypts the data key with the private keys of the key ring.
+func (k *PGPKeys) UnwrapKey(_ string, wrapped []byte) ([]byte, error) {
+	md, err := openpgp.ReadMessage(bytes.NewReader(wrapped), k.keyring, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(md.UnverifiedBody)
+}
+
+// AgeKeys is a KeyProvider that encrypts data keys to age X25519 keys. The
+// public key of the first secret key is the recipient of new data keys; all
+// the secret keys decrypt them.
+type AgeKeys struct {
+	recipient  *age.X25519Recipient
+	identities []age.Identity
+}
+
+// LoadAgeKeys reads AgeKeys from a file of age secret keys, one per line, as
+// written by age-keygen. Empty lines and lines starting with '#' are ignored.
+func LoadAgeKeys(path string) (*AgeKeys, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid age key file %s", path)
+	}
+	first, ok := identities[0].(*age.X25519Identity)
+	if !ok {
+		return nil, errors.Errorf("invalid age key file %s: the first key is not an X25519 key", path)
+	}
+	return &AgeKeys{recipient: first.Recipient(), identities: identities}, nil
+}
+
+// KeyID returns the public key of the first secret key.
+func (k *AgeKeys) KeyID() string {
+	return "age:" + k.recipient.String()
+}
+
+// WrapKey encrypts the data key to the public key of the first secret key.
+func (k *AgeKeys) WrapKey(dataKey []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := age.Encrypt(&buf, k.recipient)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(dataKey); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnwrapKey decrypts the data key with the secret keys.
+func (k *AgeKeys) UnwrapKey(_ string, wrapped []byte) ([]byte, error) {
+	r, err := age.Decrypt(bytes.NewReader(wrapped), k.identities...)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+// ExecKeys is a KeyProvider that runs a command to wrap and unwrap data keys,
+// e.g. with a key management service.
+//
+// The command is run with an extra "wrap" or "unwrap" argument. It reads the
+// key from its standard input and writes the result to its standard output.
+// When unwrapping, the HELM_KEY_ID environment variable holds the ID of the
+// key that wrapped it. The ID of the current key is "exec:" followed by the
+// first line written by the command run with the "key-id" argument.
+type ExecKeys struct {
+	command string
+	args    []string
+
+	once  sync.Once
+	keyID string
+}
+
+// NewExecKeys returns ExecKeys that run the command with the arguments.
+func NewExecKeys(command string, args ...string) *ExecKeys {
+	return &ExecKeys{command: command, args: args}
+}
+
+// KeyID returns the ID of the current key of the command. If the command
+// fails to report it, the name of the command is used.
+func (k *ExecKeys) KeyID() string {
+	k.once.Do(func() {
+		k.keyID = "exec:" + filepath.Base(k.command)
+		if out, err := k.run("key-id", nil, nil); err == nil {
+			if id := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0]); id != "" {
+				k.keyID = "exec:" + id
+			}
+		}
+	})
+	return k.keyID
+}
+
+// WrapKey runs the command to encrypt the data key.
+func (k *ExecKeys) WrapKey(dataKey []byte) ([]byte, error) {
+	return k.run("wrap", dataKey, nil)
+}
+
+// UnwrapKey runs the command to decrypt the data key.
+func (k *ExecKeys) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	return k.run("unwrap", wrapped, []string{"HELM_KEY_ID=" + strings.TrimPrefix(keyID, "exec:")})
+}
+
+func (k *ExecKeys) run(op string, input []byte, env []string) ([]byte, error) {
+	cmd := exec.Command(k.command, append(append([]string{}, k.args...), op)...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = bytes.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "%s %s: %s", k.command, op, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -16,6 +16,14 @@ limitations under the License.
 
 package driver
 
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
 // labels is a map of key value pairs to be included as metadata in a configmap object.
 type labels map[string]string
 
@@ -46,3 +54,32 @@ func (lbs *labels) fromMap(kvs map[string]string) {
 		lbs.set(k, v)
 	}
 }
+
+// heartbeatPatch returns a merge patch that sets the "modifiedAt" label of a
+// storage object to the current time.
+func heartbeatPatch() []byte {
+	return []byte(fmt.Sprintf(`{"metadata":{"labels":{"modifiedAt":%q}}}`, strconv.Itoa(int(time.Now().Unix()))))
+}
+
+// lastModified returns the latest of the "createdAt" and "modifiedAt"
+// timestamps of a storage object.
+func (lbs labels) lastModified() (time.Time, error) {
+	last := int64(-1)
+	for _, key := range []string{"createdAt", "modifiedAt"} {
+		val, ok := lbs[key]
+		if !ok {
+			continue
+		}
+		ts, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return time.Time{}, errors.Wrapf(err, "invalid %s label %q", key, val)
+		}
+		if ts > last {
+			last = ts
+		}
+	}
+	if last < 0 {
+		return time.Time{}, errors.New("no createdAt or modifiedAt label")
+	}
+	return time.Unix(last, 0), nil
+}
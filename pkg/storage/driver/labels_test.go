@@ -18,6 +18,7 @@ package driver // import "helm.sh/helm/v3/pkg/storage/driver"
 
 import (
 	"testing"
+	"time"
 )
 
 func TestLabelsMatch(t *testing.T) {
@@ -47,3 +48,21 @@ func TestLabelsMatch(t *testing.T) {
 		}
 	}
 }
+
+func TestLabelsLastModified(t *testing.T) {
+	lbs := labels(map[string]string{"createdAt": "100", "modifiedAt": "200"})
+	if last, err := lbs.lastModified(); err != nil || !last.Equal(time.Unix(200, 0)) {
+		t.Errorf("Expected %s, got %s (%v)", time.Unix(200, 0), last, err)
+	}
+
+	lbs = labels(map[string]string{"createdAt": "100"})
+	if last, err := lbs.lastModified(); err != nil || !last.Equal(time.Unix(100, 0)) {
+		t.Errorf("Expected %s, got %s (%v)", time.Unix(100, 0), last, err)
+	}
+
+	for _, lbs := range []labels{{}, {"modifiedAt": "yesterday"}} {
+		if _, err := lbs.lastModified(); err == nil {
+			t.Errorf("Expected an error for labels %v", lbs)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v3/pkg/storage/driver"
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coordinationv1client "k8s.io/client-go/kubernetes/typed/coordination/v1"
+)
+
+// Leases locks releases with Kubernetes Lease objects. It is the lock
+// backend of the Secrets and ConfigMaps drivers.
+//
+// A lease is renewed while its lock is held, so that the lock of a client
+// that crashed expires after LeaseDuration.
+type Leases struct {
+	impl coordinationv1client.LeaseInterface
+
+	// Identity identifies this client as the holder of a lease.
+	Identity string
+	// LeaseDuration is how long a lease stays valid without being renewed.
+	LeaseDuration time.Duration
+	// RetryPeriod is how long to wait between attempts to acquire a lease
+	// held by another client.
+	RetryPeriod time.Duration
+
+	Log func(string, ...interface{})
+}
+
+// NewLeases initializes a new Leases wrapping an implementation of the
+// kubernetes LeaseInterface.
+func NewLeases(impl coordinationv1client.LeaseInterface) *Leases {
+	return &Leases{
+		impl:          impl,
+		Identity:      leaseIdentity(),
+		LeaseDuration: 60 * time.Second,
+		RetryPeriod:   2 * time.Second,
+		Log:           func(_ string, _ ...interface{}) {},
+	}
+}
+
+// Lock acquires the Lease of the named release. It implements storage.Locker.
+//
+// If the client is not allowed to manage Leases, the release is not locked
+// and a warning is logged, so that Helm keeps working with restricted RBAC
+// permissions.
+func (l *Leases) Lock(ctx context.Context, name string) (func() error, error) {
+	key := leaseKey(name)
+	for {
+		acquired, holder, err := l.tryAcquire(key)
+		if apierrors.IsForbidden(err) {
+			l.Log("warning: not allowed to manage leases, release %q is not locked: %s", name, err)
+			return func() error { return nil }, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "lock: failed to acquire lease %q", key)
+		}
+		if acquired {
+			return l.hold(key), nil
+		}
+
+		l.Log("release %q is locked by %s, retrying in %s", name, holder, l.RetryPeriod)
+		select {
+		case <-ctx.Done():
+			return nil, errors.Errorf("release %q is locked by %s", name, holder)
+		case <-time.After(l.RetryPeriod):
+		}
+	}
+}
+
+// tryAcquire makes one attempt at acquiring the lease named by key. If the
+// lease is held by another client, its holder is returned.
+func (l *Leases) tryAcquire(key string) (bool, string, error) {
+	now := metav1.NewMicroTime(time.Now())
+	duration := int32(l.LeaseDuration / time.Second)
+
+	lease, err := l.impl.Get(context.Background(), key, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   key,
+				Labels: map[string]string{"owner": "helm"},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &l.Identity,
+				LeaseDurationSeconds: &duration,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		_, err = l.impl.Create(context.Background(), lease, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return false, "another client", nil
+		}
+		return err == nil, "", err
+	}
+	if err != nil {
+		return false, "", err
+	}
+
+	if !leaseExpired(lease, now.Time) {
+		return false, leaseHolder(lease), nil
+	}
+
+	// The previous holder did not release or renew the lease in time. The
+	// update fails with a conflict if another client takes it over first.
+	l.Log("taking over expired lease %q of %s", key, leaseHolder(lease))
+	lease.Spec.HolderIdentity = &l.Identity
+	lease.Spec.LeaseDurationSeconds = &duration
+	lease.Spec.AcquireTime = &now
+	lease.Spec.RenewTime = &now
+	_, err = l.impl.Update(context.Background(), lease, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		return false, "another client", nil
+	}
+	return err == nil, "", err
+}
+
+// hold renews the lease named by key until the returned function is called,
+// which then deletes the lease.
+func (l *Leases) hold(key string) func() error {
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(l.LeaseDuration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := l.renew(key); err != nil {
+					l.Log("warning: failed to renew lease %q: %s", key, err)
+				}
+			}
+		}
+	}()
+
+	return func() error {
+		close(stop)
+		<-done
+
+		lease, err := l.impl.Get(context.Background(), key, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "unlock: failed to get lease %q", key)
+		}
+		if leaseHolder(lease) != l.Identity {
+			return errors.Errorf("unlock: lease %q has been taken over by %s", key, leaseHolder(lease))
+		}
+		err = l.impl.Delete(context.Background(), key, metav1.DeleteOptions{
+			Preconditions: &metav1.Preconditions{ResourceVersion: &lease.ResourceVersion},
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "unlock: failed to delete lease %q", key)
+		}
+		return nil
+	}
+}
+
+// renew extends the lease named by key, if it is still held by this client.
+func (l *Leases) renew(key string) error {
+	lease, err := l.impl.Get(context.Background(), key, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if leaseHolder(lease) != l.Identity {
+		return errors.Errorf("lease has been taken over by %s", leaseHolder(lease))
+	}
+	now := metav1.NewMicroTime(time.Now())
+	lease.Spec.RenewTime = &now
+	_, err = l.impl.Update(context.Background(), lease, metav1.UpdateOptions{})
+	return err
+}
+
+// leaseKey returns the name of the Lease locking the named release.
+func leaseKey(name string) string {
+	return fmt.Sprintf("sh.helm.release.v1.%s.lock", name)
+}
+
+func leaseHolder(lease *coordinationv1.Lease) string {
+	if lease.Spec.HolderIdentity == nil {
+		return "an unknown client"
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+// leaseExpired reports whether the lease has not been renewed within its
+// duration.
+func leaseExpired(lease *coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return now.After(expiry)
+}
+
+// leaseIdentity returns an identity that is unique to this process.
+func leaseIdentity() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "helm"
+	}
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%s_%d", host, os.Getpid())
+	}
+	return fmt.Sprintf("%s_%s", host, hex.EncodeToString(b))
+}
@@ -0,0 +1,146 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newTestFixtureLeases(identity string, objects ...runtime.Object) (*Leases, *fake.Clientset) {
+	clientset := fake.NewSimpleClientset(objects...)
+	l := NewLeases(clientset.CoordinationV1().Leases("default"))
+	l.Identity = identity
+	l.RetryPeriod = time.Millisecond
+	return l, clientset
+}
+
+func TestLeasesLock(t *testing.T) {
+	l, clientset := newTestFixtureLeases("alice")
+	leases := clientset.CoordinationV1().Leases("default")
+
+	unlock, err := l.Lock(context.Background(), "smug-pigeon")
+	if err != nil {
+		t.Fatalf("failed to lock release: %v", err)
+	}
+
+	lease, err := leases.Get(context.Background(), leaseKey("smug-pigeon"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get lease: %v", err)
+	}
+	if holder := leaseHolder(lease); holder != "alice" {
+		t.Errorf("expected lease to be held by alice, got %s", holder)
+	}
+
+	// Another client fails to acquire the lock until it is released.
+	other := NewLeases(leases)
+	other.Identity = "bob"
+	other.RetryPeriod = time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := other.Lock(ctx, "smug-pigeon"); err == nil || !strings.Contains(err.Error(), "locked by alice") {
+		t.Errorf("expected release to be locked by alice, got %v", err)
+	}
+
+	if err := unlock(); err != nil {
+		t.Fatalf("failed to unlock release: %v", err)
+	}
+	if _, err := leases.Get(context.Background(), leaseKey("smug-pigeon"), metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected lease to be deleted, got %v", err)
+	}
+
+	unlock, err = other.Lock(context.Background(), "smug-pigeon")
+	if err != nil {
+		t.Fatalf("failed to lock released release: %v", err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatalf("failed to unlock release: %v", err)
+	}
+}
+
+func TestLeasesLockExpired(t *testing.T) {
+	holder := "bob"
+	duration := int32(60)
+	renewed := metav1.NewMicroTime(time.Now().Add(-2 * time.Minute))
+	expired := &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: leaseKey("smug-pigeon"), Namespace: "default"},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &duration,
+			RenewTime:            &renewed,
+		},
+	}
+
+	l, clientset := newTestFixtureLeases("alice", expired)
+
+	unlock, err := l.Lock(context.Background(), "smug-pigeon")
+	if err != nil {
+		t.Fatalf("failed to take over expired lease: %v", err)
+	}
+	lease, err := clientset.CoordinationV1().Leases("default").Get(context.Background(), leaseKey("smug-pigeon"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get lease: %v", err)
+	}
+	if holder := leaseHolder(lease); holder != "alice" {
+		t.Errorf("expected lease to be held by alice, got %s", holder)
+	}
+	if err := unlock(); err != nil {
+		t.Fatalf("failed to unlock release: %v", err)
+	}
+}
+
+func TestLeasesLockForbidden(t *testing.T) {
+	l, clientset := newTestFixtureLeases("alice")
+	clientset.PrependReactor("*", "leases", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, "", nil)
+	})
+
+	unlock, err := l.Lock(context.Background(), "smug-pigeon")
+	if err != nil {
+		t.Fatalf("expected lock to be skipped without permission to manage leases, got %v", err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatalf("failed to unlock release: %v", err)
+	}
+}
+
+func TestLeaseExpired(t *testing.T) {
+	now := time.Now()
+	duration := int32(60)
+	renewed := metav1.NewMicroTime(now.Add(-30 * time.Second))
+	lease := &coordinationv1.Lease{Spec: coordinationv1.LeaseSpec{LeaseDurationSeconds: &duration, RenewTime: &renewed}}
+
+	if leaseExpired(lease, now) {
+		t.Error("expected lease renewed 30s ago not to be expired")
+	}
+	if !leaseExpired(lease, now.Add(time.Minute)) {
+		t.Error("expected lease renewed 90s ago to be expired")
+	}
+	if !leaseExpired(&coordinationv1.Lease{}, now) {
+		t.Error("expected lease without renew time to be expired")
+	}
+}
@@ -20,11 +20,13 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	rspb "helm.sh/helm/v3/pkg/release"
 )
 
 var _ Driver = (*Memory)(nil)
+var _ Heartbeater = (*Memory)(nil)
 
 const (
 	// MemoryDriverName is the string name of this driver.
@@ -164,14 +166,16 @@ func (mem *Memory) Create(key string, rls *rspb.Release) error {
 		mem.cache[namespace] = memReleases{}
 	}
 
+	rec := newRecord(key, rls)
+	rec.modified = time.Now()
 	if recs, ok := mem.cache[namespace][rls.Name]; ok {
-		if err := recs.Add(newRecord(key, rls)); err != nil {
+		if err := recs.Add(rec); err != nil {
 			return err
 		}
 		mem.cache[namespace][rls.Name] = recs
 		return nil
 	}
-	mem.cache[namespace][rls.Name] = records{newRecord(key, rls)}
+	mem.cache[namespace][rls.Name] = records{rec}
 	return nil
 }
 
@@ -188,7 +192,9 @@ func (mem *Memory) Update(key string, rls *rspb.Release) error {
 
 	if _, ok := mem.cache[namespace]; ok {
 		if rs, ok := mem.cache[namespace][rls.Name]; ok && rs.Exists(key) {
-			rs.Replace(key, newRecord(key, rls))
+			rec := newRecord(key, rls)
+			rec.modified = time.Now()
+			rs.Replace(key, rec)
 			return nil
 		}
 	}
@@ -222,6 +228,47 @@ func (mem *Memory) Delete(key string) (*rspb.Release, error) {
 	return nil, ErrReleaseNotFound
 }
 
+// Heartbeat marks the release named by key as modified now.
+func (mem *Memory) Heartbeat(key string) error {
+	defer unlock(mem.wlock())
+
+	rec, err := mem.record(key)
+	if err != nil {
+		return err
+	}
+	rec.modified = time.Now()
+	return nil
+}
+
+// LastHeartbeat returns when the release named by key was last stored or
+// heartbeated.
+func (mem *Memory) LastHeartbeat(key string) (time.Time, error) {
+	defer unlock(mem.rlock())
+
+	rec, err := mem.record(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return rec.modified, nil
+}
+
+// record returns the record of the release named by key in the current
+// namespace. The caller must hold the lock.
+func (mem *Memory) record(key string) (*record, error) {
+	keyWithoutPrefix := strings.TrimPrefix(key, "sh.helm.release.v1.")
+	elems := strings.Split(keyWithoutPrefix, ".v")
+	if len(elems) != 2 {
+		return nil, ErrInvalidKey
+	}
+	if _, err := strconv.Atoi(elems[1]); err != nil {
+		return nil, ErrInvalidKey
+	}
+	if rec := mem.cache[mem.namespace][elems[0]].Get(key); rec != nil {
+		return rec, nil
+	}
+	return nil, ErrReleaseNotFound
+}
+
 // wlock locks mem for writing
 func (mem *Memory) wlock() func() {
 	mem.Lock()
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	rspb "helm.sh/helm/v3/pkg/release"
 )
@@ -287,3 +288,28 @@ func TestMemoryDelete(t *testing.T) {
 	}
 
 }
+
+func TestMemoryHeartbeat(t *testing.T) {
+	ts := tsFixtureMemory(t)
+	ts.SetNamespace("default")
+
+	before := time.Now()
+	if err := ts.Heartbeat("rls-a.v4"); err != nil {
+		t.Fatalf("Failed to record heartbeat: %s", err)
+	}
+
+	last, err := ts.LastHeartbeat("rls-a.v4")
+	if err != nil {
+		t.Fatalf("Failed to get last heartbeat: %s", err)
+	}
+	if last.Before(before) {
+		t.Errorf("Expected heartbeat after %s, got %s", before, last)
+	}
+
+	if err := ts.Heartbeat("rls-c.v4"); err != ErrReleaseNotFound {
+		t.Errorf("Expected %s for a release in another namespace, got %v", ErrReleaseNotFound, err)
+	}
+	if _, err := ts.LastHeartbeat("rls-a"); err != ErrInvalidKey {
+		t.Errorf("Expected %s, got %v", ErrInvalidKey, err)
+	}
+}
@@ -18,6 +18,7 @@ package driver // import "helm.sh/helm/v3/pkg/storage/driver"
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"testing"
 
@@ -29,6 +30,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kblabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 
 	rspb "helm.sh/helm/v3/pkg/release"
@@ -99,7 +101,7 @@ func (mock *MockConfigMapsInterface) Init(t *testing.T, releases ...*rspb.Releas
 	for _, rls := range releases {
 		objkey := testKey(rls.Name, rls.Version)
 
-		cfgmap, err := newConfigMapsObject(objkey, rls, nil)
+		cfgmap, err := newConfigMapsObject(objkey, rls, nil, "")
 		if err != nil {
 			t.Fatalf("Failed to create configmap: %s", err)
 		}
@@ -153,6 +155,22 @@ func (mock *MockConfigMapsInterface) Update(_ context.Context, cfgmap *v1.Config
 	return cfgmap, nil
 }
 
+// Patch merges the labels of a merge patch into a ConfigMap.
+func (mock *MockConfigMapsInterface) Patch(_ context.Context, name string, _ types.PatchType, data []byte, _ metav1.PatchOptions, _ ...string) (*v1.ConfigMap, error) {
+	object, ok := mock.objects[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(v1.Resource("tests"), name)
+	}
+	var patch v1.ConfigMap
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, err
+	}
+	for k, v := range patch.Labels {
+		object.Labels[k] = v
+	}
+	return object, nil
+}
+
 // Delete deletes a ConfigMap by name.
 func (mock *MockConfigMapsInterface) Delete(_ context.Context, name string, _ metav1.DeleteOptions) error {
 	if _, ok := mock.objects[name]; !ok {
@@ -185,7 +203,7 @@ func (mock *MockSecretsInterface) Init(t *testing.T, releases ...*rspb.Release)
 	for _, rls := range releases {
 		objkey := testKey(rls.Name, rls.Version)
 
-		secret, err := newSecretsObject(objkey, rls, nil)
+		secret, err := newSecretsObject(objkey, rls, nil, "")
 		if err != nil {
 			t.Fatalf("Failed to create secret: %s", err)
 		}
@@ -239,6 +257,22 @@ func (mock *MockSecretsInterface) Update(_ context.Context, secret *v1.Secret, _
 	return secret, nil
 }
 
+// Patch merges the labels of a merge patch into a Secret.
+func (mock *MockSecretsInterface) Patch(_ context.Context, name string, _ types.PatchType, data []byte, _ metav1.PatchOptions, _ ...string) (*v1.Secret, error) {
+	object, ok := mock.objects[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(v1.Resource("tests"), name)
+	}
+	var patch v1.Secret
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, err
+	}
+	for k, v := range patch.Labels {
+		object.Labels[k] = v
+	}
+	return object, nil
+}
+
 // Delete deletes a Secret by name.
 func (mock *MockSecretsInterface) Delete(_ context.Context, name string, _ metav1.DeleteOptions) error {
 	if _, ok := mock.objects[name]; !ok {
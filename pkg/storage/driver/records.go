@@ -19,6 +19,7 @@ package driver // import "helm.sh/helm/v3/pkg/storage/driver"
 import (
 	"sort"
 	"strconv"
+	"time"
 
 	rspb "helm.sh/helm/v3/pkg/release"
 )
@@ -107,6 +108,9 @@ type record struct {
 	key string
 	lbs labels
 	rls *rspb.Release
+	// modified is when the record was last stored or heartbeated. It is set
+	// by the Memory driver.
+	modified time.Time
 }
 
 // newRecord creates a new in-memory release record
@@ -27,6 +27,7 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kblabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/validation"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 
@@ -34,6 +35,7 @@ import (
 )
 
 var _ Driver = (*Secrets)(nil)
+var _ Heartbeater = (*Secrets)(nil)
 
 // SecretsDriverName is the string name of the driver.
 const SecretsDriverName = "Secret"
@@ -43,6 +45,13 @@ const SecretsDriverName = "Secret"
 type Secrets struct {
 	impl corev1.SecretInterface
 	Log  func(string, ...interface{})
+	// Compression is the codec that compresses the releases. The default
+	// is CompressionGzip.
+	Compression Compression
+	// ChunkSize is the maximum size of the encoded release stored in a
+	// Secret. Larger releases are split across several Secrets. Zero means
+	// DefaultChunkSize.
+	ChunkSize int
 }
 
 // NewSecrets initializes a new Secrets wrapping an implementation of
@@ -71,7 +80,7 @@ func (secrets *Secrets) Get(key string) (*rspb.Release, error) {
 		return nil, errors.Wrapf(err, "get: failed to get %q", key)
 	}
 	// found the secret, decode the base64 data string
-	r, err := decodeRelease(string(obj.Data["release"]))
+	r, err := secrets.decode(obj)
 	return r, errors.Wrapf(err, "get: failed to decode data %q", key)
 }
 
@@ -91,8 +100,9 @@ func (secrets *Secrets) List(filter func(*rspb.Release) bool) ([]*rspb.Release,
 
 	// iterate over the secrets object list
 	// and decode each release
-	for _, item := range list.Items {
-		rls, err := decodeRelease(string(item.Data["release"]))
+	for i := range list.Items {
+		item := &list.Items[i]
+		rls, err := secrets.decode(item)
 		if err != nil {
 			secrets.Log("list: failed to decode release: %v: %s", item, err)
 			continue
@@ -117,6 +127,8 @@ func (secrets *Secrets) Query(labels map[string]string) ([]*rspb.Release, error)
 		}
 		ls[k] = v
 	}
+	// Match the releases only, not the chunks of large releases
+	ls["owner"] = "helm"
 
 	opts := metav1.ListOptions{LabelSelector: ls.AsSelector().String()}
 
@@ -130,8 +142,8 @@ func (secrets *Secrets) Query(labels map[string]string) ([]*rspb.Release, error)
 	}
 
 	var results []*rspb.Release
-	for _, item := range list.Items {
-		rls, err := decodeRelease(string(item.Data["release"]))
+	for i := range list.Items {
+		rls, err := secrets.decode(&list.Items[i])
 		if err != nil {
 			secrets.Log("query: failed to decode release: %s", err)
 			continue
@@ -151,12 +163,17 @@ func (secrets *Secrets) Create(key string, rls *rspb.Release) error {
 	lbs.set("createdAt", strconv.Itoa(int(time.Now().Unix())))
 
 	// create a new secret to hold the release
-	obj, err := newSecretsObject(key, rls, lbs)
+	obj, err := newSecretsObject(key, rls, lbs, secrets.Compression)
 	if err != nil {
 		return errors.Wrapf(err, "create: failed to encode release %q", rls.Name)
 	}
+	chunks, err := secrets.createChunks(obj)
+	if err != nil {
+		return errors.Wrap(err, "create: failed to create chunks")
+	}
 	// push the secret object out into the kubiverse
 	if _, err := secrets.impl.Create(context.Background(), obj, metav1.CreateOptions{}); err != nil {
+		secrets.deleteChunks(chunks)
 		if apierrors.IsAlreadyExists(err) {
 			return ErrReleaseExists
 		}
@@ -176,13 +193,45 @@ func (secrets *Secrets) Update(key string, rls *rspb.Release) error {
 	lbs.set("modifiedAt", strconv.Itoa(int(time.Now().Unix())))
 
 	// create a new secret object to hold the release
-	obj, err := newSecretsObject(key, rls, lbs)
+	obj, err := newSecretsObject(key, rls, lbs, secrets.Compression)
 	if err != nil {
 		return errors.Wrapf(err, "update: failed to encode release %q", rls.Name)
 	}
+	chunks, err := secrets.createChunks(obj)
+	if err != nil {
+		return errors.Wrap(err, "update: failed to create chunks")
+	}
 	// push the secret object out into the kubiverse
-	_, err = secrets.impl.Update(context.Background(), obj, metav1.UpdateOptions{})
-	return errors.Wrap(err, "update: failed to update")
+	if _, err = secrets.impl.Update(context.Background(), obj, metav1.UpdateOptions{}); err != nil {
+		secrets.deleteChunks(chunks)
+		return errors.Wrap(err, "update: failed to update")
+	}
+	// the chunks of the previous version of the object are no longer used
+	secrets.pruneChunks(obj.Labels, obj.Labels["chunkId"])
+	return nil
+}
+
+// Heartbeat sets the "modifiedAt" label of the Secret holding the release
+// named by key to the current time.
+func (secrets *Secrets) Heartbeat(key string) error {
+	_, err := secrets.impl.Patch(context.Background(), key, types.MergePatchType, heartbeatPatch(), metav1.PatchOptions{})
+	if apierrors.IsNotFound(err) {
+		return ErrReleaseNotFound
+	}
+	return errors.Wrapf(err, "heartbeat: failed to patch %q", key)
+}
+
+// LastHeartbeat returns the latest of the "createdAt" and "modifiedAt" labels
+// of the Secret holding the release named by key.
+func (secrets *Secrets) LastHeartbeat(key string) (time.Time, error) {
+	obj, err := secrets.impl.Get(context.Background(), key, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return time.Time{}, ErrReleaseNotFound
+		}
+		return time.Time{}, errors.Wrapf(err, "heartbeat: failed to get %q", key)
+	}
+	return labels(obj.Labels).lastModified()
 }
 
 // Delete deletes the Secret holding the release named by key.
@@ -192,13 +241,101 @@ func (secrets *Secrets) Delete(key string) (rls *rspb.Release, err error) {
 		return nil, err
 	}
 	// delete the release
-	err = secrets.impl.Delete(context.Background(), key, metav1.DeleteOptions{})
-	return rls, err
+	if err = secrets.impl.Delete(context.Background(), key, metav1.DeleteOptions{}); err != nil {
+		return rls, err
+	}
+	secrets.pruneChunks(map[string]string{"name": rls.Name, "version": strconv.Itoa(rls.Version)}, "")
+	return rls, nil
+}
+
+// decode decodes the release held by the Secret, reading its other chunks if
+// it has been split.
+func (secrets *Secrets) decode(obj *v1.Secret) (*rspb.Release, error) {
+	for attempt := 0; ; attempt++ {
+		data, err := joinChunks(obj.Name, string(obj.Data["release"]), obj.Labels, func(name string) (string, error) {
+			chunk, err := secrets.impl.Get(context.Background(), name, metav1.GetOptions{})
+			if err != nil {
+				return "", err
+			}
+			return string(chunk.Data["chunk"]), nil
+		})
+		if err == nil {
+			return decodeRelease(data)
+		}
+		if !apierrors.IsNotFound(errors.Cause(err)) || attempt == chunkRetries {
+			return nil, err
+		}
+		// The release was updated while its chunks were read, so read it again
+		if obj, err = secrets.impl.Get(context.Background(), obj.Name, metav1.GetOptions{}); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// createChunks splits the release held by obj if it is larger than the chunk
+// size. obj keeps the first chunk, and the others are stored in new Secrets,
+// which are returned.
+func (secrets *Secrets) createChunks(obj *v1.Secret) ([]string, error) {
+	parts := splitChunks(string(obj.Data["release"]), secrets.ChunkSize)
+	if len(parts) == 1 {
+		return nil, nil
+	}
+	id, err := newChunkID()
+	if err != nil {
+		return nil, err
+	}
+	var created []string
+	for i, part := range parts[1:] {
+		chunk := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   chunkName(obj.Name, id, i+1),
+				Labels: chunkLabels(obj.Labels, id),
+			},
+			Type: "helm.sh/release-chunk.v1",
+			Data: map[string][]byte{"chunk": []byte(part)},
+		}
+		if _, err := secrets.impl.Create(context.Background(), chunk, metav1.CreateOptions{}); err != nil {
+			secrets.deleteChunks(created)
+			return nil, err
+		}
+		created = append(created, chunk.Name)
+	}
+	obj.Data["release"] = []byte(parts[0])
+	setChunkLabels(obj.Labels, id, len(parts))
+	return created, nil
+}
+
+// deleteChunks deletes the named chunk Secrets. Failures are logged, as the
+// chunks are not referenced by any release.
+func (secrets *Secrets) deleteChunks(names []string) {
+	for _, name := range names {
+		if err := secrets.impl.Delete(context.Background(), name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			secrets.Log("failed to delete chunk %q: %s", name, err)
+		}
+	}
+}
+
+// pruneChunks deletes the chunk Secrets of the release with the given labels,
+// except those of the chunk ID keep.
+func (secrets *Secrets) pruneChunks(lbs map[string]string, keep string) {
+	sel := kblabels.Set{"owner": chunkOwner, "name": lbs["name"], "version": lbs["version"]}.AsSelector()
+	list, err := secrets.impl.List(context.Background(), metav1.ListOptions{LabelSelector: sel.String()})
+	if err != nil {
+		secrets.Log("failed to list the chunks of release %q: %s", lbs["name"], err)
+		return
+	}
+	var names []string
+	for _, item := range list.Items {
+		if item.Labels["chunkId"] != keep {
+			names = append(names, item.Name)
+		}
+	}
+	secrets.deleteChunks(names)
 }
 
 // newSecretsObject constructs a kubernetes Secret object
 // to store a release. Each secret data entry is the base64
-// encoded gzipped string of a release.
+// encoded compressed string of a release.
 //
 // The following labels are used within each secret:
 //
@@ -208,12 +345,14 @@ func (secrets *Secrets) Delete(key string) (rls *rspb.Release, err error) {
 //    "status"         - status of the release (see pkg/release/status.go for variants)
 //    "owner"          - owner of the secret, currently "helm".
 //    "name"           - name of the release.
+//    "chunks"         - number of chunks of the release, if it is split. (set in Create and Update)
+//    "chunkId"        - identifier of the chunks of the release, if it is split. (set in Create and Update)
 //
-func newSecretsObject(key string, rls *rspb.Release, lbs labels) (*v1.Secret, error) {
+func newSecretsObject(key string, rls *rspb.Release, lbs labels, compression Compression) (*v1.Secret, error) {
 	const owner = "helm"
 
 	// encode the release
-	s, err := encodeRelease(rls)
+	s, err := encodeReleaseWith(rls, compression)
 	if err != nil {
 		return nil, err
 	}
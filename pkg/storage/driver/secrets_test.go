@@ -14,10 +14,15 @@ limitations under the License.
 package driver
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 	"testing"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
 
@@ -59,7 +64,7 @@ func TestUNcompressedSecretGet(t *testing.T) {
 	rel := releaseStub(name, vers, namespace, rspb.StatusDeployed)
 
 	// Create a test fixture which contains an uncompressed release
-	secret, err := newSecretsObject(key, rel, nil)
+	secret, err := newSecretsObject(key, rel, nil, "")
 	if err != nil {
 		t.Fatalf("Failed to create secret: %s", err)
 	}
@@ -239,3 +244,183 @@ func TestSecretDelete(t *testing.T) {
 		t.Errorf("Expected {%v}, got {%v}", ErrReleaseNotFound, err)
 	}
 }
+
+func TestSecretHeartbeat(t *testing.T) {
+	vers := 1
+	name := "smug-pigeon"
+	namespace := "default"
+	key := testKey(name, vers)
+	rel := releaseStub(name, vers, namespace, rspb.StatusPendingUpgrade)
+
+	secrets := newTestFixtureSecrets(t, []*rspb.Release{rel}...)
+
+	before := time.Now().Add(-time.Second)
+	if err := secrets.Heartbeat(key); err != nil {
+		t.Fatalf("Failed to record heartbeat: %s", err)
+	}
+
+	last, err := secrets.LastHeartbeat(key)
+	if err != nil {
+		t.Fatalf("Failed to get last heartbeat: %s", err)
+	}
+	if last.Before(before) {
+		t.Errorf("Expected heartbeat after %s, got %s", before, last)
+	}
+
+	if err := secrets.Heartbeat(testKey("missing", 1)); err != ErrReleaseNotFound {
+		t.Errorf("Expected %s, got %v", ErrReleaseNotFound, err)
+	}
+}
+
+func TestSecretChunks(t *testing.T) {
+	name := "smug-pigeon"
+	rel := releaseStub(name, 1, "default", rspb.StatusDeployed)
+	key := testKey(name, 1)
+
+	secrets := newTestFixtureSecrets(t)
+	secrets.ChunkSize = 64
+	mock := secrets.impl.(*MockSecretsInterface)
+
+	if err := secrets.Create(key, rel); err != nil {
+		t.Fatalf("Failed to create release: %s", err)
+	}
+	chunks := chunkNames(mock.objects)
+	if len(chunks) < 2 {
+		t.Fatalf("Expected the release to be split, got chunks %v", chunks)
+	}
+	obj := mock.objects[key]
+	if got := obj.Labels["chunks"]; got != strconv.Itoa(len(chunks)+1) {
+		t.Errorf("Expected %d chunks, got label %q", len(chunks)+1, got)
+	}
+	if len(obj.Data["release"]) > 64 {
+		t.Errorf("Expected the Secret to hold at most 64 bytes, got %d", len(obj.Data["release"]))
+	}
+
+	got, err := secrets.Get(key)
+	if err != nil {
+		t.Fatalf("Failed to get release: %s", err)
+	}
+	if !reflect.DeepEqual(rel, got) {
+		t.Errorf("Expected {%v}, got {%v}", rel, got)
+	}
+
+	// chunks are not listed as releases
+	all, err := secrets.List(func(*rspb.Release) bool { return true })
+	if err != nil || len(all) != 1 {
+		t.Fatalf("Expected 1 release, got %d: %v", len(all), err)
+	}
+	if all[0].Name != name {
+		t.Errorf("Expected release %q, got %q", name, all[0].Name)
+	}
+	query, err := secrets.Query(map[string]string{"name": name, "owner": "helm"})
+	if err != nil || len(query) != 1 {
+		t.Fatalf("Expected 1 release, got %d: %v", len(query), err)
+	}
+	var logged []string
+	secrets.Log = func(format string, v ...interface{}) { logged = append(logged, fmt.Sprintf(format, v...)) }
+	query, err = secrets.Query(map[string]string{"name": name})
+	if err != nil || len(query) != 1 {
+		t.Fatalf("Expected 1 release without the owner label, got %d: %v", len(query), err)
+	}
+	if len(logged) != 0 {
+		t.Errorf("Expected the chunks not to be queried, got %v", logged)
+	}
+
+	// a reader of the previous version of the Secret reads the new chunks
+	previous := obj.DeepCopy()
+	rel.Info.Description = "updated"
+	if err := secrets.Update(key, rel); err != nil {
+		t.Fatalf("Failed to update release: %s", err)
+	}
+	for _, c := range chunks {
+		if _, ok := mock.objects[c]; ok {
+			t.Errorf("Expected chunk %q of the previous version to be deleted", c)
+		}
+	}
+	got, err = secrets.decode(previous)
+	if err != nil {
+		t.Fatalf("Failed to decode release: %s", err)
+	}
+	if got.Info.Description != "updated" {
+		t.Errorf("Expected the updated release, got description %q", got.Info.Description)
+	}
+
+	// a release that fits in one Secret has no chunks
+	secrets.ChunkSize = 0
+	if err := secrets.Update(key, rel); err != nil {
+		t.Fatalf("Failed to update release: %s", err)
+	}
+	if chunks := chunkNames(mock.objects); len(chunks) != 0 {
+		t.Errorf("Expected no chunks, got %v", chunks)
+	}
+	if _, ok := mock.objects[key].Labels["chunks"]; ok {
+		t.Error("Expected no chunks label")
+	}
+
+	secrets.ChunkSize = 64
+	if err := secrets.Update(key, rel); err != nil {
+		t.Fatalf("Failed to update release: %s", err)
+	}
+	if _, err := secrets.Delete(key); err != nil {
+		t.Fatalf("Failed to delete release: %s", err)
+	}
+	if len(mock.objects) != 0 {
+		t.Errorf("Expected all Secrets to be deleted, got %d", len(mock.objects))
+	}
+}
+
+func TestSecretMissingChunk(t *testing.T) {
+	rel := releaseStub("smug-pigeon", 1, "default", rspb.StatusDeployed)
+	key := testKey(rel.Name, 1)
+
+	secrets := newTestFixtureSecrets(t)
+	secrets.ChunkSize = 64
+	mock := secrets.impl.(*MockSecretsInterface)
+	if err := secrets.Create(key, rel); err != nil {
+		t.Fatalf("Failed to create release: %s", err)
+	}
+	delete(mock.objects, chunkNames(mock.objects)[0])
+
+	if _, err := secrets.Get(key); err == nil {
+		t.Error("Expected an error for a missing chunk")
+	}
+}
+
+func TestSecretCompression(t *testing.T) {
+	rel := releaseStub("smug-pigeon", 1, "default", rspb.StatusDeployed)
+	key := testKey(rel.Name, 1)
+
+	secrets := newTestFixtureSecrets(t)
+	secrets.Compression = CompressionZstd
+	if err := secrets.Create(key, rel); err != nil {
+		t.Fatalf("Failed to create release: %s", err)
+	}
+	data, err := base64.StdEncoding.DecodeString(string(secrets.impl.(*MockSecretsInterface).objects[key].Data["release"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, magicZstd) {
+		t.Errorf("Expected a zstd compressed release, got %x", data[:4])
+	}
+
+	// a driver with another codec still reads the release
+	secrets.Compression = CompressionGzip
+	got, err := secrets.Get(key)
+	if err != nil {
+		t.Fatalf("Failed to get release: %s", err)
+	}
+	if !reflect.DeepEqual(rel, got) {
+		t.Errorf("Expected {%v}, got {%v}", rel, got)
+	}
+}
+
+func chunkNames(objects map[string]*v1.Secret) []string {
+	var names []string
+	for name, obj := range objects {
+		if obj.Labels["owner"] == chunkOwner {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package driver // import "helm.sh/helm/v3/pkg/storage/driver"
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"time"
@@ -33,6 +34,7 @@ import (
 )
 
 var _ Driver = (*SQL)(nil)
+var _ Heartbeater = (*SQL)(nil)
 
 var labelMap = map[string]struct{}{
 	"modifiedAt": {},
@@ -443,6 +445,104 @@ func (s *SQL) Update(key string, rls *rspb.Release) error {
 	return nil
 }
 
+// Heartbeat sets the modifiedAt column of the release named by key to the
+// current time.
+func (s *SQL) Heartbeat(key string) error {
+	query, args, err := s.statementBuilder.
+		Update(sqlReleaseTableName).
+		Set(sqlReleaseTableModifiedAtColumn, int(time.Now().Unix())).
+		Where(sq.Eq{sqlReleaseTableKeyColumn: key}).
+		Where(sq.Eq{sqlReleaseTableNamespaceColumn: s.namespace}).
+		ToSql()
+	if err != nil {
+		s.Log("failed to build heartbeat query: %v", err)
+		return err
+	}
+
+	res, err := s.db.Exec(query, args...)
+	if err != nil {
+		s.Log("failed to record heartbeat of release %s in SQL database: %v", key, err)
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrReleaseNotFound
+	}
+	return nil
+}
+
+// sqlLockRetryPeriod is how long Lock waits between attempts to acquire an
+// advisory lock held by another client.
+var sqlLockRetryPeriod = 2 * time.Second
+
+// Lock acquires a PostgreSQL session-level advisory lock for the named release
+// in the namespace of the driver. It implements storage.Locker.
+//
+// The lock is bound to a dedicated connection, so it is released by the
+// database if the client dies while holding it.
+func (s *SQL) Lock(ctx context.Context, name string) (func() error, error) {
+	key := s.namespace + "/" + name
+
+	conn, err := s.db.Connx(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("lock: failed to open a connection: %v", err)
+	}
+
+	for {
+		var acquired bool
+		if err := conn.QueryRowxContext(context.Background(), "SELECT pg_try_advisory_lock(hashtext($1))", key).Scan(&acquired); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("lock: failed to acquire advisory lock of release %q: %v", name, err)
+		}
+		if acquired {
+			break
+		}
+
+		s.Log("release %q is locked by another client, retrying in %s", name, sqlLockRetryPeriod)
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			return nil, fmt.Errorf("release %q is locked by another client", name)
+		case <-time.After(sqlLockRetryPeriod):
+		}
+	}
+
+	return func() error {
+		defer conn.Close()
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock(hashtext($1))", key); err != nil {
+			return fmt.Errorf("unlock: failed to release advisory lock of release %q: %v", name, err)
+		}
+		return nil
+	}, nil
+}
+
+// LastHeartbeat returns the latest of the createdAt and modifiedAt columns of
+// the release named by key.
+func (s *SQL) LastHeartbeat(key string) (time.Time, error) {
+	var record SQLReleaseWrapper
+
+	query, args, err := s.statementBuilder.
+		Select(sqlReleaseTableCreatedAtColumn, sqlReleaseTableModifiedAtColumn).
+		From(sqlReleaseTableName).
+		Where(sq.Eq{sqlReleaseTableKeyColumn: key}).
+		Where(sq.Eq{sqlReleaseTableNamespaceColumn: s.namespace}).
+		ToSql()
+	if err != nil {
+		s.Log("failed to build query: %v", err)
+		return time.Time{}, err
+	}
+
+	if err := s.db.Get(&record, query, args...); err != nil {
+		s.Log("got SQL error when getting heartbeat of release %s: %v", key, err)
+		return time.Time{}, ErrReleaseNotFound
+	}
+
+	last := record.CreatedAt
+	if record.ModifiedAt > last {
+		last = record.ModifiedAt
+	}
+	return time.Unix(int64(last), 0), nil
+}
+
 // Delete deletes a release or returns ErrReleaseNotFound.
 func (s *SQL) Delete(key string) (*rspb.Release, error) {
 	transaction, err := s.db.Beginx()
@@ -14,6 +14,7 @@ limitations under the License.
 package driver
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"regexp"
@@ -290,6 +291,130 @@ func TestSqlUpdate(t *testing.T) {
 	}
 }
 
+func TestSqlHeartbeat(t *testing.T) {
+	key := testKey("smug-pigeon", 1)
+	namespace := "default"
+
+	sqlDriver, mock := newTestFixtureSQL(t)
+
+	query := fmt.Sprintf(
+		"UPDATE %s SET %s = $1 WHERE %s = $2 AND %s = $3",
+		sqlReleaseTableName,
+		sqlReleaseTableModifiedAtColumn,
+		sqlReleaseTableKeyColumn,
+		sqlReleaseTableNamespaceColumn,
+	)
+
+	mock.
+		ExpectExec(regexp.QuoteMeta(query)).
+		WithArgs(int(time.Now().Unix()), key, namespace).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.
+		ExpectExec(regexp.QuoteMeta(query)).
+		WithArgs(int(time.Now().Unix()), key, namespace).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := sqlDriver.Heartbeat(key); err != nil {
+		t.Fatalf("failed to record heartbeat of release with key %s: %v", key, err)
+	}
+	if err := sqlDriver.Heartbeat(key); err != ErrReleaseNotFound {
+		t.Errorf("Expected %s, got %v", ErrReleaseNotFound, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("sql expectations weren't met: %v", err)
+	}
+}
+
+func TestSqlLastHeartbeat(t *testing.T) {
+	key := testKey("smug-pigeon", 1)
+	namespace := "default"
+
+	sqlDriver, mock := newTestFixtureSQL(t)
+
+	query := fmt.Sprintf(
+		"SELECT %s, %s FROM %s WHERE %s = $1 AND %s = $2",
+		sqlReleaseTableCreatedAtColumn,
+		sqlReleaseTableModifiedAtColumn,
+		sqlReleaseTableName,
+		sqlReleaseTableKeyColumn,
+		sqlReleaseTableNamespaceColumn,
+	)
+
+	mock.
+		ExpectQuery(regexp.QuoteMeta(query)).
+		WithArgs(key, namespace).
+		WillReturnRows(
+			mock.NewRows([]string{
+				sqlReleaseTableCreatedAtColumn,
+				sqlReleaseTableModifiedAtColumn,
+			}).AddRow(100, 200),
+		).RowsWillBeClosed()
+
+	last, err := sqlDriver.LastHeartbeat(key)
+	if err != nil {
+		t.Fatalf("failed to get last heartbeat of release with key %s: %v", key, err)
+	}
+	if !last.Equal(time.Unix(200, 0)) {
+		t.Errorf("Expected last heartbeat %s, got %s", time.Unix(200, 0), last)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("sql expectations weren't met: %v", err)
+	}
+}
+
+func TestSqlLock(t *testing.T) {
+	defer func(d time.Duration) { sqlLockRetryPeriod = d }(sqlLockRetryPeriod)
+	sqlLockRetryPeriod = time.Millisecond
+
+	sqlDriver, mock := newTestFixtureSQL(t)
+
+	lockQuery := regexp.QuoteMeta("SELECT pg_try_advisory_lock(hashtext($1))")
+	mock.ExpectQuery(lockQuery).
+		WithArgs("default/smug-pigeon").
+		WillReturnRows(mock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+	mock.ExpectQuery(lockQuery).
+		WithArgs("default/smug-pigeon").
+		WillReturnRows(mock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(true))
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock(hashtext($1))")).
+		WithArgs("default/smug-pigeon").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	unlock, err := sqlDriver.Lock(context.Background(), "smug-pigeon")
+	if err != nil {
+		t.Fatalf("failed to lock release: %v", err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatalf("failed to unlock release: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("sql expectations weren't met: %v", err)
+	}
+}
+
+func TestSqlLockTimeout(t *testing.T) {
+	defer func(d time.Duration) { sqlLockRetryPeriod = d }(sqlLockRetryPeriod)
+	sqlLockRetryPeriod = time.Millisecond
+
+	sqlDriver, mock := newTestFixtureSQL(t)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT pg_try_advisory_lock(hashtext($1))")).
+		WithArgs("default/smug-pigeon").
+		WillReturnRows(mock.NewRows([]string{"pg_try_advisory_lock"}).AddRow(false))
+
+	// A done context still makes one attempt at acquiring the lock.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := sqlDriver.Lock(ctx, "smug-pigeon"); err == nil {
+		t.Fatal("expected an error locking a release locked by another client")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("sql expectations weren't met: %v", err)
+	}
+}
+
 func TestSqlQuery(t *testing.T) {
 	// Reflect actual use cases in ../storage.go
 	labelSetDeployed := map[string]string{
@@ -23,6 +23,9 @@ import (
 	"encoding/json"
 	"io/ioutil"
 
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+
 	rspb "helm.sh/helm/v3/pkg/release"
 )
 
@@ -30,24 +33,71 @@ var b64 = base64.StdEncoding
 
 var magicGzip = []byte{0x1f, 0x8b, 0x08}
 
+var magicZstd = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// Compression is the codec that compresses the releases stored by a driver.
+//
+// The codec of a stored release is recognized from the magic number its
+// format starts with, so releases stored with any codec can be read back
+// whatever the codec of the driver.
+type Compression string
+
+const (
+	// CompressionGzip compresses releases with gzip. It is the default.
+	CompressionGzip Compression = "gzip"
+	// CompressionZstd compresses releases with Zstandard, which compresses
+	// better than gzip. Releases stored with it cannot be read by versions of
+	// Helm that do not support it.
+	CompressionZstd Compression = "zstd"
+)
+
+// ParseCompression returns the Compression named by s. An empty string
+// selects the default, CompressionGzip.
+func ParseCompression(s string) (Compression, error) {
+	switch c := Compression(s); c {
+	case "":
+		return CompressionGzip, nil
+	case CompressionGzip, CompressionZstd:
+		return c, nil
+	}
+	return "", errors.Errorf("unknown compression %q: supported values are %s and %s", s, CompressionGzip, CompressionZstd)
+}
+
 // encodeRelease encodes a release returning a base64 encoded
 // gzipped string representation, or error.
 func encodeRelease(rls *rspb.Release) (string, error) {
+	return encodeReleaseWith(rls, CompressionGzip)
+}
+
+// encodeReleaseWith encodes a release returning a base64 encoded string
+// representation compressed with the given codec, or error.
+func encodeReleaseWith(rls *rspb.Release, compression Compression) (string, error) {
 	b, err := json.Marshal(rls)
 	if err != nil {
 		return "", err
 	}
-	var buf bytes.Buffer
-	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
-	if err != nil {
-		return "", err
-	}
-	if _, err = w.Write(b); err != nil {
-		return "", err
-	}
-	w.Close()
+	switch compression {
+	case CompressionZstd:
+		w, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+		if err != nil {
+			return "", err
+		}
+		defer w.Close()
+		return b64.EncodeToString(w.EncodeAll(b, nil)), nil
+	case CompressionGzip, "":
+		var buf bytes.Buffer
+		w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if err != nil {
+			return "", err
+		}
+		if _, err = w.Write(b); err != nil {
+			return "", err
+		}
+		w.Close()
 
-	return b64.EncodeToString(buf.Bytes()), nil
+		return b64.EncodeToString(buf.Bytes()), nil
+	}
+	return "", errors.Errorf("unknown compression %q", compression)
 }
 
 // decodeRelease decodes the bytes of data into a release
@@ -63,7 +113,16 @@ func decodeRelease(data string) (*rspb.Release, error) {
 	// For backwards compatibility with releases that were stored before
 	// compression was introduced we skip decompression if the
 	// gzip magic header is not found
-	if bytes.Equal(b[0:3], magicGzip) {
+	if bytes.HasPrefix(b, magicZstd) {
+		r, err := zstd.NewReader(nil)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		if b, err = r.DecodeAll(b, nil); err != nil {
+			return nil, err
+		}
+	} else if bytes.HasPrefix(b, magicGzip) {
 		r, err := gzip.NewReader(bytes.NewReader(b))
 		if err != nil {
 			return nil, err
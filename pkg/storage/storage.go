@@ -17,8 +17,10 @@ limitations under the License.
 package storage // import "helm.sh/helm/v3/pkg/storage"
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -43,9 +45,22 @@ type Storage struct {
 	// ignored (meaning no limits are imposed).
 	MaxHistory int
 
+	// Locker, if set, serializes operations on a release across Helm
+	// clients. See Lock.
+	Locker Locker
+
 	Log func(string, ...interface{})
 }
 
+// Locker is the interface that wraps the Lock method.
+//
+// Lock acquires the operation lock of the named release. While the lock is
+// held by someone else, Lock retries until ctx is done, but it always makes
+// at least one attempt. The returned function releases the lock.
+type Locker interface {
+	Lock(ctx context.Context, name string) (unlock func() error, err error)
+}
+
 // Get retrieves the release from storage. An error is returned
 // if the storage driver failed to fetch the release, or the
 // release identified by the key, version pair does not exist.
@@ -242,6 +257,51 @@ func (s *Storage) Last(name string) (*rspb.Release, error) {
 	return h[0], nil
 }
 
+// Heartbeat records that the operation working on the release identified by
+// the name, version pair is still alive. It does nothing if the storage driver
+// does not implement driver.Heartbeater.
+func (s *Storage) Heartbeat(name string, version int) error {
+	hb, ok := s.Driver.(driver.Heartbeater)
+	if !ok {
+		return nil
+	}
+	return hb.Heartbeat(makeKey(name, version))
+}
+
+// LastHeartbeat returns when the release identified by the name, version pair
+// was last stored or heartbeated. If the storage driver does not implement
+// driver.Heartbeater, the time the release was last deployed is returned.
+func (s *Storage) LastHeartbeat(name string, version int) (time.Time, error) {
+	if hb, ok := s.Driver.(driver.Heartbeater); ok {
+		return hb.LastHeartbeat(makeKey(name, version))
+	}
+	rls, err := s.Get(name, version)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return rls.Info.LastDeployed.Time, nil
+}
+
+// Lock acquires the operation lock of the named release with the Locker, so
+// that concurrent clients do not both create the next revision of the
+// release. It is a no-op if no Locker is set.
+func (s *Storage) Lock(ctx context.Context, name string) (func(), error) {
+	if s.Locker == nil {
+		return func() {}, nil
+	}
+	s.Log("acquiring lock of release %q", name)
+	unlock, err := s.Locker.Lock(ctx, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to acquire the lock of release %q", name)
+	}
+	return func() {
+		s.Log("releasing lock of release %q", name)
+		if err := unlock(); err != nil {
+			s.Log("failed to release lock of release %q: %s", name, err)
+		}
+	}, nil
+}
+
 // makeKey concatenates the Kubernetes storage object type, a release name and version
 // into a string with format:```<helm_storage_type>.<release_name>.v<release_version>```.
 // The storage type is prepended to keep name uniqueness between different
@@ -17,14 +17,17 @@ limitations under the License.
 package storage // import "helm.sh/helm/v3/pkg/storage"
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 
 	rspb "helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/storage/driver"
+	helmtime "helm.sh/helm/v3/pkg/time"
 )
 
 func TestStorageCreate(t *testing.T) {
@@ -444,6 +447,39 @@ func TestStorageLast(t *testing.T) {
 	}
 }
 
+func TestStorageHeartbeat(t *testing.T) {
+	storage := Init(driver.NewMemory())
+
+	rls := ReleaseTestData{Name: "angry-bird", Version: 1, Status: rspb.StatusPendingUpgrade}.ToRelease()
+	assertErrNil(t.Fatal, storage.Create(rls), "StoreRelease")
+
+	before := time.Now()
+	assertErrNil(t.Fatal, storage.Heartbeat(rls.Name, rls.Version), "Heartbeat")
+
+	last, err := storage.LastHeartbeat(rls.Name, rls.Version)
+	assertErrNil(t.Fatal, err, "LastHeartbeat")
+	if last.Before(before) {
+		t.Errorf("Expected heartbeat after %s, got %s", before, last)
+	}
+}
+
+func TestStorageHeartbeatUnsupported(t *testing.T) {
+	// hide the driver.Heartbeater methods of the memory driver
+	storage := Init(struct{ driver.Driver }{driver.NewMemory()})
+
+	rls := ReleaseTestData{Name: "angry-bird", Version: 1, Status: rspb.StatusPendingUpgrade}.ToRelease()
+	rls.Info.LastDeployed = helmtime.Unix(100, 0)
+	assertErrNil(t.Fatal, storage.Create(rls), "StoreRelease")
+
+	assertErrNil(t.Fatal, storage.Heartbeat(rls.Name, rls.Version), "Heartbeat")
+
+	last, err := storage.LastHeartbeat(rls.Name, rls.Version)
+	assertErrNil(t.Fatal, err, "LastHeartbeat")
+	if !last.Equal(time.Unix(100, 0)) {
+		t.Errorf("Expected the last deployed time %s, got %s", time.Unix(100, 0), last)
+	}
+}
+
 type ReleaseTestData struct {
 	Name      string
 	Version   int
@@ -467,3 +503,43 @@ func assertErrNil(eh func(args ...interface{}), err error, message string) {
 		eh(fmt.Sprintf("%s: %q", message, err))
 	}
 }
+
+type fakeLocker struct {
+	locked map[string]bool
+}
+
+func (l *fakeLocker) Lock(_ context.Context, name string) (func() error, error) {
+	if l.locked[name] {
+		return nil, errors.Errorf("release %q is locked", name)
+	}
+	l.locked[name] = true
+	return func() error {
+		delete(l.locked, name)
+		return nil
+	}, nil
+}
+
+func TestStorageLock(t *testing.T) {
+	storage := Init(driver.NewMemory())
+
+	// without a Locker, locking is a no-op
+	unlock, err := storage.Lock(context.Background(), "angry-bird")
+	assertErrNil(t.Fatal, err, "Lock")
+	unlock()
+
+	locker := &fakeLocker{locked: map[string]bool{}}
+	storage.Locker = locker
+
+	unlock, err = storage.Lock(context.Background(), "angry-bird")
+	assertErrNil(t.Fatal, err, "Lock")
+	if !locker.locked["angry-bird"] {
+		t.Fatal("Expected release to be locked")
+	}
+	if _, err := storage.Lock(context.Background(), "angry-bird"); err == nil {
+		t.Error("Expected an error locking a locked release")
+	}
+	unlock()
+	if locker.locked["angry-bird"] {
+		t.Error("Expected release to be unlocked")
+	}
+}
@@ -17,6 +17,7 @@ package strvals
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"strconv"
@@ -103,6 +104,16 @@ func ParseIntoFile(s string, dest map[string]interface{}, reader RunesValueReade
 	return t.parse()
 }
 
+// ParseJSON parses a set line whose values are JSON documents and merges the
+// result into dest.
+//
+// A set line is of the form name1=jsonval1,name2=jsonval2
+func ParseJSON(s string, dest map[string]interface{}) error {
+	scanner := bytes.NewBufferString(s)
+	t := newJSONParser(scanner, dest)
+	return t.parse()
+}
+
 // RunesValueReader is a function that takes the given value (a slice of runes)
 // and returns the parsed value
 type RunesValueReader func([]rune) (interface{}, error)
@@ -116,6 +127,7 @@ type parser struct {
 	sc     *bytes.Buffer
 	data   map[string]interface{}
 	reader RunesValueReader
+	isjson bool
 }
 
 func newParser(sc *bytes.Buffer, data map[string]interface{}, stringBool bool) *parser {
@@ -129,6 +141,10 @@ func newFileParser(sc *bytes.Buffer, data map[string]interface{}, reader RunesVa
 	return &parser{sc: sc, data: data, reader: reader}
 }
 
+func newJSONParser(sc *bytes.Buffer, data map[string]interface{}) *parser {
+	return &parser{sc: sc, data: data, isjson: true}
+}
+
 func (t *parser) parse() error {
 	for {
 		err := t.key(t.data)
@@ -183,6 +199,13 @@ func (t *parser) key(data map[string]interface{}) (reterr error) {
 			list, err = t.listItem(list, i)
 			set(data, kk, list)
 			return err
+		case last == '=' && t.isjson:
+			v, e := t.jsonVal()
+			if e != nil {
+				return errors.Wrapf(e, "key %q has an invalid JSON value", string(k))
+			}
+			set(data, string(k), v)
+			return nil
 		case last == '=':
 			//End of key. Consume =, Get value.
 			// FIXME: Get value list first
@@ -279,6 +302,12 @@ func (t *parser) listItem(list []interface{}, i int) ([]interface{}, error) {
 		return list, errors.Errorf("unexpected data at end of array index: %q", k)
 	case err != nil:
 		return list, err
+	case last == '=' && t.isjson:
+		v, e := t.jsonVal()
+		if e != nil {
+			return list, errors.Wrap(e, "invalid JSON value")
+		}
+		return setIndex(list, i, v)
 	case last == '=':
 		vl, e := t.valList()
 		switch e {
@@ -349,6 +378,24 @@ func (t *parser) val() ([]rune, error) {
 	return v, err
 }
 
+// jsonVal decodes the JSON document at the start of the remaining input and
+// consumes the comma that separates it from the next key, if any.
+func (t *parser) jsonVal() (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(t.sc.Bytes()))
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	t.sc.Next(int(dec.InputOffset()))
+	switch r, _, e := t.sc.ReadRune(); {
+	case e == io.EOF:
+		return v, nil
+	case r != ',':
+		return nil, errors.Errorf("unexpected data after JSON value: %q", string(r)+t.sc.String())
+	}
+	return v, nil
+}
+
 func (t *parser) valList() ([]interface{}, error) {
 	r, _, e := t.sc.ReadRune()
 	if e != nil {
@@ -567,6 +567,79 @@ func TestParseIntoString(t *testing.T) {
 	}
 }
 
+func TestParseJSON(t *testing.T) {
+	tests := []struct {
+		input  string
+		got    map[string]interface{}
+		expect map[string]interface{}
+		err    bool
+	}{
+		{
+			input: `outer.inner1="1",outer.inner3={"a":[1,"b",null],"c":true}`,
+			got: map[string]interface{}{
+				"outer": map[string]interface{}{
+					"inner1": "overwrite",
+					"inner2": "value2",
+				},
+			},
+			expect: map[string]interface{}{
+				"outer": map[string]interface{}{
+					"inner1": "1",
+					"inner2": "value2",
+					"inner3": map[string]interface{}{
+						"a": []interface{}{1, "b", nil},
+						"c": true,
+					},
+				},
+			},
+		},
+		{
+			input: `list[1]={"name":"a,b"},str="x=y"`,
+			got:   map[string]interface{}{},
+			expect: map[string]interface{}{
+				"list": []interface{}{nil, map[string]interface{}{"name": "a,b"}},
+				"str":  "x=y",
+			},
+		},
+		{
+			input: `name=unquoted`,
+			got:   map[string]interface{}{},
+			err:   true,
+		},
+		{
+			input: `name={"a":1}b`,
+			got:   map[string]interface{}{},
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		err := ParseJSON(tt.input, tt.got)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: Expected error. Got nil", tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %s", tt.input, err)
+		}
+
+		y1, err := yaml.Marshal(tt.expect)
+		if err != nil {
+			t.Fatal(err)
+		}
+		y2, err := yaml.Marshal(tt.got)
+		if err != nil {
+			t.Fatalf("Error serializing parsed value: %s", err)
+		}
+
+		if string(y1) != string(y2) {
+			t.Errorf("%s: Expected:\n%s\nGot:\n%s", tt.input, y1, y2)
+		}
+	}
+}
+
 func TestParseFile(t *testing.T) {
 	input := "name1=path1"
 	expect := map[string]interface{}{